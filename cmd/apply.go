@@ -0,0 +1,113 @@
+// cmd 包包含执行计划文件的 apply 命令。
+package cmd
+
+import (
+	"click-house-sync/internal/clickhouse"
+	kadmin "click-house-sync/internal/kafka"
+	"click-house-sync/internal/plan"
+	"database/sql"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// applyCmd 按计划文件中的顺序执行 create/alter 步骤；noop 与 drift 步骤只输出不执行。
+var applyCmd = &cobra.Command{
+	Use:   "apply <plan-file>",
+	Short: "执行 plan 生成的计划文件",
+	Long:  "按表依次执行计划文件中的 create/alter 步骤（建库、创建 Topic、Kafka 引擎表、错误流、目标表与物化视图），使用计划中记录的精确 SQL。某表步骤失败时停止执行；--continue-on-error 时跳过该表剩余步骤继续下一张表。",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		continueOnError, _ := cmd.Flags().GetBool("continue-on-error")
+		p, err := plan.Load(args[0])
+		if err != nil {
+			return err
+		}
+		db, err := connectClickHouse(nil, chDatabase)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		var results []map[string]any
+		applied, failed := 0, 0
+		var firstErr error
+		for _, tp := range p.Tables {
+			// 表级 clickhouse 配置覆盖连接选项时，为该表单独建立连接
+			tdb := db
+			tconf, err := lookupTableConfig(tp.Table)
+			if err == nil && tconf != nil && tconf.ClickHouse != nil {
+				c, err := connectClickHouse(tconf, chDatabase)
+				if err != nil {
+					return err
+				}
+				tdb = c
+			}
+			stopped := false
+			for _, s := range tp.Steps {
+				r := map[string]any{"table": tp.Table, "kind": s.Kind, "object": s.Object, "action": s.Action}
+				switch {
+				case !s.Executable():
+					r["status"] = "skipped"
+					if s.Reason != "" {
+						r["reason"] = s.Reason
+					}
+				case stopped:
+					r["status"] = "not_run"
+				default:
+					if err := applyStep(tdb, s); err != nil {
+						r["status"] = "failed"
+						r["error"] = err.Error()
+						failed++
+						stopped = true
+						if firstErr == nil {
+							firstErr = fmt.Errorf("%s %s: %v", tp.Table, s.Object, err)
+						}
+					} else {
+						r["status"] = "applied"
+						applied++
+					}
+				}
+				results = append(results, r)
+			}
+			if tdb != db {
+				tdb.Close()
+			}
+			if stopped && !continueOnError {
+				break
+			}
+		}
+		printJSON(map[string]any{
+			"command": "apply",
+			"plan":    args[0],
+			"applied": applied,
+			"failed":  failed,
+			"results": results,
+		})
+		return firstErr
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(applyCmd)
+	applyCmd.Flags().Bool("continue-on-error", false, "某表步骤失败时跳过该表剩余步骤，继续执行下一张表")
+}
+
+// applyStep 执行单个步骤：Topic 步骤创建主题（alter 时调整已有主题的配置），其余步骤按顺序执行 SQL。
+func applyStep(db *sql.DB, s plan.Step) error {
+	if s.Topic != nil {
+		if s.Action == plan.ActionAlter {
+			return kadmin.AlterTopicConfigs(s.Topic.Brokers, s.Topic.Name, s.Topic.Configs)
+		}
+		return kadmin.CreateTopicWithConfigs(s.Topic.Brokers, s.Topic.Name, s.Topic.Partitions, s.Topic.ReplicationFactor, s.Topic.Configs)
+	}
+	for i, q := range s.SQL {
+		var fallbacks []string
+		if i == 0 {
+			fallbacks = s.FallbackSQL
+		}
+		if err := clickhouse.ExecWithFallback(db, q, fallbacks); err != nil {
+			return err
+		}
+	}
+	return nil
+}
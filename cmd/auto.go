@@ -3,7 +3,9 @@ package cmd
 
 import (
 	"click-house-sync/internal/clickhouse"
+	"click-house-sync/internal/config"
 	kadmin "click-house-sync/internal/kafka"
+	"click-house-sync/internal/naming"
 	"fmt"
 	"strings"
 
@@ -21,33 +23,37 @@ var autoCmd = &cobra.Command{
 		if table == "" {
 			return fmt.Errorf("缺少 --table")
 		}
-		// 连接 ClickHouse
-		db, err := clickhouse.Connect(chHost, chPort, chUser, chPassword, chDatabase, chSecure)
+		// 查找表级配置
+		tconf, err := lookupTableConfig(table)
 		if err != nil {
 			return err
 		}
-		defer db.Close()
-		// 查找表级配置
-		tconf, err := lookupTableConfig(table)
+		if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+			t := config.Table{Name: table}
+			if tconf != nil {
+				t = *tconf
+			}
+			return printDryRun(cmd, "auto", []config.Table{t}, planOptions{withTarget: true})
+		}
+		// 连接 ClickHouse（表级 clickhouse 配置可覆盖连接选项）
+		db, err := connectClickHouse(tconf, chDatabase)
 		if err != nil {
 			return err
 		}
+		defer db.Close()
 		// 源库与默认 topic
 		srcDB := chDatabase
 		if !cmd.Root().PersistentFlags().Changed("ch-database") && tconf != nil && tconf.CurrentDatabase != "" {
 			srcDB = tconf.CurrentDatabase
 		}
 		if kafkaTopic == "" {
-			kafkaTopic = srcDB + "_" + table
+			kafkaTopic = naming.Topic(srcDB, table)
 		}
-		// 目标库（Kafka 表所在库）
-		if targetDatabase == "" {
-			if tconf != nil && tconf.TargetDatabase != "" {
-				targetDatabase = tconf.TargetDatabase
-			} else {
-				targetDatabase = chDatabase
-			}
+		if err := naming.ValidateTopic(kafkaTopic); err != nil {
+			return err
 		}
+		// 目标库（Kafka 表所在库）
+		targetDatabase = targetDatabaseFor(cmd, tconf)
 		tgtTable := targetTable
 		if strings.TrimSpace(tgtTable) == "" {
 			if tconf != nil && strings.TrimSpace(tconf.TargetTable) != "" {
@@ -74,7 +80,11 @@ var autoCmd = &cobra.Command{
 		} else {
 			brokers = brokersList()
 		}
-		if err := kadmin.CreateTopic(brokers, kafkaTopic, p, replicationFactor); err != nil {
+		topicConfigs, err := topicConfigFor(tconf)
+		if err != nil {
+			return err
+		}
+		if err := kadmin.CreateTopicWithConfigs(brokers, kafkaTopic, p, replicationFactor, topicConfigs); err != nil {
 			return err
 		}
 		// 创建 Kafka 表与推送型 MV
@@ -84,7 +94,7 @@ var autoCmd = &cobra.Command{
 		} else if tconf != nil && strings.TrimSpace(tconf.GroupName) != "" {
 			group = tconf.GroupName
 		} else {
-			group = groupName + "-" + table
+			group = naming.Group(srcDB, table, groupName)
 		}
 		kafkaDB := targetDatabase
 		if err := clickhouse.CreateDatabaseIfNotExists(db, kafkaDB); err != nil {
@@ -124,13 +134,21 @@ var autoCmd = &cobra.Command{
 				extras["version"] = "UInt64"
 			}
 		}
-		if err := clickhouse.CreateKafkaTableFromSource(db, srcDB, table, kafkaDB, brokers, kafkaTopic, group, "JSONEachRow", 1, kafkaMaxBlockSize, kafkaAutoOffsetReset, extras); err != nil {
+		mapper, err := typeMapperFor(tconf)
+		if err != nil {
+			return err
+		}
+		if err := clickhouse.CreateKafkaTableFromSource(db, srcDB, table, kafkaDB, brokers, kafkaTopic, group, kafkaSettingsFor(tconf), extras, mapper); err != nil {
+			return err
+		}
+		layout, err := targetLayoutFor(tconf)
+		if err != nil {
 			return err
 		}
-		if err := clickhouse.CreateTargetTableLikeSource(db, srcDB, table, targetDatabase, tgtTable, "tuple()", ""); err != nil {
+		if err := createTargetTable(db, layout, srcDB, table, targetDatabase, tgtTable, "tuple()", "", mapper); err != nil {
 			return err
 		}
-		if err := clickhouse.CreateMaterializedView(db, kafkaDB, table, targetDatabase, tgtTable); err != nil {
+		if err := clickhouse.CreateMaterializedView(db, kafkaDB, srcDB, table, targetDatabase, tgtTable); err != nil {
 			return err
 		}
 		sourceCols, err := clickhouse.GetColumns(db, srcDB, table)
@@ -191,9 +209,10 @@ var autoCmd = &cobra.Command{
 			"partitions":        p,
 			"replication_factor": replicationFactor,
 			"group":             group,
-			"kafka_table":       strings.Join([]string{kafkaDB, "kafka_" + table + "_sink"}, "."),
-			"materialized_view": strings.Join([]string{targetDatabase, "mv_from_kafka_" + table}, "."),
+			"kafka_table":       strings.Join([]string{kafkaDB, naming.Sink(srcDB, table)}, "."),
+			"materialized_view": strings.Join([]string{targetDatabase, naming.MVFromKafka(srcDB, table)}, "."),
 			"target_table":      strings.Join([]string{targetDatabase, tgtTable}, "."),
+			"target_layout":     layout,
 			"type_diffs":        typeDiffs,
 			"source":            strings.Join([]string{srcDB, table}, "."),
 		})
@@ -205,4 +224,5 @@ func init() {
 	rootCmd.AddCommand(autoCmd)
 	// 源表名：用于生成 Kafka 表字段，并作为推送 MV 的数据来源
 	autoCmd.Flags().String("table", "", "源表名（必填）")
+	autoCmd.Flags().Bool("dry-run", false, "仅输出将要创建的对象与 SQL（同 plan），不创建对象也不导出数据")
 }
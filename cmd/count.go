@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"click-house-sync/internal/clickhouse"
+	"click-house-sync/internal/naming"
 	"fmt"
 	"strings"
 
@@ -16,7 +17,7 @@ var countCmd = &cobra.Command{
 		withMV, _ := cmd.Flags().GetBool("with-mv")
 		diff, _ := cmd.Flags().GetBool("diff")
 		qualitySQL, _ := cmd.Flags().GetBool("quality-sql")
-		conn, err := clickhouse.Connect(chHost, chPort, chUser, chPassword, chDatabase, chSecure)
+		conn, err := connectClickHouse(nil, chDatabase)
 		if err != nil {
 			return err
 		}
@@ -34,15 +35,10 @@ var countCmd = &cobra.Command{
 				return err
 			}
 			out := map[string]any{"command": "count", "database": srcDB, "table": table, "rows": n}
-			tgtDB := chDatabase
 			tgtTable := table
-			if cmd.Root().PersistentFlags().Changed("target-database") && targetDatabase != "" {
-				tgtDB = targetDatabase
-			} else {
-				tconf, _ := lookupTableConfig(table)
-				if tconf != nil && tconf.TargetDatabase != "" {
-					tgtDB = tconf.TargetDatabase
-				}
+			tconf, _ := lookupTableConfig(table)
+			tgtDB := targetDatabaseFor(cmd, tconf)
+			if !cmd.Root().PersistentFlags().Changed("target-database") {
 				if tconf != nil && strings.TrimSpace(tconf.TargetTable) != "" && strings.TrimSpace(targetTable) == "" {
 					tgtTable = tconf.TargetTable
 				}
@@ -50,7 +46,7 @@ var countCmd = &cobra.Command{
 			if strings.TrimSpace(targetTable) != "" {
 				tgtTable = targetTable
 			}
-			mvName := "mv_from_kafka_" + table
+			mvName := naming.MVFromKafka(srcDB, table)
 			out["materialized_view"] = tgtDB + "." + mvName
 			out["target_table"] = tgtDB + "." + tgtTable
 			var mvRows uint64
@@ -100,15 +96,12 @@ var countCmd = &cobra.Command{
 			printJSON(map[string]any{"command": "count_all", "database": chDatabase, "tables": items, "total": total})
 			return nil
 		}
-		tgtDB := chDatabase
-		if cmd.Root().PersistentFlags().Changed("target-database") && targetDatabase != "" {
-			tgtDB = targetDatabase
-		}
+		tgtDB := targetDatabaseFor(cmd, nil)
 		var total uint64
 		var items []map[string]any
 		for _, r := range rows {
 			total += r.Rows
-			mvName := "mv_from_kafka_" + r.Table
+			mvName := naming.MVFromKafka(chDatabase, r.Table)
 			var mvRows uint64
 			var mvErr error
 			if eng, e1 := clickhouse.GetTableEngine(conn, tgtDB, mvName); e1 == nil && eng == "MaterializedView" {
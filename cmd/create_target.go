@@ -12,7 +12,7 @@ import (
 var createTargetCmd = &cobra.Command{
 	Use:   "create-target",
 	Short: "创建目标MergeTree表",
-	Long:  "在目标数据库创建与源表列结构一致的 MergeTree 表。可指定 ORDER BY 与 PARTITION BY 表达式，用于优化写入与查询性能。",
+	Long:  "在目标数据库创建与源表列结构一致的 MergeTree 表。可指定 ORDER BY 与 PARTITION BY 表达式，用于优化写入与查询性能；--target-layout mirror 时按源表复刻引擎、键、TTL、编解码、默认值、索引、投影与设置。",
 	RunE: func(cmd *cobra.Command, args []string) error {
 		// 读取必需参数：源表名
 		table, _ := cmd.Flags().GetString("table")
@@ -23,7 +23,7 @@ var createTargetCmd = &cobra.Command{
 		orderBy, _ := cmd.Flags().GetString("order-by")
 		partitionBy, _ := cmd.Flags().GetString("partition-by")
 		// 建立到 ClickHouse 的连接（基于全局连接参数）
-		db, err := clickhouse.Connect(chHost, chPort, chUser, chPassword, chDatabase, chSecure)
+		db, err := connectClickHouse(nil, chDatabase)
 		if err != nil {
 			return err
 		}
@@ -38,13 +38,8 @@ var createTargetCmd = &cobra.Command{
 		if !cmd.Root().PersistentFlags().Changed("ch-database") && tconf != nil && tconf.CurrentDatabase != "" {
 			srcDB = tconf.CurrentDatabase
 		}
-		// 决定目标库：优先使用 --target-database，其次 tables.yaml 的 target_database，否则回退到全局 ch-database
-		tgtDB := chDatabase
-		if cmd.Root().PersistentFlags().Changed("target-database") && targetDatabase != "" {
-			tgtDB = targetDatabase
-		} else if tconf != nil && tconf.TargetDatabase != "" {
-			tgtDB = tconf.TargetDatabase
-		}
+		// 决定目标库：优先使用 --target-database，其次 tables.yaml 的 target_database、配置文件 sync.target_database，否则回退到全局 ch-database
+		tgtDB := targetDatabaseFor(cmd, tconf)
 		// 决定目标表名：优先使用 --target-table 或 tables.yaml 的 target_table，否则默认源表名后缀 _replica
 		tgtTable := targetTable
 		if tgtTable == "" {
@@ -55,7 +50,33 @@ var createTargetCmd = &cobra.Command{
 			}
 		}
 		// 基于源表结构创建目标 MergeTree 表，并应用指定的 ORDER/PARTITION 表达式
-		if err := clickhouse.CreateTargetTableLikeSource(db, srcDB, table, tgtDB, tgtTable, orderBy, partitionBy); err != nil {
+		mapper, err := typeMapperFor(tconf)
+		if err != nil {
+			return err
+		}
+		layout, err := targetLayoutFor(tconf)
+		if err != nil {
+			return err
+		}
+		if layout == clickhouse.TargetLayoutMirror {
+			// mirror 布局沿用源表的引擎与键，不接受自定义排序/分区
+			if orderBy != "" || partitionBy != "" {
+				return fmt.Errorf("--target-layout mirror 沿用源表的排序与分区键，不能同时指定 --order-by/--partition-by")
+			}
+			ddl, err := clickhouse.CreateTargetTableMirror(db, srcDB, table, tgtDB, tgtTable, mapper)
+			if err != nil {
+				return err
+			}
+			printJSON(map[string]any{
+				"command": "create-target",
+				"source":  fmt.Sprintf("%s.%s", srcDB, table),
+				"target":  fmt.Sprintf("%s.%s", tgtDB, tgtTable),
+				"layout":  layout,
+				"ddl":     ddl,
+			})
+			return nil
+		}
+		if err := clickhouse.CreateTargetTableLikeSource(db, srcDB, table, tgtDB, tgtTable, orderBy, partitionBy, mapper); err != nil {
 			return err
 		}
 		// 输出执行结果，便于在日志中追踪
@@ -63,6 +84,7 @@ var createTargetCmd = &cobra.Command{
 			"command":      "create-target",
 			"source":       fmt.Sprintf("%s.%s", srcDB, table),
 			"target":       fmt.Sprintf("%s.%s", tgtDB, tgtTable),
+			"layout":       layout,
 			"engine":       "MergeTree",
 			"order_by":     orderBy,
 			"partition_by": partitionBy,
@@ -0,0 +1,300 @@
+// cmd 包包含对运行中同步链路做结构演进的 evolve 命令。
+package cmd
+
+import (
+	"click-house-sync/internal/clickhouse"
+	"click-house-sync/internal/config"
+	"click-house-sync/internal/naming"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var evolveCmd = &cobra.Command{
+	Use:   "evolve",
+	Short: "源表结构变更后同步演进 sink、物化视图与目标表",
+	Long:  "对比源表与 kafka_<table>_sink、目标表的列：目标表执行 ALTER TABLE ADD/MODIFY COLUMN，其中有损收窄、不兼容或去掉 Nullable 的 MODIFY 默认拒绝（该表状态为 blocked，不执行任何步骤），需 --allow-lossy 放开；sink 缺列时按“先停 MV、再重建 sink、最后重建 MV”的顺序重建。sink 沿用原 broker 列表、topic、消费者组与引擎设置，已提交位点保留在 Kafka 中，重建后从原位点继续消费；任一步骤失败时按原定义恢复已删除的 sink 与物化视图并返回错误。",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		table, _ := cmd.Flags().GetString("table")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		allowLossy, _ := cmd.Flags().GetBool("allow-lossy")
+		var names []string
+		if strings.TrimSpace(table) != "" {
+			names = []string{strings.TrimSpace(table)}
+		} else {
+			path := tablesFile
+			if strings.TrimSpace(path) == "" {
+				path = "tables.yaml"
+			}
+			tlist, err := config.LoadTablesFile(path)
+			if err != nil {
+				return err
+			}
+			for _, t := range tlist {
+				names = append(names, t.Name)
+			}
+			if len(names) == 0 {
+				return fmt.Errorf("缺少 --table 且 tables_file 无表项")
+			}
+		}
+		db, err := connectClickHouse(nil, chDatabase)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		var results []map[string]any
+		evolved, blocked := 0, 0
+		for _, n := range names {
+			s, err := resolveTableStream(cmd, n)
+			if err != nil {
+				return err
+			}
+			kafkaDB, err := pipelineKafkaDatabase(cmd, n)
+			if err != nil {
+				return err
+			}
+			res, err := evolveTable(db, n, s, kafkaDB, dryRun, allowLossy)
+			if err != nil {
+				return err
+			}
+			switch res["status"] {
+			case "evolved":
+				evolved++
+			case "blocked":
+				blocked++
+			}
+			results = append(results, res)
+		}
+		printJSON(map[string]any{"command": "evolve", "dry_run": dryRun, "tables": results, "total": len(results), "evolved": evolved, "blocked": blocked})
+		if blocked > 0 {
+			return fmt.Errorf("%d 张表的目标列变更有损，未执行（确认后使用 --allow-lossy）", blocked)
+		}
+		return nil
+	},
+}
+
+// evolveTable 对单表执行结构演进；dryRun 时只返回计划步骤。目标列存在有损 MODIFY 且未 allowLossy 时返回 blocked，不执行任何步骤。
+// 执行中某步失败时按原定义恢复已删除的 sink 与视图，并返回错误；目标表已执行的 ALTER 不回退。
+func evolveTable(db *sql.DB, table string, s *tableStream, kafkaDB string, dryRun bool, allowLossy bool) (map[string]any, error) {
+	tgtDB := s.KafkaDatabase
+	sinkName := naming.Sink(s.SourceDatabase, table)
+	mvName := naming.MVFromKafka(s.SourceDatabase, table)
+	pushName := naming.MVToKafka(s.SourceDatabase, table)
+	out := map[string]any{
+		"table":      table,
+		"source":     s.SourceDatabase + "." + table,
+		"kafka_sink": kafkaDB + "." + sinkName,
+	}
+	srcCols, err := clickhouse.GetColumns(db, s.SourceDatabase, table)
+	if err != nil {
+		return nil, err
+	}
+	sinkExists, err := clickhouse.TableExists(db, kafkaDB, sinkName)
+	if err != nil {
+		return nil, err
+	}
+	if !sinkExists {
+		return nil, fmt.Errorf("sink 不存在: %s.%s（请先执行 prepare 或 sync）", kafkaDB, sinkName)
+	}
+	sinkCols, err := clickhouse.GetColumns(db, kafkaDB, sinkName)
+	if err != nil {
+		return nil, err
+	}
+	mvExists, err := clickhouse.TableExists(db, tgtDB, mvName)
+	if err != nil {
+		return nil, err
+	}
+	pushExists, err := clickhouse.TableExists(db, kafkaDB, pushName)
+	if err != nil {
+		return nil, err
+	}
+	mapper, err := typeMapperFor(s.Config)
+	if err != nil {
+		return nil, err
+	}
+	targetChanges := []clickhouse.ColumnChange{}
+	if mvExists {
+		hasTarget, err := clickhouse.MaterializedViewHasTarget(db, tgtDB, mvName)
+		if err != nil {
+			return nil, err
+		}
+		if !hasTarget {
+			out["status"] = "unsupported"
+			out["reason"] = "查询型物化视图自带存储，无法在不丢数据的前提下重建，请手动迁移"
+			return out, nil
+		}
+		targetCols, err := clickhouse.GetColumns(db, tgtDB, s.TargetTable)
+		if err != nil {
+			return nil, err
+		}
+		targetChanges = clickhouse.DiffTargetColumns(srcCols, targetCols, mapper)
+		out["target_table"] = tgtDB + "." + s.TargetTable
+	}
+	sinkChanges := clickhouse.DiffSinkColumns(srcCols, sinkCols, mapper)
+	out["sink_changes"] = sinkChanges
+	out["target_changes"] = targetChanges
+	if len(sinkChanges) == 0 && len(targetChanges) == 0 {
+		out["status"] = "up_to_date"
+		return out, nil
+	}
+	var lossy []string
+	for _, c := range targetChanges {
+		if c.Lossy() {
+			lossy = append(lossy, fmt.Sprintf("%s: %s -> %s (%s)", c.Column, c.FromType, c.ToType, c.Class))
+		}
+	}
+	if len(lossy) > 0 && !allowLossy {
+		out["status"] = "blocked"
+		out["reason"] = "目标表列变更有损，已有数据可能截断或转换失败: " + strings.Join(lossy, ", ")
+		return out, nil
+	}
+	// 沿用 sink 当前的 topic 与消费者组，保证重建后从已提交位点继续消费
+	topic, group, err := clickhouse.KafkaTableTopicGroup(db, kafkaDB, sinkName)
+	if err != nil {
+		return nil, err
+	}
+	if topic == "" {
+		topic = s.Topic
+	}
+	if group == "" {
+		group = s.Group
+	}
+	// 重建的 sink 沿用原表的 broker 列表与引擎设置；ClickHouse 不回显 SASL 密码，密码取当前配置
+	brokers, settings, err := clickhouse.KafkaTableSettings(db, kafkaDB, sinkName)
+	if err != nil {
+		return nil, err
+	}
+	settings.SASLPassword = kafkaSettingsFor(s.Config).SASLPassword
+	if len(brokers) == 0 && settings.NamedCollection == "" {
+		brokers = s.Brokers
+	}
+	recreateSink := len(sinkChanges) > 0
+	errView := clickhouse.KafkaErrorsViewName(s.SourceDatabase, table)
+	errViewExists := false
+	if recreateSink {
+		if errViewExists, err = clickhouse.TableExists(db, kafkaDB, errView); err != nil {
+			return nil, err
+		}
+	}
+	type step struct {
+		desc string
+		run  func() error
+	}
+	var steps []step
+	if mvExists {
+		steps = append(steps, step{"drop_mv " + tgtDB + "." + mvName, func() error {
+			return clickhouse.DropMaterializedViewIfExists(db, tgtDB, mvName)
+		}})
+	}
+	if recreateSink {
+		if errViewExists {
+			steps = append(steps, step{"drop_mv " + kafkaDB + "." + errView, func() error {
+				return clickhouse.DropMaterializedViewIfExists(db, kafkaDB, errView)
+			}})
+		}
+		if pushExists {
+			steps = append(steps, step{"drop_mv " + kafkaDB + "." + pushName, func() error {
+				return clickhouse.DropMaterializedViewIfExists(db, kafkaDB, pushName)
+			}})
+		}
+		steps = append(steps, step{"drop_sink " + kafkaDB + "." + sinkName, func() error {
+			return clickhouse.DropTableIfExists(db, kafkaDB, sinkName)
+		}})
+	}
+	for _, c := range targetChanges {
+		c := c
+		steps = append(steps, step{"alter_target " + c.SQL(), func() error {
+			return clickhouse.AlterColumns(db, tgtDB, s.TargetTable, []clickhouse.ColumnChange{c})
+		}})
+	}
+	if recreateSink {
+		extras := clickhouse.SinkExtraColumns(srcCols, sinkCols)
+		steps = append(steps, step{"create_sink " + kafkaDB + "." + sinkName, func() error {
+			return clickhouse.CreateKafkaTableFromSource(db, s.SourceDatabase, table, kafkaDB, brokers, topic, group, settings, extras, mapper)
+		}})
+		if pushExists {
+			steps = append(steps, step{"create_mv " + kafkaDB + "." + pushName, func() error {
+				return clickhouse.CreateMaterializedViewToKafka(db, s.SourceDatabase, table, kafkaDB)
+			}})
+			out["warning"] = "源侧推送物化视图重建期间写入源表的行不会推送到 Kafka，需按游标回补"
+		}
+	}
+	if mvExists {
+		steps = append(steps, step{"create_mv " + tgtDB + "." + mvName, func() error {
+			return clickhouse.CreateMaterializedView(db, kafkaDB, s.SourceDatabase, table, tgtDB, s.TargetTable)
+		}})
+	}
+	var descs []string
+	for _, st := range steps {
+		descs = append(descs, st.desc)
+	}
+	out["steps"] = descs
+	out["topic"] = topic
+	out["group"] = group
+	if dryRun {
+		out["status"] = "planned"
+		return out, nil
+	}
+	// 失败时按原定义恢复已删除的对象：视图取删除前的建表语句，sink 按原列与原设置重建
+	type restoreObject struct {
+		desc string
+		db   string
+		name string
+		ddl  string
+	}
+	var restore []restoreObject
+	if recreateSink {
+		restore = append(restore, restoreObject{"sink", kafkaDB, sinkName, clickhouse.KafkaSinkDDL(kafkaDB, s.SourceDatabase, table, sinkCols, brokers, topic, group, settings)})
+		for _, v := range []struct {
+			exists bool
+			name   string
+		}{{errViewExists, errView}, {pushExists, pushName}} {
+			if !v.exists {
+				continue
+			}
+			q, err := clickhouse.TableCreateQuery(db, kafkaDB, v.name)
+			if err != nil {
+				return nil, err
+			}
+			restore = append(restore, restoreObject{"view", kafkaDB, v.name, q})
+		}
+	}
+	if mvExists {
+		q, err := clickhouse.TableCreateQuery(db, tgtDB, mvName)
+		if err != nil {
+			return nil, err
+		}
+		restore = append(restore, restoreObject{"view", tgtDB, mvName, q})
+	}
+	for i, st := range steps {
+		if err := st.run(); err != nil {
+			stepErr := fmt.Errorf("evolve %s 第 %d 步失败（%s），已完成步骤: %v ; error: %w", table, i+1, st.desc, descs[:i], err)
+			var restored []string
+			for _, o := range restore {
+				exists, err := clickhouse.TableExists(db, o.db, o.name)
+				if err == nil && !exists {
+					_, err = db.Exec(o.ddl)
+				}
+				if err != nil {
+					return nil, fmt.Errorf("%v；恢复 %s %s.%s 失败，链路需手动修复: %v", stepErr, o.desc, o.db, o.name, err)
+				}
+				if !exists {
+					restored = append(restored, o.db+"."+o.name)
+				}
+			}
+			return nil, fmt.Errorf("%v；已按原定义恢复: %v", stepErr, restored)
+		}
+	}
+	out["status"] = "evolved"
+	return out, nil
+}
+
+func init() {
+	rootCmd.AddCommand(evolveCmd)
+	evolveCmd.Flags().String("table", "", "源表名（为空时处理 tables.yaml 中全部表）")
+	evolveCmd.Flags().Bool("dry-run", false, "仅输出演进步骤，不执行")
+	evolveCmd.Flags().Bool("allow-lossy", false, "允许对目标表执行有损收窄、不兼容或去掉 Nullable 的 MODIFY COLUMN")
+	evolveCmd.Flags().String("kafka-database", "", "Kafka 引擎表所在库（默认跟随 target-database）")
+}
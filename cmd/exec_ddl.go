@@ -3,24 +3,51 @@ package cmd
 
 import (
 	"click-house-sync/internal/clickhouse"
+	"database/sql"
+	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/spf13/cobra"
 )
 
-// execDDLCmd 读取 SQL 文件并执行以分号分隔的语句。
+// execDDLCmd 读取 SQL 文件并执行以分号分隔的语句；已执行的语句记录在迁移台账中，重复执行时跳过。
 var execDDLCmd = &cobra.Command{
 	Use:   "exec-ddl",
 	Short: "执行SQL文件",
-	Long:  "读取并执行指定文件中的 SQL 语句（以分号分隔）。",
+	Long:  "读取并执行指定文件中的 SQL 语句（以分号分隔）。每条成功执行的语句按迁移名（--migration，默认文件绝对路径）、语句标识与校验和记录到台账表 ch_sync_migrations（默认建在 --ch-database，可用 --ledger-database 指定），再次执行同一迁移时跳过已执行语句。迁移段外的语句以 stmt#序号 为标识，迁移段内的语句以 段名#序号 为标识；已执行的语句内容被修改（校验和与台账不一致）时报错且不执行任何语句，--force 重新执行这些语句，--reset 清空该迁移的台账。--status 只输出各语句的 applied/pending/changed 状态及台账中已不在文件里的语句（stale）。文件中以 -- +up <name>/-- +down <name> 标记的迁移段（gen-ddl --with-sync-cast 生成）在 up 语句失败时自动执行对应 down 语句回滚；--direction down 按相反顺序回滚已执行的迁移段。",
 	RunE: func(cmd *cobra.Command, args []string) error {
 		file, _ := cmd.Flags().GetString("file")
 		cont, _ := cmd.Flags().GetBool("continue-on-error")
+		status, _ := cmd.Flags().GetBool("status")
+		ledgerDB, _ := cmd.Flags().GetString("ledger-database")
+		direction, _ := cmd.Flags().GetString("direction")
+		unitsCSV, _ := cmd.Flags().GetString("units")
+		migration, _ := cmd.Flags().GetString("migration")
+		force, _ := cmd.Flags().GetBool("force")
+		reset, _ := cmd.Flags().GetBool("reset")
 		if strings.TrimSpace(file) == "" {
 			file = "create_tables.sql"
 		}
-		db, err := clickhouse.Connect(chHost, chPort, chUser, chPassword, chDatabase, chSecure)
+		// 台账默认按文件绝对路径记录；不同目录或不同机器执行同一迁移时应用 --migration 指定固定名称
+		key := strings.TrimSpace(migration)
+		if key == "" {
+			abs, err := filepath.Abs(file)
+			if err != nil {
+				return err
+			}
+			key = abs
+		}
+		if strings.TrimSpace(ledgerDB) == "" {
+			ledgerDB = chDatabase
+		}
+		direction = strings.ToLower(strings.TrimSpace(direction))
+		if direction != clickhouse.MigrationUp && direction != clickhouse.MigrationDown {
+			return fmt.Errorf("--direction 仅支持 up|down: %s", direction)
+		}
+		db, err := connectClickHouse(nil, chDatabase)
 		if err != nil {
 			return err
 		}
@@ -29,32 +56,394 @@ var execDDLCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
-		stmts := splitSQLStatements(b)
-		var results []map[string]any
-		executed := 0
-		for i, s := range stmts {
-			if strings.TrimSpace(s) == "" {
-				continue
-			}
-			if _, err := db.Exec(s); err != nil {
-				results = append(results, map[string]any{"index": i, "error": err.Error()})
-				if !cont {
+		stmts, units := parseDDLFile(b)
+		applied, err := clickhouse.GetMigrations(db, ledgerDB, key)
+		if err != nil {
+			return err
+		}
+		if reset {
+			for _, a := range applied {
+				a.Direction = clickhouse.MigrationDown
+				a.Executor = ""
+				if err := clickhouse.RecordMigration(db, ledgerDB, a); err != nil {
 					return err
 				}
-				continue
 			}
-			results = append(results, map[string]any{"index": i, "ok": true})
-			executed++
+			printJSON(map[string]any{"command": "exec-ddl", "file": file, "migration": key, "reset": len(applied)})
+			return nil
+		}
+		migrations, changed, pending := ddlStatus(stmts, applied)
+		total := len(migrations)
+		stale := staleMigrations(stmts, applied)
+		if status {
+			printJSON(map[string]any{
+				"command":    "exec-ddl",
+				"file":       file,
+				"migration":  key,
+				"ledger":     ledgerDB + "." + clickhouse.MigrationsTable,
+				"statements": total,
+				"applied":    total - pending - len(changed),
+				"pending":    pending,
+				"changed":    len(changed),
+				"stale":      stale,
+				"migrations": migrations,
+			})
+			return nil
+		}
+		if err := checkChangedStatements(file, changed, force); err != nil {
+			return err
+		}
+		// --force：内容已修改的语句按待执行处理
+		for _, k := range changed {
+			delete(applied, k)
+			pending++
 		}
-		printJSON(map[string]any{"command": "exec-ddl", "file": file, "statements": len(stmts), "executed": executed, "results": results})
-		return nil
+		run := &ddlRun{db: db, ledgerDB: ledgerDB, file: file, migration: key, applied: applied}
+		if direction == clickhouse.MigrationDown {
+			runErr := run.down(units, splitCSV(unitsCSV))
+			printJSON(map[string]any{"command": "exec-ddl", "file": file, "migration": key, "direction": direction, "rolled_back": run.rolledBack, "results": run.results})
+			return runErr
+		}
+		if pending > 0 {
+			if err := clickhouse.EnsureMigrationsTable(db, ledgerDB); err != nil {
+				return err
+			}
+		}
+		runErr := run.up(stmts, units, cont)
+		out := map[string]any{"command": "exec-ddl", "file": file, "migration": key, "statements": total, "executed": run.executed, "skipped": run.skipped, "results": run.results}
+		if len(run.rolledBack) > 0 {
+			out["rolled_back"] = run.rolledBack
+		}
+		printJSON(out)
+		return runErr
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(execDDLCmd)
 	execDDLCmd.Flags().String("file", "create_tables.sql", "SQL 文件路径（默认 create_tables.sql）")
-	execDDLCmd.Flags().Bool("continue-on-error", false, "遇到错误继续执行下一条语句")
+	execDDLCmd.Flags().Bool("continue-on-error", false, "遇到错误继续执行下一条语句（失败的迁移段先回滚，其余迁移段不回滚）")
+	execDDLCmd.Flags().Bool("status", false, "仅输出各语句在台账中的状态（applied/pending/changed），不执行")
+	execDDLCmd.Flags().String("ledger-database", "", "迁移台账表 ch_sync_migrations 所在库（默认 --ch-database）")
+	execDDLCmd.Flags().String("direction", "up", "执行方向 up|down；down 按相反顺序执行已执行迁移段的 -- +down 语句")
+	execDDLCmd.Flags().String("units", "", "--direction down 时仅回滚指定迁移段（逗号分隔，对应 -- +up <name> 中的名称）")
+	execDDLCmd.Flags().String("migration", "", "台账中的迁移名（默认文件绝对路径）；同一迁移在不同目录或机器执行时应指定")
+	execDDLCmd.Flags().Bool("force", false, "重新执行内容已修改（changed）的语句并更新台账")
+	execDDLCmd.Flags().Bool("reset", false, "清空该迁移的台账（全部标记为 down），不执行任何语句")
+}
+
+// ddlStatus 对比文件中的 up 语句与台账，返回各语句的状态（applied/pending/changed）、内容已修改的语句标识与待执行语句数。
+func ddlStatus(stmts []ddlStatement, applied map[string]clickhouse.Migration) ([]map[string]any, []string, int) {
+	var migrations []map[string]any
+	var changed []string
+	pending := 0
+	for _, s := range stmts {
+		if s.direction == clickhouse.MigrationDown {
+			continue
+		}
+		sum := clickhouse.StatementChecksum(s.text)
+		m := map[string]any{"index": s.index, "key": s.key, "checksum": sum, "statement": statementPreview(s.text)}
+		if s.unit != "" {
+			m["unit"] = s.unit
+		}
+		if a, ok := applied[s.key]; ok {
+			m["applied_at"] = a.AppliedAt
+			m["executor"] = a.Executor
+			if a.Checksum != sum {
+				m["status"] = "changed"
+				m["applied_checksum"] = a.Checksum
+				changed = append(changed, s.key)
+			} else {
+				m["status"] = "applied"
+			}
+		} else {
+			m["status"] = "pending"
+			pending++
+		}
+		migrations = append(migrations, m)
+	}
+	return migrations, changed, pending
+}
+
+// staleMigrations 返回台账中已不在文件里的语句标识（按字典序）。
+func staleMigrations(stmts []ddlStatement, applied map[string]clickhouse.Migration) []string {
+	inFile := map[string]bool{}
+	for _, s := range stmts {
+		if s.direction != clickhouse.MigrationDown {
+			inFile[s.key] = true
+		}
+	}
+	stale := []string{}
+	for k := range applied {
+		if !inFile[k] {
+			stale = append(stale, k)
+		}
+	}
+	sort.Strings(stale)
+	return stale
+}
+
+// checkChangedStatements 在已执行语句的内容被修改且未指定 --force 时返回错误。
+func checkChangedStatements(file string, changed []string, force bool) error {
+	if len(changed) > 0 && !force {
+		return fmt.Errorf("%s 中已执行的语句 %v 内容已被修改（校验和与台账不一致），请改为追加新语句，或使用 --force 重新执行、--reset 清空台账", file, changed)
+	}
+	return nil
+}
+
+// 迁移段标记，见 clickhouse.MigrationMarkerUp。
+const (
+	ddlMarkerUp   = clickhouse.MigrationMarkerUp
+	ddlMarkerDown = clickhouse.MigrationMarkerDown
+	ddlMarkerEnd  = clickhouse.MigrationMarkerEnd
+)
+
+// ddlStatementKeyPrefix 是迁移段外语句在台账中的标识前缀。
+const ddlStatementKeyPrefix = "stmt"
+
+// ddlStatement 是 SQL 文件中的一条语句；index 为语句在文件中的序号（含 down 段语句），unit 为所属迁移段（段外为空）。
+// key 是 up 语句在台账中的标识：迁移段内为 段名#段内序号，段外为 stmt#段外序号；台账按迁移名（文件）区分，
+// 已执行语句被修改时标识不变、校验和不一致，由 exec-ddl 报错而不是当作新语句执行。
+type ddlStatement struct {
+	index     int
+	key       string
+	text      string
+	direction string
+	unit      string
+}
+
+// ddlUnit 是一个迁移段的 up 与 down 语句。
+type ddlUnit struct {
+	name string
+	up   []ddlStatement
+	down []ddlStatement
+}
+
+// parseDDLFile 按迁移段标记拆分 SQL 文件；同名的 up 与 down 段配对，未命名的 down 段与最近的 up 段配对。
+// 不含标记的文件与拆分前一致，全部为段外语句。
+func parseDDLFile(b []byte) ([]ddlStatement, []*ddlUnit) {
+	var stmts []ddlStatement
+	var units []*ddlUnit
+	byName := map[string]*ddlUnit{}
+	outside := 0
+	direction, unit, lastUp := "", "", ""
+	var chunk strings.Builder
+	flush := func() {
+		for _, text := range splitSQLStatements([]byte(chunk.String())) {
+			st := ddlStatement{index: len(stmts), text: text, direction: direction, unit: unit}
+			if u := byName[unit]; u != nil && direction != clickhouse.MigrationDown {
+				st.key = fmt.Sprintf("%s#%d", unit, len(u.up))
+			} else if direction != clickhouse.MigrationDown {
+				st.key = fmt.Sprintf("%s#%d", ddlStatementKeyPrefix, outside)
+				outside++
+			}
+			stmts = append(stmts, st)
+			if u := byName[unit]; u != nil {
+				if direction == clickhouse.MigrationDown {
+					u.down = append(u.down, st)
+				} else {
+					u.up = append(u.up, st)
+				}
+			}
+		}
+		chunk.Reset()
+	}
+	for _, line := range strings.SplitAfter(string(b), "\n") {
+		marker, name, ok := parseDDLMarker(line)
+		if !ok {
+			chunk.WriteString(line)
+			continue
+		}
+		flush()
+		switch marker {
+		case ddlMarkerUp:
+			if name == "" {
+				name = fmt.Sprintf("#%d", len(units)+1)
+			}
+			direction, unit, lastUp = clickhouse.MigrationUp, name, name
+		case ddlMarkerDown:
+			if name == "" {
+				name = lastUp
+			}
+			direction, unit = clickhouse.MigrationDown, name
+		default:
+			direction, unit = "", ""
+		}
+		if unit != "" && byName[unit] == nil {
+			u := &ddlUnit{name: unit}
+			byName[unit] = u
+			units = append(units, u)
+		}
+	}
+	flush()
+	return stmts, units
+}
+
+// parseDDLMarker 识别独占一行的迁移段标记，返回标记与段名。
+func parseDDLMarker(line string) (string, string, bool) {
+	t := strings.TrimSpace(line)
+	for _, m := range []string{ddlMarkerUp, ddlMarkerDown, ddlMarkerEnd} {
+		if t == m {
+			return m, "", true
+		}
+		if strings.HasPrefix(t, m+" ") {
+			return m, strings.TrimSpace(t[len(m):]), true
+		}
+	}
+	return "", "", false
+}
+
+// ddlRun 记录一次 exec-ddl 的执行结果并维护迁移台账。
+type ddlRun struct {
+	db         *sql.DB
+	ledgerDB   string
+	file       string
+	migration  string
+	applied    map[string]clickhouse.Migration
+	results    []map[string]any
+	rolledBack []string
+	executed   int
+	skipped    int
+}
+
+// up 按文件顺序执行段外语句与 up 段语句，跳过已执行语句。某迁移段的语句失败时先执行该段的 down 语句；
+// 未开启 continueOnError 时本次已执行的其他迁移段也按相反顺序回滚，然后返回错误。
+func (r *ddlRun) up(stmts []ddlStatement, units []*ddlUnit, continueOnError bool) error {
+	byName := map[string]*ddlUnit{}
+	for _, u := range units {
+		byName[u.name] = u
+	}
+	var touched []*ddlUnit
+	touchedSet := map[*ddlUnit]bool{}
+	failedUnits := map[string]bool{}
+	for _, s := range stmts {
+		if s.direction == clickhouse.MigrationDown {
+			continue
+		}
+		res := map[string]any{"index": s.index}
+		if s.unit != "" {
+			res["unit"] = s.unit
+		}
+		if failedUnits[s.unit] {
+			res["not_run"] = true
+			r.results = append(r.results, res)
+			continue
+		}
+		if _, ok := r.applied[s.key]; ok {
+			res["skipped"] = true
+			r.results = append(r.results, res)
+			r.skipped++
+			continue
+		}
+		if _, err := r.db.Exec(s.text); err != nil {
+			res["error"] = err.Error()
+			r.results = append(r.results, res)
+			stmtErr := fmt.Errorf("语句 %d 执行失败: %v", s.index, err)
+			if u := byName[s.unit]; u != nil && len(u.down) > 0 {
+				failedUnits[u.name] = true
+				rollback := []*ddlUnit{u}
+				if !continueOnError {
+					for i := len(touched) - 1; i >= 0; i-- {
+						if touched[i] != u {
+							rollback = append(rollback, touched[i])
+						}
+					}
+				}
+				for _, ru := range rollback {
+					if err := r.rollback(ru); err != nil {
+						return fmt.Errorf("%v；%v", stmtErr, err)
+					}
+				}
+			}
+			if !continueOnError {
+				return stmtErr
+			}
+			continue
+		}
+		m := clickhouse.Migration{Name: r.migration, Key: s.key, Index: s.index, Checksum: clickhouse.StatementChecksum(s.text)}
+		if err := clickhouse.RecordMigration(r.db, r.ledgerDB, m); err != nil {
+			return fmt.Errorf("语句 %d 已执行但写入台账失败: %v", s.index, err)
+		}
+		r.applied[s.key] = m
+		res["ok"] = true
+		r.results = append(r.results, res)
+		r.executed++
+		if u := byName[s.unit]; u != nil && !touchedSet[u] {
+			touchedSet[u] = true
+			touched = append(touched, u)
+		}
+	}
+	return nil
+}
+
+// down 按相反顺序回滚有已执行 up 语句的迁移段；names 非空时只回滚指定段。
+func (r *ddlRun) down(units []*ddlUnit, names []string) error {
+	want := map[string]bool{}
+	for _, n := range names {
+		want[n] = true
+	}
+	found := false
+	for i := len(units) - 1; i >= 0; i-- {
+		u := units[i]
+		if len(want) > 0 && !want[u.name] {
+			continue
+		}
+		if len(u.down) > 0 {
+			found = true
+		}
+		appliedUp := false
+		for _, s := range u.up {
+			if _, ok := r.applied[s.key]; ok {
+				appliedUp = true
+				break
+			}
+		}
+		if !appliedUp {
+			continue
+		}
+		if err := r.rollback(u); err != nil {
+			return err
+		}
+	}
+	if !found {
+		return fmt.Errorf("%s 中没有可回滚的 -- +down 段", r.file)
+	}
+	return nil
+}
+
+// rollback 按书写顺序执行迁移段的 down 语句，全部成功后把该段已执行的 up 语句在台账中标记为 down。
+func (r *ddlRun) rollback(u *ddlUnit) error {
+	for _, s := range u.down {
+		res := map[string]any{"index": s.index, "unit": u.name, "direction": clickhouse.MigrationDown}
+		if _, err := r.db.Exec(s.text); err != nil {
+			res["error"] = err.Error()
+			r.results = append(r.results, res)
+			return fmt.Errorf("回滚 %s 失败（语句 %d）: %v", u.name, s.index, err)
+		}
+		res["ok"] = true
+		r.results = append(r.results, res)
+	}
+	for _, s := range u.up {
+		a, ok := r.applied[s.key]
+		if !ok {
+			continue
+		}
+		if err := clickhouse.RecordMigration(r.db, r.ledgerDB, clickhouse.Migration{Name: r.migration, Key: s.key, Index: s.index, Checksum: a.Checksum, Direction: clickhouse.MigrationDown}); err != nil {
+			return fmt.Errorf("回滚 %s 后写入台账失败: %v", u.name, err)
+		}
+		delete(r.applied, s.key)
+	}
+	r.rolledBack = append(r.rolledBack, u.name)
+	return nil
+}
+
+// statementPreview 返回语句的单行摘要，用于 --status 输出。
+func statementPreview(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if r := []rune(s); len(r) > 120 {
+		return string(r[:120]) + "..."
+	}
+	return s
 }
 
 // splitSQLStatements 将 SQL 文本拆分为语句，跳过注释并正确处理引号。
@@ -0,0 +1,306 @@
+package cmd
+
+import (
+	"click-house-sync/internal/clickhouse"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// execRecorder 是只支持 Exec 的测试驱动：记录执行的语句，语句包含 fail 中的片段时返回错误。
+type execRecorder struct {
+	fail  []string
+	execs []string
+}
+
+func (r *execRecorder) Connect(context.Context) (driver.Conn, error) { return recorderConn{r}, nil }
+func (r *execRecorder) Driver() driver.Driver                        { return nil }
+
+// statements 返回执行过的 DDL（不含台账写入）。
+func (r *execRecorder) statements() []string {
+	var out []string
+	for _, q := range r.execs {
+		if !strings.Contains(q, clickhouse.MigrationsTable) {
+			out = append(out, q)
+		}
+	}
+	return out
+}
+
+type recorderConn struct{ r *execRecorder }
+
+func (c recorderConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c recorderConn) Close() error                        { return nil }
+func (c recorderConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+func (c recorderConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	for _, f := range c.r.fail {
+		if strings.Contains(query, f) {
+			return nil, errors.New("boom")
+		}
+	}
+	c.r.execs = append(c.r.execs, query)
+	return driver.RowsAffected(0), nil
+}
+
+func newDDLRun(t *testing.T, applied map[string]clickhouse.Migration, fail ...string) (*ddlRun, *execRecorder) {
+	rec := &execRecorder{fail: fail}
+	db := sql.OpenDB(rec)
+	t.Cleanup(func() { db.Close() })
+	if applied == nil {
+		applied = map[string]clickhouse.Migration{}
+	}
+	return &ddlRun{db: db, ledgerDB: "default", file: "test.sql", migration: "test", applied: applied}, rec
+}
+
+// appliedUp 返回 stmts 中指定迁移段全部 up 语句已执行的台账。
+func appliedUp(stmts []ddlStatement, units ...string) map[string]clickhouse.Migration {
+	want := map[string]bool{}
+	for _, u := range units {
+		want[u] = true
+	}
+	out := map[string]clickhouse.Migration{}
+	for _, s := range stmts {
+		if s.direction != clickhouse.MigrationDown && want[s.unit] {
+			out[s.key] = clickhouse.Migration{Key: s.key, Index: s.index, Checksum: clickhouse.StatementChecksum(s.text)}
+		}
+	}
+	return out
+}
+
+const ddlTwoUnits = `-- +up a
+CREATE TABLE a1;
+CREATE TABLE a2;
+-- +down a
+DROP TABLE a1;
+-- +end
+-- +up b
+CREATE TABLE b1;
+-- +down b
+DROP TABLE b1;
+-- +end
+`
+
+func TestParseDDLFile(t *testing.T) {
+	type stmt struct{ key, direction, unit, text string }
+	cases := []struct {
+		name  string
+		in    string
+		stmts []stmt
+		units map[string][2]int
+	}{
+		{
+			name: "plain statements keyed by position",
+			in:   "CREATE TABLE t1 (id UInt8);\nCREATE TABLE t2 (id UInt8);\n",
+			stmts: []stmt{
+				{"stmt#0", "", "", "CREATE TABLE t1 (id UInt8)"},
+				{"stmt#1", "", "", "CREATE TABLE t2 (id UInt8)"},
+			},
+			units: map[string][2]int{},
+		},
+		{
+			name: "duplicate statements get distinct keys",
+			in:   "OPTIMIZE TABLE t;\n-- +up u\nOPTIMIZE TABLE t;\nOPTIMIZE TABLE t;\n-- +end\nOPTIMIZE TABLE t;\n",
+			stmts: []stmt{
+				{"stmt#0", "", "", "OPTIMIZE TABLE t"},
+				{"u#0", "up", "u", "OPTIMIZE TABLE t"},
+				{"u#1", "up", "u", "OPTIMIZE TABLE t"},
+				{"stmt#1", "", "", "OPTIMIZE TABLE t"},
+			},
+			units: map[string][2]int{"u": {2, 0}},
+		},
+		{
+			name: "named up and down sections",
+			in:   "CREATE DATABASE d;\n" + ddlTwoUnits + "SELECT 1;\n",
+			stmts: []stmt{
+				{"stmt#0", "", "", "CREATE DATABASE d"},
+				{"a#0", "up", "a", "CREATE TABLE a1"},
+				{"a#1", "up", "a", "CREATE TABLE a2"},
+				{"", "down", "a", "DROP TABLE a1"},
+				{"b#0", "up", "b", "CREATE TABLE b1"},
+				{"", "down", "b", "DROP TABLE b1"},
+				{"stmt#1", "", "", "SELECT 1"},
+			},
+			units: map[string][2]int{"a": {2, 1}, "b": {1, 1}},
+		},
+		{
+			name: "unnamed sections pair with the latest up",
+			in:   "-- +up\nCREATE TABLE x;\n-- +down\nDROP TABLE x;\n-- +end\n",
+			stmts: []stmt{
+				{"#1#0", "up", "#1", "CREATE TABLE x"},
+				{"", "down", "#1", "DROP TABLE x"},
+			},
+			units: map[string][2]int{"#1": {1, 1}},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			stmts, units := parseDDLFile([]byte(c.in))
+			var got []stmt
+			for i, s := range stmts {
+				if s.index != i {
+					t.Errorf("statement %d has index %d", i, s.index)
+				}
+				got = append(got, stmt{s.key, s.direction, s.unit, s.text})
+			}
+			if !reflect.DeepEqual(got, c.stmts) {
+				t.Fatalf("statements:\n got %v\nwant %v", got, c.stmts)
+			}
+			gotUnits := map[string][2]int{}
+			for _, u := range units {
+				gotUnits[u.name] = [2]int{len(u.up), len(u.down)}
+			}
+			if !reflect.DeepEqual(gotUnits, c.units) {
+				t.Fatalf("units: got %v, want %v", gotUnits, c.units)
+			}
+		})
+	}
+}
+
+func TestDDLStatusChecksumMismatch(t *testing.T) {
+	stmts, _ := parseDDLFile([]byte("CREATE TABLE t1 (id UInt8);\nCREATE TABLE t2 (id UInt16);\nCREATE TABLE t3 (id UInt8);\n"))
+	applied := map[string]clickhouse.Migration{
+		"stmt#0": {Key: "stmt#0", Checksum: clickhouse.StatementChecksum("CREATE TABLE t1 (id UInt8)")},
+		"stmt#1": {Key: "stmt#1", Checksum: clickhouse.StatementChecksum("CREATE TABLE t2 (id UInt8)")},
+		"old#0":  {Key: "old#0", Checksum: "x"},
+	}
+	migrations, changed, pending := ddlStatus(stmts, applied)
+	var statuses []string
+	for _, m := range migrations {
+		statuses = append(statuses, m["status"].(string))
+	}
+	if want := []string{"applied", "changed", "pending"}; !reflect.DeepEqual(statuses, want) {
+		t.Fatalf("statuses = %v, want %v", statuses, want)
+	}
+	if !reflect.DeepEqual(changed, []string{"stmt#1"}) || pending != 1 {
+		t.Fatalf("changed = %v, pending = %d", changed, pending)
+	}
+	if stale := staleMigrations(stmts, applied); !reflect.DeepEqual(stale, []string{"old#0"}) {
+		t.Fatalf("stale = %v", stale)
+	}
+	cases := []struct {
+		changed []string
+		force   bool
+		wantErr bool
+	}{
+		{changed, false, true},
+		{changed, true, false},
+		{nil, false, false},
+	}
+	for _, c := range cases {
+		err := checkChangedStatements("test.sql", c.changed, c.force)
+		if (err != nil) != c.wantErr {
+			t.Fatalf("checkChangedStatements(%v, force=%v) = %v", c.changed, c.force, err)
+		}
+		if err != nil && !strings.Contains(err.Error(), "stmt#1") {
+			t.Fatalf("error does not name the changed statement: %v", err)
+		}
+	}
+}
+
+func TestDDLRunDown(t *testing.T) {
+	stmts, units := parseDDLFile([]byte(ddlTwoUnits))
+	cases := []struct {
+		name       string
+		applied    []string
+		units      []string
+		wantExec   []string
+		wantRolled []string
+		wantLeft   []string
+		wantErr    bool
+	}{
+		{"all units in reverse order", []string{"a", "b"}, nil, []string{"DROP TABLE b1", "DROP TABLE a1"}, []string{"b", "a"}, nil, false},
+		{"filtered by --units", []string{"a", "b"}, []string{"a"}, []string{"DROP TABLE a1"}, []string{"a"}, []string{"b#0"}, false},
+		{"units without applied up are skipped", []string{"b"}, nil, []string{"DROP TABLE b1"}, []string{"b"}, nil, false},
+		{"unknown unit has nothing to roll back", []string{"a"}, []string{"c"}, nil, nil, []string{"a#0", "a#1"}, true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			run, rec := newDDLRun(t, appliedUp(stmts, c.applied...))
+			err := run.down(units, c.units)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("down() error = %v", err)
+			}
+			if got := rec.statements(); !reflect.DeepEqual(got, c.wantExec) {
+				t.Fatalf("executed %v, want %v", got, c.wantExec)
+			}
+			if !reflect.DeepEqual(run.rolledBack, c.wantRolled) {
+				t.Fatalf("rolled back %v, want %v", run.rolledBack, c.wantRolled)
+			}
+			var left []string
+			for _, s := range stmts {
+				if _, ok := run.applied[s.key]; ok && s.key != "" {
+					left = append(left, s.key)
+				}
+			}
+			if !reflect.DeepEqual(left, c.wantLeft) {
+				t.Fatalf("still applied %v, want %v", left, c.wantLeft)
+			}
+		})
+	}
+}
+
+func TestDDLRunUpRollsBackTouchedUnits(t *testing.T) {
+	file := ddlTwoUnits + "-- +up c\nCREATE TABLE c1;\n-- +down c\nDROP TABLE c1;\n-- +end\nCREATE TABLE tail;\n"
+	stmts, units := parseDDLFile([]byte(file))
+	cases := []struct {
+		name       string
+		cont       bool
+		wantExec   []string
+		wantRolled []string
+		wantErr    bool
+	}{
+		{
+			name:       "failure rolls back the failed unit and every touched unit",
+			wantExec:   []string{"CREATE TABLE a1", "CREATE TABLE a2", "CREATE TABLE b1", "DROP TABLE c1", "DROP TABLE b1", "DROP TABLE a1"},
+			wantRolled: []string{"c", "b", "a"},
+			wantErr:    true,
+		},
+		{
+			name:       "continue-on-error only rolls back the failed unit",
+			cont:       true,
+			wantExec:   []string{"CREATE TABLE a1", "CREATE TABLE a2", "CREATE TABLE b1", "DROP TABLE c1", "CREATE TABLE tail"},
+			wantRolled: []string{"c"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			run, rec := newDDLRun(t, nil, "CREATE TABLE c1")
+			err := run.up(stmts, units, c.cont)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("up() error = %v", err)
+			}
+			if got := rec.statements(); !reflect.DeepEqual(got, c.wantExec) {
+				t.Fatalf("executed %v, want %v", got, c.wantExec)
+			}
+			if !reflect.DeepEqual(run.rolledBack, c.wantRolled) {
+				t.Fatalf("rolled back %v, want %v", run.rolledBack, c.wantRolled)
+			}
+			for _, u := range c.wantRolled {
+				for k := range run.applied {
+					if strings.HasPrefix(k, u+"#") {
+						t.Fatalf("unit %s still applied after rollback: %s", u, k)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestDDLRunUpSkipsApplied(t *testing.T) {
+	stmts, units := parseDDLFile([]byte(ddlTwoUnits))
+	run, rec := newDDLRun(t, appliedUp(stmts, "a"))
+	if err := run.up(stmts, units, false); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := rec.statements(), []string{"CREATE TABLE b1"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("executed %v, want %v", got, want)
+	}
+	if run.skipped != 2 || run.executed != 1 {
+		t.Fatalf("skipped = %d, executed = %d", run.skipped, run.executed)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"click-house-sync/internal/config"
 	kadmin "click-house-sync/internal/kafka"
 	kprod "click-house-sync/internal/kafka"
+	"click-house-sync/internal/naming"
 	"context"
 	"database/sql"
 	"fmt"
@@ -30,21 +31,21 @@ var exportCmd = &cobra.Command{
 		if table == "" {
 			return fmt.Errorf("缺少 --table")
 		}
-		db, err := clickhouse.Connect(chHost, chPort, chUser, chPassword, chDatabase, chSecure)
+		tconf, err := lookupTableConfig(table)
 		if err != nil {
 			return err
 		}
-		defer db.Close()
-		tconf, err := lookupTableConfig(table)
+		db, err := connectClickHouse(tconf, chDatabase)
 		if err != nil {
 			return err
 		}
+		defer db.Close()
 		srcDB := chDatabase
 		if !cmd.Root().PersistentFlags().Changed("ch-database") && tconf != nil && tconf.CurrentDatabase != "" {
 			srcDB = tconf.CurrentDatabase
 		}
 		if kafkaTopic == "" {
-			kafkaTopic = srcDB + "_" + table
+			kafkaTopic = naming.Topic(srcDB, table)
 		}
 		cols, err := clickhouse.GetColumns(db, srcDB, table)
 		if err != nil {
@@ -93,15 +94,10 @@ var exportCmd = &cobra.Command{
 		}
 		if watch && cursorStartFromTarget && strings.TrimSpace(curCol) != "" {
 			// determine target location
-			tgtDB := chDatabase
-			if cmd.Root().PersistentFlags().Changed("target-database") {
-				tgtDB = targetDatabase
-			} else if tconf != nil && strings.TrimSpace(tconf.TargetDatabase) != "" {
-				tgtDB = tconf.TargetDatabase
-			}
+			tgtDB := targetDatabaseFor(cmd, tconf)
 			var src string
 			if mvOwnTable {
-				src = qualified(tgtDB, "mv_from_kafka_"+table)
+				src = qualified(tgtDB, naming.MVFromKafka(srcDB, table))
 			} else {
 				tgtTbl := targetTable
 				if strings.TrimSpace(tgtTbl) == "" {
@@ -219,10 +215,48 @@ var exportCmd = &cobra.Command{
 				}
 			}()
 		}
+		autoEvolve, _ := cmd.Flags().GetBool("auto-evolve")
+		var lastEvolveCheck time.Time
 		for {
 			if stop.Load() {
 				break
 			}
+			// watch 模式下按轮询间隔检查源表列变化：先演进下游 sink/MV/目标表，再导出新列
+			if autoEvolve && watch && time.Since(lastEvolveCheck) >= time.Duration(pollInterval)*time.Second {
+				lastEvolveCheck = time.Now()
+				cur, err := clickhouse.GetColumns(db, srcDB, table)
+				if err == nil && columnsChanged(names, cur) {
+					res, err := autoEvolveTable(cmd, db, table)
+					if err != nil {
+						// 演进中途失败时链路可能缺少 sink 或 MV，停止导出，避免新数据写入无人消费的 topic
+						printErrJSON(map[string]any{"event": "schema_evolve_failed", "database": srcDB, "table": table, "error": err.Error()})
+						close(workCh)
+						wg.Wait()
+						select {
+						case e := <-errCh:
+							return e
+						default:
+						}
+						return err
+					}
+					if res["status"] == "blocked" {
+						printErrJSON(map[string]any{"event": "schema_evolve_blocked", "database": srcDB, "table": table, "reason": res["reason"], "hint": "请确认后手动执行 evolve --allow-lossy"})
+					} else {
+						names = names[:0]
+						for _, c := range cur {
+							names = append(names, c.Name)
+						}
+						cursorIdx, keyIndex = indexOf(names, curCol), indexOf(names, keyCol)
+						if strings.TrimSpace(curCol) == "" {
+							cursorIdx = -1
+						}
+						if keyCol == "" {
+							keyIndex = -1
+						}
+						printJSON(map[string]any{"event": "schema_evolved", "database": srcDB, "table": table, "columns": len(names), "result": res})
+					}
+				}
+			}
 			base := fmt.Sprintf("SELECT %s FROM %s", joinQuoted(names), qualified(srcDB, table))
 			var where string
 			if cursorIdx >= 0 {
@@ -575,6 +609,7 @@ var exportCmd = &cobra.Command{
 func init() {
 	rootCmd.AddCommand(exportCmd)
 	exportCmd.Flags().String("table", "", "源表名（必填）")
+	exportCmd.Flags().Bool("auto-evolve", false, "watch 模式下检测源表新增/变更列，自动执行 evolve 后再导出新列")
 }
 
 // joinComma 用逗号拼接字符串切片。
@@ -1095,3 +1130,36 @@ func normalizeOrderBy(expr string, cols []string) string {
 	}
 	return strings.Join(out, ", ")
 }
+
+// columnsChanged 判断源表当前列与导出列名列表是否不一致。
+func columnsChanged(names []string, cols []clickhouse.Column) bool {
+	if len(names) != len(cols) {
+		return true
+	}
+	for i, c := range cols {
+		if names[i] != c.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// indexOf 返回列名在列表中的下标，不存在返回 -1。
+func indexOf(names []string, name string) int {
+	for i, n := range names {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// autoEvolveTable 供 export --watch --auto-evolve 调用，按表配置推导链路位置后执行 evolve；有损的目标列变更不会自动执行，
+// 返回 status 为 blocked 的结果，链路保持不变。
+func autoEvolveTable(cmd *cobra.Command, db *sql.DB, table string) (map[string]any, error) {
+	s, err := resolveTableStream(cmd, table)
+	if err != nil {
+		return nil, err
+	}
+	return evolveTable(db, table, s, s.KafkaDatabase, false, false)
+}
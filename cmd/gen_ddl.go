@@ -3,27 +3,28 @@ package cmd
 
 import (
 	"click-house-sync/internal/clickhouse"
+	"click-house-sync/internal/naming"
 	"database/sql"
 	"fmt"
 	"os"
-	"sort"
 	"strings"
 	"unicode"
 
 	"github.com/spf13/cobra"
 )
 
-// genDDLCmd 对库中每个表输出 SHOW CREATE TABLE，可覆盖库名。
+// genDDLCmd 按依赖顺序对库中每个对象输出 SHOW CREATE TABLE/DICTIONARY，可覆盖库名。
 var genDDLCmd = &cobra.Command{
 	Use:   "gen-ddl",
 	Short: "生成库中的建表语句",
-	Long:  "遍历指定数据库，输出每个表的建表 DDL（SHOW CREATE TABLE）。支持过滤视图与 Kafka 表，按前/后缀过滤，并可用 --ddl-database 覆盖输出中的库名。",
+	Long:  "遍历指定数据库，按依赖顺序输出表、字典与视图的建表 DDL（被视图、物化视图、字典引用的对象在前；依赖取自 system.tables 的 dependencies_* 与对象 DDL 中的引用）。支持过滤视图与 Kafka 表，按前/后缀过滤，并可用 --ddl-database 覆盖输出中的库名；可选输出自定义函数、行策略与用户/角色/授权。",
 	RunE: func(cmd *cobra.Command, args []string) error {
 		output, _ := cmd.Flags().GetString("output")
 		includeViews, _ := cmd.Flags().GetBool("include-views")
 		prefix, _ := cmd.Flags().GetString("prefix")
 		suffix, _ := cmd.Flags().GetString("suffix")
 		ddlDB, _ := cmd.Flags().GetString("ddl-database")
+		dbMapCSV, _ := cmd.Flags().GetString("db-map")
 		tablesCSV, _ := cmd.Flags().GetString("tables")
 		forceMergeTree, _ := cmd.Flags().GetBool("merge-tree")
 		withSyncCast, _ := cmd.Flags().GetBool("with-sync-cast")
@@ -31,97 +32,121 @@ var genDDLCmd = &cobra.Command{
 		includeQualitySQL, _ := cmd.Flags().GetBool("include-quality-sql")
 		castTargetDB, _ := cmd.Flags().GetString("cast-target-database")
 		castTargetSuffix, _ := cmd.Flags().GetString("cast-target-suffix")
+		includeFunctions, _ := cmd.Flags().GetBool("include-functions")
+		includeRowPolicies, _ := cmd.Flags().GetBool("include-row-policies")
+		includeAccess, _ := cmd.Flags().GetBool("include-access")
 
 		if output == "" {
 			output = "create_tables.sql"
 		}
 
-		db, err := clickhouse.Connect(chHost, chPort, chUser, chPassword, chDatabase, chSecure)
+		db, err := connectClickHouse(nil, chDatabase)
 		if err != nil {
 			return err
 		}
 		defer db.Close()
 
-		query := "SELECT name, engine FROM system.tables WHERE database = ?"
-		rows, err := db.Query(query, chDatabase)
+		// --ddl-database 等价于把当前库映射到该库；--db-map 可同时改写 DDL 中引用的其他库
+		dbMap, err := clickhouse.ParseDatabaseMap(dbMapCSV)
 		if err != nil {
 			return err
 		}
-		defer rows.Close()
-		var names []string
-		var engines []string
-		for rows.Next() {
-			var name, engine string
-			if err := rows.Scan(&name, &engine); err != nil {
-				return err
+		if ddlDB != "" {
+			if prev, ok := dbMap[chDatabase]; ok && prev != ddlDB {
+				return fmt.Errorf("--ddl-database=%s 与 --db-map 中 %s=%s 冲突", ddlDB, chDatabase, prev)
 			}
-			if !includeViews && (engine == "MaterializedView" || engine == "View" || engine == "Kafka") {
-				continue
+			dbMap[chDatabase] = ddlDB
+		}
+
+		objs, err := clickhouse.ListDatabaseObjects(db, chDatabase)
+		if err != nil {
+			return err
+		}
+		var want map[string]struct{}
+		if strings.TrimSpace(tablesCSV) != "" {
+			want = map[string]struct{}{}
+			for _, w := range splitCSVLocal(tablesCSV) {
+				want[w] = struct{}{}
 			}
-			if len(prefix) > 0 && !hasPrefix(name, prefix) {
+		}
+		var selected []clickhouse.DatabaseObject
+		for _, o := range objs {
+			if !includeViews && o.IsView() {
 				continue
 			}
-			if len(suffix) > 0 && !hasSuffix(name, suffix) {
+			if len(prefix) > 0 && !hasPrefix(o.Name, prefix) {
 				continue
 			}
-			names = append(names, name)
-			engines = append(engines, engine)
-		}
-		sort.Strings(names)
-		if strings.TrimSpace(tablesCSV) != "" {
-			want := splitCSVLocal(tablesCSV)
-			set := map[string]struct{}{}
-			for _, w := range want {
-				set[w] = struct{}{}
+			if len(suffix) > 0 && !hasSuffix(o.Name, suffix) {
+				continue
 			}
-			var filtered []string
-			for _, n := range names {
-				if _, ok := set[n]; ok {
-					filtered = append(filtered, n)
+			if want != nil {
+				if _, ok := want[o.Name]; !ok {
+					continue
 				}
 			}
-			names = filtered
+			selected = append(selected, o)
+		}
+		// 按依赖排序：被视图、物化视图、字典引用的对象先输出
+		ordered, cycles := clickhouse.SortObjectsByDependency(selected)
+		kinds := map[string]int{}
+		var names []string
+		for _, o := range ordered {
+			kinds[o.Kind]++
+			names = append(names, o.Name)
 		}
 
 		var content []byte
 		dbOut := chDatabase
-		if ddlDB != "" {
-			dbOut = ddlDB
+		if m, ok := dbMap[chDatabase]; ok {
+			dbOut = m
 		}
 		if withSyncCast && strings.TrimSpace(castTargetDB) != "" {
 			dbOut = strings.TrimSpace(castTargetDB)
 		}
+		var functions []string
+		if includeFunctions {
+			functions, err = clickhouse.UserDefinedFunctionsDDL(db)
+			if err != nil {
+				return err
+			}
+			content = appendDDLSection(content, "functions", renameDatabasesAll(functions, dbMap))
+		}
 		content = append(content, []byte(fmt.Sprintf("CREATE DATABASE IF NOT EXISTS %s;\n\n", dbOut))...)
 		var details []map[string]any
-		for _, n := range names {
+		for _, o := range ordered {
+			n := o.Name
 			if withSyncCast {
+				if o.Kind != clickhouse.ObjectTable {
+					continue
+				}
 				detail, upDDL, downDDL, qualitySQL, err := buildSyncCastDDL(db, chDatabase, dbOut, n, castTargetSuffix, includeQualitySQL)
 				if err != nil {
 					return err
 				}
 				details = append(details, detail)
+				// 每张表的重建与回滚 DDL 用 -- +up/-- +down 标记成对，exec-ddl 在 up 失败时据此回滚
+				content = append(content, []byte(ddlMarkerUp+" "+n+"\n")...)
 				content = append(content, []byte(upDDL)...)
-				content = append(content, []byte("\n\n")...)
+				content = append(content, []byte("\n")...)
 				if includeRollback {
+					content = append(content, []byte(ddlMarkerDown+" "+n+"\n")...)
 					content = append(content, []byte(downDDL)...)
-					content = append(content, []byte("\n\n")...)
+					content = append(content, []byte("\n")...)
 				}
+				content = append(content, []byte(ddlMarkerEnd+"\n\n")...)
 				if includeQualitySQL && len(qualitySQL) > 0 {
 					content = append(content, []byte(strings.Join(qualitySQL, "\n\n"))...)
 					content = append(content, []byte("\n\n")...)
 				}
 				continue
 			}
-			var ddl string
-			if err := db.QueryRow(fmt.Sprintf("SHOW CREATE TABLE %s.%s", chDatabase, n)).Scan(&ddl); err != nil {
+			ddl, err := clickhouse.ShowCreateObject(db, chDatabase, o)
+			if err != nil {
 				return err
 			}
-			if ddlDB != "" && ddlDB != chDatabase {
-				orig := fmt.Sprintf("%s.%s", chDatabase, n)
-				target := fmt.Sprintf("%s.%s", ddlDB, n)
-				ddl = strings.ReplaceAll(ddl, orig, target)
-			}
-			if forceMergeTree {
+			ddl = clickhouse.RenameDatabases(ddl, dbMap)
+			if forceMergeTree && o.Kind == clickhouse.ObjectTable {
 				ddl = ensureMergeTreeDDL(ddl)
 			}
 			content = append(content, []byte(ddl)...)
@@ -130,8 +155,31 @@ var genDDLCmd = &cobra.Command{
 			}
 			content = append(content, []byte("\n\n")...)
 		}
+		var policies []string
+		if includeRowPolicies {
+			policies, err = clickhouse.RowPoliciesDDL(db, chDatabase)
+			if err != nil {
+				return err
+			}
+			content = appendDDLSection(content, "row policies", renameDatabasesAll(policies, dbMap))
+		}
+		var access *clickhouse.AccessDDL
+		if includeAccess {
+			access, err = clickhouse.GetAccessDDL(db)
+			if err != nil {
+				return err
+			}
+			content = appendDDLSection(content, "roles", access.Roles)
+			content = appendDDLSection(content, "users", access.Users)
+			content = appendDDLSection(content, "grants", renameDatabasesAll(access.Grants, dbMap))
+		}
+		// 用户 DDL 可能包含密码哈希，输出文件仅所有者可读
+		mode := os.FileMode(0644)
+		if includeAccess {
+			mode = 0600
+		}
 		tmp := output + ".tmp"
-		if err := os.WriteFile(tmp, content, 0644); err != nil {
+		if err := os.WriteFile(tmp, content, mode); err != nil {
 			return err
 		}
 		if err := os.Rename(tmp, output); err != nil {
@@ -141,6 +189,26 @@ var genDDLCmd = &cobra.Command{
 		if ddlDB != "" {
 			out["ddl_database"] = ddlDB
 		}
+		if len(dbMap) > 0 {
+			out["db_map"] = dbMap
+		}
+		if !withSyncCast {
+			out["objects"] = kinds
+		}
+		if len(cycles) > 0 {
+			out["dependency_cycles"] = cycles
+		}
+		if includeFunctions {
+			out["functions"] = len(functions)
+		}
+		if includeRowPolicies {
+			out["row_policies"] = len(policies)
+		}
+		if access != nil {
+			out["roles"] = len(access.Roles)
+			out["users"] = len(access.Users)
+			out["grants"] = len(access.Grants)
+		}
 		if withSyncCast {
 			out["mode"] = "sync_cast"
 			out["details"] = details
@@ -159,14 +227,18 @@ func init() {
 	genDDLCmd.Flags().Bool("include-views", false, "包含视图与 Kafka 引擎表")
 	genDDLCmd.Flags().String("prefix", "", "仅包含指定前缀的表名")
 	genDDLCmd.Flags().String("suffix", "", "仅包含指定后缀的表名")
-	genDDLCmd.Flags().String("ddl-database", "", "覆盖输出 DDL 中的库名（例如将 demo 改写为 mv）")
+	genDDLCmd.Flags().String("ddl-database", "", "覆盖输出 DDL 中的库名（例如将 demo 改写为 mv），等价于 --db-map <ch-database>=<ddl-database>")
+	genDDLCmd.Flags().String("db-map", "", "按映射改写 DDL 中出现的库名（逗号分隔，如 demo=mv,dim=dim_prod），覆盖对象名、FROM/JOIN、TO 目标、字典源、remote/Distributed 参数与授权")
 	genDDLCmd.Flags().String("tables", "", "仅生成指定表 DDL（逗号分隔）")
 	genDDLCmd.Flags().Bool("merge-tree", false, "将输出 DDL 的引擎统一改为 MergeTree")
 	genDDLCmd.Flags().Bool("with-sync-cast", false, "生成 String 中间层 + 严格 CAST 物化视图的重建 DDL")
-	genDDLCmd.Flags().Bool("include-rollback", true, "在 with-sync-cast 模式下附加回滚 DDL")
+	genDDLCmd.Flags().Bool("include-rollback", true, "在 with-sync-cast 模式下附加回滚 DDL（-- +down 段）")
 	genDDLCmd.Flags().Bool("include-quality-sql", true, "在 with-sync-cast 模式下附加数据质量巡检 SQL")
 	genDDLCmd.Flags().String("cast-target-database", "", "with-sync-cast 模式下目标库名（默认与 ddl-database 或 ch-database 一致）")
 	genDDLCmd.Flags().String("cast-target-suffix", "", "with-sync-cast 模式下目标表后缀（默认空，即与源表同名）")
+	genDDLCmd.Flags().Bool("include-functions", false, "在库 DDL 之前输出 SQL 自定义函数（system.functions）")
+	genDDLCmd.Flags().Bool("include-row-policies", false, "在表 DDL 之后输出作用于本库表的行策略")
+	genDDLCmd.Flags().Bool("include-access", false, "在末尾输出 SQL 管理的角色、用户与授权（输出文件权限为 0600）")
 }
 
 func buildSyncCastDDL(db *sql.DB, sourceDB string, targetDB string, table string, targetSuffix string, includeQualitySQL bool) (map[string]any, string, string, []string, error) {
@@ -175,37 +247,95 @@ func buildSyncCastDDL(db *sql.DB, sourceDB string, targetDB string, table string
 		return nil, "", "", nil, err
 	}
 	targetTableName := table + targetSuffix
-	targetCols, err := clickhouse.GetColumns(db, targetDB, targetTableName)
+	tconf, _ := lookupTableConfig(table)
+	mapper, err := typeMapperFor(tconf)
 	if err != nil {
-		targetCols = sourceCols
+		return nil, "", "", nil, err
 	}
+	// 目标表已存在时以其实际类型为准，否则按 type_mappings 由源表类型推导
+	targetCols, err := clickhouse.GetColumns(db, targetDB, targetTableName)
+	if err != nil || len(targetCols) == 0 {
+		targetCols = mapper.MapColumns(sourceCols)
+	}
+	// 未展开的 Nested 列按子列逐一同步，与 flatten_nested=1 下的实际表结构一致
+	sourceCols = clickhouse.FlattenNestedColumns(sourceCols)
+	targetCols = clickhouse.FlattenNestedColumns(targetCols)
 	typeDiffs := clickhouse.AnalyzeTypeDiff(sourceCols, targetCols)
-	kafkaTable := "kafka_" + table + "_sink"
-	mvName := "mv_from_kafka_" + table
-	topic := sourceDB + "_" + table
-	group := groupName + "-" + table
-	if strings.TrimSpace(groupName) == "" {
-		group = "ch-sync-" + table
+	kafkaTable := naming.Sink(sourceDB, table)
+	mvName := naming.MVFromKafka(sourceDB, table)
+	topic := naming.Topic(sourceDB, table)
+	grp := groupName
+	if strings.TrimSpace(grp) == "" {
+		grp = "ch-sync"
 	}
+	group := naming.Group(sourceDB, table, grp)
 	stringCols := buildColumnsDDL(sourceCols, true)
 	targetColsDDL := buildColumnsDDL(targetCols, false)
-	typedCols := buildColumnsDDL(sourceCols, false)
-	selectExpr, err := clickhouse.BuildMvSelectWithCasts(sourceCols, targetCols)
+	typedCols := buildColumnsDDL(sinkColumns(sourceCols, mapper), false)
+	mode, err := castModeFor(tconf)
+	if err != nil {
+		return nil, "", "", nil, err
+	}
+	selectExpr, err := clickhouse.BuildMvSelectWithCasts(sourceCols, targetCols, mode)
 	if err != nil {
 		return nil, "", "", nil, err
 	}
+	kafkaSettings := kafkaSettingsFor(tconf)
+	engineClause := clickhouse.KafkaEngineClause(brokersList(), topic, group, kafkaSettings)
+	mvFilter := ""
+	baseFilter := ""
+	if kafkaSettings.ErrorStream() {
+		baseFilter = clickhouse.KafkaErrorFilter
+		mvFilter = " WHERE " + baseFilter
+	}
+	// quarantine 模式下主链路只写入可转换的行，其余行由隔离 MV 写入 <table>_quarantine
+	var quarantineDDL []string
+	castFilter := ""
+	if mode == clickhouse.CastModeQuarantine {
+		quarantineDDL = clickhouse.QuarantineDDL(targetDB, sourceDB, table, sourceCols, targetCols, baseFilter)
+		if f := clickhouse.QuarantineFilter(targetCols); f != "" {
+			castFilter = "NOT (" + f + ")"
+		}
+	}
+	castMVFilter := mvFilter
+	if castFilter != "" {
+		if baseFilter != "" {
+			castMVFilter = " WHERE " + baseFilter + " AND " + castFilter
+		} else {
+			castMVFilter = " WHERE " + castFilter
+		}
+	}
 	up := []string{
 		fmt.Sprintf("DROP VIEW IF EXISTS %s;", qualifiedDDL(targetDB, mvName)),
 		fmt.Sprintf("DROP TABLE IF EXISTS %s;", qualifiedDDL(targetDB, kafkaTable)),
-		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s) ENGINE = Kafka SETTINGS kafka_broker_list = '%s', kafka_topic_list = '%s', kafka_group_name = '%s', kafka_format = 'JSONEachRow', kafka_num_consumers = %d, kafka_max_block_size = %d, kafka_auto_offset_reset = '%s';", qualifiedDDL(targetDB, kafkaTable), stringCols, kafkaBrokers, topic, group, 1, kafkaMaxBlockSize, kafkaAutoOffsetReset),
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s) %s;", qualifiedDDL(targetDB, kafkaTable), stringCols, engineClause),
 		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s) ENGINE = MergeTree ORDER BY tuple();", qualifiedDDL(targetDB, targetTableName), targetColsDDL),
-		fmt.Sprintf("CREATE MATERIALIZED VIEW IF NOT EXISTS %s TO %s AS SELECT %s FROM %s SETTINGS stream_like_engine_allow_direct_select=1, input_format_skip_unknown_fields=1, date_time_input_format='best_effort';", qualifiedDDL(targetDB, mvName), qualifiedDDL(targetDB, targetTableName), selectExpr, qualifiedDDL(targetDB, kafkaTable)),
+		fmt.Sprintf("CREATE MATERIALIZED VIEW IF NOT EXISTS %s TO %s AS SELECT %s FROM %s%s SETTINGS stream_like_engine_allow_direct_select=1, input_format_skip_unknown_fields=1, date_time_input_format='best_effort';", qualifiedDDL(targetDB, mvName), qualifiedDDL(targetDB, targetTableName), selectExpr, qualifiedDDL(targetDB, kafkaTable), castMVFilter),
 	}
 	down := []string{
 		fmt.Sprintf("DROP VIEW IF EXISTS %s;", qualifiedDDL(targetDB, mvName)),
 		fmt.Sprintf("DROP TABLE IF EXISTS %s;", qualifiedDDL(targetDB, kafkaTable)),
-		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s) ENGINE = Kafka SETTINGS kafka_broker_list = '%s', kafka_topic_list = '%s', kafka_group_name = '%s', kafka_format = 'JSONEachRow', kafka_num_consumers = %d, kafka_max_block_size = %d, kafka_auto_offset_reset = '%s';", qualifiedDDL(targetDB, kafkaTable), typedCols, kafkaBrokers, topic, group, 1, kafkaMaxBlockSize, kafkaAutoOffsetReset),
-		fmt.Sprintf("CREATE MATERIALIZED VIEW IF NOT EXISTS %s TO %s AS SELECT * FROM %s SETTINGS stream_like_engine_allow_direct_select=1, input_format_skip_unknown_fields=1, date_time_input_format='best_effort';", qualifiedDDL(targetDB, mvName), qualifiedDDL(targetDB, targetTableName), qualifiedDDL(targetDB, kafkaTable)),
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s) %s;", qualifiedDDL(targetDB, kafkaTable), typedCols, engineClause),
+		fmt.Sprintf("CREATE MATERIALIZED VIEW IF NOT EXISTS %s TO %s AS SELECT * FROM %s%s SETTINGS stream_like_engine_allow_direct_select=1, input_format_skip_unknown_fields=1, date_time_input_format='best_effort';", qualifiedDDL(targetDB, mvName), qualifiedDDL(targetDB, targetTableName), qualifiedDDL(targetDB, kafkaTable), mvFilter),
+	}
+	if kafkaSettings.ErrorStream() {
+		// 错误表与错误 MV 在 sink 重建之后创建；回滚脚本同样需要重建错误 MV（sink 被删时其依赖 MV 也失效）
+		errView := qualifiedDDL(targetDB, clickhouse.KafkaErrorsViewName(sourceDB, table))
+		up = append([]string{fmt.Sprintf("DROP VIEW IF EXISTS %s;", errView)}, up...)
+		down = append([]string{fmt.Sprintf("DROP VIEW IF EXISTS %s;", errView)}, down...)
+		for _, ddl := range clickhouse.KafkaErrorStreamDDL(targetDB, sourceDB, table) {
+			up = append(up, ddl+";")
+			down = append(down, ddl+";")
+		}
+	}
+	if len(quarantineDDL) > 0 {
+		// 隔离 MV 依赖 sink，重建 sink 前先删除；回滚时同样删除，隔离表保留以便排查
+		qView := fmt.Sprintf("DROP VIEW IF EXISTS %s;", qualifiedDDL(targetDB, clickhouse.QuarantineViewName(sourceDB, table)))
+		up = append([]string{qView}, up...)
+		down = append([]string{qView}, down...)
+		for _, ddl := range quarantineDDL {
+			up = append(up, ddl+";")
+		}
 	}
 	var qualitySQL []string
 	if includeQualitySQL {
@@ -215,10 +345,53 @@ func buildSyncCastDDL(db *sql.DB, sourceDB string, targetDB string, table string
 		"table":        table,
 		"target_table": targetTableName,
 		"type_diffs":   typeDiffs,
+		"cast_mode":    mode,
+	}
+	if len(quarantineDDL) > 0 {
+		detail["quarantine_table"] = targetDB + "." + clickhouse.QuarantineTableName(sourceDB, table)
+	}
+	if kafkaSettings.ErrorStream() {
+		detail["kafka_errors_table"] = targetDB + "." + clickhouse.KafkaErrorsTableName(sourceDB, table)
 	}
 	return detail, strings.Join(up, "\n"), strings.Join(down, "\n"), qualitySQL, nil
 }
 
+// renameDatabasesAll 对一组语句按映射改写库名。
+func renameDatabasesAll(stmts []string, dbMap map[string]string) []string {
+	out := make([]string, len(stmts))
+	for i, q := range stmts {
+		out[i] = clickhouse.RenameDatabases(q, dbMap)
+	}
+	return out
+}
+
+// appendDDLSection 以注释标题输出一组语句，语句为空时不输出。
+func appendDDLSection(content []byte, title string, stmts []string) []byte {
+	if len(stmts) == 0 {
+		return content
+	}
+	content = append(content, []byte("-- "+title+"\n")...)
+	for _, q := range stmts {
+		q = strings.TrimSpace(q)
+		content = append(content, []byte(q)...)
+		if !strings.HasSuffix(q, ";") {
+			content = append(content, ';')
+		}
+		content = append(content, '\n')
+	}
+	return append(content, '\n')
+}
+
+// sinkColumns 返回按 mapper.SinkType 推导出的 sink 列。
+func sinkColumns(cols []clickhouse.Column, mapper *clickhouse.TypeMapper) []clickhouse.Column {
+	out := make([]clickhouse.Column, len(cols))
+	for i, c := range cols {
+		c.Type = mapper.SinkType(c.Type)
+		out[i] = c
+	}
+	return out
+}
+
 func buildColumnsDDL(cols []clickhouse.Column, asString bool) string {
 	var out []string
 	for _, c := range cols {
@@ -3,6 +3,7 @@ package cmd
 
 import (
 	"click-house-sync/internal/clickhouse"
+	"click-house-sync/internal/naming"
 	"os"
 	"sort"
 	"strings"
@@ -58,7 +59,7 @@ var genTablesCmd = &cobra.Command{
 		if output == "" {
 			output = "tables.yaml"
 		}
-		db, err := clickhouse.Connect(chHost, chPort, chUser, chPassword, chDatabase, chSecure)
+		db, err := connectClickHouse(nil, chDatabase)
 		if err != nil {
 			return err
 		}
@@ -85,7 +86,7 @@ var genTablesCmd = &cobra.Command{
 			if len(suffix) > 0 && !hasSuffix(name, suffix) {
 				continue
 			}
-			if hasPrefix(name, "kafka_") || hasPrefix(name, "mv_") {
+			if hasPrefix(name, "kafka_") || hasPrefix(name, "mv_") || naming.IsPipelineObject(name) {
 				continue
 			}
 			names = append(names, name)
@@ -163,7 +164,7 @@ var genTablesCmd = &cobra.Command{
 				Brokers:           brokersList(),
 				RowsPerPartition:  rowsPerPartition,
 				BatchSize:         batchSize,
-				GroupName:         groupName + "-" + n,
+				GroupName:         naming.Group(chDatabase, n, groupName),
 				TargetTable:       n,
 				TargetDatabase:    targetDatabase,
 				CurrentDatabase:   chDatabase,
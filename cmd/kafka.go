@@ -1,9 +1,19 @@
 package cmd
 
 import (
+	"click-house-sync/internal/clickhouse"
+	"click-house-sync/internal/config"
 	kadmin "click-house-sync/internal/kafka"
+	"click-house-sync/internal/naming"
 	"fmt"
+	"os"
+	"os/signal"
 	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
 )
@@ -185,3 +195,375 @@ func init() {
 	kafkaTopicInfoCmd.Flags().String("topic", "", "主题名称（必填）")
 	kafkaTopicMessagesCmd.Flags().String("topic", "", "主题名称（必填）")
 }
+
+// tableStream 汇总单表同步链路在 Kafka 侧使用的主题、消费者组与 Kafka 引擎表位置。
+type tableStream struct {
+	SourceDatabase string
+	KafkaDatabase  string
+	TargetTable    string
+	Topic          string
+	Group          string
+	Brokers        []string
+	Config         *config.Table
+}
+
+// resolveTableStream 按全局参数与 tables.yaml 推导表对应的 topic/group/brokers，规则与 prepare/sync 保持一致。
+func resolveTableStream(cmd *cobra.Command, table string) (*tableStream, error) {
+	tconf, err := lookupTableConfig(table)
+	if err != nil {
+		return nil, err
+	}
+	pf := cmd.Root().PersistentFlags()
+	s := &tableStream{SourceDatabase: chDatabase, KafkaDatabase: chDatabase, TargetTable: table, Config: tconf}
+	if !pf.Changed("ch-database") && tconf != nil && tconf.CurrentDatabase != "" {
+		s.SourceDatabase = tconf.CurrentDatabase
+	}
+	s.KafkaDatabase = targetDatabaseFor(cmd, tconf)
+	if strings.TrimSpace(targetTable) != "" {
+		s.TargetTable = targetTable
+	} else if tconf != nil && strings.TrimSpace(tconf.TargetTable) != "" {
+		s.TargetTable = tconf.TargetTable
+	}
+	s.Topic = naming.Topic(s.SourceDatabase, table)
+	if pf.Changed("kafka-topic") && strings.TrimSpace(kafkaTopic) != "" {
+		s.Topic = kafkaTopic
+	}
+	if pf.Changed("group-name") && strings.TrimSpace(groupName) != "" {
+		s.Group = groupName
+	} else if tconf != nil && strings.TrimSpace(tconf.GroupName) != "" {
+		s.Group = tconf.GroupName
+	} else {
+		s.Group = naming.Group(s.SourceDatabase, table, groupName)
+	}
+	if pf.Changed("kafka-brokers") {
+		s.Brokers = brokersList()
+	} else if tconf != nil && len(tconf.Brokers) > 0 {
+		s.Brokers = tconf.Brokers
+	} else {
+		s.Brokers = brokersList()
+	}
+	return s, nil
+}
+
+// groupTarget 读取 --table/--group/--topic，返回消费者组与主题（显式参数优先于按表推导的值）。
+func groupTarget(cmd *cobra.Command) (string, *tableStream, error) {
+	table, _ := cmd.Flags().GetString("table")
+	group, _ := cmd.Flags().GetString("group")
+	topic, _ := cmd.Flags().GetString("topic")
+	s := &tableStream{Brokers: brokersList()}
+	if strings.TrimSpace(table) != "" {
+		rs, err := resolveTableStream(cmd, table)
+		if err != nil {
+			return "", nil, err
+		}
+		s = rs
+	}
+	if strings.TrimSpace(group) != "" {
+		s.Group = strings.TrimSpace(group)
+	}
+	if strings.TrimSpace(topic) != "" {
+		s.Topic = strings.TrimSpace(topic)
+	}
+	if s.Group == "" {
+		return "", nil, fmt.Errorf("缺少 --group 或 --table")
+	}
+	if s.Topic == "" {
+		return "", nil, fmt.Errorf("缺少 --topic 或 --table")
+	}
+	return table, s, nil
+}
+
+var kafkaGroupLagCmd = &cobra.Command{
+	Use:   "group-lag",
+	Short: "查看消费者组积压",
+	Long:  "按分区输出消费者组的已提交位点、high watermark、积压条数，以及最早未消费消息距今的时间（基于消息时间戳）。",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		table, s, err := groupTarget(cmd)
+		if err != nil {
+			return err
+		}
+		lags, err := kadmin.GroupLag(s.Brokers, s.Group, s.Topic)
+		if err != nil {
+			return err
+		}
+		var total int64
+		var maxSeconds float64
+		for _, l := range lags {
+			total += l.Lag
+			if l.LagSeconds > maxSeconds {
+				maxSeconds = l.LagSeconds
+			}
+		}
+		out := map[string]any{
+			"command":         "kafka group-lag",
+			"brokers":         s.Brokers,
+			"group":           s.Group,
+			"topic":           s.Topic,
+			"partitions":      lags,
+			"total_lag":       total,
+			"max_lag_seconds": maxSeconds,
+		}
+		if table != "" {
+			out["table"] = table
+		}
+		if state, members, err := kadmin.GroupState(s.Brokers, s.Group); err == nil {
+			out["group_state"] = state
+			out["members"] = members
+		}
+		printJSON(out)
+		return nil
+	},
+}
+
+var kafkaGroupResetCmd = &cobra.Command{
+	Use:   "group-reset",
+	Short: "重置消费者组位点",
+	Long:  "将消费者组位点重置到 earliest|latest|timestamp|offset，用于向目标重放数据。指定 --table 时先 DETACH（非永久）Kafka 引擎表以停止消费，等待组内无活跃成员后解析并提交位点，再 ATTACH 恢复消费；重新挂载失败时命令以非零状态退出，收到 SIGINT/SIGTERM 时先重新挂载再退出。",
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		table, s, err := groupTarget(cmd)
+		if err != nil {
+			return err
+		}
+		to, _ := cmd.Flags().GetString("to")
+		tsText, _ := cmd.Flags().GetString("timestamp")
+		offset, _ := cmd.Flags().GetInt64("offset")
+		waitSeconds, _ := cmd.Flags().GetInt("wait-timeout")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		kafkaDBFlag, _ := cmd.Flags().GetString("kafka-database")
+		var at time.Time
+		switch strings.ToLower(strings.TrimSpace(to)) {
+		case "earliest", "latest":
+		case "timestamp":
+			if strings.TrimSpace(tsText) == "" {
+				return fmt.Errorf("--to timestamp 需要 --timestamp")
+			}
+			t, err := parseResetTimestamp(tsText)
+			if err != nil {
+				return err
+			}
+			at = t
+		case "offset":
+			if !cmd.Flags().Changed("offset") {
+				return fmt.Errorf("--to offset 需要 --offset")
+			}
+		default:
+			return fmt.Errorf("--to 仅支持 earliest|latest|timestamp|offset")
+		}
+		out := map[string]any{
+			"command": "kafka group-reset",
+			"brokers": s.Brokers,
+			"group":   s.Group,
+			"topic":   s.Topic,
+			"to":      to,
+		}
+		if dryRun {
+			offsets, err := kadmin.ResolveResetOffsets(s.Brokers, s.Topic, to, at, offset)
+			if err != nil {
+				return err
+			}
+			out["dry_run"] = true
+			out["offsets"] = offsets
+			printJSON(out)
+			return nil
+		}
+		// 指定表时先卸载 Kafka 引擎表停止消费者，保证提交位点时组内无活跃成员
+		sink := ""
+		kafkaDB := s.KafkaDatabase
+		if strings.TrimSpace(kafkaDBFlag) != "" {
+			kafkaDB = strings.TrimSpace(kafkaDBFlag)
+		}
+		if table != "" {
+			db, err := connectClickHouse(nil, chDatabase)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+			sink = naming.Sink(s.SourceDatabase, table)
+			// 非永久卸载：进程意外退出时服务重启也会恢复消费
+			if err := clickhouse.DetachTableTemporarily(db, kafkaDB, sink); err != nil {
+				return err
+			}
+			printJSON(map[string]any{"event": "sink_detached", "kafka_table": kafkaDB + "." + sink})
+			var attachOnce sync.Once
+			var attachErr error
+			attach := func() error {
+				attachOnce.Do(func() {
+					if attachErr = clickhouse.AttachTable(db, kafkaDB, sink); attachErr != nil {
+						printErrJSON(map[string]any{"event": "sink_attach_failed", "kafka_table": kafkaDB + "." + sink, "error": attachErr.Error()})
+						return
+					}
+					printJSON(map[string]any{"event": "sink_attached", "kafka_table": kafkaDB + "." + sink})
+				})
+				return attachErr
+			}
+			defer func() {
+				if aerr := attach(); aerr != nil && err == nil {
+					err = fmt.Errorf("重新挂载 %s.%s 失败: %v", kafkaDB, sink, aerr)
+				}
+			}()
+			// 等待或提交期间被中断时先重新挂载 sink 再退出
+			sigCh := make(chan os.Signal, 1)
+			signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+			defer func() {
+				signal.Stop(sigCh)
+				close(sigCh)
+			}()
+			go func() {
+				if _, ok := <-sigCh; !ok {
+					return
+				}
+				code := 130
+				if attach() != nil {
+					code = 1
+				}
+				os.Exit(code)
+			}()
+		}
+		if err := kadmin.WaitGroupEmpty(s.Brokers, s.Group, time.Duration(waitSeconds)*time.Second); err != nil {
+			return err
+		}
+		// 消费者停止后再解析位点，避免使用仍在运行的消费者已越过的位置
+		offsets, err := kadmin.ResolveResetOffsets(s.Brokers, s.Topic, to, at, offset)
+		if err != nil {
+			return err
+		}
+		res, err := kadmin.ResetGroupOffsets(s.Brokers, s.Group, s.Topic, offsets)
+		if err != nil {
+			return err
+		}
+		out["partitions"] = res
+		if sink != "" {
+			out["kafka_table"] = kafkaDB + "." + sink
+		}
+		printJSON(out)
+		return nil
+	},
+}
+
+// parseResetTimestamp 解析 RFC3339、'YYYY-MM-DD HH:MM:SS' 或毫秒时间戳。
+func parseResetTimestamp(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if ms, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.UnixMilli(ms), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02 15:04:05", s, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("无法解析 --timestamp: %s", s)
+}
+
+func init() {
+	kafkaCmd.AddCommand(kafkaGroupLagCmd)
+	kafkaCmd.AddCommand(kafkaGroupResetCmd)
+	for _, c := range []*cobra.Command{kafkaGroupLagCmd, kafkaGroupResetCmd} {
+		c.Flags().String("table", "", "源表名（按 tables.yaml 推导 topic 与 group）")
+		c.Flags().String("group", "", "消费者组名（优先于 --table 推导值）")
+		c.Flags().String("topic", "", "主题名称（优先于 --table 推导值）")
+	}
+	kafkaGroupResetCmd.Flags().String("to", "", "重置目标 earliest|latest|timestamp|offset（必填）")
+	kafkaGroupResetCmd.Flags().String("timestamp", "", "--to timestamp 时的时间点（RFC3339、'YYYY-MM-DD HH:MM:SS' 或毫秒时间戳）")
+	kafkaGroupResetCmd.Flags().Int64("offset", 0, "--to offset 时各分区的目标位点（超出范围时截断到分区边界）")
+	kafkaGroupResetCmd.Flags().Int("wait-timeout", 60, "等待消费者组无活跃成员的超时秒数")
+	kafkaGroupResetCmd.Flags().String("kafka-database", "", "Kafka 引擎表所在库（默认跟随 target-database）")
+	kafkaGroupResetCmd.Flags().Bool("dry-run", false, "仅计算并输出目标位点，不执行重置")
+}
+
+var kafkaTopicConfigCmd = &cobra.Command{
+	Use:   "topic-config",
+	Short: "比对并调整 Topic 配置",
+	Long:  "按 tables.yaml 的 topic_config 比对已有 Topic 的主题级配置（retention.ms、cleanup.policy、min.insync.replicas、max.message.bytes、compression.type 等），输出不一致的配置项；--apply 时通过 IncrementalAlterConfigs 只修改不一致的配置项，未声明的配置保持不变。不指定 --table/--tables 时处理 tables.yaml 中所有配置了 topic_config 的表。",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		table, _ := cmd.Flags().GetString("table")
+		tablesCSV, _ := cmd.Flags().GetString("tables")
+		apply, _ := cmd.Flags().GetBool("apply")
+		var names []string
+		if strings.TrimSpace(table) != "" {
+			names = []string{table}
+		} else {
+			list, err := loadPlanTables(splitCSV(tablesCSV))
+			if err != nil {
+				return err
+			}
+			explicit := strings.TrimSpace(tablesCSV) != ""
+			for _, t := range list {
+				if explicit || len(t.TopicConfig) > 0 {
+					names = append(names, t.Name)
+				}
+			}
+		}
+		var items []map[string]any
+		drift, reconciled, failed := 0, 0, 0
+		for _, n := range names {
+			s, err := resolveTableStream(cmd, n)
+			if err != nil {
+				return err
+			}
+			item := map[string]any{"table": n, "topic": s.Topic}
+			items = append(items, item)
+			desired, err := topicConfigFor(s.Config)
+			if err != nil {
+				return err
+			}
+			if len(desired) == 0 {
+				item["status"] = "no_config"
+				continue
+			}
+			current, err := kadmin.DescribeTopicConfigs(s.Brokers, s.Topic)
+			if err != nil {
+				if kadmin.IsUnknownTopicOrPartition(err) {
+					item["status"] = "missing_topic"
+					continue
+				}
+				item["status"] = "error"
+				item["error"] = err.Error()
+				failed++
+				continue
+			}
+			diffs := kadmin.DiffTopicConfigs(current, desired)
+			item["diffs"] = diffs
+			if len(diffs) == 0 {
+				item["status"] = "in_sync"
+				continue
+			}
+			drift++
+			item["status"] = "drift"
+			if !apply {
+				continue
+			}
+			changes := map[string]string{}
+			for _, d := range diffs {
+				changes[d.Name] = d.Desired
+			}
+			if err := kadmin.AlterTopicConfigs(s.Brokers, s.Topic, changes); err != nil {
+				item["status"] = "error"
+				item["error"] = err.Error()
+				failed++
+				continue
+			}
+			item["status"] = "reconciled"
+			reconciled++
+		}
+		printJSON(map[string]any{
+			"command":    "kafka topic-config",
+			"apply":      apply,
+			"topics":     items,
+			"drift":      drift,
+			"reconciled": reconciled,
+			"failed":     failed,
+		})
+		if failed > 0 {
+			return fmt.Errorf("%d 个 Topic 的配置读取或调整失败", failed)
+		}
+		return nil
+	},
+}
+
+func init() {
+	kafkaCmd.AddCommand(kafkaTopicConfigCmd)
+	kafkaTopicConfigCmd.Flags().String("table", "", "源表名（按 tables.yaml 推导 topic 与 topic_config）")
+	kafkaTopicConfigCmd.Flags().String("tables", "", "逗号分隔的表名（默认 tables.yaml 中所有配置了 topic_config 的表）")
+	kafkaTopicConfigCmd.Flags().Bool("apply", false, "将不一致的配置项调整为 topic_config 中的值（默认只输出差异）")
+}
@@ -0,0 +1,296 @@
+// cmd 包包含暂停/恢复单表同步链路的 pipeline 命令。
+package cmd
+
+import (
+	"click-house-sync/internal/clickhouse"
+	"click-house-sync/internal/config"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var pipelineCmd = &cobra.Command{
+	Use:   "pipeline",
+	Short: "同步链路暂停/恢复与状态查看",
+}
+
+// pipelineObject 描述一个可被 DETACH/ATTACH 的链路对象及其所在库。
+type pipelineObject struct {
+	Side     string
+	Object   string
+	Database string
+	Name     string
+}
+
+func (o pipelineObject) qualified() string {
+	return o.Database + "." + o.Name
+}
+
+// pipelineObjects 按 --side/--object 返回单表链路需要操作的对象，顺序即暂停顺序（恢复时逆序）。
+// 对象所在库与 sync 一致：推送 MV 与 Kafka 引擎表在 Kafka 库，落库 MV 在目标库。
+func pipelineObjects(n pipelineNames, side string, object string) ([]pipelineObject, error) {
+	side = strings.ToLower(strings.TrimSpace(side))
+	object = strings.ToLower(strings.TrimSpace(object))
+	var out []pipelineObject
+	if side == "source" || side == "both" {
+		out = append(out, pipelineObject{Side: "source", Object: "mv", Database: n.kafkaDB, Name: n.mvToKafka})
+	}
+	if side == "target" || side == "both" {
+		switch object {
+		case "", "mv":
+			out = append(out, pipelineObject{Side: "target", Object: "mv", Database: n.tgtDB, Name: n.mvFromKafka})
+		case "sink":
+			out = append(out, pipelineObject{Side: "target", Object: "sink", Database: n.kafkaDB, Name: n.sink})
+		default:
+			return nil, fmt.Errorf("--object 仅支持 mv|sink")
+		}
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("--side 仅支持 target|source|both")
+	}
+	return out, nil
+}
+
+// pipelineKafkaDatabase 返回链路对象所在库：--kafka-database 优先，否则按表配置推导。
+func pipelineKafkaDatabase(cmd *cobra.Command, table string) (string, error) {
+	if v, _ := cmd.Flags().GetString("kafka-database"); strings.TrimSpace(v) != "" {
+		return strings.TrimSpace(v), nil
+	}
+	s, err := resolveTableStream(cmd, table)
+	if err != nil {
+		return "", err
+	}
+	return s.KafkaDatabase, nil
+}
+
+// pipelineNamesFor 按 tables.yaml 表项与 --kafka-database 解析单表链路对象名与所在库，规则与 sync/teardown 一致。
+func pipelineNamesFor(cmd *cobra.Command, table string) (pipelineNames, error) {
+	tconf, err := lookupTableConfig(table)
+	if err != nil {
+		return pipelineNames{}, err
+	}
+	t := config.Table{Name: table}
+	if tconf != nil {
+		t = *tconf
+	}
+	kafkaDB, _ := cmd.Flags().GetString("kafka-database")
+	return resolvePipelineNames(cmd, t, strings.TrimSpace(kafkaDB)), nil
+}
+
+var pipelinePauseCmd = &cobra.Command{
+	Use:   "pause",
+	Short: "暂停单表同步链路",
+	Long:  "通过 DETACH ... PERMANENTLY 暂停单表链路而不删除对象（服务重启后仍保持卸载）：目标侧卸载落库物化视图（默认 mv_from_kafka_<table>，或 --object sink 卸载 Kafka 引擎表），源侧卸载推送物化视图（默认 mv_to_kafka_<table>）；对象名按 naming 模板解析。暂停状态记录在 ch_sync_pipeline_state，可由 pipeline status 查看。",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		table, _ := cmd.Flags().GetString("table")
+		if strings.TrimSpace(table) == "" {
+			return fmt.Errorf("缺少 --table")
+		}
+		side, _ := cmd.Flags().GetString("side")
+		object, _ := cmd.Flags().GetString("object")
+		reason, _ := cmd.Flags().GetString("reason")
+		n, err := pipelineNamesFor(cmd, table)
+		if err != nil {
+			return err
+		}
+		kafkaDB := n.kafkaDB
+		objs, err := pipelineObjects(n, side, object)
+		if err != nil {
+			return err
+		}
+		db, err := connectClickHouse(nil, chDatabase)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		states, err := clickhouse.GetPipelineStates(db, kafkaDB, n.srcDB, table)
+		if err != nil {
+			return err
+		}
+		paused := map[string]bool{}
+		for _, s := range states {
+			paused[s.Side+"/"+s.Object] = s.State == "paused"
+		}
+		var results []map[string]any
+		for _, o := range objs {
+			exists, err := clickhouse.TableExists(db, o.Database, o.Name)
+			if err != nil {
+				return err
+			}
+			item := map[string]any{"side": o.Side, "object": o.qualified()}
+			if !exists {
+				if !paused[o.Side+"/"+o.Object] {
+					return fmt.Errorf("对象不存在: %s", o.qualified())
+				}
+				item["status"] = "already_paused"
+				results = append(results, item)
+				continue
+			}
+			if err := clickhouse.DetachTable(db, o.Database, o.Name); err != nil {
+				return err
+			}
+			if err := clickhouse.SetPipelineState(db, kafkaDB, clickhouse.PipelineState{SourceDatabase: n.srcDB, Table: table, Side: o.Side, Object: o.Object, State: "paused", Reason: reason}); err != nil {
+				return err
+			}
+			item["status"] = "paused"
+			if o.Side == "source" {
+				item["warning"] = "源侧 MV 暂停期间的新增行不会推送到 Kafka，恢复后需按游标回补"
+			}
+			results = append(results, item)
+		}
+		printJSON(map[string]any{"command": "pipeline pause", "table": table, "kafka_database": kafkaDB, "results": results})
+		return nil
+	},
+}
+
+var pipelineResumeCmd = &cobra.Command{
+	Use:   "resume",
+	Short: "恢复单表同步链路",
+	Long:  "通过 ATTACH 恢复此前被 pipeline pause 卸载的对象，并将状态更新为 running。",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		table, _ := cmd.Flags().GetString("table")
+		if strings.TrimSpace(table) == "" {
+			return fmt.Errorf("缺少 --table")
+		}
+		side, _ := cmd.Flags().GetString("side")
+		object, _ := cmd.Flags().GetString("object")
+		n, err := pipelineNamesFor(cmd, table)
+		if err != nil {
+			return err
+		}
+		kafkaDB := n.kafkaDB
+		objs, err := pipelineObjects(n, side, object)
+		if err != nil {
+			return err
+		}
+		db, err := connectClickHouse(nil, chDatabase)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		var results []map[string]any
+		for i := len(objs) - 1; i >= 0; i-- {
+			o := objs[i]
+			if err := clickhouse.AttachTable(db, o.Database, o.Name); err != nil {
+				return err
+			}
+			if err := clickhouse.SetPipelineState(db, kafkaDB, clickhouse.PipelineState{SourceDatabase: n.srcDB, Table: table, Side: o.Side, Object: o.Object, State: "running"}); err != nil {
+				return err
+			}
+			results = append(results, map[string]any{"side": o.Side, "object": o.qualified(), "status": "running"})
+		}
+		printJSON(map[string]any{"command": "pipeline resume", "table": table, "kafka_database": kafkaDB, "results": results})
+		return nil
+	},
+}
+
+var pipelineStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "查看同步链路状态",
+	Long:  "输出单表或 tables.yaml 中所有表的链路对象（Kafka 引擎表与两侧物化视图，名称按 naming 模板解析）是否挂载，以及暂停记录；状态表记录为 paused 但对象已挂载（例如被手动 ATTACH）时标记为 attached_while_paused 并计入 mismatch_count。",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		table, _ := cmd.Flags().GetString("table")
+		var names []string
+		if strings.TrimSpace(table) != "" {
+			names = []string{strings.TrimSpace(table)}
+		} else {
+			path := tablesFile
+			if strings.TrimSpace(path) == "" {
+				path = "tables.yaml"
+			}
+			tlist, err := config.LoadTablesFile(path)
+			if err != nil {
+				return err
+			}
+			for _, t := range tlist {
+				names = append(names, t.Name)
+			}
+			if len(names) == 0 {
+				return fmt.Errorf("缺少 --table 且 tables_file 无表项")
+			}
+		}
+		db, err := connectClickHouse(nil, chDatabase)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		var items []map[string]any
+		pausedCount := 0
+		mismatchCount := 0
+		for _, n := range names {
+			pn, err := pipelineNamesFor(cmd, n)
+			if err != nil {
+				return err
+			}
+			kafkaDB := pn.kafkaDB
+			states, err := clickhouse.GetPipelineStates(db, kafkaDB, pn.srcDB, n)
+			if err != nil {
+				return err
+			}
+			byKey := map[string]clickhouse.PipelineState{}
+			for _, s := range states {
+				byKey[s.Side+"/"+s.Object] = s
+			}
+			objs := []pipelineObject{
+				{Side: "source", Object: "mv", Database: pn.kafkaDB, Name: pn.mvToKafka},
+				{Side: "target", Object: "sink", Database: pn.kafkaDB, Name: pn.sink},
+				{Side: "target", Object: "mv", Database: pn.tgtDB, Name: pn.mvFromKafka},
+			}
+			paused := false
+			var objItems []map[string]any
+			for _, o := range objs {
+				exists, err := clickhouse.TableExists(db, o.Database, o.Name)
+				if err != nil {
+					return err
+				}
+				st := "missing"
+				if exists {
+					st = "attached"
+				}
+				item := map[string]any{"side": o.Side, "object": o.qualified(), "status": st}
+				if s, ok := byKey[o.Side+"/"+o.Object]; ok {
+					item["state"] = s.State
+					item["updated_at"] = s.UpdatedAt
+					if s.Reason != "" {
+						item["reason"] = s.Reason
+					}
+					if s.State == "paused" {
+						paused = true
+						if !exists {
+							item["status"] = "detached"
+						} else {
+							// 记录为暂停但对象仍在消费/推送，状态表与实际不一致
+							item["status"] = "attached_while_paused"
+							mismatchCount++
+						}
+					}
+				}
+				objItems = append(objItems, item)
+			}
+			if paused {
+				pausedCount++
+			}
+			items = append(items, map[string]any{"table": n, "source": pn.srcDB + "." + n, "kafka_database": kafkaDB, "paused": paused, "objects": objItems})
+		}
+		printJSON(map[string]any{"command": "pipeline status", "tables": items, "total": len(items), "paused_count": pausedCount, "mismatch_count": mismatchCount})
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(pipelineCmd)
+	pipelineCmd.AddCommand(pipelinePauseCmd)
+	pipelineCmd.AddCommand(pipelineResumeCmd)
+	pipelineCmd.AddCommand(pipelineStatusCmd)
+	for _, c := range []*cobra.Command{pipelinePauseCmd, pipelineResumeCmd} {
+		c.Flags().String("table", "", "源表名（必填）")
+		c.Flags().String("side", "target", "操作侧 target|source|both")
+		c.Flags().String("object", "mv", "目标侧卸载对象 mv|sink（mv 保留 Kafka 位点不消费，sink 停止 Kafka 消费者）")
+	}
+	pipelinePauseCmd.Flags().String("reason", "", "暂停原因（记录到状态表）")
+	for _, c := range []*cobra.Command{pipelinePauseCmd, pipelineResumeCmd, pipelineStatusCmd} {
+		c.Flags().String("kafka-database", "", "Kafka 引擎表与 MV 所在库（默认跟随 target-database）")
+	}
+	pipelineStatusCmd.Flags().String("table", "", "源表名（为空时读取 tables.yaml 全部表）")
+}
@@ -0,0 +1,561 @@
+// cmd 包包含计算链路创建计划的 plan 命令。
+package cmd
+
+import (
+	"click-house-sync/internal/clickhouse"
+	"click-house-sync/internal/config"
+	kadmin "click-house-sync/internal/kafka"
+	"click-house-sync/internal/naming"
+	"click-house-sync/internal/plan"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// planCmd 计算 tables.yaml 中各表链路的期望对象，与现状对比后写入计划文件，由 apply 执行。
+var planCmd = &cobra.Command{
+	Use:   "plan",
+	Short: "计算链路对象的创建计划",
+	Long:  "按 tables.yaml 计算每张表链路所需的 Topic（分区与副本）、Kafka 引擎表、错误流、目标表与物化视图，与 ClickHouse/Kafka 现状对比后写入计划文件（含精确 SQL 与 Topic 操作）。计划经审阅后使用 apply 执行；已存在的目标表按源表生成 ALTER，其他已存在但不一致的对象标记为 drift，不自动处理。",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		output, _ := cmd.Flags().GetString("output")
+		tablesCSV, _ := cmd.Flags().GetString("tables")
+		sourceMVToKafka, _ := cmd.Flags().GetBool("source-mv-to-kafka")
+		kafkaDatabaseFlag, _ := cmd.Flags().GetString("kafka-database")
+		tables, err := loadPlanTables(splitCSV(tablesCSV))
+		if err != nil {
+			return err
+		}
+		p, err := buildPlan(cmd, tables, planOptions{
+			queryable:       queryableMV,
+			sourceMVToKafka: sourceMVToKafka,
+			kafkaDatabase:   strings.TrimSpace(kafkaDatabaseFlag),
+		})
+		if err != nil {
+			return err
+		}
+		if err := plan.Save(output, p); err != nil {
+			return err
+		}
+		printJSON(map[string]any{
+			"command": "plan",
+			"output":  output,
+			"tables":  len(p.Tables),
+			"summary": p.Counts(),
+			"changes": planChanges(p),
+		})
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(planCmd)
+	planCmd.Flags().String("output", "ch-sync.plan.json", "计划文件路径")
+	planCmd.Flags().String("tables", "", "仅处理指定表（逗号分隔），默认 tables.yaml 中全部表")
+	planCmd.Flags().Bool("source-mv-to-kafka", false, "计划在源库创建 mv_to_kafka_<table>，不创建目标侧对象（同 sync --source-mv-to-kafka）")
+	planCmd.Flags().String("kafka-database", "", "Kafka 引擎表与 MV 所在库（默认跟随 target-database）")
+}
+
+// planOptions 决定计划包含的对象，对应 sync/prepare/auto 的建链分支。
+type planOptions struct {
+	queryable       bool   // 目标侧为自带存储的查询型 MV（--queryable-mv）
+	sourceMVToKafka bool   // 源库创建推送物化视图（naming.mv_to_kafka），不创建目标侧对象
+	withTarget      bool   // 查询型 MV 之外仍创建目标表（prepare 的行为）
+	kafkaDatabase   string // Kafka 引擎表与 MV 所在库，为空时跟随目标库
+}
+
+// loadPlanTables 读取 tables.yaml，names 非空时只保留指定表。
+func loadPlanTables(names []string) ([]config.Table, error) {
+	if tablesFile == "" {
+		tablesFile = "tables.yaml"
+	}
+	tlist, err := config.LoadTablesFile(tablesFile)
+	if err != nil {
+		return nil, err
+	}
+	var out []config.Table
+	if len(names) > 0 {
+		for _, n := range names {
+			for _, t := range tlist {
+				if t.Name == n {
+					out = append(out, t)
+				}
+			}
+		}
+	} else {
+		out = tlist
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("tables_file 无表项或未匹配到指定表")
+	}
+	return out, nil
+}
+
+// buildPlan 先检查命名冲突，再为每张表计算计划；表级 clickhouse 配置覆盖连接选项时为该表单独建立连接。
+func buildPlan(cmd *cobra.Command, tables []config.Table, opts planOptions) (*plan.Plan, error) {
+	if err := checkNameCollisions(cmd, tables, opts); err != nil {
+		return nil, err
+	}
+	db, err := connectClickHouse(nil, chDatabase)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	p := &plan.Plan{Version: plan.Version, CreatedAt: time.Now().Format(time.RFC3339)}
+	for _, t := range tables {
+		tdb := db
+		if t.ClickHouse != nil {
+			c, err := connectClickHouse(&t, chDatabase)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", t.Name, err)
+			}
+			tdb = c
+		}
+		tp, err := buildTablePlan(cmd, tdb, t, opts)
+		if tdb != db {
+			tdb.Close()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", t.Name, err)
+		}
+		p.Tables = append(p.Tables, tp)
+	}
+	return p, nil
+}
+
+// planChanges 汇总计划中需要执行或需要关注的步骤（不含 SQL，完整内容见计划文件）。
+func planChanges(p *plan.Plan) []map[string]any {
+	out := []map[string]any{}
+	for _, t := range p.Tables {
+		for _, s := range t.Steps {
+			if s.Action == plan.ActionNoop {
+				continue
+			}
+			m := map[string]any{"table": t.Table, "kind": s.Kind, "object": s.Object, "action": s.Action}
+			if s.Reason != "" {
+				m["reason"] = s.Reason
+			}
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// pipelineNames 是单表链路各对象所在的库、对象名与 Topic，解析规则与 sync 一致；对象名按命名模板生成。
+type pipelineNames struct {
+	srcDB       string
+	tgtDB       string
+	tgtTable    string
+	kafkaDB     string
+	topic       string
+	group       string
+	sink        string
+	mvFromKafka string
+	mvToKafka   string
+	brokers     []string
+}
+
+// resolvePipelineNames 按命令行参数、tables.yaml 表项与默认规则解析单表链路的对象位置；kafkaDatabase 为空时跟随目标库。
+func resolvePipelineNames(cmd *cobra.Command, t config.Table, kafkaDatabase string) pipelineNames {
+	flags := cmd.Root().PersistentFlags()
+	var n pipelineNames
+	n.srcDB = chDatabase
+	if !flags.Changed("ch-database") && t.CurrentDatabase != "" {
+		n.srcDB = t.CurrentDatabase
+	}
+	n.tgtDB = targetDatabaseFor(cmd, &t)
+	n.tgtTable = targetTable
+	if strings.TrimSpace(n.tgtTable) == "" {
+		if strings.TrimSpace(t.TargetTable) != "" {
+			n.tgtTable = t.TargetTable
+		} else {
+			n.tgtTable = t.Name
+		}
+	}
+	n.topic = naming.Topic(n.srcDB, t.Name)
+	if flags.Changed("kafka-topic") && strings.TrimSpace(kafkaTopic) != "" {
+		n.topic = kafkaTopic
+	}
+	n.brokers = brokersList()
+	if !flags.Changed("kafka-brokers") && len(t.Brokers) > 0 {
+		n.brokers = t.Brokers
+	}
+	if flags.Changed("group-name") && strings.TrimSpace(groupName) != "" {
+		n.group = groupName
+	} else if strings.TrimSpace(t.GroupName) != "" {
+		n.group = t.GroupName
+	} else {
+		n.group = naming.Group(n.srcDB, t.Name, groupName)
+	}
+	n.kafkaDB = n.tgtDB
+	if kafkaDatabase != "" {
+		n.kafkaDB = kafkaDatabase
+	}
+	n.sink = naming.Sink(n.srcDB, t.Name)
+	n.mvFromKafka = naming.MVFromKafka(n.srcDB, t.Name)
+	n.mvToKafka = naming.MVToKafka(n.srcDB, t.Name)
+	return n
+}
+
+// checkNameCollisions 在计划阶段检查各表解析出的 Topic 名是否合法，以及不同源表的 Topic 与 ClickHouse 对象
+// （含启用时的错误流与隔离对象）是否重名（例如两个源库的同名表共用 Kafka 库而命名模板不含 {db}）。
+func checkNameCollisions(cmd *cobra.Command, tables []config.Table, opts planOptions) error {
+	owner := map[string]string{}
+	var conflicts []string
+	claim := func(key string, source string) {
+		if prev, ok := owner[key]; ok && prev != source {
+			conflicts = append(conflicts, fmt.Sprintf("%s（%s 与 %s）", key, prev, source))
+			return
+		}
+		owner[key] = source
+	}
+	for _, t := range tables {
+		n := resolvePipelineNames(cmd, t, opts.kafkaDatabase)
+		if err := naming.ValidateTopic(n.topic); err != nil {
+			return fmt.Errorf("%s: %v", t.Name, err)
+		}
+		source := n.srcDB + "." + t.Name
+		claim("Topic "+n.topic, source)
+		claim("对象 "+n.kafkaDB+"."+n.sink, source)
+		if kafkaSettingsFor(&t).ErrorStream() {
+			claim("对象 "+n.kafkaDB+"."+clickhouse.KafkaErrorsTableName(n.srcDB, t.Name), source)
+			claim("对象 "+n.kafkaDB+"."+clickhouse.KafkaErrorsViewName(n.srcDB, t.Name), source)
+		}
+		if mode, err := castModeFor(&t); err == nil && mode == clickhouse.CastModeQuarantine {
+			claim("对象 "+n.tgtDB+"."+clickhouse.QuarantineTableName(n.srcDB, t.Name), source)
+			claim("对象 "+n.tgtDB+"."+clickhouse.QuarantineViewName(n.srcDB, t.Name), source)
+		}
+		if opts.sourceMVToKafka {
+			claim("对象 "+n.kafkaDB+"."+n.mvToKafka, source)
+			continue
+		}
+		claim("对象 "+n.tgtDB+"."+n.mvFromKafka, source)
+		if !opts.queryable || opts.withTarget {
+			claim("对象 "+n.tgtDB+"."+n.tgtTable, source)
+		}
+	}
+	if len(conflicts) > 0 {
+		return fmt.Errorf("链路对象命名冲突，请调整 naming 模板或表级配置: %s", strings.Join(conflicts, "; "))
+	}
+	return nil
+}
+
+// buildTablePlan 计算单表链路的期望对象并与现状对比；参数解析规则与 sync 一致。
+func buildTablePlan(cmd *cobra.Command, db *sql.DB, t config.Table, opts planOptions) (plan.TablePlan, error) {
+	names := resolvePipelineNames(cmd, t, opts.kafkaDatabase)
+	srcDB, tgtDB, tgtTable, kafkaDB := names.srcDB, names.tgtDB, names.tgtTable, names.kafkaDB
+	topic, group, brokers := names.topic, names.group, names.brokers
+	rowsPer := t.RowsPerPartition
+	if rowsPer <= 0 {
+		rowsPer = rowsPerPartition
+	}
+	tp := plan.TablePlan{Table: t.Name, Source: srcDB + "." + t.Name}
+
+	sourceCols, err := clickhouse.GetColumns(db, srcDB, t.Name)
+	if err != nil {
+		return tp, err
+	}
+	if len(sourceCols) == 0 {
+		return tp, fmt.Errorf("source table has no columns: %s.%s", srcDB, t.Name)
+	}
+	n, err := clickhouse.CountTableRows(db, srcDB, t.Name)
+	if err != nil {
+		return tp, err
+	}
+	mapper, err := typeMapperFor(&t)
+	if err != nil {
+		return tp, err
+	}
+	layout, err := targetLayoutFor(&t)
+	if err != nil {
+		return tp, err
+	}
+	settings := kafkaSettingsFor(&t)
+
+	// 查询型 MV 的引擎参数与 sink 附加列，规则同 sync
+	eng := mvEngine
+	if strings.TrimSpace(t.MVEngine) != "" {
+		eng = t.MVEngine
+	}
+	mvOrd := mvOrderBy
+	if strings.TrimSpace(t.MVOrderBy) != "" {
+		mvOrd = t.MVOrderBy
+	}
+	mvPart := mvPartitionBy
+	if strings.TrimSpace(t.MVPartitionBy) != "" {
+		mvPart = t.MVPartitionBy
+	}
+	verCol := versionColumn
+	if strings.TrimSpace(t.VersionColumn) != "" {
+		verCol = t.VersionColumn
+	}
+	sCol := signColumn
+	if strings.TrimSpace(t.SignColumn) != "" {
+		sCol = t.SignColumn
+	}
+	ownMV := opts.queryable && !opts.sourceMVToKafka
+	if layout == clickhouse.TargetLayoutMirror && ownMV {
+		if err := applyMirrorMVLayout(cmd, db, srcDB, t.Name, &t, &eng, &mvOrd, &mvPart, &verCol, &sCol); err != nil {
+			return tp, err
+		}
+	}
+	if strings.ToLower(strings.TrimSpace(eng)) == "replacing" && strings.TrimSpace(verCol) == "" {
+		verCol = "version"
+	}
+	extras := map[string]string{}
+	switch strings.ToLower(strings.TrimSpace(eng)) {
+	case "replacing":
+		if strings.TrimSpace(verCol) != "" {
+			extras[verCol] = "UInt64"
+		}
+	case "collapsing":
+		if strings.TrimSpace(sCol) != "" {
+			extras[sCol] = "Int8"
+		}
+	case "versioned_collapsing":
+		if strings.TrimSpace(sCol) != "" {
+			extras[sCol] = "Int8"
+		} else {
+			extras["sign"] = "Int8"
+		}
+		if strings.TrimSpace(verCol) != "" {
+			extras[verCol] = "UInt64"
+		} else {
+			extras["version"] = "UInt64"
+		}
+	}
+	sinkCols := clickhouse.SinkColumnsFromSource(sourceCols, extras, mapper)
+
+	// 库
+	dbs := []string{kafkaDB}
+	if !opts.sourceMVToKafka && tgtDB != kafkaDB {
+		dbs = append(dbs, tgtDB)
+	}
+	for _, name := range dbs {
+		ok, err := clickhouse.DatabaseExists(db, name)
+		if err != nil {
+			return tp, err
+		}
+		step := plan.Step{Kind: plan.KindDatabase, Object: name, Action: plan.ActionNoop}
+		if !ok {
+			step.Action = plan.ActionCreate
+			step.SQL = []string{fmt.Sprintf("CREATE DATABASE IF NOT EXISTS %s", quoteIdent(name))}
+		}
+		tp.Steps = append(tp.Steps, step)
+	}
+
+	// Topic：分区数按源表行数估算；已存在的 Topic 不调整分区，主题配置与 tables.yaml 的 topic_config 不一致时调整
+	topicConfigs, err := topicConfigFor(&t)
+	if err != nil {
+		return tp, err
+	}
+	partitions := clickhouse.PartitionsForRows(n, rowsPer)
+	topicStep := plan.Step{
+		Kind:   plan.KindTopic,
+		Object: topic,
+		Action: plan.ActionNoop,
+		Topic:  &plan.Topic{Name: topic, Brokers: brokers, Partitions: partitions, ReplicationFactor: replicationFactor, Configs: topicConfigs},
+	}
+	parts, err := kadmin.ReadTopicPartitions(brokers, topic)
+	switch {
+	case err != nil && !kadmin.IsUnknownTopicOrPartition(err):
+		return tp, err
+	case err != nil || len(parts) == 0:
+		topicStep.Action = plan.ActionCreate
+	default:
+		var reasons []string
+		if len(parts) != partitions {
+			topicStep.Action = plan.ActionDrift
+			reasons = append(reasons, fmt.Sprintf("已有 %d 个分区，按行数估算为 %d；分区数不会自动调整", len(parts), partitions))
+		}
+		if len(topicConfigs) > 0 {
+			current, err := kadmin.DescribeTopicConfigs(brokers, topic)
+			if err != nil {
+				return tp, err
+			}
+			if diffs := kadmin.DiffTopicConfigs(current, topicConfigs); len(diffs) > 0 {
+				topicStep.Action = plan.ActionAlter
+				var names []string
+				for _, d := range diffs {
+					names = append(names, d.Name)
+				}
+				reasons = append(reasons, "主题配置不一致: "+strings.Join(names, ", "))
+			}
+		}
+		topicStep.Reason = strings.Join(reasons, "；")
+	}
+	tp.Steps = append(tp.Steps, topicStep)
+
+	// Kafka 引擎表：已存在但列与源表不一致时需用 evolve 重建
+	sinkName := names.sink
+	sinkStep := plan.Step{Kind: plan.KindSink, Object: kafkaDB + "." + sinkName, Action: plan.ActionNoop}
+	ok, err := clickhouse.TableExists(db, kafkaDB, sinkName)
+	if err != nil {
+		return tp, err
+	}
+	if ok {
+		live, err := clickhouse.GetColumns(db, kafkaDB, sinkName)
+		if err != nil {
+			return tp, err
+		}
+		if changes := clickhouse.DiffSinkColumns(sourceCols, live, mapper); len(changes) > 0 {
+			sinkStep.Action = plan.ActionDrift
+			sinkStep.Reason = fmt.Sprintf("%d 列与源表不一致，使用 evolve 重建 sink", len(changes))
+		}
+	} else {
+		sinkStep.Action = plan.ActionCreate
+		sinkStep.SQL = []string{clickhouse.KafkaSinkDDL(kafkaDB, srcDB, t.Name, sinkCols, brokers, topic, group, settings)}
+		sinkStep.FallbackSQL = clickhouse.KafkaSinkFallbackDDL(kafkaDB, srcDB, t.Name, sinkCols, brokers, topic, group, settings)
+	}
+	tp.Steps = append(tp.Steps, sinkStep)
+
+	errorStream := settings.ErrorStream()
+	if errorStream {
+		step := plan.Step{Kind: plan.KindErrorStream, Object: kafkaDB + "." + clickhouse.KafkaErrorsTableName(srcDB, t.Name), Action: plan.ActionNoop}
+		ok, err := clickhouse.TableExists(db, kafkaDB, clickhouse.KafkaErrorsViewName(srcDB, t.Name))
+		if err != nil {
+			return tp, err
+		}
+		if !ok {
+			step.Action = plan.ActionCreate
+			step.SQL = clickhouse.KafkaErrorStreamDDL(kafkaDB, srcDB, t.Name)
+		}
+		tp.Steps = append(tp.Steps, step)
+	}
+
+	if opts.sourceMVToKafka {
+		step, err := planViewStep(db, plan.KindMVToKafka, kafkaDB, names.mvToKafka,
+			clickhouse.MaterializedViewToKafkaDDL(srcDB, t.Name, kafkaDB, sinkCols))
+		if err != nil {
+			return tp, err
+		}
+		tp.Steps = append(tp.Steps, step)
+		return tp, nil
+	}
+	if !ownMV || opts.withTarget {
+		step, err := planTargetStep(db, layout, srcDB, t.Name, tgtDB, tgtTable, sourceCols, mapper)
+		if err != nil {
+			return tp, err
+		}
+		tp.Steps = append(tp.Steps, step)
+	}
+	var mvDDL, mvFallback string
+	if ownMV {
+		if strings.TrimSpace(mvOrd) == "" {
+			if sk, _, err := clickhouse.GetTableKeys(db, srcDB, t.Name); err == nil {
+				mvOrd = sk
+			}
+		}
+		td := mvTTLDays
+		if t.MVTTLDays > 0 {
+			td = t.MVTTLDays
+		}
+		tc := mvTTLColumn
+		if strings.TrimSpace(t.MVTTLColumn) != "" {
+			tc = t.MVTTLColumn
+		}
+		spec := clickhouse.MVOwnSpec{
+			MVLayout:                    clickhouse.MVLayout{Engine: eng, OrderBy: mvOrd, PartitionBy: mvPart, VersionColumn: verCol, SignColumn: sCol},
+			TTLDays:                     td,
+			TTLColumn:                   tc,
+			MaxPartitionsPerInsertBlock: mvMaxPartitionsPerInsertBlock,
+		}
+		mvDDL = clickhouse.MaterializedViewOwnDDL(kafkaDB, srcDB, t.Name, tgtDB, sinkCols, errorStream, spec)
+		mvFallback = clickhouse.MaterializedViewOwnFallbackDDL(kafkaDB, srcDB, t.Name, tgtDB, sinkCols, errorStream, spec)
+	} else {
+		mvDDL = clickhouse.MaterializedViewDDL(kafkaDB, srcDB, t.Name, tgtDB, tgtTable, sinkCols, errorStream)
+	}
+	step, err := planViewStep(db, plan.KindMV, tgtDB, names.mvFromKafka, mvDDL)
+	if err != nil {
+		return tp, err
+	}
+	if step.Action == plan.ActionCreate && mvFallback != "" {
+		step.FallbackSQL = []string{mvFallback}
+	}
+	tp.Steps = append(tp.Steps, step)
+	return tp, nil
+}
+
+// planViewStep 返回物化视图步骤：不存在时创建，已存在时不做处理。
+func planViewStep(db *sql.DB, kind string, database string, name string, ddl string) (plan.Step, error) {
+	step := plan.Step{Kind: kind, Object: database + "." + name, Action: plan.ActionNoop}
+	ok, err := clickhouse.TableExists(db, database, name)
+	if err != nil {
+		return step, err
+	}
+	if !ok {
+		step.Action = plan.ActionCreate
+		step.SQL = []string{ddl}
+	}
+	return step, nil
+}
+
+// planTargetStep 返回目标表步骤：不存在时按布局建表，已存在时生成对齐源表的 ADD/MODIFY COLUMN。
+func planTargetStep(db *sql.DB, layout string, srcDB string, table string, tgtDB string, tgtTable string, sourceCols []clickhouse.Column, mapper *clickhouse.TypeMapper) (plan.Step, error) {
+	step := plan.Step{Kind: plan.KindTarget, Object: tgtDB + "." + tgtTable, Action: plan.ActionNoop}
+	ok, err := clickhouse.TableExists(db, tgtDB, tgtTable)
+	if err != nil {
+		return step, err
+	}
+	if ok {
+		live, err := clickhouse.GetColumns(db, tgtDB, tgtTable)
+		if err != nil {
+			return step, err
+		}
+		changes := clickhouse.DiffTargetColumns(sourceCols, live, mapper)
+		var lossy []string
+		for _, c := range changes {
+			if c.Lossy() {
+				lossy = append(lossy, fmt.Sprintf("%s: %s -> %s (%s)", c.Column, c.FromType, c.ToType, c.Class))
+			}
+		}
+		if len(lossy) > 0 {
+			// 有损的类型变更不由 apply 自动执行，与 evolve 的 --allow-lossy 保护一致
+			step.Action = plan.ActionDrift
+			step.Reason = "目标列变更有损，需 evolve --allow-lossy 或人工处理: " + strings.Join(lossy, ", ")
+			return step, nil
+		}
+		if len(changes) > 0 {
+			step.Action = plan.ActionAlter
+			step.Reason = fmt.Sprintf("%d 列与源表不一致", len(changes))
+			for _, c := range changes {
+				step.SQL = append(step.SQL, fmt.Sprintf("ALTER TABLE %s %s", qualified(tgtDB, tgtTable), c.SQL()))
+			}
+		}
+		return step, nil
+	}
+	step.Action = plan.ActionCreate
+	if layout == clickhouse.TargetLayoutMirror {
+		s, err := clickhouse.GetTableSchema(db, srcDB, table)
+		if err != nil {
+			return step, err
+		}
+		ddl, err := clickhouse.MirrorTableDDL(s, srcDB, table, tgtDB, tgtTable, mapper)
+		if err != nil {
+			return step, err
+		}
+		step.SQL = []string{ddl}
+	} else {
+		step.SQL = []string{clickhouse.TargetTableDDL(sourceCols, tgtDB, tgtTable, "tuple()", "", mapper)}
+		step.FallbackSQL = []string{clickhouse.TargetTableFallbackDDL(sourceCols, tgtDB, tgtTable, "tuple()", "", mapper)}
+	}
+	return step, nil
+}
+
+// printDryRun 输出命令将要执行的计划而不做任何变更，供 prepare/sync/auto 的 --dry-run 使用。
+func printDryRun(cmd *cobra.Command, command string, tables []config.Table, opts planOptions) error {
+	p, err := buildPlan(cmd, tables, opts)
+	if err != nil {
+		return err
+	}
+	printJSON(map[string]any{
+		"command": command,
+		"dry_run": true,
+		"summary": p.Counts(),
+		"plan":    p,
+	})
+	return nil
+}
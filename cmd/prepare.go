@@ -3,7 +3,9 @@ package cmd
 
 import (
 	"click-house-sync/internal/clickhouse"
+	"click-house-sync/internal/config"
 	kadmin "click-house-sync/internal/kafka"
+	"click-house-sync/internal/naming"
 	"fmt"
 	"strings"
 
@@ -21,33 +23,37 @@ var prepareCmd = &cobra.Command{
 		if table == "" {
 			return fmt.Errorf("缺少 --table")
 		}
-		// 连接 ClickHouse
-		db, err := clickhouse.Connect(chHost, chPort, chUser, chPassword, chDatabase, chSecure)
+		// 查询表级配置（current/target database、brokers、group 等）
+		tconf, err := lookupTableConfig(table)
 		if err != nil {
 			return err
 		}
-		defer db.Close()
-		// 查询表级配置（current/target database、brokers、group 等）
-		tconf, err := lookupTableConfig(table)
+		if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+			t := config.Table{Name: table}
+			if tconf != nil {
+				t = *tconf
+			}
+			return printDryRun(cmd, "prepare", []config.Table{t}, planOptions{queryable: queryableMV, withTarget: true})
+		}
+		// 连接 ClickHouse（表级 clickhouse 配置可覆盖连接选项）
+		db, err := connectClickHouse(tconf, chDatabase)
 		if err != nil {
 			return err
 		}
+		defer db.Close()
 		// 确认源库（默认全局 ch-database，或取表级 current_database）
 		srcDB := chDatabase
 		if !cmd.Root().PersistentFlags().Changed("ch-database") && tconf != nil && tconf.CurrentDatabase != "" {
 			srcDB = tconf.CurrentDatabase
 		}
-		// 生成默认 topic 名：<srcDB>_<table>
+		// 生成默认 topic 名：按 naming.topic 模板（默认 <srcDB>_<table>）
 		if kafkaTopic == "" {
-			kafkaTopic = srcDB + "_" + table
+			kafkaTopic = naming.Topic(srcDB, table)
 		}
-		if targetDatabase == "" {
-			if tconf != nil && tconf.TargetDatabase != "" {
-				targetDatabase = tconf.TargetDatabase
-			} else {
-				targetDatabase = chDatabase
-			}
+		if err := naming.ValidateTopic(kafkaTopic); err != nil {
+			return err
 		}
+		targetDatabase = targetDatabaseFor(cmd, tconf)
 		tgtTable := targetTable
 		if strings.TrimSpace(tgtTable) == "" {
 			if tconf != nil && strings.TrimSpace(tconf.TargetTable) != "" {
@@ -77,9 +83,15 @@ var prepareCmd = &cobra.Command{
 		// 创建 Kafka Topic
 		recreateTopic, _ := cmd.Flags().GetBool("recreate-topic")
 		if recreateTopic {
-			_ = kadmin.DeleteTopic(brokers, kafkaTopic)
+			if err := kadmin.DeleteTopic(brokers, kafkaTopic); err != nil && !kadmin.IsUnknownTopicOrPartition(err) {
+				return err
+			}
+		}
+		topicConfigs, err := topicConfigFor(tconf)
+		if err != nil {
+			return err
 		}
-		if err := kadmin.CreateTopic(brokers, kafkaTopic, p, replicationFactor); err != nil {
+		if err := kadmin.CreateTopicWithConfigs(brokers, kafkaTopic, p, replicationFactor, topicConfigs); err != nil {
 			return err
 		}
 		var group string
@@ -88,7 +100,7 @@ var prepareCmd = &cobra.Command{
 		} else if tconf != nil && strings.TrimSpace(tconf.GroupName) != "" {
 			group = tconf.GroupName
 		} else {
-			group = groupName + "-" + table
+			group = naming.Group(srcDB, table, groupName)
 		}
 		// Kafka 引擎表与推送型 MV 保持与源库一致，确保跨库不引发不稳定行为
 		kafkaDB := targetDatabase
@@ -117,6 +129,15 @@ var prepareCmd = &cobra.Command{
 		if tconf != nil && strings.TrimSpace(tconf.SignColumn) != "" {
 			sCol = tconf.SignColumn
 		}
+		layout, err := targetLayoutFor(tconf)
+		if err != nil {
+			return err
+		}
+		if layout == clickhouse.TargetLayoutMirror && queryableMV {
+			if err := applyMirrorMVLayout(cmd, db, srcDB, table, tconf, &eng, &mvOrd, &mvPart, &verCol, &sCol); err != nil {
+				return err
+			}
+		}
 		extras := map[string]string{}
 		switch strings.ToLower(strings.TrimSpace(eng)) {
 		case "replacing":
@@ -139,10 +160,14 @@ var prepareCmd = &cobra.Command{
 				extras["version"] = "UInt64"
 			}
 		}
-		if err := clickhouse.CreateKafkaTableFromSource(db, srcDB, table, kafkaDB, brokers, kafkaTopic, group, "JSONEachRow", 1, kafkaMaxBlockSize, kafkaAutoOffsetReset, extras); err != nil {
+		mapper, err := typeMapperFor(tconf)
+		if err != nil {
+			return err
+		}
+		if err := clickhouse.CreateKafkaTableFromSource(db, srcDB, table, kafkaDB, brokers, kafkaTopic, group, kafkaSettingsFor(tconf), extras, mapper); err != nil {
 			return err
 		}
-		if err := clickhouse.CreateTargetTableLikeSource(db, srcDB, table, targetDatabase, tgtTable, "tuple()", ""); err != nil {
+		if err := createTargetTable(db, layout, srcDB, table, targetDatabase, tgtTable, "tuple()", "", mapper); err != nil {
 			return err
 		}
 		sourceCols, err := clickhouse.GetColumns(db, srcDB, table)
@@ -176,14 +201,15 @@ var prepareCmd = &cobra.Command{
 				"partitions":         p,
 				"replication_factor": replicationFactor,
 				"group":              group,
-				"kafka_table":        strings.Join([]string{kafkaDB, "kafka_" + table + "_sink"}, "."),
-				"materialized_view":  strings.Join([]string{targetDatabase, "mv_from_kafka_" + table}, "."),
+				"kafka_table":        strings.Join([]string{kafkaDB, naming.Sink(srcDB, table)}, "."),
+				"materialized_view":  strings.Join([]string{targetDatabase, naming.MVFromKafka(srcDB, table)}, "."),
 				"target_table":       strings.Join([]string{targetDatabase, tgtTable}, "."),
+				"target_layout":      layout,
 				"type_diffs":         typeDiffs,
 				"source":             strings.Join([]string{srcDB, table}, "."),
 			})
 		} else {
-			if err := clickhouse.CreateMaterializedView(db, kafkaDB, table, targetDatabase, tgtTable); err != nil {
+			if err := clickhouse.CreateMaterializedView(db, kafkaDB, srcDB, table, targetDatabase, tgtTable); err != nil {
 				return err
 			}
 			printJSON(map[string]any{
@@ -194,9 +220,10 @@ var prepareCmd = &cobra.Command{
 				"partitions":         p,
 				"replication_factor": replicationFactor,
 				"group":              group,
-				"kafka_table":        strings.Join([]string{kafkaDB, "kafka_" + table + "_sink"}, "."),
-				"materialized_view":  strings.Join([]string{targetDatabase, "mv_from_kafka_" + table}, "."),
+				"kafka_table":        strings.Join([]string{kafkaDB, naming.Sink(srcDB, table)}, "."),
+				"materialized_view":  strings.Join([]string{targetDatabase, naming.MVFromKafka(srcDB, table)}, "."),
 				"target_table":       strings.Join([]string{targetDatabase, tgtTable}, "."),
+				"target_layout":      layout,
 				"type_diffs":         typeDiffs,
 				"source":             strings.Join([]string{srcDB, table}, "."),
 			})
@@ -211,4 +238,5 @@ func init() {
 	prepareCmd.Flags().String("table", "", "源表名（必填）")
 	prepareCmd.Flags().Bool("recreate", false, "删除并重建 Kafka 表与物化视图")
 	prepareCmd.Flags().Bool("recreate-topic", false, "删除并重建 Kafka 主题")
+	prepareCmd.Flags().Bool("dry-run", false, "仅输出将要创建的对象与 SQL（同 plan），不做任何变更")
 }
@@ -3,12 +3,21 @@ package cmd
 
 import (
 	"bytes"
+	"click-house-sync/internal/clickhouse"
 	"click-house-sync/internal/config"
+	"click-house-sync/internal/kafka"
 	"click-house-sync/internal/logging"
+	"click-house-sync/internal/naming"
+	"click-house-sync/internal/redact"
+	"database/sql"
 	"encoding/json"
+	"fmt"
+	"net"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 	"unicode"
 
 	"github.com/spf13/cobra"
@@ -61,6 +70,43 @@ var (
 	mvTTLDays             int
     mvTTLColumn           string
     mvMaxPartitionsPerInsertBlock int
+	kafkaNumConsumers             int
+	kafkaThreadPerConsumer        bool
+	kafkaPollMaxBatchSize         int
+	kafkaFlushIntervalMs          int
+	kafkaCommitEveryBatch         bool
+	kafkaSkipBrokenMessages       int
+	kafkaHandleErrorMode          string
+	kafkaSecurityProtocol         string
+	kafkaSASLMechanism            string
+	kafkaSASLUsername             string
+	kafkaSASLPassword             string
+	kafkaTLSCA                    string
+	kafkaTLSCert                  string
+	kafkaTLSKey                   string
+	kafkaTLSInsecure              bool
+	kafkaNamedCollection          string
+	chAddrs                       string
+	chProtocol                    string
+	chTLSCA                       string
+	chTLSCert                     string
+	chTLSKey                      string
+	chTLSServerName               string
+	chTLSInsecure                 bool
+	chLoadBalancing               string
+	chCompression                 string
+	chDialTimeout                 int
+	chReadTimeout                 int
+	chMaxOpenConns                int
+	chMaxIdleConns                int
+	chConnMaxLifetime             int
+	chSettings                    string
+	chSettingsConf                map[string]any
+	typeMappingsConf              []config.TypeMapping
+	castMode                      string
+	targetLayout                  string
+	namingEnv                     string
+	namingConf                    config.Naming
 )
 
 // rootCmd 是 ch-sync 的根命令。
@@ -81,6 +127,15 @@ var rootCmd = &cobra.Command{
 			}
 			applyConfig(cmd, conf)
 		}
+		if err := resolveSecretFlags(); err != nil {
+			return err
+		}
+		if err := kafka.Configure(kafkaSecurity()); err != nil {
+			return err
+		}
+		if err := naming.Configure(namingTemplates()); err != nil {
+			return err
+		}
 		if logger == nil {
 			lg, cleanup, err := logging.New(logLevel, logFormat, logFile)
 			if err != nil {
@@ -110,6 +165,21 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&chPassword, "ch-password", "", "ClickHouse 密码")
 	rootCmd.PersistentFlags().StringVar(&chDatabase, "ch-database", "default", "默认连接的数据库名")
 	rootCmd.PersistentFlags().BoolVar(&chSecure, "ch-secure", false, "启用 TLS 连接 ClickHouse")
+	rootCmd.PersistentFlags().StringVar(&chAddrs, "ch-addrs", "", "ClickHouse 多地址列表 host:port，逗号分隔（设置后替代 --ch-host/--ch-port）")
+	rootCmd.PersistentFlags().StringVar(&chProtocol, "ch-protocol", "native", "ClickHouse 连接协议 native|http|https")
+	rootCmd.PersistentFlags().StringVar(&chTLSCA, "ch-tls-ca", "", "ClickHouse TLS CA 证书路径（为空使用系统 CA）")
+	rootCmd.PersistentFlags().StringVar(&chTLSCert, "ch-tls-cert", "", "ClickHouse TLS 客户端证书路径")
+	rootCmd.PersistentFlags().StringVar(&chTLSKey, "ch-tls-key", "", "ClickHouse TLS 客户端私钥路径")
+	rootCmd.PersistentFlags().StringVar(&chTLSServerName, "ch-tls-server-name", "", "ClickHouse TLS 校验的服务端名称（SNI）")
+	rootCmd.PersistentFlags().BoolVar(&chTLSInsecure, "ch-tls-insecure-skip-verify", false, "跳过 ClickHouse 服务端证书校验（仅用于测试环境）")
+	rootCmd.PersistentFlags().StringVar(&chLoadBalancing, "ch-load-balancing", "in_order", "多地址连接策略 in_order|round_robin|random")
+	rootCmd.PersistentFlags().StringVar(&chCompression, "ch-compression", "none", "ClickHouse 传输压缩 none|lz4|zstd")
+	rootCmd.PersistentFlags().IntVar(&chDialTimeout, "ch-dial-timeout", 0, "ClickHouse 建连超时秒数（0 使用驱动默认）")
+	rootCmd.PersistentFlags().IntVar(&chReadTimeout, "ch-read-timeout", 0, "ClickHouse 读取超时秒数（0 使用驱动默认）")
+	rootCmd.PersistentFlags().IntVar(&chMaxOpenConns, "ch-max-open-conns", 0, "ClickHouse 连接池最大连接数（0 使用驱动默认）")
+	rootCmd.PersistentFlags().IntVar(&chMaxIdleConns, "ch-max-idle-conns", 0, "ClickHouse 连接池最大空闲连接数（0 使用驱动默认）")
+	rootCmd.PersistentFlags().IntVar(&chConnMaxLifetime, "ch-conn-max-lifetime", 0, "ClickHouse 连接最大存活秒数（0 使用驱动默认）")
+	rootCmd.PersistentFlags().StringVar(&chSettings, "ch-settings", "", "ClickHouse 默认查询设置 key=value，逗号分隔（与配置文件 clickhouse.settings 合并，参数优先）")
 	rootCmd.PersistentFlags().StringVar(&kafkaBrokers, "kafka-brokers", "127.0.0.1:9092", "Kafka broker 列表，逗号分隔")
 	rootCmd.PersistentFlags().StringVar(&kafkaTopic, "kafka-topic", "", "Kafka topic 名称（为空按 <db>_<table> 生成）")
 	rootCmd.PersistentFlags().IntVar(&rowsPerPartition, "rows-per-partition", 1000000, "每分区行数（用于估算分区数）")
@@ -148,6 +218,25 @@ func init() {
 	rootCmd.PersistentFlags().IntVar(&mvTTLDays, "mv-ttl-days", 730, "查询物化视图数据有效期天数（TTL），默认 730（约两年）")
     rootCmd.PersistentFlags().StringVar(&mvTTLColumn, "mv-ttl-column", "", "查询物化视图 TTL 的时间列名（可选，缺省自动探测）")
     rootCmd.PersistentFlags().IntVar(&mvMaxPartitionsPerInsertBlock, "max-partitions-per-insert-block", 1000, "查询物化视图单次插入块允许的分区上限（默认 1000）")
+	rootCmd.PersistentFlags().IntVar(&kafkaNumConsumers, "kafka-num-consumers", 1, "Kafka 引擎表的 kafka_num_consumers 设置")
+	rootCmd.PersistentFlags().BoolVar(&kafkaThreadPerConsumer, "kafka-thread-per-consumer", false, "Kafka 引擎表的 kafka_thread_per_consumer 设置（每个消费者独立线程与独立 flush）")
+	rootCmd.PersistentFlags().IntVar(&kafkaPollMaxBatchSize, "kafka-poll-max-batch-size", 0, "Kafka 引擎表的 kafka_poll_max_batch_size 设置（0 表示使用 ClickHouse 默认）")
+	rootCmd.PersistentFlags().IntVar(&kafkaFlushIntervalMs, "kafka-flush-interval-ms", 0, "Kafka 引擎表的 kafka_flush_interval_ms 设置（0 表示使用 ClickHouse 默认）")
+	rootCmd.PersistentFlags().BoolVar(&kafkaCommitEveryBatch, "kafka-commit-every-batch", false, "Kafka 引擎表的 kafka_commit_every_batch 设置（每批提交位点）")
+	rootCmd.PersistentFlags().IntVar(&kafkaSkipBrokenMessages, "kafka-skip-broken-messages", 1000, "Kafka 引擎表的 kafka_skip_broken_messages 设置（0 表示不跳过；stream 错误模式下忽略）")
+	rootCmd.PersistentFlags().StringVar(&kafkaHandleErrorMode, "kafka-handle-error-mode", "default", "Kafka 引擎表的 kafka_handle_error_mode 设置 default|stream（stream 时自动创建 <table>_kafka_errors 错误表与错误 MV）")
+	rootCmd.PersistentFlags().StringVar(&kafkaSecurityProtocol, "kafka-security-protocol", "", "Kafka 安全协议 PLAINTEXT|SSL|SASL_PLAINTEXT|SASL_SSL（为空按是否配置用户名/证书推导）")
+	rootCmd.PersistentFlags().StringVar(&kafkaSASLMechanism, "kafka-sasl-mechanism", "PLAIN", "Kafka SASL 机制 PLAIN|SCRAM-SHA-256|SCRAM-SHA-512")
+	rootCmd.PersistentFlags().StringVar(&kafkaSASLUsername, "kafka-sasl-username", "", "Kafka SASL 用户名")
+	rootCmd.PersistentFlags().StringVar(&kafkaSASLPassword, "kafka-sasl-password", "", "Kafka SASL 密码")
+	rootCmd.PersistentFlags().StringVar(&kafkaTLSCA, "kafka-tls-ca", "", "Kafka TLS CA 证书路径")
+	rootCmd.PersistentFlags().StringVar(&kafkaTLSCert, "kafka-tls-cert", "", "Kafka TLS 客户端证书路径")
+	rootCmd.PersistentFlags().StringVar(&kafkaTLSKey, "kafka-tls-key", "", "Kafka TLS 客户端私钥路径")
+	rootCmd.PersistentFlags().BoolVar(&kafkaTLSInsecure, "kafka-tls-insecure-skip-verify", false, "跳过 Kafka 服务端证书校验（仅用于测试环境）")
+	rootCmd.PersistentFlags().StringVar(&castMode, "cast-mode", "strict", "gen-ddl --with-sync-cast 的转换模式 strict|lenient|quarantine（quarantine 时无法转换的行写入 <table>_quarantine）")
+	rootCmd.PersistentFlags().StringVar(&targetLayout, "target-layout", "custom", "目标表与查询物化视图的布局 mirror|custom（mirror 复刻源表引擎、键、编解码、默认值与设置）")
+	rootCmd.PersistentFlags().StringVar(&namingEnv, "naming-env", "", "命名模板中 {env} 的取值（配置文件 naming.env）")
+	rootCmd.PersistentFlags().StringVar(&kafkaNamedCollection, "kafka-named-collection", "", "ClickHouse 中的 Kafka named collection 名称；设置后引擎表的 broker 与认证信息取自该集合")
 }
 
 func brokersList() []string {
@@ -198,11 +287,82 @@ func applyConfig(cmd *cobra.Command, conf *config.Config) {
 	if !cmd.Flags().Changed("ch-secure") {
 		chSecure = conf.ClickHouse.Secure
 	}
+	if !cmd.Flags().Changed("ch-addrs") && len(conf.ClickHouse.Addrs) > 0 {
+		chAddrs = strings.Join(conf.ClickHouse.Addrs, ",")
+	}
+	if !cmd.Flags().Changed("ch-protocol") && conf.ClickHouse.Protocol != "" {
+		chProtocol = conf.ClickHouse.Protocol
+	}
+	if !cmd.Flags().Changed("ch-tls-ca") && conf.ClickHouse.TLS.CAFile != "" {
+		chTLSCA = conf.ClickHouse.TLS.CAFile
+	}
+	if !cmd.Flags().Changed("ch-tls-cert") && conf.ClickHouse.TLS.CertFile != "" {
+		chTLSCert = conf.ClickHouse.TLS.CertFile
+	}
+	if !cmd.Flags().Changed("ch-tls-key") && conf.ClickHouse.TLS.KeyFile != "" {
+		chTLSKey = conf.ClickHouse.TLS.KeyFile
+	}
+	if !cmd.Flags().Changed("ch-tls-server-name") && conf.ClickHouse.TLS.ServerName != "" {
+		chTLSServerName = conf.ClickHouse.TLS.ServerName
+	}
+	if !cmd.Flags().Changed("ch-tls-insecure-skip-verify") {
+		chTLSInsecure = conf.ClickHouse.TLS.InsecureSkipVerify
+	}
+	if !cmd.Flags().Changed("ch-load-balancing") && conf.ClickHouse.LoadBalancing != "" {
+		chLoadBalancing = conf.ClickHouse.LoadBalancing
+	}
+	if !cmd.Flags().Changed("ch-compression") && conf.ClickHouse.Compression != "" {
+		chCompression = conf.ClickHouse.Compression
+	}
+	if !cmd.Flags().Changed("ch-dial-timeout") && conf.ClickHouse.DialTimeout > 0 {
+		chDialTimeout = conf.ClickHouse.DialTimeout
+	}
+	if !cmd.Flags().Changed("ch-read-timeout") && conf.ClickHouse.ReadTimeout > 0 {
+		chReadTimeout = conf.ClickHouse.ReadTimeout
+	}
+	if !cmd.Flags().Changed("ch-max-open-conns") && conf.ClickHouse.MaxOpenConns > 0 {
+		chMaxOpenConns = conf.ClickHouse.MaxOpenConns
+	}
+	if !cmd.Flags().Changed("ch-max-idle-conns") && conf.ClickHouse.MaxIdleConns > 0 {
+		chMaxIdleConns = conf.ClickHouse.MaxIdleConns
+	}
+	if !cmd.Flags().Changed("ch-conn-max-lifetime") && conf.ClickHouse.ConnMaxLifetime > 0 {
+		chConnMaxLifetime = conf.ClickHouse.ConnMaxLifetime
+	}
+	chSettingsConf = conf.ClickHouse.Settings
+	typeMappingsConf = conf.TypeMappings
 
 	brokersJoined := config.JoinBrokers(conf.Kafka.Brokers)
 	if !cmd.Flags().Changed("kafka-brokers") && brokersJoined != "" {
 		kafkaBrokers = brokersJoined
 	}
+	if !cmd.Flags().Changed("kafka-security-protocol") && conf.Kafka.SecurityProtocol != "" {
+		kafkaSecurityProtocol = conf.Kafka.SecurityProtocol
+	}
+	if !cmd.Flags().Changed("kafka-sasl-mechanism") && conf.Kafka.SASLMechanism != "" {
+		kafkaSASLMechanism = conf.Kafka.SASLMechanism
+	}
+	if !cmd.Flags().Changed("kafka-sasl-username") && conf.Kafka.SASLUsername != "" {
+		kafkaSASLUsername = conf.Kafka.SASLUsername
+	}
+	if !cmd.Flags().Changed("kafka-sasl-password") && conf.Kafka.SASLPassword != "" {
+		kafkaSASLPassword = conf.Kafka.SASLPassword
+	}
+	if !cmd.Flags().Changed("kafka-tls-ca") && conf.Kafka.TLS.CAFile != "" {
+		kafkaTLSCA = conf.Kafka.TLS.CAFile
+	}
+	if !cmd.Flags().Changed("kafka-tls-cert") && conf.Kafka.TLS.CertFile != "" {
+		kafkaTLSCert = conf.Kafka.TLS.CertFile
+	}
+	if !cmd.Flags().Changed("kafka-tls-key") && conf.Kafka.TLS.KeyFile != "" {
+		kafkaTLSKey = conf.Kafka.TLS.KeyFile
+	}
+	if !cmd.Flags().Changed("kafka-tls-insecure-skip-verify") {
+		kafkaTLSInsecure = conf.Kafka.TLS.InsecureSkipVerify
+	}
+	if !cmd.Flags().Changed("kafka-named-collection") && conf.Kafka.NamedCollection != "" {
+		kafkaNamedCollection = conf.Kafka.NamedCollection
+	}
 
 	if !cmd.Flags().Changed("rows-per-partition") && conf.Sync.RowsPerPartition > 0 {
 		rowsPerPartition = conf.Sync.RowsPerPartition
@@ -252,6 +412,267 @@ func applyConfig(cmd *cobra.Command, conf *config.Config) {
     if !cmd.Flags().Changed("max-partitions-per-insert-block") && conf.Sync.MaxPartitionsPerInsertBlock > 0 {
         mvMaxPartitionsPerInsertBlock = conf.Sync.MaxPartitionsPerInsertBlock
     }
+	ke := conf.Sync.KafkaEngine
+	if !cmd.Flags().Changed("kafka-num-consumers") && ke.NumConsumers > 0 {
+		kafkaNumConsumers = ke.NumConsumers
+	}
+	if !cmd.Flags().Changed("kafka-thread-per-consumer") && ke.ThreadPerConsumer != nil {
+		kafkaThreadPerConsumer = *ke.ThreadPerConsumer
+	}
+	if !cmd.Flags().Changed("kafka-poll-max-batch-size") && ke.PollMaxBatchSize > 0 {
+		kafkaPollMaxBatchSize = ke.PollMaxBatchSize
+	}
+	if !cmd.Flags().Changed("kafka-flush-interval-ms") && ke.FlushIntervalMs > 0 {
+		kafkaFlushIntervalMs = ke.FlushIntervalMs
+	}
+	if !cmd.Flags().Changed("kafka-commit-every-batch") && ke.CommitEveryBatch != nil {
+		kafkaCommitEveryBatch = *ke.CommitEveryBatch
+	}
+	if !cmd.Flags().Changed("kafka-skip-broken-messages") && ke.SkipBrokenMessages != nil {
+		kafkaSkipBrokenMessages = *ke.SkipBrokenMessages
+	}
+	if !cmd.Flags().Changed("kafka-handle-error-mode") && strings.TrimSpace(ke.HandleErrorMode) != "" {
+		kafkaHandleErrorMode = ke.HandleErrorMode
+	}
+	if !cmd.Flags().Changed("cast-mode") && strings.TrimSpace(conf.Sync.CastMode) != "" {
+		castMode = conf.Sync.CastMode
+	}
+	if !cmd.Flags().Changed("target-layout") && strings.TrimSpace(conf.Sync.TargetLayout) != "" {
+		targetLayout = conf.Sync.TargetLayout
+	}
+	namingConf = conf.Naming
+	if !cmd.Flags().Changed("naming-env") && strings.TrimSpace(conf.Naming.Env) != "" {
+		namingEnv = conf.Naming.Env
+	}
+}
+
+// namingTemplates 汇总配置文件 naming 段与 --naming-env。
+func namingTemplates() naming.Templates {
+	return naming.Templates{
+		Env:             namingEnv,
+		Topic:           namingConf.Topic,
+		Sink:            namingConf.Sink,
+		MVFromKafka:     namingConf.MVFromKafka,
+		MVToKafka:       namingConf.MVToKafka,
+		Group:           namingConf.Group,
+		ErrorsTable:     namingConf.ErrorsTable,
+		ErrorsView:      namingConf.ErrorsView,
+		QuarantineTable: namingConf.QuarantineTable,
+		QuarantineView:  namingConf.QuarantineView,
+	}
+}
+
+// chConnOptions 汇总全局 ClickHouse 连接参数，并叠加表级 clickhouse 覆盖项（t 可为 nil）。
+func chConnOptions(t *config.Table, database string) (clickhouse.ConnOptions, error) {
+	o := clickhouse.ConnOptions{
+		Addrs:              splitCSV(chAddrs),
+		User:               chUser,
+		Password:           chPassword,
+		Database:           database,
+		Protocol:           chProtocol,
+		Secure:             chSecure,
+		CAFile:             chTLSCA,
+		CertFile:           chTLSCert,
+		KeyFile:            chTLSKey,
+		ServerName:         chTLSServerName,
+		InsecureSkipVerify: chTLSInsecure,
+		LoadBalancing:      chLoadBalancing,
+		Compression:        chCompression,
+		DialTimeout:        time.Duration(chDialTimeout) * time.Second,
+		ReadTimeout:        time.Duration(chReadTimeout) * time.Second,
+		MaxOpenConns:       chMaxOpenConns,
+		MaxIdleConns:       chMaxIdleConns,
+		ConnMaxLifetime:    time.Duration(chConnMaxLifetime) * time.Second,
+		Settings:           map[string]any{},
+	}
+	if len(o.Addrs) == 0 {
+		o.Addrs = []string{net.JoinHostPort(chHost, strconv.Itoa(chPort))}
+	}
+	for k, v := range chSettingsConf {
+		o.Settings[k] = v
+	}
+	if t != nil && t.ClickHouse != nil {
+		ov := t.ClickHouse
+		if len(ov.Addrs) > 0 {
+			o.Addrs = ov.Addrs
+		}
+		if ov.Secure != nil {
+			o.Secure = *ov.Secure
+		}
+		if ov.Protocol != "" {
+			o.Protocol = ov.Protocol
+		}
+		if ov.TLS != nil {
+			o.CAFile = ov.TLS.CAFile
+			o.CertFile = ov.TLS.CertFile
+			o.KeyFile = ov.TLS.KeyFile
+			o.ServerName = ov.TLS.ServerName
+			o.InsecureSkipVerify = ov.TLS.InsecureSkipVerify
+		}
+		if ov.LoadBalancing != "" {
+			o.LoadBalancing = ov.LoadBalancing
+		}
+		if ov.Compression != "" {
+			o.Compression = ov.Compression
+		}
+		if ov.DialTimeout > 0 {
+			o.DialTimeout = time.Duration(ov.DialTimeout) * time.Second
+		}
+		if ov.ReadTimeout > 0 {
+			o.ReadTimeout = time.Duration(ov.ReadTimeout) * time.Second
+		}
+		if ov.MaxOpenConns > 0 {
+			o.MaxOpenConns = ov.MaxOpenConns
+		}
+		if ov.MaxIdleConns > 0 {
+			o.MaxIdleConns = ov.MaxIdleConns
+		}
+		if ov.ConnMaxLifetime > 0 {
+			o.ConnMaxLifetime = time.Duration(ov.ConnMaxLifetime) * time.Second
+		}
+		for k, v := range ov.Settings {
+			o.Settings[k] = v
+		}
+	}
+	// --ch-settings 参数优先级最高
+	for _, kv := range splitCSV(chSettings) {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return o, fmt.Errorf("--ch-settings 格式应为 key=value: %s", kv)
+		}
+		o.Settings[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return o, nil
+}
+
+// connectClickHouse 按全局参数与表级覆盖项连接 ClickHouse（t 可为 nil）。
+func connectClickHouse(t *config.Table, database string) (*sql.DB, error) {
+	o, err := chConnOptions(t, database)
+	if err != nil {
+		return nil, err
+	}
+	return clickhouse.Open(o)
+}
+
+// kafkaSecurity 汇总全局参数得到 Kafka 认证配置。
+func kafkaSecurity() kafka.Security {
+	return kafka.Security{
+		Protocol:           kafkaSecurityProtocol,
+		Mechanism:          kafkaSASLMechanism,
+		Username:           kafkaSASLUsername,
+		Password:           kafkaSASLPassword,
+		CAFile:             kafkaTLSCA,
+		CertFile:           kafkaTLSCert,
+		KeyFile:            kafkaTLSKey,
+		InsecureSkipVerify: kafkaTLSInsecure,
+	}
+}
+
+// typeMapperFor 合并表级与全局 type_mappings（表级规则先匹配），返回类型映射器；无规则时返回 nil。
+func typeMapperFor(t *config.Table) (*clickhouse.TypeMapper, error) {
+	var rules []clickhouse.TypeMapping
+	if t != nil {
+		for _, r := range t.TypeMappings {
+			rules = append(rules, clickhouse.TypeMapping{Match: r.Match, To: r.To})
+		}
+	}
+	for _, r := range typeMappingsConf {
+		rules = append(rules, clickhouse.TypeMapping{Match: r.Match, To: r.To})
+	}
+	return clickhouse.NewTypeMapper(rules)
+}
+
+// castModeFor 返回表的 sync-cast 转换模式：tables.yaml 的 cast_mode 优先于全局参数。
+func castModeFor(t *config.Table) (string, error) {
+	if t != nil && strings.TrimSpace(t.CastMode) != "" {
+		return clickhouse.NormalizeCastMode(t.CastMode)
+	}
+	return clickhouse.NormalizeCastMode(castMode)
+}
+
+// targetLayoutFor 返回表的目标表布局：tables.yaml 的 target_layout 优先于全局参数。
+func targetLayoutFor(t *config.Table) (string, error) {
+	if t != nil && strings.TrimSpace(t.TargetLayout) != "" {
+		return clickhouse.NormalizeTargetLayout(t.TargetLayout)
+	}
+	return clickhouse.NormalizeTargetLayout(targetLayout)
+}
+
+// targetDatabaseFor 返回表的目标库（Kafka 引擎表默认也建在此库）：显式 --target-database 优先，其次 tables.yaml 的 target_database，
+// 再次配置文件的 sync.target_database，都未设置时与 --ch-database 相同。
+func targetDatabaseFor(cmd *cobra.Command, t *config.Table) string {
+	if cmd.Root().PersistentFlags().Changed("target-database") && strings.TrimSpace(targetDatabase) != "" {
+		return targetDatabase
+	}
+	if t != nil && t.TargetDatabase != "" {
+		return t.TargetDatabase
+	}
+	if strings.TrimSpace(targetDatabase) != "" {
+		return targetDatabase
+	}
+	return chDatabase
+}
+
+// topicConfigFor 返回表的主题级配置（tables.yaml 的 topic_config），t 为 nil 或未配置时为空。
+func topicConfigFor(t *config.Table) (map[string]string, error) {
+	if t == nil || len(t.TopicConfig) == 0 {
+		return nil, nil
+	}
+	if err := kafka.ValidateTopicConfigs(t.TopicConfig); err != nil {
+		return nil, fmt.Errorf("%s: %v", t.Name, err)
+	}
+	return t.TopicConfig, nil
+}
+
+// kafkaSettingsFor 合并全局参数与表级 kafka_engine 配置，得到 Kafka 引擎表设置（表级优先）。
+func kafkaSettingsFor(t *config.Table) clickhouse.KafkaSettings {
+	s := clickhouse.KafkaSettings{
+		Format:             "JSONEachRow",
+		NumConsumers:       kafkaNumConsumers,
+		MaxBlockSize:       kafkaMaxBlockSize,
+		AutoOffsetReset:    kafkaAutoOffsetReset,
+		SkipBrokenMessages: kafkaSkipBrokenMessages,
+		ThreadPerConsumer:  kafkaThreadPerConsumer,
+		PollMaxBatchSize:   kafkaPollMaxBatchSize,
+		FlushIntervalMs:    kafkaFlushIntervalMs,
+		CommitEveryBatch:   kafkaCommitEveryBatch,
+		HandleErrorMode:    kafkaHandleErrorMode,
+		NamedCollection:    strings.TrimSpace(kafkaNamedCollection),
+	}
+	if sec := kafkaSecurity(); sec.NormalizedProtocol() != "PLAINTEXT" {
+		s.SecurityProtocol = sec.NormalizedProtocol()
+		if sec.UsesSASL() {
+			s.SASLMechanism = sec.NormalizedMechanism()
+			s.SASLUsername = sec.Username
+			s.SASLPassword = sec.Password
+		}
+	}
+	if t == nil || t.KafkaEngine == nil {
+		return s
+	}
+	ke := t.KafkaEngine
+	if ke.NumConsumers > 0 {
+		s.NumConsumers = ke.NumConsumers
+	}
+	if ke.ThreadPerConsumer != nil {
+		s.ThreadPerConsumer = *ke.ThreadPerConsumer
+	}
+	if ke.PollMaxBatchSize > 0 {
+		s.PollMaxBatchSize = ke.PollMaxBatchSize
+	}
+	if ke.FlushIntervalMs > 0 {
+		s.FlushIntervalMs = ke.FlushIntervalMs
+	}
+	if ke.CommitEveryBatch != nil {
+		s.CommitEveryBatch = *ke.CommitEveryBatch
+	}
+	if ke.SkipBrokenMessages != nil {
+		s.SkipBrokenMessages = *ke.SkipBrokenMessages
+	}
+	if strings.TrimSpace(ke.HandleErrorMode) != "" {
+		s.HandleErrorMode = ke.HandleErrorMode
+	}
+	return s
 }
 
 func autoFindConfig() string {
@@ -282,13 +703,52 @@ func autoFindConfig() string {
 	return ""
 }
 
-func printJSON(v any) {
+// marshalRedacted 序列化输出事件并屏蔽其中的密码与 broker 凭据。
+func marshalRedacted(v any) []byte {
 	var b []byte
 	if jsonPretty {
 		b, _ = json.MarshalIndent(v, "", "  ")
 	} else {
 		b, _ = json.Marshal(v)
 	}
+	return []byte(redact.String(string(b)))
+}
+
+// resolveSecretFlags 解析凭据参数中的密钥引用（${env:X}、file:、exec:），并登记到输出屏蔽列表。
+func resolveSecretFlags() error {
+	for _, f := range []struct {
+		name string
+		ptr  *string
+	}{
+		{"ch-user", &chUser},
+		{"ch-password", &chPassword},
+		{"kafka-sasl-username", &kafkaSASLUsername},
+		{"kafka-sasl-password", &kafkaSASLPassword},
+	} {
+		v, err := resolveSecretValue(f.name, *f.ptr)
+		if err != nil {
+			return err
+		}
+		*f.ptr = v
+	}
+	redact.Register(chPassword, kafkaSASLPassword)
+	return nil
+}
+
+// resolveSecretValue 解析单个参数的密钥引用；解析出的值由 config.ResolveSecret 登记到输出屏蔽列表。
+func resolveSecretValue(flag string, v string) (string, error) {
+	if !config.IsSecretRef(v) {
+		return v, nil
+	}
+	out, err := config.ResolveSecret(v)
+	if err != nil {
+		return "", fmt.Errorf("--%s: %w", flag, err)
+	}
+	return out, nil
+}
+
+func printJSON(v any) {
+	b := marshalRedacted(v)
 	if shouldColorStdout() {
 		s := colorizeJSONString(string(b))
 		os.Stdout.WriteString(s)
@@ -300,12 +760,7 @@ func printJSON(v any) {
 }
 
 func printErrJSON(v any) {
-	var b []byte
-	if jsonPretty {
-		b, _ = json.MarshalIndent(v, "", "  ")
-	} else {
-		b, _ = json.Marshal(v)
-	}
+	b := marshalRedacted(v)
 	if shouldColorStderr() {
 		s := colorizeJSONString(string(b))
 		os.Stderr.WriteString(s)
@@ -317,12 +772,7 @@ func printErrJSON(v any) {
 }
 
 func printRedJSON(v any) {
-	var b []byte
-	if jsonPretty {
-		b, _ = json.MarshalIndent(v, "", "  ")
-	} else {
-		b, _ = json.Marshal(v)
-	}
+	b := marshalRedacted(v)
 	if shouldColorStdout() {
 		s := colorizeJSONString(string(b))
 		os.Stdout.WriteString("\x1b[31m")
@@ -457,12 +907,7 @@ func colorizeJSONString(s string) string {
 }
 
 func printJSONWithRedKeys(v any, keys []string) {
-	var b []byte
-	if jsonPretty {
-		b, _ = json.MarshalIndent(v, "", "  ")
-	} else {
-		b, _ = json.Marshal(v)
-	}
+	b := marshalRedacted(v)
 	if shouldColorStdout() {
 		red := map[string]struct{}{}
 		for _, k := range keys {
@@ -2,7 +2,13 @@ package cmd
 
 import (
 	"click-house-sync/internal/clickhouse"
+	"click-house-sync/internal/config"
+	"click-house-sync/internal/redact"
+	"database/sql"
 	"fmt"
+	"net"
+	"os"
+	"strconv"
 	"strings"
 
 	"github.com/spf13/cobra"
@@ -32,76 +38,223 @@ var schemaDiffCmd = &cobra.Command{
 				targetDBName = chDatabase
 			}
 		}
-		targetHost, _ := cmd.Flags().GetString("target-host")
-		targetPort, _ := cmd.Flags().GetInt("target-port")
-		targetUser, _ := cmd.Flags().GetString("target-user")
-		targetPassword, _ := cmd.Flags().GetString("target-password")
-		targetSecure, _ := cmd.Flags().GetBool("target-secure")
-
-		if strings.TrimSpace(targetHost) == "" {
-			targetHost = chHost
-		}
-		if targetPort <= 0 {
-			targetPort = chPort
-		}
-		if strings.TrimSpace(targetUser) == "" {
-			targetUser = chUser
-		}
-
-		srcConn, err := clickhouse.Connect(chHost, chPort, chUser, chPassword, sourceDBName, chSecure)
+		srcConn, err := connectClickHouse(nil, sourceDBName)
 		if err != nil {
 			return err
 		}
 		defer srcConn.Close()
 
-		tgtConn, err := clickhouse.Connect(targetHost, targetPort, targetUser, targetPassword, targetDBName, targetSecure)
+		tgtOpts, err := schemaDiffTargetOptions(cmd, targetDBName)
+		if err != nil {
+			return err
+		}
+		tgtConn, err := clickhouse.Open(tgtOpts)
 		if err != nil {
 			return err
 		}
 		defer tgtConn.Close()
 
-		sourceCols, err := clickhouse.GetColumns(srcConn, sourceDBName, table)
+		cats, err := schemaDiffCategories(cmd)
 		if err != nil {
 			return err
 		}
-		targetCols, err := clickhouse.GetColumns(tgtConn, targetDBName, targetTableName)
+		format, failOn, err := schemaDiffReportOptions(cmd)
+		if err != nil {
+			return err
+		}
+		tconf, err := lookupTableConfig(table)
+		if err != nil {
+			return err
+		}
+		d, err := diffTableSchemas(srcConn, sourceDBName, table, tgtConn, targetDBName, targetTableName, cats, typeRulesFor(tconf))
 		if err != nil {
 			return err
 		}
 
-		typeDiffs := clickhouse.AnalyzeTypeDiff(sourceCols, targetCols)
-		srcPos := map[string]uint64{}
-		tgtPos := map[string]uint64{}
-		for _, c := range sourceCols {
-			srcPos[c.Name] = c.Position
+		out := map[string]any{
+			"command":         "schema-diff",
+			"source":          fmt.Sprintf("%s:%d/%s.%s", chHost, chPort, sourceDBName, table),
+			"target":          fmt.Sprintf("%s/%s.%s", strings.Join(tgtOpts.Addrs, ","), targetDBName, targetTableName),
+			"categories":      enabledCategories(cats),
+			"type_diffs":      d.TypeDiffs,
+			"ignored_diffs":   d.IgnoredDiffs,
+			"order_diffs":     d.OrderDiffs,
+			"diffs":           d.Structural,
+			"source_cols":     d.SourceCols,
+			"target_cols":     d.TargetCols,
+			"diffs_count":     d.Count(),
+			"matched_exact":   d.Count() == 0,
+			"sync_compatible": clickhouse.SyncCompatible(d.TypeDiffs),
 		}
+		emitSQL, _ := cmd.Flags().GetString("emit-sql")
+		if strings.TrimSpace(emitSQL) != "" {
+			stmts := reconcileStatements(cmd, d, targetDBName, targetTableName)
+			header := fmt.Sprintf("%s.%s -> %s.%s", sourceDBName, table, targetDBName, targetTableName)
+			if err := writeSQLFile(emitSQL, clickhouse.RenderReconcileSQL(header, stmts)); err != nil {
+				return err
+			}
+			out["emit_sql"] = emitSQL
+			out["reconcile"] = stmts
+		}
+		source := fmt.Sprintf("%s.%s", sourceDBName, table)
+		target := fmt.Sprintf("%s.%s", targetDBName, targetTableName)
+		reports := []schemaDiffTableReport{tableReportFromDiff(table, source, target, d)}
+		if err := writeSchemaDiffReport(cmd, format, "schema-diff: "+source+" -> "+target, out, reports); err != nil {
+			return err
+		}
+		return schemaDiffFailure(failOn, reports)
+	},
+}
+
+// schemaDiffCategories 解析 --include-categories / --ignore-categories，返回启用的差异类别（默认全部启用）。
+func schemaDiffCategories(cmd *cobra.Command) (map[string]bool, error) {
+	known := map[string]bool{}
+	for _, c := range clickhouse.SchemaDiffCategories {
+		known[c] = true
+	}
+	include, _ := cmd.Flags().GetString("include-categories")
+	ignore, _ := cmd.Flags().GetString("ignore-categories")
+	enabled := map[string]bool{}
+	if list := splitCSV(include); len(list) > 0 {
+		for _, c := range list {
+			if !known[c] {
+				return nil, fmt.Errorf("未知的差异类别: %s（可选 %s）", c, strings.Join(clickhouse.SchemaDiffCategories, ","))
+			}
+			enabled[c] = true
+		}
+	} else {
+		for c := range known {
+			enabled[c] = true
+		}
+	}
+	for _, c := range splitCSV(ignore) {
+		if !known[c] {
+			return nil, fmt.Errorf("未知的差异类别: %s（可选 %s）", c, strings.Join(clickhouse.SchemaDiffCategories, ","))
+		}
+		delete(enabled, c)
+	}
+	return enabled, nil
+}
+
+// enabledCategories 按固定顺序返回启用的类别，便于输出。
+func enabledCategories(cats map[string]bool) []string {
+	var out []string
+	for _, c := range clickhouse.SchemaDiffCategories {
+		if cats[c] {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// typeRulesFor 把 tables.yaml 中的 schema_diff 规则转换为类型差异规则。
+func typeRulesFor(t *config.Table) clickhouse.TypeRules {
+	var r clickhouse.TypeRules
+	if t == nil || t.SchemaDiff == nil {
+		return r
+	}
+	r.IgnoreColumns = t.SchemaDiff.IgnoreColumns
+	r.Ignore = t.SchemaDiff.Ignore
+	for _, e := range t.SchemaDiff.EquivalentTypes {
+		r.Equivalent = append(r.Equivalent, clickhouse.TypeEquivalence{Source: e.Source, Target: e.Target})
+	}
+	return r
+}
+
+// tableSchemaDiff 汇总单表的列差异、位置差异与结构维度差异；IgnoredDiffs 为被规则忽略的列差异，不计入总数。
+type tableSchemaDiff struct {
+	TypeDiffs    []clickhouse.TypeDiff
+	IgnoredDiffs []clickhouse.TypeDiff
+	OrderDiffs   []map[string]any
+	Structural   map[string][]clickhouse.SchemaDiffItem
+	SourceCols   []clickhouse.Column
+	TargetCols   []clickhouse.Column
+}
+
+// Count 返回所有启用类别的差异总数。
+func (d *tableSchemaDiff) Count() int {
+	n := len(d.TypeDiffs) + len(d.OrderDiffs)
+	for _, items := range d.Structural {
+		n += len(items)
+	}
+	return n
+}
+
+// diffTableSchemas 对比单表结构：columns/order 基于 system.columns，其余类别基于 system.tables 与 system.data_skipping_indices。
+func diffTableSchemas(srcConn *sql.DB, srcDB string, srcTable string, tgtConn *sql.DB, tgtDB string, tgtTable string, cats map[string]bool, rules clickhouse.TypeRules) (*tableSchemaDiff, error) {
+	return diffTableSides(schemaSide{Conn: srcConn, Database: srcDB, Table: srcTable}, schemaSide{Conn: tgtConn, Database: tgtDB, Table: tgtTable}, cats, rules)
+}
+
+// schemaSide 描述对比的一侧；Columns 非 nil 时复用整库预取的列详情，不再逐表查询 system.columns。
+type schemaSide struct {
+	Conn     *sql.DB
+	Database string
+	Table    string
+	Columns  []clickhouse.ColumnDetail
+}
+
+func (s schemaSide) columns() ([]clickhouse.Column, error) {
+	if s.Columns != nil {
+		return clickhouse.ColumnsOf(s.Columns), nil
+	}
+	return clickhouse.GetColumns(s.Conn, s.Database, s.Table)
+}
+
+// diffTableSides 对比两侧表结构，见 diffTableSchemas。
+func diffTableSides(src schemaSide, tgt schemaSide, cats map[string]bool, rules clickhouse.TypeRules) (*tableSchemaDiff, error) {
+	sourceCols, err := src.columns()
+	if err != nil {
+		return nil, err
+	}
+	targetCols, err := tgt.columns()
+	if err != nil {
+		return nil, err
+	}
+	d := &tableSchemaDiff{
+		TypeDiffs:    []clickhouse.TypeDiff{},
+		IgnoredDiffs: []clickhouse.TypeDiff{},
+		OrderDiffs:   make([]map[string]any, 0),
+		Structural:   map[string][]clickhouse.SchemaDiffItem{},
+		SourceCols:   sourceCols,
+		TargetCols:   targetCols,
+	}
+	if cats["columns"] {
+		d.TypeDiffs, d.IgnoredDiffs = clickhouse.ApplyTypeRules(clickhouse.AnalyzeTypeDiff(sourceCols, targetCols), rules)
+	}
+	if cats["order"] {
+		tgtPos := map[string]uint64{}
 		for _, c := range targetCols {
 			tgtPos[c.Name] = c.Position
 		}
-		orderDiffs := make([]map[string]any, 0)
-		for name, sPos := range srcPos {
-			if tPos, ok := tgtPos[name]; ok && sPos != tPos {
-				orderDiffs = append(orderDiffs, map[string]any{
-					"column":          name,
-					"source_position": sPos,
+		for _, c := range sourceCols {
+			if tPos, ok := tgtPos[c.Name]; ok && c.Position != tPos {
+				d.OrderDiffs = append(d.OrderDiffs, map[string]any{
+					"column":          c.Name,
+					"source_position": c.Position,
 					"target_position": tPos,
 				})
 			}
 		}
-
-		printJSON(map[string]any{
-			"command":       "schema-diff",
-			"source":        fmt.Sprintf("%s:%d/%s.%s", chHost, chPort, sourceDBName, table),
-			"target":        fmt.Sprintf("%s:%d/%s.%s", targetHost, targetPort, targetDBName, targetTableName),
-			"type_diffs":    typeDiffs,
-			"order_diffs":   orderDiffs,
-			"source_cols":   sourceCols,
-			"target_cols":   targetCols,
-			"diffs_count":   len(typeDiffs) + len(orderDiffs),
-			"matched_exact": len(typeDiffs) == 0 && len(orderDiffs) == 0,
-		})
-		return nil
-	},
+	}
+	structural := false
+	for c := range cats {
+		if c != "columns" && c != "order" {
+			structural = true
+			break
+		}
+	}
+	if structural {
+		srcSchema, err := clickhouse.GetTableSchemaWithColumns(src.Conn, src.Database, src.Table, src.Columns)
+		if err != nil {
+			return nil, err
+		}
+		tgtSchema, err := clickhouse.GetTableSchemaWithColumns(tgt.Conn, tgt.Database, tgt.Table, tgt.Columns)
+		if err != nil {
+			return nil, err
+		}
+		d.Structural = clickhouse.CompareTableSchemas(srcSchema, tgtSchema, cats)
+	}
+	return d, nil
 }
 
 func init() {
@@ -110,9 +263,93 @@ func init() {
 	schemaDiffCmd.Flags().String("target-table", "", "目标表名（默认与源表同名）")
 	schemaDiffCmd.Flags().String("source-database", "", "源库名（默认 --ch-database）")
 	schemaDiffCmd.Flags().String("target-db", "", "目标库名（默认 --target-database）")
-	schemaDiffCmd.Flags().String("target-host", "", "目标 ClickHouse host（默认 --ch-host）")
-	schemaDiffCmd.Flags().Int("target-port", 0, "目标 ClickHouse 端口（默认 --ch-port）")
-	schemaDiffCmd.Flags().String("target-user", "", "目标 ClickHouse 用户（默认 --ch-user）")
-	schemaDiffCmd.Flags().String("target-password", "", "目标 ClickHouse 密码（默认空）")
-	schemaDiffCmd.Flags().Bool("target-secure", false, "目标 ClickHouse 是否启用 TLS")
+	addSchemaDiffTargetFlags(schemaDiffCmd)
+	addSchemaDiffCategoryFlags(schemaDiffCmd)
+	addSchemaDiffReportFlags(schemaDiffCmd)
+}
+
+// addSchemaDiffTargetFlags 为 schema-diff 系列命令注册目标 ClickHouse 连接参数；未设置的 TLS 参数沿用源端 --ch-tls-*。
+func addSchemaDiffTargetFlags(c *cobra.Command) {
+	c.Flags().String("target-host", "", "目标 ClickHouse host（默认与源端相同的地址，见 --ch-addrs/--ch-host）")
+	c.Flags().Int("target-port", 0, "目标 ClickHouse 端口（默认 --ch-port）")
+	c.Flags().String("target-user", "", "目标 ClickHouse 用户（默认 --ch-user）")
+	c.Flags().String("target-password", "", "目标 ClickHouse 密码（默认空）")
+	c.Flags().Bool("target-secure", false, "目标 ClickHouse 是否启用 TLS（默认同 --ch-secure）")
+	c.Flags().String("target-tls-ca", "", "目标 ClickHouse TLS CA 证书路径（默认 --ch-tls-ca）")
+	c.Flags().String("target-tls-cert", "", "目标 ClickHouse TLS 客户端证书路径（默认 --ch-tls-cert）")
+	c.Flags().String("target-tls-key", "", "目标 ClickHouse TLS 客户端私钥路径（默认 --ch-tls-key）")
+	c.Flags().String("target-tls-server-name", "", "目标 ClickHouse TLS 校验的服务端名称（默认 --ch-tls-server-name）")
+	c.Flags().Bool("target-tls-insecure-skip-verify", false, "跳过目标 ClickHouse 服务端证书校验（默认同 --ch-tls-insecure-skip-verify）")
+}
+
+// schemaDiffTargetOptions 以源端连接选项（超时、连接池、协议、TLS 等）为基础，按 --target-* 参数覆盖得到目标连接选项。
+func schemaDiffTargetOptions(cmd *cobra.Command, database string) (clickhouse.ConnOptions, error) {
+	o, err := chConnOptions(nil, database)
+	if err != nil {
+		return o, err
+	}
+	host, _ := cmd.Flags().GetString("target-host")
+	port, _ := cmd.Flags().GetInt("target-port")
+	if strings.TrimSpace(host) != "" || port > 0 {
+		if strings.TrimSpace(host) == "" {
+			host = chHost
+		}
+		if port <= 0 {
+			port = chPort
+		}
+		o.Addrs = []string{net.JoinHostPort(strings.TrimSpace(host), strconv.Itoa(port))}
+	}
+	if user, _ := cmd.Flags().GetString("target-user"); strings.TrimSpace(user) != "" {
+		o.User = user
+	}
+	password, _ := cmd.Flags().GetString("target-password")
+	password, err = resolveSecretValue("target-password", password)
+	if err != nil {
+		return o, err
+	}
+	redact.Register(password)
+	o.Password = password
+	if cmd.Flags().Changed("target-secure") {
+		o.Secure, _ = cmd.Flags().GetBool("target-secure")
+	}
+	for flag, dst := range map[string]*string{
+		"target-tls-ca":          &o.CAFile,
+		"target-tls-cert":        &o.CertFile,
+		"target-tls-key":         &o.KeyFile,
+		"target-tls-server-name": &o.ServerName,
+	} {
+		if v, _ := cmd.Flags().GetString(flag); strings.TrimSpace(v) != "" {
+			*dst = v
+		}
+	}
+	if cmd.Flags().Changed("target-tls-insecure-skip-verify") {
+		o.InsecureSkipVerify, _ = cmd.Flags().GetBool("target-tls-insecure-skip-verify")
+	}
+	return o, nil
+}
+
+// addSchemaDiffCategoryFlags 为 schema-diff 系列命令注册差异类别过滤与对齐脚本参数。
+func addSchemaDiffCategoryFlags(c *cobra.Command) {
+	all := strings.Join(clickhouse.SchemaDiffCategories, ",")
+	c.Flags().String("include-categories", "", "仅对比指定的差异类别，逗号分隔（默认全部；可选 "+all+"）")
+	c.Flags().String("ignore-categories", "", "忽略指定差异类别，逗号分隔（例如 settings,codecs）")
+	c.Flags().String("emit-sql", "", "将让目标表向源表看齐的 ALTER ADD/MODIFY/DROP COLUMN 语句及回滚语句写入文件")
+	c.Flags().Bool("allow-lossy", false, "emit-sql 中有损收窄/不兼容的 MODIFY 不加注释保护")
+	c.Flags().Bool("allow-drop", false, "emit-sql 中目标多余列的 DROP COLUMN 不加注释保护")
+}
+
+// reconcileStatements 根据列差异生成目标表的对齐语句（被规则忽略的差异不生成）。
+func reconcileStatements(cmd *cobra.Command, d *tableSchemaDiff, tgtDB string, tgtTable string) []clickhouse.ReconcileStatement {
+	allowLossy, _ := cmd.Flags().GetBool("allow-lossy")
+	allowDrop, _ := cmd.Flags().GetBool("allow-drop")
+	return clickhouse.ReconcileColumns(tgtDB, tgtTable, d.SourceCols, d.TargetCols, d.TypeDiffs, clickhouse.ReconcileOptions{AllowLossy: allowLossy, AllowDrop: allowDrop})
+}
+
+// writeSQLFile 先写临时文件再重命名，避免中途失败留下半份脚本。
+func writeSQLFile(path string, content string) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(content), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
 }
@@ -3,8 +3,11 @@ package cmd
 import (
 	"click-house-sync/internal/clickhouse"
 	"click-house-sync/internal/config"
+	"database/sql"
 	"fmt"
+	"regexp"
 	"strings"
+	"sync"
 
 	"github.com/spf13/cobra"
 )
@@ -22,11 +25,23 @@ var schemaDiffBatchCmd = &cobra.Command{
 			"error_tables":  []map[string]any{},
 			"forced_output": true,
 		}
+		format, failOn := "json", ""
 		fail := func(msg string) error {
 			result["fatal_error"] = msg
-			printJSON(result)
+			if format == "json" {
+				printJSON(result)
+			}
+			// 非 JSON 报告或 CI 模式下致命错误需体现在退出码上
+			if format != "json" || failOn != "" {
+				return fmt.Errorf("%s", msg)
+			}
 			return nil
 		}
+		f, fo, err := schemaDiffReportOptions(cmd)
+		if err != nil {
+			return fail(err.Error())
+		}
+		format, failOn = f, fo
 		tablesPath, _ := cmd.Flags().GetString("tables-file")
 		if strings.TrimSpace(tablesPath) == "" {
 			tablesPath = tablesFile
@@ -35,142 +50,314 @@ var schemaDiffBatchCmd = &cobra.Command{
 			tablesPath = "tables.yaml"
 		}
 		result["tables_file"] = tablesPath
-		targetHost, _ := cmd.Flags().GetString("target-host")
-		targetPort, _ := cmd.Flags().GetInt("target-port")
-		targetUser, _ := cmd.Flags().GetString("target-user")
-		targetPassword, _ := cmd.Flags().GetString("target-password")
-		targetSecure, _ := cmd.Flags().GetBool("target-secure")
 		targetDBFlag, _ := cmd.Flags().GetString("target-db")
 
-		if strings.TrimSpace(targetHost) == "" {
-			targetHost = chHost
-		}
-		if targetPort <= 0 {
-			targetPort = chPort
-		}
-		if strings.TrimSpace(targetUser) == "" {
-			targetUser = chUser
-		}
-
-		srcConn, err := clickhouse.Connect(chHost, chPort, chUser, chPassword, chDatabase, chSecure)
+		srcConn, err := connectClickHouse(nil, chDatabase)
 		if err != nil {
 			return fail(err.Error())
 		}
 		defer srcConn.Close()
 
-		tgtConn, err := clickhouse.Connect(targetHost, targetPort, targetUser, targetPassword, chDatabase, targetSecure)
+		tgtOpts, err := schemaDiffTargetOptions(cmd, chDatabase)
+		if err != nil {
+			return fail(err.Error())
+		}
+		tgtConn, err := clickhouse.Open(tgtOpts)
 		if err != nil {
 			return fail(err.Error())
 		}
 		defer tgtConn.Close()
 
-		tlist, err := config.LoadTablesFile(tablesPath)
+		cats, err := schemaDiffCategories(cmd)
 		if err != nil {
 			return fail(err.Error())
 		}
-		if len(tlist) == 0 {
-			return fail("tables_file 无表项")
+		result["categories"] = enabledCategories(cats)
+
+		sourceDBFlag, _ := cmd.Flags().GetString("source-database")
+		var jobs []schemaDiffJob
+		var sourceOnly, targetOnly []string
+		var wideSrcDB, wideTgtDB string
+		if strings.TrimSpace(sourceDBFlag) != "" {
+			// 整库模式：不读取 tables.yaml，按库列出两侧数据表
+			wideSrcDB = strings.TrimSpace(sourceDBFlag)
+			wideTgtDB = strings.TrimSpace(targetDBFlag)
+			if wideTgtDB == "" && cmd.Root().PersistentFlags().Changed("target-database") {
+				wideTgtDB = strings.TrimSpace(targetDatabase)
+			}
+			if wideTgtDB == "" {
+				wideTgtDB = wideSrcDB
+			}
+			delete(result, "tables_file")
+			result["source_database"] = wideSrcDB
+			result["target_database"] = wideTgtDB
+			jobs, sourceOnly, targetOnly, err = databaseDiffJobs(cmd, srcConn, wideSrcDB, tgtConn, wideTgtDB)
+			if err != nil {
+				return fail(err.Error())
+			}
+			result["source_only_tables"] = sourceOnly
+			result["target_only_tables"] = targetOnly
+		} else {
+			tlist, err := config.LoadTablesFile(tablesPath)
+			if err != nil {
+				return fail(err.Error())
+			}
+			if len(tlist) == 0 {
+				return fail("tables_file 无表项")
+			}
+			for _, t := range tlist {
+				srcDB := chDatabase
+				if strings.TrimSpace(t.CurrentDatabase) != "" {
+					srcDB = t.CurrentDatabase
+				}
+				tgtDB := targetDBFlag
+				if strings.TrimSpace(tgtDB) == "" {
+					if strings.TrimSpace(t.TargetDatabase) != "" {
+						tgtDB = t.TargetDatabase
+					} else if strings.TrimSpace(targetDatabase) != "" {
+						tgtDB = targetDatabase
+					} else {
+						tgtDB = chDatabase
+					}
+				}
+				tgtTable := t.Name
+				if strings.TrimSpace(t.TargetTable) != "" {
+					tgtTable = t.TargetTable
+				}
+				t := t
+				jobs = append(jobs, schemaDiffJob{
+					Name:  t.Name,
+					Src:   schemaSide{Conn: srcConn, Database: srcDB, Table: t.Name},
+					Tgt:   schemaSide{Conn: tgtConn, Database: tgtDB, Table: tgtTable},
+					Rules: typeRulesFor(&t),
+				})
+			}
 		}
+		concurrency, _ := cmd.Flags().GetInt("concurrency")
+		runSchemaDiffJobs(jobs, cats, concurrency)
 
 		total := 0
 		okCount := 0
 		errCount := 0
+		incompatibleCount := 0
+		emitSQL, _ := cmd.Flags().GetString("emit-sql")
+		var sqlBlocks []string
+		reconcileCount := 0
 		errorTables := make([]map[string]any, 0)
+		reports := make([]schemaDiffTableReport, 0, len(jobs)+len(sourceOnly)+len(targetOnly))
 
-		for _, t := range tlist {
-			srcDB := chDatabase
-			if strings.TrimSpace(t.CurrentDatabase) != "" {
-				srcDB = t.CurrentDatabase
-			}
-			tgtDB := targetDBFlag
-			if strings.TrimSpace(tgtDB) == "" {
-				if strings.TrimSpace(t.TargetDatabase) != "" {
-					tgtDB = t.TargetDatabase
-				} else if strings.TrimSpace(targetDatabase) != "" {
-					tgtDB = targetDatabase
-				} else {
-					tgtDB = chDatabase
-				}
-			}
-			tgtTable := t.Name
-			if strings.TrimSpace(t.TargetTable) != "" {
-				tgtTable = t.TargetTable
-			}
+		for _, t := range jobs {
+			srcDB, tgtDB, tgtTable := t.Src.Database, t.Tgt.Database, t.Tgt.Table
 			total++
-			srcCols, e1 := clickhouse.GetColumns(srcConn, srcDB, t.Name)
-			if e1 != nil {
+			d, err := t.Diff, t.Err
+			if err != nil {
 				errCount++
+				incompatibleCount++
 				errorTables = append(errorTables, map[string]any{
 					"table":  t.Name,
 					"source": fmt.Sprintf("%s.%s", srcDB, t.Name),
 					"target": fmt.Sprintf("%s.%s", tgtDB, tgtTable),
-					"error":  e1.Error(),
+					"error":  err.Error(),
 				})
-				continue
-			}
-			tgtCols, e2 := clickhouse.GetColumns(tgtConn, tgtDB, tgtTable)
-			if e2 != nil {
-				errCount++
-				errorTables = append(errorTables, map[string]any{
-					"table":  t.Name,
-					"source": fmt.Sprintf("%s.%s", srcDB, t.Name),
-					"target": fmt.Sprintf("%s.%s", tgtDB, tgtTable),
-					"error":  e2.Error(),
+				reports = append(reports, schemaDiffTableReport{
+					Table:  t.Name,
+					Source: fmt.Sprintf("%s.%s", srcDB, t.Name),
+					Target: fmt.Sprintf("%s.%s", tgtDB, tgtTable),
+					Error:  err.Error(),
 				})
 				continue
 			}
-
-			typeDiffs := clickhouse.AnalyzeTypeDiff(srcCols, tgtCols)
-			srcPos := map[string]uint64{}
-			tgtPos := map[string]uint64{}
-			for _, c := range srcCols {
-				srcPos[c.Name] = c.Position
-			}
-			for _, c := range tgtCols {
-				tgtPos[c.Name] = c.Position
+			rep := tableReportFromDiff(t.Name, fmt.Sprintf("%s.%s", srcDB, t.Name), fmt.Sprintf("%s.%s", tgtDB, tgtTable), d)
+			reports = append(reports, rep)
+			compatible := rep.SyncCompatible
+			if !compatible {
+				incompatibleCount++
 			}
-			orderDiffs := make([]map[string]any, 0)
-			for name, sPos := range srcPos {
-				if tPos, ok := tgtPos[name]; ok && sPos != tPos {
-					orderDiffs = append(orderDiffs, map[string]any{
-						"column":          name,
-						"source_position": sPos,
-						"target_position": tPos,
-					})
-				}
-			}
-
-			if len(typeDiffs) == 0 && len(orderDiffs) == 0 {
+			if d.Count() == 0 {
 				okCount++
 				continue
 			}
 			errCount++
-			errorTables = append(errorTables, map[string]any{
-				"table":       t.Name,
-				"source":      fmt.Sprintf("%s.%s", srcDB, t.Name),
-				"target":      fmt.Sprintf("%s.%s", tgtDB, tgtTable),
-				"type_diffs":  typeDiffs,
-				"order_diffs": orderDiffs,
-			})
+			entry := map[string]any{
+				"table":           t.Name,
+				"source":          fmt.Sprintf("%s.%s", srcDB, t.Name),
+				"target":          fmt.Sprintf("%s.%s", tgtDB, tgtTable),
+				"type_diffs":      d.TypeDiffs,
+				"ignored_diffs":   d.IgnoredDiffs,
+				"order_diffs":     d.OrderDiffs,
+				"diffs":           d.Structural,
+				"sync_compatible": compatible,
+			}
+			if strings.TrimSpace(emitSQL) != "" {
+				stmts := reconcileStatements(cmd, d, tgtDB, tgtTable)
+				if len(stmts) > 0 {
+					header := fmt.Sprintf("%s.%s -> %s.%s", srcDB, t.Name, tgtDB, tgtTable)
+					sqlBlocks = append(sqlBlocks, clickhouse.RenderReconcileSQL(header, stmts))
+					reconcileCount += len(stmts)
+				}
+				entry["reconcile"] = stmts
+			}
+			errorTables = append(errorTables, entry)
+		}
+		// 只存在于一侧的表：目标缺表视为不可同步，目标多出的表仅计为差异
+		for _, n := range sourceOnly {
+			total++
+			errCount++
+			incompatibleCount++
+			reports = append(reports, schemaDiffTableReport{Table: n, Source: wideSrcDB + "." + n, OnlyIn: "source"})
+		}
+		for _, n := range targetOnly {
+			total++
+			errCount++
+			reports = append(reports, schemaDiffTableReport{Table: n, Target: wideTgtDB + "." + n, OnlyIn: "target", SyncCompatible: true})
 		}
 
+		if strings.TrimSpace(emitSQL) != "" {
+			if err := writeSQLFile(emitSQL, strings.Join(sqlBlocks, "\n")); err != nil {
+				return fail(err.Error())
+			}
+			result["emit_sql"] = emitSQL
+			result["reconcile_count"] = reconcileCount
+		}
 		result["total_count"] = total
 		result["correct_count"] = okCount
 		result["error_count"] = errCount
 		result["error_tables"] = errorTables
 		result["matched_exact"] = errCount == 0 && total > 0
-		printJSON(result)
-		return nil
+		result["incompatible_count"] = incompatibleCount
+		result["sync_compatible"] = incompatibleCount == 0 && total > 0
+		title := "schema-diff-batch: " + tablesPath
+		if wideSrcDB != "" {
+			title = "schema-diff-batch: " + wideSrcDB + " -> " + wideTgtDB
+		}
+		if err := writeSchemaDiffReport(cmd, format, title, result, reports); err != nil {
+			return err
+		}
+		return schemaDiffFailure(failOn, reports)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(schemaDiffBatchCmd)
 	schemaDiffBatchCmd.Flags().String("tables-file", "", "tables.yaml 路径（默认 --tables-file）")
-	schemaDiffBatchCmd.Flags().String("target-db", "", "目标库名（优先级高于 tables.yaml 的 target_database）")
-	schemaDiffBatchCmd.Flags().String("target-host", "", "目标 ClickHouse host（默认 --ch-host）")
-	schemaDiffBatchCmd.Flags().Int("target-port", 0, "目标 ClickHouse 端口（默认 --ch-port）")
-	schemaDiffBatchCmd.Flags().String("target-user", "", "目标 ClickHouse 用户（默认 --ch-user）")
-	schemaDiffBatchCmd.Flags().String("target-password", "", "目标 ClickHouse 密码（默认空）")
-	schemaDiffBatchCmd.Flags().Bool("target-secure", false, "目标 ClickHouse 是否启用 TLS")
+	schemaDiffBatchCmd.Flags().String("target-db", "", "目标库名（优先级高于 tables.yaml 的 target_database；整库模式默认 --target-database 或与源库同名）")
+	addSchemaDiffTargetFlags(schemaDiffBatchCmd)
+	schemaDiffBatchCmd.Flags().String("source-database", "", "整库模式：对比该源库下全部数据表（不读取 tables.yaml）")
+	schemaDiffBatchCmd.Flags().String("include", "", "整库模式：仅对比名称匹配该正则的表")
+	schemaDiffBatchCmd.Flags().String("exclude", "", "整库模式：跳过名称匹配该正则的表")
+	schemaDiffBatchCmd.Flags().Int("concurrency", 4, "并发对比的表数")
+	addSchemaDiffCategoryFlags(schemaDiffBatchCmd)
+	addSchemaDiffReportFlags(schemaDiffBatchCmd)
+}
+
+// schemaDiffJob 是一张表的对比任务，Diff/Err 由 runSchemaDiffJobs 填充。
+type schemaDiffJob struct {
+	Name  string
+	Src   schemaSide
+	Tgt   schemaSide
+	Rules clickhouse.TypeRules
+	Diff  *tableSchemaDiff
+	Err   error
+}
+
+// runSchemaDiffJobs 以固定并发数执行对比任务，结果写回各任务（保持原有顺序）。
+func runSchemaDiffJobs(jobs []schemaDiffJob, cats map[string]bool, concurrency int) {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	workCh := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range workCh {
+				j := &jobs[idx]
+				j.Diff, j.Err = diffTableSides(j.Src, j.Tgt, cats, j.Rules)
+			}
+		}()
+	}
+	for i := range jobs {
+		workCh <- i
+	}
+	close(workCh)
+	wg.Wait()
+}
+
+// databaseDiffJobs 列出两侧库中的数据表并按 --include/--exclude 过滤；两侧 system.columns 各只查询一次。
+// 返回同名表的对比任务，以及只存在于源库、只存在于目标库的表名。
+func databaseDiffJobs(cmd *cobra.Command, srcConn *sql.DB, srcDB string, tgtConn *sql.DB, tgtDB string) ([]schemaDiffJob, []string, []string, error) {
+	include, _ := cmd.Flags().GetString("include")
+	exclude, _ := cmd.Flags().GetString("exclude")
+	var incRe, excRe *regexp.Regexp
+	var err error
+	if strings.TrimSpace(include) != "" {
+		if incRe, err = regexp.Compile(include); err != nil {
+			return nil, nil, nil, fmt.Errorf("无效的 --include: %v", err)
+		}
+	}
+	if strings.TrimSpace(exclude) != "" {
+		if excRe, err = regexp.Compile(exclude); err != nil {
+			return nil, nil, nil, fmt.Errorf("无效的 --exclude: %v", err)
+		}
+	}
+	keep := func(name string) bool {
+		return (incRe == nil || incRe.MatchString(name)) && (excRe == nil || !excRe.MatchString(name))
+	}
+	srcTables, err := clickhouse.ListDataTables(srcConn, srcDB)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	tgtTables, err := clickhouse.ListDataTables(tgtConn, tgtDB)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	srcCols, err := clickhouse.GetDatabaseColumns(srcConn, srcDB)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	tgtCols, err := clickhouse.GetDatabaseColumns(tgtConn, tgtDB)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	inTarget := map[string]bool{}
+	for _, n := range tgtTables {
+		if keep(n) {
+			inTarget[n] = true
+		}
+	}
+	var jobs []schemaDiffJob
+	sourceOnly := []string{}
+	for _, n := range srcTables {
+		if !keep(n) {
+			continue
+		}
+		if !inTarget[n] {
+			sourceOnly = append(sourceOnly, n)
+			continue
+		}
+		delete(inTarget, n)
+		tconf, _ := lookupTableConfig(n)
+		jobs = append(jobs, schemaDiffJob{
+			Name:  n,
+			Src:   schemaSide{Conn: srcConn, Database: srcDB, Table: n, Columns: nonNilColumns(srcCols[n])},
+			Tgt:   schemaSide{Conn: tgtConn, Database: tgtDB, Table: n, Columns: nonNilColumns(tgtCols[n])},
+			Rules: typeRulesFor(tconf),
+		})
+	}
+	targetOnly := []string{}
+	for _, n := range tgtTables {
+		if inTarget[n] {
+			targetOnly = append(targetOnly, n)
+		}
+	}
+	return jobs, sourceOnly, targetOnly, nil
+}
+
+// nonNilColumns 保证预取结果非 nil，避免回退到逐表查询。
+func nonNilColumns(cols []clickhouse.ColumnDetail) []clickhouse.ColumnDetail {
+	if cols == nil {
+		return []clickhouse.ColumnDetail{}
+	}
+	return cols
 }
@@ -0,0 +1,294 @@
+package cmd
+
+import (
+	"click-house-sync/internal/clickhouse"
+	"click-house-sync/internal/redact"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// schemaDiffTableReport 是报告中单表的对比结果；Error 非空表示该表读取失败，OnlyIn 为 source/target 表示表只存在于一侧。
+type schemaDiffTableReport struct {
+	Table          string
+	Source         string
+	Target         string
+	Error          string
+	OnlyIn         string
+	Diff           *tableSchemaDiff
+	SyncCompatible bool
+}
+
+func (r schemaDiffTableReport) count() int {
+	if r.OnlyIn != "" {
+		return 1
+	}
+	if r.Diff == nil {
+		return 0
+	}
+	return r.Diff.Count()
+}
+
+func (r schemaDiffTableReport) structuralCount() int {
+	n := 0
+	if r.Diff != nil {
+		for _, items := range r.Diff.Structural {
+			n += len(items)
+		}
+	}
+	return n
+}
+
+// status 返回 ok / mismatch / incompatible / error。
+func (r schemaDiffTableReport) status() string {
+	switch {
+	case r.Error != "":
+		return "error"
+	case !r.SyncCompatible:
+		return "incompatible"
+	case r.count() > 0:
+		return "mismatch"
+	}
+	return "ok"
+}
+
+// details 返回逐条差异描述，用于 Markdown/JUnit/HTML 明细。
+func (r schemaDiffTableReport) details() []string {
+	if r.Error != "" {
+		return []string{r.Error}
+	}
+	switch r.OnlyIn {
+	case "source":
+		return []string{"table: table_missing_in_target"}
+	case "target":
+		return []string{"table: table_missing_in_source"}
+	}
+	var out []string
+	if r.Diff == nil {
+		return out
+	}
+	for _, d := range r.Diff.TypeDiffs {
+		line := fmt.Sprintf("columns: %s %s (%s -> %s)", d.Column, d.Issue, d.SourceType, d.TargetType)
+		if d.Class != "" {
+			line += " [" + d.Class + "]"
+		}
+		out = append(out, line)
+	}
+	for _, d := range r.Diff.OrderDiffs {
+		out = append(out, fmt.Sprintf("order: %v position %v -> %v", d["column"], d["source_position"], d["target_position"]))
+	}
+	var cats []string
+	for c := range r.Diff.Structural {
+		cats = append(cats, c)
+	}
+	sort.Strings(cats)
+	for _, c := range cats {
+		for _, it := range r.Diff.Structural[c] {
+			obj := ""
+			if it.Object != "" {
+				obj = it.Object + " "
+			}
+			out = append(out, fmt.Sprintf("%s: %s%s (%s -> %s)", c, obj, it.Issue, it.Source, it.Target))
+		}
+	}
+	return out
+}
+
+// addSchemaDiffReportFlags 注册报告格式、输出文件与 CI 失败条件参数。
+func addSchemaDiffReportFlags(c *cobra.Command) {
+	c.Flags().String("format", "json", "输出格式：json|markdown|junit|html")
+	c.Flags().String("output", "", "将 markdown/junit/html 报告写入文件（默认输出到 stdout）")
+	c.Flags().String("fail-on", "", "存在差异时以非零退出码结束：mismatch（任意差异）|incompatible（不可同步）")
+}
+
+// schemaDiffReportOptions 读取并校验 --format 与 --fail-on。
+func schemaDiffReportOptions(cmd *cobra.Command) (string, string, error) {
+	format, _ := cmd.Flags().GetString("format")
+	failOn, _ := cmd.Flags().GetString("fail-on")
+	format = strings.ToLower(strings.TrimSpace(format))
+	failOn = strings.ToLower(strings.TrimSpace(failOn))
+	switch format {
+	case "", "json":
+		format = "json"
+	case "markdown", "md":
+		format = "markdown"
+	case "junit", "html":
+	default:
+		return "", "", fmt.Errorf("不支持的 --format: %s（可选 json|markdown|junit|html）", format)
+	}
+	switch failOn {
+	case "", "mismatch", "incompatible":
+	default:
+		return "", "", fmt.Errorf("不支持的 --fail-on: %s（可选 mismatch|incompatible）", failOn)
+	}
+	return format, failOn, nil
+}
+
+// writeSchemaDiffReport 按格式输出报告；json 格式输出 result，其余格式由表级结果渲染。
+func writeSchemaDiffReport(cmd *cobra.Command, format string, title string, result map[string]any, tables []schemaDiffTableReport) error {
+	if format == "json" {
+		printJSON(result)
+		return nil
+	}
+	var content string
+	switch format {
+	case "markdown":
+		content = renderSchemaDiffMarkdown(title, tables)
+	case "junit":
+		s, err := renderSchemaDiffJUnit(title, tables)
+		if err != nil {
+			return err
+		}
+		content = s
+	case "html":
+		content = renderSchemaDiffHTML(title, tables)
+	}
+	content = redact.String(content)
+	output, _ := cmd.Flags().GetString("output")
+	if strings.TrimSpace(output) != "" {
+		return writeSQLFile(output, content)
+	}
+	_, err := os.Stdout.WriteString(content)
+	return err
+}
+
+// schemaDiffFailure 按 --fail-on 判断是否需要非零退出。
+func schemaDiffFailure(failOn string, tables []schemaDiffTableReport) error {
+	if failOn == "" {
+		return nil
+	}
+	var bad []string
+	for _, t := range tables {
+		st := t.status()
+		if st == "error" || st == "incompatible" || (failOn == "mismatch" && st == "mismatch") {
+			bad = append(bad, t.Table+"("+st+")")
+		}
+	}
+	if len(bad) == 0 {
+		return nil
+	}
+	return fmt.Errorf("schema_drift: %d 张表未通过 --fail-on %s: %s", len(bad), failOn, strings.Join(bad, ", "))
+}
+
+func schemaDiffSummary(tables []schemaDiffTableReport) map[string]int {
+	out := map[string]int{"ok": 0, "mismatch": 0, "incompatible": 0, "error": 0}
+	for _, t := range tables {
+		out[t.status()]++
+	}
+	return out
+}
+
+func markdownCell(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "|", `\|`), "\n", " ")
+}
+
+// renderSchemaDiffMarkdown 输出可直接贴入 MR 的汇总表与差异明细。
+func renderSchemaDiffMarkdown(title string, tables []schemaDiffTableReport) string {
+	var b strings.Builder
+	sum := schemaDiffSummary(tables)
+	fmt.Fprintf(&b, "### %s\n\n", title)
+	fmt.Fprintf(&b, "共 %d 张表：一致 %d，有差异 %d，不可同步 %d，读取失败 %d\n\n", len(tables), sum["ok"], sum["mismatch"], sum["incompatible"], sum["error"])
+	b.WriteString("| 表 | 目标 | 列差异 | 位置差异 | 结构差异 | 可同步 | 状态 |\n")
+	b.WriteString("|---|---|---:|---:|---:|:---:|---|\n")
+	for _, t := range tables {
+		cols, order := 0, 0
+		if t.Diff != nil {
+			cols, order = len(t.Diff.TypeDiffs), len(t.Diff.OrderDiffs)
+		}
+		sync := "是"
+		if !t.SyncCompatible {
+			sync = "否"
+		}
+		fmt.Fprintf(&b, "| `%s` | `%s` | %d | %d | %d | %s | %s |\n", markdownCell(t.Table), markdownCell(t.Target), cols, order, t.structuralCount(), sync, t.status())
+	}
+	for _, t := range tables {
+		if t.status() == "ok" {
+			continue
+		}
+		fmt.Fprintf(&b, "\n<details><summary><code>%s</code> %s</summary>\n\n", html.EscapeString(t.Table), t.status())
+		for _, d := range t.details() {
+			fmt.Fprintf(&b, "- %s\n", markdownCell(html.EscapeString(d)))
+		}
+		b.WriteString("\n</details>\n")
+	}
+	return b.String()
+}
+
+type junitSuites struct {
+	XMLName xml.Name     `xml:"testsuites"`
+	Suites  []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Errors   int         `xml:"errors,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitProblem `xml:"failure,omitempty"`
+	Error     *junitProblem `xml:"error,omitempty"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Body    string `xml:",chardata"`
+}
+
+// renderSchemaDiffJUnit 把每张表输出为一个 testcase：有差异记为 failure，读取失败记为 error。
+func renderSchemaDiffJUnit(title string, tables []schemaDiffTableReport) (string, error) {
+	suite := junitSuite{Name: title, Tests: len(tables)}
+	for _, t := range tables {
+		c := junitCase{Name: t.Table, ClassName: t.Target}
+		switch st := t.status(); st {
+		case "error":
+			suite.Errors++
+			c.Error = &junitProblem{Message: t.Error, Type: st}
+		case "mismatch", "incompatible":
+			suite.Failures++
+			c.Failure = &junitProblem{Message: fmt.Sprintf("%d 处差异", t.count()), Type: st, Body: strings.Join(t.details(), "\n")}
+		}
+		suite.Cases = append(suite.Cases, c)
+	}
+	b, err := xml.MarshalIndent(junitSuites{Suites: []junitSuite{suite}}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return xml.Header + string(b) + "\n", nil
+}
+
+// renderSchemaDiffHTML 输出独立的 HTML 报告页面。
+func renderSchemaDiffHTML(title string, tables []schemaDiffTableReport) string {
+	var b strings.Builder
+	sum := schemaDiffSummary(tables)
+	e := html.EscapeString
+	b.WriteString("<!DOCTYPE html>\n<html><head><meta charset=\"utf-8\"><title>" + e(title) + "</title>\n")
+	b.WriteString("<style>body{font-family:sans-serif}table{border-collapse:collapse}td,th{border:1px solid #ccc;padding:4px 8px}.ok{color:#2a7}.mismatch{color:#c80}.incompatible,.error{color:#c22}</style>\n</head><body>\n")
+	fmt.Fprintf(&b, "<h2>%s</h2>\n<p>共 %d 张表：一致 %d，有差异 %d，不可同步 %d，读取失败 %d</p>\n", e(title), len(tables), sum["ok"], sum["mismatch"], sum["incompatible"], sum["error"])
+	b.WriteString("<table>\n<tr><th>表</th><th>目标</th><th>差异数</th><th>状态</th><th>明细</th></tr>\n")
+	for _, t := range tables {
+		st := t.status()
+		var details []string
+		for _, d := range t.details() {
+			details = append(details, e(d))
+		}
+		fmt.Fprintf(&b, "<tr><td>%s</td><td>%s</td><td>%d</td><td class=\"%s\">%s</td><td>%s</td></tr>\n", e(t.Table), e(t.Target), t.count(), st, st, strings.Join(details, "<br>"))
+	}
+	b.WriteString("</table>\n</body></html>\n")
+	return b.String()
+}
+
+// tableReportFromDiff 由单表对比结果构造报告项。
+func tableReportFromDiff(table string, source string, target string, d *tableSchemaDiff) schemaDiffTableReport {
+	return schemaDiffTableReport{Table: table, Source: source, Target: target, Diff: d, SyncCompatible: clickhouse.SyncCompatible(d.TypeDiffs)}
+}
@@ -2,7 +2,6 @@
 package cmd
 
 import (
-	"click-house-sync/internal/clickhouse"
 	"database/sql"
 	"fmt"
 	"os"
@@ -67,11 +66,11 @@ var sqlCmd = &cobra.Command{
 			}
 		}
 
-		db, err := clickhouse.Connect(chHost, chPort, chUser, chPassword, chDatabase, chSecure)
+		db, err := connectClickHouse(nil, chDatabase)
 		if err != nil {
 			// 当默认库不存在时，回退到 default，行为更接近 clickhouse-client。
 			if isDatabaseNotExistError(err) && !strings.EqualFold(strings.TrimSpace(chDatabase), "default") {
-				db, err = clickhouse.Connect(chHost, chPort, chUser, chPassword, "default", chSecure)
+				db, err = connectClickHouse(nil, "default")
 			}
 			if err != nil {
 				return err
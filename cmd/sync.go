@@ -5,6 +5,8 @@ import (
 	"click-house-sync/internal/clickhouse"
 	"click-house-sync/internal/config"
 	kadmin "click-house-sync/internal/kafka"
+	"click-house-sync/internal/naming"
+	"database/sql"
 	"fmt"
 	"strings"
 
@@ -33,11 +35,22 @@ var syncCmd = &cobra.Command{
 		if tablesCSV != "" {
 			names = splitCSV(tablesCSV)
 		}
+		if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+			tables, err := loadPlanTables(names)
+			if err != nil {
+				return err
+			}
+			return printDryRun(cmd, "sync", tables, planOptions{
+				queryable:       queryableMV,
+				sourceMVToKafka: sourceMVToKafka,
+				kafkaDatabase:   strings.TrimSpace(kafkaDatabaseFlag),
+			})
+		}
 		if tablesFile == "" {
 			tablesFile = "tables.yaml"
 		}
 		// 建立 ClickHouse 连接
-		db, err := clickhouse.Connect(chHost, chPort, chUser, chPassword, chDatabase, chSecure)
+		db, err := connectClickHouse(nil, chDatabase)
 		if err != nil {
 			return err
 		}
@@ -63,21 +76,47 @@ var syncCmd = &cobra.Command{
 		if len(targetList) == 0 {
 			return fmt.Errorf("tables_file 无表项或未匹配到指定表")
 		}
+		if err := checkNameCollisions(cmd, targetList, planOptions{
+			queryable:       queryableMV,
+			sourceMVToKafka: sourceMVToKafka,
+			kafkaDatabase:   strings.TrimSpace(kafkaDatabaseFlag),
+		}); err != nil {
+			return err
+		}
 		var results []map[string]any
 		dbset := map[string]struct{}{}
+		// 表级连接只在该表的处理过程中使用，进入下一张表（或命令返回）时关闭
+		var tableConn *sql.DB
+		closeTableConn := func() {
+			if tableConn != nil {
+				tableConn.Close()
+				tableConn = nil
+			}
+		}
+		defer closeTableConn()
 		for i, t := range targetList {
+			closeTableConn()
+			// 表级 clickhouse 配置覆盖连接选项时，为该表单独建立连接
+			db := db
+			if t.ClickHouse != nil {
+				tdb, err := connectClickHouse(&t, chDatabase)
+				if err != nil {
+					results = append(results, map[string]any{"table": t.Name, "error": err.Error()})
+					if continueOnError {
+						continue
+					}
+					return err
+				}
+				tableConn = tdb
+				db = tdb
+			}
 			// 决定源/目标库：优先使用表级配置，其次全局参数
 			srcDB := chDatabase
 			if !cmd.Root().PersistentFlags().Changed("ch-database") && t.CurrentDatabase != "" {
 				srcDB = t.CurrentDatabase
 			}
 			dbset[srcDB] = struct{}{}
-			tgtDB := chDatabase
-			if cmd.Root().PersistentFlags().Changed("target-database") {
-				tgtDB = targetDatabase
-			} else if t.TargetDatabase != "" {
-				tgtDB = t.TargetDatabase
-			}
+			tgtDB := targetDatabaseFor(cmd, &t)
 			tgtTable := targetTable
 			if strings.TrimSpace(tgtTable) == "" {
 				if strings.TrimSpace(t.TargetTable) != "" {
@@ -88,7 +127,7 @@ var syncCmd = &cobra.Command{
 			}
 
 			// 构造资源参数：topic、brokers、replicas、分区估算、批量大小、group
-			topic := srcDB + "_" + t.Name
+			topic := naming.Topic(srcDB, t.Name)
 			if cmd.Root().PersistentFlags().Changed("kafka-topic") && strings.TrimSpace(kafkaTopic) != "" {
 				topic = kafkaTopic
 			}
@@ -115,7 +154,7 @@ var syncCmd = &cobra.Command{
 			} else if strings.TrimSpace(t.GroupName) != "" {
 				group = t.GroupName
 			} else {
-				group = groupName + "-" + t.Name
+				group = naming.Group(srcDB, t.Name, groupName)
 			}
 			// 推送模式：不创建目标 MergeTree 表
 			n, err := clickhouse.CountTableRows(db, srcDB, t.Name)
@@ -128,9 +167,23 @@ var syncCmd = &cobra.Command{
 			}
 			p := clickhouse.PartitionsForRows(n, rowsPer)
 			if recreateTopic {
-				_ = kadmin.DeleteTopic(brokers, topic)
+				if err := kadmin.DeleteTopic(brokers, topic); err != nil && !kadmin.IsUnknownTopicOrPartition(err) {
+					results = append(results, map[string]any{"table": t.Name, "error": err.Error()})
+					if continueOnError {
+						continue
+					}
+					return err
+				}
 			}
-			if err := kadmin.CreateTopic(brokers, topic, p, rep); err != nil {
+			topicConfigs, err := topicConfigFor(&t)
+			if err != nil {
+				results = append(results, map[string]any{"table": t.Name, "error": err.Error()})
+				if continueOnError {
+					continue
+				}
+				return err
+			}
+			if err := kadmin.CreateTopicWithConfigs(brokers, topic, p, rep, topicConfigs); err != nil {
 				results = append(results, map[string]any{"table": t.Name, "error": err.Error()})
 				if continueOnError {
 					continue
@@ -150,11 +203,16 @@ var syncCmd = &cobra.Command{
 				return err
 			}
 			if recreate {
-				_ = clickhouse.DropMaterializedViewIfExists(db, kafkaDB, "mv_"+t.Name)
-				_ = clickhouse.DropMaterializedViewIfExists(db, kafkaDB, "mv_from_kafka_"+t.Name)
-				_ = clickhouse.DropMaterializedViewIfExists(db, kafkaDB, "mv_to_kafka_"+t.Name)
-				_ = clickhouse.DropTableIfExists(db, kafkaDB, "kafka_"+t.Name)
-				_ = clickhouse.DropTableIfExists(db, kafkaDB, "kafka_"+t.Name+"_sink")
+				// 对象位置与 teardown 一致：落库 MV 在目标库，sink 与推送 MV 在 Kafka 库
+				n := resolvePipelineNames(cmd, t, kafkaDB)
+				n.srcDB, n.tgtDB, n.tgtTable, n.topic, n.brokers = srcDB, tgtDB, tgtTable, topic, brokers
+				if err := dropKafkaObjects(db, n, t.Name); err != nil {
+					results = append(results, map[string]any{"table": t.Name, "error": err.Error()})
+					if continueOnError {
+						continue
+					}
+					return err
+				}
 			}
 			// 创建 Kafka 引擎表（字段结构对齐源表）
 			// decide MV engine and extras for Kafka sink schema per table
@@ -174,13 +232,24 @@ var syncCmd = &cobra.Command{
 			if strings.TrimSpace(t.VersionColumn) != "" {
 				verCol = t.VersionColumn
 			}
-			if strings.ToLower(strings.TrimSpace(eng)) == "replacing" && strings.TrimSpace(verCol) == "" {
-				verCol = "version"
-			}
 			sCol := signColumn
 			if strings.TrimSpace(t.SignColumn) != "" {
 				sCol = t.SignColumn
 			}
+			layout, err := targetLayoutFor(&t)
+			if err == nil && layout == clickhouse.TargetLayoutMirror && queryableMV && !sourceMVToKafka {
+				err = applyMirrorMVLayout(cmd, db, srcDB, t.Name, &t, &eng, &mvOrd, &mvPart, &verCol, &sCol)
+			}
+			if err != nil {
+				results = append(results, map[string]any{"table": t.Name, "error": err.Error()})
+				if continueOnError {
+					continue
+				}
+				return err
+			}
+			if strings.ToLower(strings.TrimSpace(eng)) == "replacing" && strings.TrimSpace(verCol) == "" {
+				verCol = "version"
+			}
 			extras := map[string]string{}
 			switch strings.ToLower(strings.TrimSpace(eng)) {
 			case "replacing":
@@ -203,7 +272,15 @@ var syncCmd = &cobra.Command{
 					extras["version"] = "UInt64"
 				}
 			}
-			if err := clickhouse.CreateKafkaTableFromSource(db, srcDB, t.Name, kafkaDB, brokers, topic, group, "JSONEachRow", 1, kafkaMaxBlockSize, kafkaAutoOffsetReset, extras); err != nil {
+			mapper, err := typeMapperFor(&t)
+			if err != nil {
+				results = append(results, map[string]any{"table": t.Name, "error": err.Error()})
+				if continueOnError {
+					continue
+				}
+				return err
+			}
+			if err := clickhouse.CreateKafkaTableFromSource(db, srcDB, t.Name, kafkaDB, brokers, topic, group, kafkaSettingsFor(&t), extras, mapper); err != nil {
 				results = append(results, map[string]any{"table": t.Name, "error": err.Error()})
 				if continueOnError {
 					continue
@@ -236,7 +313,7 @@ var syncCmd = &cobra.Command{
 					return err
 				}
 			} else {
-				if err := clickhouse.CreateTargetTableLikeSource(db, srcDB, t.Name, tgtDB, tgtTable, "tuple()", ""); err != nil {
+				if err := createTargetTable(db, layout, srcDB, t.Name, tgtDB, tgtTable, "tuple()", "", mapper); err != nil {
 					results = append(results, map[string]any{"table": t.Name, "error": err.Error()})
 					if continueOnError {
 						continue
@@ -260,7 +337,7 @@ var syncCmd = &cobra.Command{
 					return err
 				}
 				typeDiffs = clickhouse.AnalyzeTypeDiff(sourceCols, targetCols)
-				if err := clickhouse.CreateMaterializedView(db, kafkaDB, t.Name, tgtDB, tgtTable); err != nil {
+				if err := clickhouse.CreateMaterializedView(db, kafkaDB, srcDB, t.Name, tgtDB, tgtTable); err != nil {
 					results = append(results, map[string]any{"table": t.Name, "error": err.Error()})
 					if continueOnError {
 						continue
@@ -303,7 +380,7 @@ var syncCmd = &cobra.Command{
 				if cursorStartFromTarget && strings.TrimSpace(curCol) != "" {
 					var src string
 					if queryableMV {
-						src = qualified(tgtDB, "mv_from_kafka_"+t.Name)
+						src = qualified(tgtDB, naming.MVFromKafka(srcDB, t.Name))
 					} else {
 						tgtTbl := targetTable
 						if strings.TrimSpace(tgtTbl) == "" {
@@ -342,15 +419,16 @@ var syncCmd = &cobra.Command{
 				"partitions":         p,
 				"replication_factor": rep,
 				"group":              group,
-				"kafka_table":        fmt.Sprintf("%s.%s", kafkaDB, "kafka_"+t.Name+"_sink"),
+				"kafka_table":        fmt.Sprintf("%s.%s", kafkaDB, naming.Sink(srcDB, t.Name)),
 				"type_diffs":         typeDiffs,
 				"source":             fmt.Sprintf("%s.%s", srcDB, t.Name),
 			}
 			if sourceMVToKafka {
-				m["materialized_view_to_kafka"] = fmt.Sprintf("%s.%s", kafkaDB, "mv_to_kafka_"+t.Name)
+				m["materialized_view_to_kafka"] = fmt.Sprintf("%s.%s", kafkaDB, naming.MVToKafka(srcDB, t.Name))
 			} else {
 				m["target_table"] = fmt.Sprintf("%s.%s", tgtDB, tgtTable)
-				m["materialized_view"] = fmt.Sprintf("%s.%s", kafkaDB, "mv_from_kafka_"+t.Name)
+				m["materialized_view"] = fmt.Sprintf("%s.%s", tgtDB, naming.MVFromKafka(srcDB, t.Name))
+				m["target_layout"] = layout
 			}
 			results = append(results, m)
 		}
@@ -384,8 +462,9 @@ func init() {
 	syncCmd.Flags().Bool("full-export", false, "创建资源后对所有表执行全量导出到Kafka（覆盖 prepare-only，忽略表级/全局游标过滤）")
 	syncCmd.Flags().Bool("recreate", false, "删除并重建 Kafka 表与物化视图（应用新的 brokers/offset/reset 设置）")
 	syncCmd.Flags().Bool("recreate-topic", false, "按 tables.yaml 重新创建 Kafka 主题（先删除旧主题再创建）")
-	syncCmd.Flags().Bool("source-mv-to-kafka", false, "在源库创建 mv_to_kafka_<table>（实时写入 Kafka），不创建目标落库 MV")
+	syncCmd.Flags().Bool("source-mv-to-kafka", false, "在源库创建推送物化视图（默认 mv_to_kafka_<table>，实时写入 Kafka），不创建目标落库 MV")
 	syncCmd.Flags().String("kafka-database", "", "Kafka 引擎表与 MV 所在库（默认跟随 target-database）")
+	syncCmd.Flags().Bool("dry-run", false, "仅输出将要创建的对象与 SQL（同 plan），不做任何变更也不导出数据")
 }
 
 // splitCSV 将逗号分隔的字符串拆分并去除空格。
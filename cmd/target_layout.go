@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"click-house-sync/internal/clickhouse"
+	"click-house-sync/internal/config"
+	"database/sql"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// createTargetTable 按布局创建目标表：mirror 复刻源表结构，custom 按 orderBy/partitionBy 创建 MergeTree 表。
+func createTargetTable(db *sql.DB, layout string, srcDB string, table string, tgtDB string, tgtTable string, orderBy string, partitionBy string, mapper *clickhouse.TypeMapper) error {
+	if layout == clickhouse.TargetLayoutMirror {
+		_, err := clickhouse.CreateTargetTableMirror(db, srcDB, table, tgtDB, tgtTable, mapper)
+		return err
+	}
+	return clickhouse.CreateTargetTableLikeSource(db, srcDB, table, tgtDB, tgtTable, orderBy, partitionBy, mapper)
+}
+
+// applyMirrorMVLayout 在 mirror 布局下用源表引擎推导的查询 MV 参数替换未显式指定的项；
+// 命令行参数或 tables.yaml 中给出的值保持不变（配置文件 sync 段的 mv_* 仅作为 custom 布局的默认值）。
+func applyMirrorMVLayout(cmd *cobra.Command, db *sql.DB, srcDB string, table string, t *config.Table, eng *string, mvOrd *string, mvPart *string, verCol *string, sCol *string) error {
+	ml, err := clickhouse.GetSourceMVLayout(db, srcDB, table)
+	if err != nil {
+		return err
+	}
+	var tc config.Table
+	if t != nil {
+		tc = *t
+	}
+	explicit := func(flag string, tableValue string) bool {
+		return cmd.Root().PersistentFlags().Changed(flag) || strings.TrimSpace(tableValue) != ""
+	}
+	if !explicit("mv-engine", tc.MVEngine) {
+		*eng = ml.Engine
+	}
+	if !explicit("mv-order-by", tc.MVOrderBy) {
+		*mvOrd = ml.OrderBy
+	}
+	if !explicit("mv-partition-by", tc.MVPartitionBy) {
+		*mvPart = ml.PartitionBy
+	}
+	if !explicit("version-column", tc.VersionColumn) && ml.VersionColumn != "" {
+		*verCol = ml.VersionColumn
+	}
+	if !explicit("sign-column", tc.SignColumn) && ml.SignColumn != "" {
+		*sCol = ml.SignColumn
+	}
+	return nil
+}
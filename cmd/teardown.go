@@ -0,0 +1,309 @@
+// cmd 包包含删除单表整条链路的 teardown 命令。
+package cmd
+
+import (
+	"bufio"
+	"click-house-sync/internal/clickhouse"
+	"click-house-sync/internal/config"
+	kadmin "click-house-sync/internal/kafka"
+	"click-house-sync/internal/plan"
+	"database/sql"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// teardownCmd 按依赖顺序删除表链路上的物化视图、Kafka 引擎表、错误流、目标表与 Topic，是 prepare/sync 的逆操作。
+var teardownCmd = &cobra.Command{
+	Use:   "teardown",
+	Short: "删除单表或多表的同步链路",
+	Long:  "按依赖顺序删除表链路对象（对象名按 naming 模板解析）：先停写入（推送与落库物化视图、隔离与错误流视图），再删 Kafka 引擎表、错误流表、隔离表、目标表，最后删除 Topic。--keep-target 保留目标数据（目标表、隔离表、错误流表与自带存储的查询型 MV），--keep-topic 保留 Topic。旧版命名的 mv_<table>/kafka_<table> 仅在其定义指向本链路（kafka_<table> 消费本链路 Topic、mv_<table> 读取该表或本链路 sink）或指定 --include-legacy 时删除。执行前输出将删除的对象并要求输入 yes 确认（--yes 跳过确认）；任一对象删除失败时该表剩余对象不再删除，并在结果中列出。",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		table, _ := cmd.Flags().GetString("table")
+		tablesCSV, _ := cmd.Flags().GetString("tables")
+		keepTarget, _ := cmd.Flags().GetBool("keep-target")
+		keepTopic, _ := cmd.Flags().GetBool("keep-topic")
+		kafkaDatabaseFlag, _ := cmd.Flags().GetString("kafka-database")
+		yes, _ := cmd.Flags().GetBool("yes")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		includeLegacy, _ := cmd.Flags().GetBool("include-legacy")
+		var tables []config.Table
+		if strings.TrimSpace(table) != "" {
+			tconf, err := lookupTableConfig(table)
+			if err != nil {
+				return err
+			}
+			t := config.Table{Name: table}
+			if tconf != nil {
+				t = *tconf
+			}
+			tables = []config.Table{t}
+		} else {
+			list, err := loadPlanTables(splitCSV(tablesCSV))
+			if err != nil {
+				return err
+			}
+			tables = list
+		}
+		// 多张表解析到同一对象时，删除一张表的链路会波及另一张
+		if err := checkNameCollisions(cmd, tables, planOptions{kafkaDatabase: strings.TrimSpace(kafkaDatabaseFlag)}); err != nil {
+			return err
+		}
+		db, err := connectClickHouse(nil, chDatabase)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		type tableTeardown struct {
+			table   string
+			db      *sql.DB
+			objects []teardownObject
+		}
+		var all []tableTeardown
+		var preview []map[string]any
+		for _, t := range tables {
+			tdb := db
+			if t.ClickHouse != nil {
+				c, err := connectClickHouse(&t, chDatabase)
+				if err != nil {
+					return fmt.Errorf("%s: %v", t.Name, err)
+				}
+				defer c.Close()
+				tdb = c
+			}
+			names := resolvePipelineNames(cmd, t, strings.TrimSpace(kafkaDatabaseFlag))
+			objs, err := collectTeardownObjects(tdb, t.Name, names, keepTarget, keepTopic, includeLegacy)
+			if err != nil {
+				return fmt.Errorf("%s: %v", t.Name, err)
+			}
+			all = append(all, tableTeardown{table: t.Name, db: tdb, objects: objs})
+			for _, o := range objs {
+				preview = append(preview, o.result(t.Name, o.action()))
+			}
+		}
+		if len(preview) == 0 {
+			printJSON(map[string]any{"command": "teardown", "objects": []any{}, "message": "未找到需要删除的对象"})
+			return nil
+		}
+		if dryRun || !yes {
+			printJSON(map[string]any{"command": "teardown", "preview": true, "objects": preview})
+		}
+		if dryRun {
+			return nil
+		}
+		if !yes && !confirmTeardown() {
+			return fmt.Errorf("teardown 已取消")
+		}
+
+		var results []map[string]any
+		var failed []string
+		dropped := 0
+		for _, tt := range all {
+			stopped := false
+			for _, o := range tt.objects {
+				switch {
+				case o.keep:
+					results = append(results, o.result(tt.table, "kept"))
+				case stopped:
+					results = append(results, o.result(tt.table, "not_run"))
+				default:
+					if err := o.drop(tt.db); err != nil {
+						r := o.result(tt.table, "failed")
+						r["error"] = err.Error()
+						results = append(results, r)
+						failed = append(failed, o.object())
+						stopped = true
+					} else {
+						results = append(results, o.result(tt.table, "dropped"))
+						dropped++
+					}
+				}
+			}
+		}
+		printJSON(map[string]any{
+			"command": "teardown",
+			"dropped": dropped,
+			"failed":  failed,
+			"results": results,
+		})
+		if len(failed) > 0 {
+			return fmt.Errorf("%d 个对象删除失败: %s", len(failed), strings.Join(failed, ", "))
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(teardownCmd)
+	teardownCmd.Flags().String("table", "", "要删除链路的源表名；为空时处理 tables.yaml（--tables-file）中的表")
+	teardownCmd.Flags().String("tables", "", "仅处理 tables.yaml 中的指定表（逗号分隔）")
+	teardownCmd.Flags().Bool("keep-target", false, "保留目标数据：目标表、隔离表、错误流表与自带存储的查询型 MV")
+	teardownCmd.Flags().Bool("keep-topic", false, "保留 Kafka 主题")
+	teardownCmd.Flags().String("kafka-database", "", "Kafka 引擎表与 MV 所在库（默认跟随 target-database）")
+	teardownCmd.Flags().Bool("include-legacy", false, "同时删除 Kafka 库中旧版命名的 mv_<table>/kafka_<table>，不检查其定义是否属于本链路")
+	teardownCmd.Flags().Bool("yes", false, "跳过交互确认直接删除")
+	teardownCmd.Flags().Bool("dry-run", false, "仅输出将删除的对象，不做任何变更")
+}
+
+// teardownObject 是链路上的一个已存在对象；keep 为 true 时按参数保留。
+type teardownObject struct {
+	kind     string
+	database string
+	name     string
+	view     bool
+	keep     bool
+	topic    *plan.Topic
+}
+
+func (o teardownObject) object() string {
+	if o.topic != nil {
+		return o.topic.Name
+	}
+	return o.database + "." + o.name
+}
+
+func (o teardownObject) action() string {
+	if o.keep {
+		return "keep"
+	}
+	return "drop"
+}
+
+func (o teardownObject) result(table string, status string) map[string]any {
+	return map[string]any{"table": table, "kind": o.kind, "object": o.object(), "status": status}
+}
+
+// drop 删除对象：视图用 DROP VIEW，表用 DROP TABLE，Topic 通过 Kafka 管理接口删除。
+func (o teardownObject) drop(db *sql.DB) error {
+	if o.topic != nil {
+		return kadmin.DeleteTopic(o.topic.Brokers, o.topic.Name)
+	}
+	if o.view {
+		return clickhouse.DropMaterializedViewIfExists(db, o.database, o.name)
+	}
+	return clickhouse.DropTableIfExists(db, o.database, o.name)
+}
+
+// collectTeardownObjects 按删除顺序列出单表链路上已存在的对象：写入方（各物化视图）在前，被写入的表在后，Topic 最后。
+// 旧版命名的 mv_<table>/kafka_<table> 仅在 includeLegacy 或其定义属于本链路时列入。
+func collectTeardownObjects(db *sql.DB, table string, n pipelineNames, keepTarget bool, keepTopic bool, includeLegacy bool) ([]teardownObject, error) {
+	legacyMV, legacyKafka := includeLegacy, includeLegacy
+	if !includeLegacy {
+		var err error
+		if legacyMV, legacyKafka, err = ownedLegacyObjects(db, n.kafkaDB, table, n.topic, n.sink); err != nil {
+			return nil, err
+		}
+	}
+	candidates := []teardownObject{
+		{kind: plan.KindMVToKafka, database: n.kafkaDB, name: n.mvToKafka, view: true},
+		{kind: plan.KindMV, database: n.tgtDB, name: n.mvFromKafka, view: true},
+		{kind: "quarantine_view", database: n.tgtDB, name: clickhouse.QuarantineViewName(n.srcDB, table), view: true},
+		{kind: "error_stream_view", database: n.kafkaDB, name: clickhouse.KafkaErrorsViewName(n.srcDB, table), view: true},
+	}
+	if legacyMV {
+		candidates = append(candidates, teardownObject{kind: "legacy_mv", database: n.kafkaDB, name: "mv_" + table, view: true})
+	}
+	candidates = append(candidates, teardownObject{kind: plan.KindSink, database: n.kafkaDB, name: n.sink})
+	if legacyKafka {
+		candidates = append(candidates, teardownObject{kind: "legacy_kafka", database: n.kafkaDB, name: "kafka_" + table})
+	}
+	candidates = append(candidates,
+		teardownObject{kind: plan.KindErrorStream, database: n.kafkaDB, name: clickhouse.KafkaErrorsTableName(n.srcDB, table), keep: keepTarget},
+		teardownObject{kind: "quarantine", database: n.tgtDB, name: clickhouse.QuarantineTableName(n.srcDB, table), keep: keepTarget},
+		teardownObject{kind: plan.KindTarget, database: n.tgtDB, name: n.tgtTable, keep: keepTarget},
+	)
+	var out []teardownObject
+	seen := map[string]bool{}
+	for _, o := range candidates {
+		// 目标表与源表同库同名时（未指定目标库）绝不删除源表
+		if o.kind == plan.KindTarget && o.database == n.srcDB && o.name == table {
+			continue
+		}
+		if seen[o.object()] {
+			continue
+		}
+		seen[o.object()] = true
+		ok, err := clickhouse.TableExists(db, o.database, o.name)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		if o.kind == plan.KindMV && keepTarget {
+			own, err := clickhouse.MaterializedViewHasOwnStorage(db, o.database, o.name)
+			if err != nil {
+				return nil, err
+			}
+			o.keep = own
+		}
+		out = append(out, o)
+	}
+	parts, err := kadmin.ReadTopicPartitions(n.brokers, n.topic)
+	if err != nil && !kadmin.IsUnknownTopicOrPartition(err) {
+		return nil, err
+	}
+	if err == nil && len(parts) > 0 {
+		out = append(out, teardownObject{kind: plan.KindTopic, keep: keepTopic, topic: &plan.Topic{Name: n.topic, Brokers: n.brokers}})
+	}
+	return out, nil
+}
+
+// ownedLegacyObjects 判断 kafkaDB 中旧版命名的对象是否属于本链路：kafka_<table> 消费的是 topic，
+// mv_<table> 的定义读取属于本链路的 kafka_<table> 或本链路的 sink。对象不存在时返回 false。
+func ownedLegacyObjects(db *sql.DB, kafkaDB string, table string, topic string, sink string) (bool, bool, error) {
+	legacyKafka := "kafka_" + table
+	kafkaOwned := false
+	ok, err := clickhouse.TableExists(db, kafkaDB, legacyKafka)
+	if err != nil {
+		return false, false, err
+	}
+	if ok {
+		t, _, err := clickhouse.KafkaTableTopicGroup(db, kafkaDB, legacyKafka)
+		if err != nil {
+			return false, false, err
+		}
+		kafkaOwned = t != "" && t == topic
+	}
+	q, err := clickhouse.TableCreateQuery(db, kafkaDB, "mv_"+table)
+	if err != nil || q == "" {
+		return false, kafkaOwned, err
+	}
+	mvOwned := referencesIdent(q, sink) || (kafkaOwned && referencesIdent(q, legacyKafka))
+	return mvOwned, kafkaOwned, nil
+}
+
+// referencesIdent 判断 SQL 文本是否以完整标识符（可带反引号）引用 name。
+func referencesIdent(query string, name string) bool {
+	return regexp.MustCompile("(^|[^A-Za-z0-9_])" + regexp.QuoteMeta(name) + "($|[^A-Za-z0-9_])").MatchString(query)
+}
+
+// dropKafkaObjects 删除 sync --recreate 需要重建的对象：collectTeardownObjects 列出的全部视图（含目标库中的落库 MV）
+// 与 Kafka 引擎表；目标表、错误流表、隔离表与 Topic 保留。返回首个删除失败的对象。
+func dropKafkaObjects(db *sql.DB, n pipelineNames, table string) error {
+	objs, err := collectTeardownObjects(db, table, n, true, true, false)
+	if err != nil {
+		return err
+	}
+	for _, o := range objs {
+		// 自带存储的查询型 MV 在 keepTarget 下标记为保留，重建时仍需删除
+		if o.topic != nil || (o.keep && !o.view) {
+			continue
+		}
+		if err := o.drop(db); err != nil {
+			return fmt.Errorf("drop %s: %v", o.object(), err)
+		}
+	}
+	return nil
+}
+
+// confirmTeardown 在标准错误输出提示并从标准输入读取确认，仅输入 yes 时继续。
+func confirmTeardown() bool {
+	fmt.Fprint(os.Stderr, "将删除以上对象，输入 yes 确认: ")
+	line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	return strings.TrimSpace(line) == "yes"
+}
@@ -0,0 +1,130 @@
+// clickhouse 包中导出自定义函数、行策略与用户/角色/授权 DDL 的辅助方法（gen-ddl 的可选段）。
+package clickhouse
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// AccessDDL 是用户、角色与授权的 DDL，按角色、用户、授权的顺序执行。
+type AccessDDL struct {
+	Roles  []string `json:"roles"`
+	Users  []string `json:"users"`
+	Grants []string `json:"grants"`
+}
+
+// UserDefinedFunctionsDDL 返回 SQL 自定义函数（system.functions 中 origin = 'SQLUserDefined'）的建函数语句，按名称排序。
+func UserDefinedFunctionsDDL(db *sql.DB) ([]string, error) {
+	rs, err := db.Query("SELECT create_query FROM system.functions WHERE origin = 'SQLUserDefined' ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Close()
+	var out []string
+	for rs.Next() {
+		var q string
+		if err := rs.Scan(&q); err != nil {
+			return nil, err
+		}
+		out = append(out, withIfNotExists(q, "CREATE FUNCTION"))
+	}
+	return out, rs.Err()
+}
+
+// RowPoliciesDDL 返回作用于库内表的行策略（不含 users.xml 中定义的策略）。
+func RowPoliciesDDL(db *sql.DB, database string) ([]string, error) {
+	rs, err := db.Query("SELECT short_name, table FROM system.row_policies WHERE database = ? AND storage != 'users_xml' ORDER BY table, short_name", database)
+	if err != nil {
+		return nil, err
+	}
+	type policy struct{ name, table string }
+	var list []policy
+	for rs.Next() {
+		var p policy
+		if err := rs.Scan(&p.name, &p.table); err != nil {
+			rs.Close()
+			return nil, err
+		}
+		list = append(list, p)
+	}
+	rs.Close()
+	if err := rs.Err(); err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, p := range list {
+		var q string
+		if err := db.QueryRow(fmt.Sprintf("SHOW CREATE ROW POLICY %s ON %s", quoteIdent(p.name), qualified(database, p.table))).Scan(&q); err != nil {
+			return nil, err
+		}
+		out = append(out, withIfNotExists(q, "CREATE ROW POLICY"))
+	}
+	return out, nil
+}
+
+// GetAccessDDL 返回 SQL 管理的角色、用户（不含 users.xml 中定义的）及其授权。
+// SHOW CREATE USER 是否包含密码哈希取决于服务端的 display_secrets_in_show_and_select 设置。
+func GetAccessDDL(db *sql.DB) (*AccessDDL, error) {
+	roles, err := queryStrings(db, "SELECT name FROM system.roles WHERE storage != 'users_xml' ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	users, err := queryStrings(db, "SELECT name FROM system.users WHERE storage != 'users_xml' ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	out := &AccessDDL{}
+	for _, r := range roles {
+		var q string
+		if err := db.QueryRow("SHOW CREATE ROLE " + quoteIdent(r)).Scan(&q); err != nil {
+			return nil, err
+		}
+		out.Roles = append(out.Roles, withIfNotExists(q, "CREATE ROLE"))
+	}
+	for _, u := range users {
+		var q string
+		if err := db.QueryRow("SHOW CREATE USER " + quoteIdent(u)).Scan(&q); err != nil {
+			return nil, err
+		}
+		out.Users = append(out.Users, withIfNotExists(q, "CREATE USER"))
+	}
+	for _, n := range append(roles, users...) {
+		grants, err := queryStrings(db, "SHOW GRANTS FOR "+quoteIdent(n))
+		if err != nil {
+			return nil, err
+		}
+		out.Grants = append(out.Grants, grants...)
+	}
+	return out, nil
+}
+
+func queryStrings(db *sql.DB, q string, args ...any) ([]string, error) {
+	rs, err := db.Query(q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Close()
+	var out []string
+	for rs.Next() {
+		var s string
+		if err := rs.Scan(&s); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rs.Err()
+}
+
+// withIfNotExists 在 CREATE 语句前缀后补上 IF NOT EXISTS，使导出的 DDL 可重复执行。
+func withIfNotExists(ddl string, prefix string) string {
+	ddl = strings.TrimSpace(ddl)
+	if len(ddl) < len(prefix) || !strings.EqualFold(ddl[:len(prefix)], prefix) {
+		return ddl
+	}
+	rest := strings.TrimLeft(ddl[len(prefix):], " \t\n")
+	if len(rest) >= 13 && strings.EqualFold(rest[:13], "IF NOT EXISTS") {
+		return ddl
+	}
+	return prefix + " IF NOT EXISTS " + rest
+}
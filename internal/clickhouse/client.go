@@ -2,31 +2,13 @@
 package clickhouse
 
 import (
-	"crypto/tls"
+	"click-house-sync/internal/naming"
 	"database/sql"
 	"fmt"
 	"math"
 	"strings"
-
-	ch "github.com/ClickHouse/clickhouse-go/v2"
 )
 
-// Connect 返回一个可用的 ClickHouse *sql.DB 连接（v2 驱动）。
-func Connect(host string, port int, user string, password string, database string, secure bool) (*sql.DB, error) {
-	opts := &ch.Options{
-		Addr: []string{fmt.Sprintf("%s:%d", host, port)},
-		Auth: ch.Auth{Database: database, Username: user, Password: password},
-	}
-	if secure {
-		opts.TLS = &tls.Config{InsecureSkipVerify: true}
-	}
-	db := ch.OpenDB(opts)
-	if err := db.Ping(); err != nil {
-		return nil, err
-	}
-	return db, nil
-}
-
 // TableRows 保存表名与基于 system.parts 的行数估算。
 type TableRows struct {
 	Table string
@@ -68,11 +50,13 @@ type Column struct {
 	Position uint64
 }
 
+// TypeDiff 描述单列差异；类型不一致时 Class 给出兼容性类别（见 ClassifyTypeChange）。
 type TypeDiff struct {
 	Column     string `json:"column"`
 	SourceType string `json:"source_type"`
 	TargetType string `json:"target_type"`
 	Issue      string `json:"issue"`
+	Class      string `json:"class,omitempty"`
 }
 
 // GetColumns 查询 system.columns 获取源表的列结构。
@@ -110,7 +94,7 @@ func AnalyzeTypeDiff(sourceCols []Column, targetCols []Column) []TypeDiff {
 			continue
 		}
 		if normalizeCHType(s.Type) != normalizeCHType(t.Type) {
-			diffs = append(diffs, TypeDiff{Column: t.Name, SourceType: s.Type, TargetType: t.Type, Issue: "type_mismatch"})
+			diffs = append(diffs, TypeDiff{Column: t.Name, SourceType: s.Type, TargetType: t.Type, Issue: "type_mismatch", Class: ClassifyTypeChange(s.Type, t.Type)})
 		}
 	}
 	for _, s := range sourceCols {
@@ -176,102 +160,89 @@ func normalizeOrderByExpr(expr string) string {
 }
 
 func isNullableType(t string) bool {
-	s := strings.ToLower(strings.TrimSpace(t))
-	return strings.HasPrefix(s, "nullable(")
+	if n, err := ParseType(t); err == nil {
+		return n.IsNullable()
+	}
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(t)), "nullable(")
 }
 
+// unwrapNullable 去掉最外层的 Nullable，返回内部类型的原文。
 func unwrapNullable(t string) string {
-	s := strings.TrimSpace(t)
-	if isNullableType(s) {
-		i := strings.Index(s, "(")
-		j := strings.LastIndex(s, ")")
-		if i >= 0 && j > i {
-			return strings.TrimSpace(s[i+1 : j])
-		}
+	if inner, ok := unwrapType(t, "Nullable"); ok {
+		return inner
 	}
-	return s
+	return strings.TrimSpace(t)
 }
 
+// defaultExprForType 返回非 Nullable 列在 ifNull 中使用的默认值；无合适默认值时返回空串。
 func defaultExprForType(t string) string {
-	s := strings.ToLower(strings.TrimSpace(t))
+	n, err := ParseType(t)
+	if err != nil {
+		return ""
+	}
+	f := n.Unwrap().Family()
 	switch {
-	case strings.HasPrefix(s, "string"):
-		return "''"
-	case strings.HasPrefix(s, "fixedstring"):
+	case f == "string" || f == "fixedstring":
 		return "''"
-	case strings.Contains(s, "int"):
-		return "0"
-	case strings.Contains(s, "uint"):
+	case intBits(f) > 0 || isFloatFamily(f) || strings.HasPrefix(f, "decimal"):
 		return "0"
-	case strings.Contains(s, "float"):
-		return "0"
-	case strings.HasPrefix(s, "decimal"):
-		return "0"
-	case strings.HasPrefix(s, "date32"):
-		return "toDate(0)"
-	case strings.HasPrefix(s, "date"):
+	case f == "date" || f == "date32":
 		return "toDate(0)"
-	case strings.HasPrefix(s, "datetime64"):
+	case f == "datetime" || f == "datetime64":
 		return "toDateTime(0)"
-	case strings.HasPrefix(s, "datetime"):
-		return "toDateTime(0)"
-	case strings.HasPrefix(s, "uuid"):
+	case f == "uuid":
 		return "toUUID('00000000-0000-0000-0000-000000000000')"
-	default:
-		return ""
 	}
+	return ""
 }
 
-func mapBaseIntTo64(t string) string {
-	s := strings.ToLower(strings.TrimSpace(t))
-	switch s {
-	case "int128", "int256":
-		return "Int64"
-	case "uint128", "uint256":
-		return "UInt64"
-	default:
+// mapWideInt 把 (Nullable) Int128/Int256/UInt128/UInt256 按 to 改写，其余类型原样返回。
+func mapWideInt(t string, to func(family string) string) string {
+	n, err := ParseType(t)
+	if err != nil {
 		return t
 	}
-}
-
-func mapTypeTo64(t string) string {
-	if isNullableType(t) {
-		base := unwrapNullable(t)
-		mapped := mapBaseIntTo64(base)
-		if mapped != base {
-			return "Nullable(" + mapped + ")"
-		}
+	inner := n
+	if n.Family() == "nullable" && len(n.Args) == 1 {
+		inner = n.Args[0]
+	}
+	if inner.Args != nil {
+		return t
+	}
+	mapped := to(inner.Family())
+	if mapped == "" {
 		return t
 	}
-	mapped := mapBaseIntTo64(t)
+	if inner != n {
+		return "Nullable(" + mapped + ")"
+	}
 	return mapped
 }
 
-func mapBaseIntToString(t string) string {
-	s := strings.ToLower(strings.TrimSpace(t))
-	switch s {
-	case "int128", "int256", "uint128", "uint256":
-		return "String"
-	default:
-		return t
-	}
+func mapTypeTo64(t string) string {
+	return mapWideInt(t, func(f string) string {
+		switch f {
+		case "int128", "int256":
+			return "Int64"
+		case "uint128", "uint256":
+			return "UInt64"
+		}
+		return ""
+	})
 }
 
 func mapTypeToString(t string) string {
-	if isNullableType(t) {
-		base := unwrapNullable(t)
-		mapped := mapBaseIntToString(base)
-		if mapped != base {
-			return "Nullable(" + mapped + ")"
+	return mapWideInt(t, func(f string) string {
+		switch f {
+		case "int128", "int256", "uint128", "uint256":
+			return "String"
 		}
-		return t
-	}
-	mapped := mapBaseIntToString(t)
-	return mapped
+		return ""
+	})
 }
 
 // CreateKafkaTable 创建与源表结构一致的 Kafka 引擎表。
-func CreateKafkaTable(db *sql.DB, database string, table string, brokers []string, topic string, group string, format string, numConsumers int, maxBlockSize int, autoOffsetReset string) error {
+func CreateKafkaTable(db *sql.DB, database string, table string, brokers []string, topic string, group string, settings KafkaSettings) error {
 	cols, err := GetColumns(db, database, table)
 	if err != nil {
 		return err
@@ -286,70 +257,12 @@ func CreateKafkaTable(db *sql.DB, database string, table string, brokers []strin
 		}
 		ddlCols += fmt.Sprintf("%s %s", quoteIdent(c.Name), mapTypeToString(c.Type))
 	}
-	name := qualified(database, "kafka_"+table+"_sink")
-	ddl := ""
-	if strings.EqualFold(strings.TrimSpace(autoOffsetReset), "skip") {
-		ddl = fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s) ENGINE = Kafka SETTINGS kafka_broker_list = '%s', kafka_topic_list = '%s', kafka_group_name = '%s', kafka_format = '%s', kafka_num_consumers = %d, kafka_max_block_size = %d, kafka_skip_broken_messages = %d", name, ddlCols, stringsJoin(brokers), topic, group, format, numConsumers, maxBlockSize, 1000)
-	} else {
-		if strings.TrimSpace(autoOffsetReset) == "" {
-			autoOffsetReset = "latest"
-		}
-		ddl = fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s) ENGINE = Kafka SETTINGS kafka_broker_list = '%s', kafka_topic_list = '%s', kafka_group_name = '%s', kafka_format = '%s', kafka_num_consumers = %d, kafka_max_block_size = %d, kafka_auto_offset_reset = '%s', kafka_skip_broken_messages = %d", name, ddlCols, stringsJoin(brokers), topic, group, format, numConsumers, maxBlockSize, autoOffsetReset, 1000)
-	}
-	_, err = db.Exec(ddl)
-	if err != nil {
-		hasUnknownOffset := isUnknownKafkaAutoOffsetResetError(err) && !strings.EqualFold(strings.TrimSpace(autoOffsetReset), "skip")
-		hasUnknownSkipBroken := isUnknownKafkaSkipBrokenMessagesError(err)
-		if hasUnknownOffset && hasUnknownSkipBroken {
-			ddl2 := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s) ENGINE = Kafka SETTINGS kafka_broker_list = '%s', kafka_topic_list = '%s', kafka_group_name = '%s', kafka_format = '%s', kafka_num_consumers = %d, kafka_max_block_size = %d", name, ddlCols, stringsJoin(brokers), topic, group, format, numConsumers, maxBlockSize)
-			if _, e2 := db.Exec(ddl2); e2 == nil {
-				return nil
-			}
-			return fmt.Errorf("ddl_failed: %s ; error: %v", ddl2, err)
-		}
-		if hasUnknownOffset {
-			ddl2 := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s) ENGINE = Kafka SETTINGS kafka_broker_list = '%s', kafka_topic_list = '%s', kafka_group_name = '%s', kafka_format = '%s', kafka_num_consumers = %d, kafka_max_block_size = %d, kafka_skip_broken_messages = %d", name, ddlCols, stringsJoin(brokers), topic, group, format, numConsumers, maxBlockSize, 1000)
-			var e2 error
-			_, e2 = db.Exec(ddl2)
-			if e2 == nil {
-				return nil
-			}
-			if isUnknownKafkaSkipBrokenMessagesError(e2) {
-				ddl3 := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s) ENGINE = Kafka SETTINGS kafka_broker_list = '%s', kafka_topic_list = '%s', kafka_group_name = '%s', kafka_format = '%s', kafka_num_consumers = %d, kafka_max_block_size = %d", name, ddlCols, stringsJoin(brokers), topic, group, format, numConsumers, maxBlockSize)
-				var e3 error
-				_, e3 = db.Exec(ddl3)
-				if e3 == nil {
-					return nil
-				}
-				return fmt.Errorf("ddl_failed: %s ; error: %v", ddl3, err)
-			}
-			return fmt.Errorf("ddl_failed: %s ; error: %v", ddl2, err)
-		}
-		if hasUnknownSkipBroken {
-			ddl2 := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s) ENGINE = Kafka SETTINGS kafka_broker_list = '%s', kafka_topic_list = '%s', kafka_group_name = '%s', kafka_format = '%s', kafka_num_consumers = %d, kafka_max_block_size = %d, kafka_auto_offset_reset = '%s'", name, ddlCols, stringsJoin(brokers), topic, group, format, numConsumers, maxBlockSize, autoOffsetReset)
-			var e2 error
-			_, e2 = db.Exec(ddl2)
-			if e2 == nil {
-				return nil
-			}
-			if isUnknownKafkaAutoOffsetResetError(e2) && !strings.EqualFold(strings.TrimSpace(autoOffsetReset), "skip") {
-				ddl3 := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s) ENGINE = Kafka SETTINGS kafka_broker_list = '%s', kafka_topic_list = '%s', kafka_group_name = '%s', kafka_format = '%s', kafka_num_consumers = %d, kafka_max_block_size = %d", name, ddlCols, stringsJoin(brokers), topic, group, format, numConsumers, maxBlockSize)
-				var e3 error
-				_, e3 = db.Exec(ddl3)
-				if e3 == nil {
-					return nil
-				}
-				return fmt.Errorf("ddl_failed: %s ; error: %v", ddl3, err)
-			}
-			return fmt.Errorf("ddl_failed: %s ; error: %v", ddl2, err)
-		}
-		return fmt.Errorf("ddl_failed: %s ; error: %v", ddl, err)
-	}
-	return nil
+	name := qualified(database, naming.Sink(database, table))
+	return createKafkaEngineTable(db, name, ddlCols, brokers, topic, group, settings)
 }
 
-// CreateKafkaTableFromSource 在 kafkaDatabase 中按 sourceDatabase.table 的结构创建 Kafka 引擎表。
-func CreateKafkaTableFromSource(db *sql.DB, sourceDatabase string, table string, kafkaDatabase string, brokers []string, topic string, group string, format string, numConsumers int, maxBlockSize int, autoOffsetReset string, extraColumns map[string]string) error {
+// CreateKafkaTableFromSource 在 kafkaDatabase 中按 sourceDatabase.table 的结构创建 Kafka 引擎表；列类型经 mapper.SinkType 映射。
+func CreateKafkaTableFromSource(db *sql.DB, sourceDatabase string, table string, kafkaDatabase string, brokers []string, topic string, group string, settings KafkaSettings, extraColumns map[string]string, mapper *TypeMapper) error {
 	cols, err := GetColumns(db, sourceDatabase, table)
 	if err != nil {
 		return err
@@ -357,110 +270,16 @@ func CreateKafkaTableFromSource(db *sql.DB, sourceDatabase string, table string,
 	if len(cols) == 0 {
 		return fmt.Errorf("source table has no columns: %s.%s", sourceDatabase, table)
 	}
-	var ddlCols string
-	present := map[string]struct{}{}
-	for i, c := range cols {
-		present[c.Name] = struct{}{}
-		if i > 0 {
-			ddlCols += ","
-		}
-		ddlCols += fmt.Sprintf("%s %s", quoteIdent(c.Name), mapTypeToString(c.Type))
-	}
-	// append extra columns if not present in source schema
-	if len(extraColumns) > 0 {
-		for name, typ := range extraColumns {
-			name = strings.TrimSpace(name)
-			typ = strings.TrimSpace(typ)
-			if name == "" || typ == "" {
-				continue
-			}
-			if _, ok := present[name]; ok {
-				continue
-			}
-			if ddlCols != "" {
-				ddlCols += ","
-			}
-			ddlCols += fmt.Sprintf("%s %s", quoteIdent(name), typ)
-		}
-	}
-	name := qualified(kafkaDatabase, "kafka_"+table+"_sink")
-	ddl := ""
-	if strings.EqualFold(strings.TrimSpace(autoOffsetReset), "skip") {
-		ddl = fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s) ENGINE = Kafka SETTINGS kafka_broker_list = '%s', kafka_topic_list = '%s', kafka_group_name = '%s', kafka_format = '%s', kafka_num_consumers = %d, kafka_max_block_size = %d, kafka_skip_broken_messages = %d", name, ddlCols, stringsJoin(brokers), topic, group, format, numConsumers, maxBlockSize, 1000)
-	} else {
-		if strings.TrimSpace(autoOffsetReset) == "" {
-			autoOffsetReset = "latest"
-		}
-		ddl = fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s) ENGINE = Kafka SETTINGS kafka_broker_list = '%s', kafka_topic_list = '%s', kafka_group_name = '%s', kafka_format = '%s', kafka_num_consumers = %d, kafka_max_block_size = %d, kafka_auto_offset_reset = '%s', kafka_skip_broken_messages = %d", name, ddlCols, stringsJoin(brokers), topic, group, format, numConsumers, maxBlockSize, autoOffsetReset, 1000)
+	name := qualified(kafkaDatabase, naming.Sink(sourceDatabase, table))
+	if err := createKafkaEngineTable(db, name, columnsDDL(SinkColumnsFromSource(cols, extraColumns, mapper)), brokers, topic, group, settings); err != nil {
+		return err
 	}
-	_, err = db.Exec(ddl)
-	if err != nil {
-		hasUnknownOffset := isUnknownKafkaAutoOffsetResetError(err) && !strings.EqualFold(strings.TrimSpace(autoOffsetReset), "skip")
-		hasUnknownSkipBroken := isUnknownKafkaSkipBrokenMessagesError(err)
-		if hasUnknownOffset && hasUnknownSkipBroken {
-			ddl2 := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s) ENGINE = Kafka SETTINGS kafka_broker_list = '%s', kafka_topic_list = '%s', kafka_group_name = '%s', kafka_format = '%s', kafka_num_consumers = %d, kafka_max_block_size = %d", name, ddlCols, stringsJoin(brokers), topic, group, format, numConsumers, maxBlockSize)
-			if _, e2 := db.Exec(ddl2); e2 == nil {
-				return nil
-			}
-			return fmt.Errorf("ddl_failed: %s ; error: %v", ddl2, err)
-		}
-		if hasUnknownOffset {
-			ddl2 := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s) ENGINE = Kafka SETTINGS kafka_broker_list = '%s', kafka_topic_list = '%s', kafka_group_name = '%s', kafka_format = '%s', kafka_num_consumers = %d, kafka_max_block_size = %d, kafka_skip_broken_messages = %d", name, ddlCols, stringsJoin(brokers), topic, group, format, numConsumers, maxBlockSize, 1000)
-			var e2 error
-			_, e2 = db.Exec(ddl2)
-			if e2 == nil {
-				return nil
-			}
-			if isUnknownKafkaSkipBrokenMessagesError(e2) {
-				ddl3 := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s) ENGINE = Kafka SETTINGS kafka_broker_list = '%s', kafka_topic_list = '%s', kafka_group_name = '%s', kafka_format = '%s', kafka_num_consumers = %d, kafka_max_block_size = %d", name, ddlCols, stringsJoin(brokers), topic, group, format, numConsumers, maxBlockSize)
-				var e3 error
-				_, e3 = db.Exec(ddl3)
-				if e3 == nil {
-					return nil
-				}
-				return fmt.Errorf("ddl_failed: %s ; error: %v", ddl3, err)
-			}
-			return fmt.Errorf("ddl_failed: %s ; error: %v", ddl2, err)
-		}
-		if hasUnknownSkipBroken {
-			ddl2 := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s) ENGINE = Kafka SETTINGS kafka_broker_list = '%s', kafka_topic_list = '%s', kafka_group_name = '%s', kafka_format = '%s', kafka_num_consumers = %d, kafka_max_block_size = %d, kafka_auto_offset_reset = '%s'", name, ddlCols, stringsJoin(brokers), topic, group, format, numConsumers, maxBlockSize, autoOffsetReset)
-			var e2 error
-			_, e2 = db.Exec(ddl2)
-			if e2 == nil {
-				return nil
-			}
-			if isUnknownKafkaAutoOffsetResetError(e2) && !strings.EqualFold(strings.TrimSpace(autoOffsetReset), "skip") {
-				ddl3 := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s) ENGINE = Kafka SETTINGS kafka_broker_list = '%s', kafka_topic_list = '%s', kafka_group_name = '%s', kafka_format = '%s', kafka_num_consumers = %d, kafka_max_block_size = %d", name, ddlCols, stringsJoin(brokers), topic, group, format, numConsumers, maxBlockSize)
-				var e3 error
-				_, e3 = db.Exec(ddl3)
-				if e3 == nil {
-					return nil
-				}
-				return fmt.Errorf("ddl_failed: %s ; error: %v", ddl3, err)
-			}
-			return fmt.Errorf("ddl_failed: %s ; error: %v", ddl2, err)
-		}
-		return fmt.Errorf("ddl_failed: %s ; error: %v", ddl, err)
+	if settings.ErrorStream() {
+		return CreateKafkaErrorStream(db, kafkaDatabase, sourceDatabase, table)
 	}
 	return nil
 }
 
-func isUnknownKafkaAutoOffsetResetError(err error) bool {
-	s := strings.ToLower(err.Error())
-	if !strings.Contains(s, "unknown setting") {
-		return false
-	}
-	return strings.Contains(s, "kafka_auto_offset_reset")
-}
-
-func isUnknownKafkaSkipBrokenMessagesError(err error) bool {
-	s := strings.ToLower(err.Error())
-	if !strings.Contains(s, "unknown setting") {
-		return false
-	}
-	return strings.Contains(s, "kafka_skip_broken_messages")
-}
-
 func isUnknownAllowNullableKeySettingError(err error) bool {
 	if err == nil {
 		return false
@@ -472,51 +291,29 @@ func isUnknownAllowNullableKeySettingError(err error) bool {
 	return strings.Contains(s, "allow_nullable_key")
 }
 
-// CreateMaterializedView 通过物化视图将 Kafka 表写入目标 MergeTree 表。
-func CreateMaterializedView(db *sql.DB, kafkaDatabase string, sourceTable string, targetDatabase string, targetTable string) error {
-	if targetDatabase == "" {
-		targetDatabase = kafkaDatabase
+// ExecWithFallback 执行 DDL；ClickHouse 报告不识别某个设置（unknown setting）时依次尝试 fallbacks，任一成功即返回，
+// 与建表方法对可选设置的回退一致。全部失败时返回原语句的错误。
+func ExecWithFallback(db *sql.DB, ddl string, fallbacks []string) error {
+	_, err := db.Exec(ddl)
+	if err == nil {
+		return nil
 	}
-	mv := qualified(targetDatabase, "mv_from_kafka_"+sourceTable)
-	sink := qualified(kafkaDatabase, "kafka_"+sourceTable+"_sink")
-	sinkCols, _ := GetColumns(db, kafkaDatabase, "kafka_"+sourceTable+"_sink")
-	var b strings.Builder
-	for i, c := range sinkCols {
-		if i > 0 {
-			b.WriteString(",")
-		}
-		base := strings.ToLower(unwrapNullable(c.Type))
-		isNull := isNullableType(c.Type)
-		name := quoteIdent(c.Name)
-		switch base {
-		case "int128", "int256":
-			if isNull {
-				b.WriteString(fmt.Sprintf("toInt64OrNull(%s) AS %s", name, name))
-			} else {
-				b.WriteString(fmt.Sprintf("toInt64(ifNull(%s, 0)) AS %s", name, name))
-			}
-		case "uint128", "uint256":
-			if isNull {
-				b.WriteString(fmt.Sprintf("toUInt64OrNull(%s) AS %s", name, name))
-			} else {
-				b.WriteString(fmt.Sprintf("toUInt64(ifNull(%s, 0)) AS %s", name, name))
-			}
-		default:
-			if isNull {
-				b.WriteString(name)
-			} else {
-				def := defaultExprForType(base)
-				if strings.TrimSpace(def) == "" {
-					b.WriteString(name)
-				} else {
-					b.WriteString(fmt.Sprintf("ifNull(%s, %s) AS %s", name, def, name))
-				}
+	if strings.Contains(strings.ToLower(err.Error()), "unknown setting") {
+		for _, f := range fallbacks {
+			if _, e2 := db.Exec(f); e2 == nil {
+				return nil
 			}
 		}
 	}
-	selectList := b.String()
-	ddl := fmt.Sprintf("CREATE MATERIALIZED VIEW IF NOT EXISTS %s TO %s AS SELECT %s FROM %s SETTINGS stream_like_engine_allow_direct_select=1, input_format_skip_unknown_fields=1, input_format_defaults_for_omitted_fields=1, input_format_null_as_default=1, input_format_json_try_infer_numbers_from_strings=1, input_format_json_read_objects_as_strings=1, date_time_input_format='best_effort', max_partitions_per_insert_block=1000", mv, qualified(targetDatabase, targetTable), selectList, sink)
-	_, err := db.Exec(ddl)
+	return fmt.Errorf("ddl_failed: %s ; error: %v", ddl, err)
+}
+
+// CreateMaterializedView 通过物化视图将 Kafka 表写入目标 MergeTree 表。
+func CreateMaterializedView(db *sql.DB, kafkaDatabase string, sourceDatabase string, sourceTable string, targetDatabase string, targetTable string) error {
+	sink := naming.Sink(sourceDatabase, sourceTable)
+	sinkCols, _ := GetColumns(db, kafkaDatabase, sink)
+	errorStream := KafkaErrorStreamEnabled(db, kafkaDatabase, sink)
+	_, err := db.Exec(MaterializedViewDDL(kafkaDatabase, sourceDatabase, sourceTable, targetDatabase, targetTable, sinkCols, errorStream))
 	return err
 }
 
@@ -528,185 +325,25 @@ func CreateMaterializedViewOwn(db *sql.DB, kafkaDatabase string, sourceDatabase
 	if err := CreateDatabaseIfNotExists(db, targetDatabase); err != nil {
 		return err
 	}
-	mv := qualified(targetDatabase, "mv_from_kafka_"+sourceTable)
-	eng := strings.ToLower(strings.TrimSpace(engine))
-	if eng == "" {
-		eng = "merge"
-	}
 	ob := strings.TrimSpace(orderBy)
-	if ob == "" {
-		if strings.TrimSpace(sourceDatabase) != "" {
-			if sk, _, err := GetTableKeys(db, sourceDatabase, sourceTable); err == nil && strings.TrimSpace(sk) != "" {
-				ob = sk
-			} else {
-				ob = "tuple()"
-			}
-		} else {
-			ob = "tuple()"
-		}
-	}
-	ob = normalizeOrderByExpr(ob)
-	var parts string
-	if strings.TrimSpace(partitionBy) != "" {
-		parts = fmt.Sprintf(" PARTITION BY %s", partitionBy)
-	} else {
-		// Prefer time-based monthly partition to avoid high-cardinality partitions
-		var timeCol string
-		if strings.TrimSpace(ttlColumn) != "" {
-			timeCol = strings.TrimSpace(ttlColumn)
-		} else {
-			sinkCols, _ := GetColumns(db, kafkaDatabase, "kafka_"+sourceTable+"_sink")
-			// first pass: common time column names with date/datetime types
-			var cands = []string{"create_date", "updated_at", "update_ts", "time", "event_time", "insert_ts", "created_at"}
-			for _, cand := range cands {
-				for _, c := range sinkCols {
-					if strings.EqualFold(c.Name, cand) {
-						tn := strings.ToLower(c.Type)
-						if strings.Contains(tn, "date") || strings.Contains(tn, "datetime") {
-							timeCol = c.Name
-							break
-						}
-					}
-				}
-				if timeCol != "" {
-					break
-				}
-			}
-			// second pass: any column with date/datetime type
-			if timeCol == "" {
-				for _, c := range sinkCols {
-					tn := strings.ToLower(c.Type)
-					if strings.Contains(tn, "date") || strings.Contains(tn, "datetime") {
-						timeCol = c.Name
-						break
-					}
-				}
-			}
-		}
-		if strings.TrimSpace(timeCol) != "" {
-			parts = fmt.Sprintf(" PARTITION BY toYYYYMM(%s)", quoteIdent(timeCol))
-		} else {
-			parts = " PARTITION BY tuple()"
-		}
-	}
-	var storage string
-	switch eng {
-	case "replacing":
-		vc := strings.TrimSpace(versionColumn)
-		if vc != "" {
-			storage = fmt.Sprintf("ENGINE = ReplacingMergeTree(%s)%s ORDER BY %s", quoteIdent(vc), parts, ob)
-		} else {
-			storage = fmt.Sprintf("ENGINE = ReplacingMergeTree()%s ORDER BY %s", parts, ob)
-		}
-	case "collapsing":
-		sc := strings.TrimSpace(signColumn)
-		if sc == "" {
-			sc = "sign"
-		}
-		storage = fmt.Sprintf("ENGINE = CollapsingMergeTree(%s)%s ORDER BY %s", quoteIdent(sc), parts, ob)
-	case "versioned_collapsing":
-		sc := strings.TrimSpace(signColumn)
-		if sc == "" {
-			sc = "sign"
-		}
-		vc := strings.TrimSpace(versionColumn)
-		if vc == "" {
-			vc = "version"
-		}
-		storage = fmt.Sprintf("ENGINE = VersionedCollapsingMergeTree(%s, %s)%s ORDER BY %s", quoteIdent(sc), quoteIdent(vc), parts, ob)
-	default:
-		storage = fmt.Sprintf("ENGINE = MergeTree%s ORDER BY %s", parts, ob)
-	}
-	if ttlDays > 0 {
-		col := strings.TrimSpace(ttlColumn)
-		if col == "" {
-			sinkCols, _ := GetColumns(db, kafkaDatabase, "kafka_"+sourceTable+"_sink")
-			cand := ""
-			for _, c := range sinkCols {
-				tn := strings.ToLower(c.Type)
-				if strings.Contains(tn, "date") {
-					n := strings.ToLower(c.Name)
-					if n == "update_ts" || n == "updated_at" || n == "ts" || n == "event_time" || n == "created_at" || n == "insert_ts" {
-						cand = c.Name
-						break
-					}
-				}
-			}
-			if cand == "" {
-				for _, c := range sinkCols {
-					tn := strings.ToLower(c.Type)
-					if strings.Contains(tn, "date") {
-						cand = c.Name
-						break
-					}
-				}
-			}
-			col = cand
-		}
-		if strings.TrimSpace(col) != "" {
-			sinkCols, _ := GetColumns(db, kafkaDatabase, "kafka_"+sourceTable+"_sink")
-			tname := ""
-			for _, c := range sinkCols {
-				if c.Name == col {
-					tname = c.Type
-					break
-				}
-			}
-			tn := strings.ToLower(tname)
-			if strings.Contains(tn, "nullable") {
-				storage = storage + fmt.Sprintf(" TTL toDateTime(%s) + INTERVAL %d DAY WHERE %s IS NOT NULL", quoteIdent(col), ttlDays, quoteIdent(col))
-			} else {
-				storage = storage + fmt.Sprintf(" TTL toDateTime(%s) + INTERVAL %d DAY", quoteIdent(col), ttlDays)
-			}
-		}
-	}
-	sinkCols, _ := GetColumns(db, kafkaDatabase, "kafka_"+sourceTable+"_sink")
-	var b strings.Builder
-	for i, c := range sinkCols {
-		if i > 0 {
-			b.WriteString(",")
-		}
-		base := strings.ToLower(unwrapNullable(c.Type))
-		isNull := isNullableType(c.Type)
-		name := quoteIdent(c.Name)
-		if eng == "replacing" && strings.TrimSpace(versionColumn) != "" && c.Name == strings.TrimSpace(versionColumn) {
-			b.WriteString(fmt.Sprintf("toUInt64(ifNull(%s, 0)) AS %s", name, name))
-			continue
-		}
-		switch base {
-		case "int128", "int256":
-			if isNull {
-				b.WriteString(fmt.Sprintf("toInt64OrNull(%s) AS %s", name, name))
-			} else {
-				b.WriteString(fmt.Sprintf("toInt64(ifNull(%s, 0)) AS %s", name, name))
-			}
-		case "uint128", "uint256":
-			if isNull {
-				b.WriteString(fmt.Sprintf("toUInt64OrNull(%s) AS %s", name, name))
-			} else {
-				b.WriteString(fmt.Sprintf("toUInt64(ifNull(%s, 0)) AS %s", name, name))
-			}
-		default:
-			if isNull {
-				b.WriteString(name)
-			} else {
-				def := defaultExprForType(base)
-				if strings.TrimSpace(def) == "" {
-					b.WriteString(name)
-				} else {
-					b.WriteString(fmt.Sprintf("ifNull(%s, %s) AS %s", name, def, name))
-				}
-			}
+	if ob == "" && strings.TrimSpace(sourceDatabase) != "" {
+		if sk, _, err := GetTableKeys(db, sourceDatabase, sourceTable); err == nil {
+			ob = strings.TrimSpace(sk)
 		}
 	}
-	selectList := b.String()
-	if maxPartitionsPerInsertBlock <= 0 {
-		maxPartitionsPerInsertBlock = 1000
+	spec := MVOwnSpec{
+		MVLayout:                    MVLayout{Engine: engine, OrderBy: ob, PartitionBy: partitionBy, VersionColumn: versionColumn, SignColumn: signColumn},
+		TTLDays:                     ttlDays,
+		TTLColumn:                   ttlColumn,
+		MaxPartitionsPerInsertBlock: maxPartitionsPerInsertBlock,
 	}
-	ddl := fmt.Sprintf("CREATE MATERIALIZED VIEW IF NOT EXISTS %s %s SETTINGS allow_nullable_key=1 AS SELECT %s FROM %s SETTINGS stream_like_engine_allow_direct_select=1, input_format_skip_unknown_fields=1, input_format_defaults_for_omitted_fields=1, input_format_null_as_default=1, input_format_json_try_infer_numbers_from_strings=1, input_format_json_read_objects_as_strings=1, date_time_input_format='best_effort', max_partitions_per_insert_block=%d", mv, storage, selectList, qualified(kafkaDatabase, "kafka_"+sourceTable+"_sink"), maxPartitionsPerInsertBlock)
+	sink := naming.Sink(sourceDatabase, sourceTable)
+	sinkCols, _ := GetColumns(db, kafkaDatabase, sink)
+	errorStream := KafkaErrorStreamEnabled(db, kafkaDatabase, sink)
+	ddl := materializedViewOwnDDL(kafkaDatabase, sourceDatabase, sourceTable, targetDatabase, sinkCols, errorStream, spec, true)
 	if _, err := db.Exec(ddl); err != nil {
 		if isUnknownAllowNullableKeySettingError(err) {
-			ddl2 := fmt.Sprintf("CREATE MATERIALIZED VIEW IF NOT EXISTS %s %s AS SELECT %s FROM %s SETTINGS stream_like_engine_allow_direct_select=1, input_format_skip_unknown_fields=1, input_format_defaults_for_omitted_fields=1, input_format_null_as_default=1, input_format_json_try_infer_numbers_from_strings=1, input_format_json_read_objects_as_strings=1, date_time_input_format='best_effort', max_partitions_per_insert_block=%d", mv, storage, selectList, qualified(kafkaDatabase, "kafka_"+sourceTable+"_sink"), maxPartitionsPerInsertBlock)
+			ddl2 := materializedViewOwnDDL(kafkaDatabase, sourceDatabase, sourceTable, targetDatabase, sinkCols, errorStream, spec, false)
 			if _, e2 := db.Exec(ddl2); e2 == nil {
 				return nil
 			}
@@ -722,54 +359,20 @@ func CreateMaterializedViewToKafka(db *sql.DB, sourceDatabase string, sourceTabl
 	if kafkaDatabase == "" {
 		kafkaDatabase = sourceDatabase
 	}
-	mv := qualified(kafkaDatabase, "mv_to_kafka_"+sourceTable)
-	targetKafka := qualified(kafkaDatabase, "kafka_"+sourceTable+"_sink")
-	src := qualified(sourceDatabase, sourceTable)
-	// Build column list (typed to sink schema) and SELECT list (cast to 64-bit when needed)
-	sinkCols, _ := GetColumns(db, kafkaDatabase, "kafka_"+sourceTable+"_sink")
-	srcCols, _ := GetColumns(db, sourceDatabase, sourceTable)
-	srcTypes := map[string]string{}
-	for _, sc := range srcCols {
-		srcTypes[sc.Name] = sc.Type
-	}
-	var selectDDL strings.Builder
-	for i, c := range sinkCols {
-		if i > 0 {
-			selectDDL.WriteString(",")
-		}
-		// SELECT expression typed to sink schema, with explicit cast when needed
-		base := strings.ToLower(unwrapNullable(c.Type))
-		isNull := isNullableType(c.Type)
-		name := quoteIdent(c.Name)
-		switch base {
-		case "int128", "int256":
-			if isNull {
-				selectDDL.WriteString(fmt.Sprintf("toInt64OrNull(%s) AS %s", name, name))
-			} else {
-				selectDDL.WriteString(fmt.Sprintf("toInt64(%s) AS %s", name, name))
-			}
-		case "uint128", "uint256":
-			if isNull {
-				selectDDL.WriteString(fmt.Sprintf("toUInt64OrNull(%s) AS %s", name, name))
-			} else {
-				selectDDL.WriteString(fmt.Sprintf("toUInt64(%s) AS %s", name, name))
-			}
-		case "string":
-			selectDDL.WriteString(fmt.Sprintf("toString(%s) AS %s", name, name))
-		default:
-			selectDDL.WriteString(name)
-		}
-	}
-	selectList := selectDDL.String()
-	ddl := fmt.Sprintf("CREATE MATERIALIZED VIEW IF NOT EXISTS %s TO %s AS SELECT %s FROM %s", mv, targetKafka, selectList, src)
-	_, err := db.Exec(ddl)
-	if err != nil {
+	sinkCols, _ := GetColumns(db, kafkaDatabase, naming.Sink(sourceDatabase, sourceTable))
+	ddl := MaterializedViewToKafkaDDL(sourceDatabase, sourceTable, kafkaDatabase, sinkCols)
+	if _, err := db.Exec(ddl); err != nil {
 		return fmt.Errorf("ddl_failed: %s ; error: %v", ddl, err)
 	}
 	return nil
 }
 
-func BuildMvSelectWithCasts(inputCols []Column, targetCols []Column) (string, error) {
+// BuildMvSelectWithCasts 生成 sink → 目标表的逐列转换 SELECT 列表；lenient 与 quarantine 模式使用宽松转换。
+func BuildMvSelectWithCasts(inputCols []Column, targetCols []Column, castMode string) (string, error) {
+	build := BuildStrictCastExpr
+	if castMode == CastModeLenient || castMode == CastModeQuarantine {
+		build = BuildLenientCastExpr
+	}
 	inMap := map[string]Column{}
 	for _, c := range inputCols {
 		inMap[c.Name] = c
@@ -780,7 +383,7 @@ func BuildMvSelectWithCasts(inputCols []Column, targetCols []Column) (string, er
 			return "", fmt.Errorf("target_column_missing_in_input: %s", t.Name)
 		}
 		src := quoteIdent(t.Name)
-		expr := BuildStrictCastExpr(src, t.Type)
+		expr := build(src, t.Type)
 		exprs = append(exprs, fmt.Sprintf("%s AS %s", expr, quoteIdent(t.Name)))
 	}
 	return strings.Join(exprs, ","), nil
@@ -795,60 +398,38 @@ func BuildSelectToString(cols []Column) string {
 	return strings.Join(exprs, ",")
 }
 
-func BuildStrictCastExpr(sourceExpr string, targetType string) string {
-	t := strings.TrimSpace(targetType)
-	if t == "" {
-		return sourceExpr
-	}
-	if inner, ok := unwrapType(t, "Nullable"); ok {
-		return fmt.Sprintf("CAST(%s, '%s')", BuildStrictCastExpr(sourceExpr, inner), t)
-	}
-	if inner, ok := unwrapType(t, "LowCardinality"); ok {
-		return fmt.Sprintf("CAST(%s, '%s')", BuildStrictCastExpr(sourceExpr, inner), t)
-	}
-	if isDateLikeType(t) {
-		return fmt.Sprintf("CAST(parseDateTimeBestEffort(%s), '%s')", sourceExpr, t)
-	}
-	if strings.EqualFold(baseTypeName(t), "String") {
-		return sourceExpr
-	}
-	return fmt.Sprintf("CAST(%s, '%s')", sourceExpr, t)
-}
-
+// unwrapType 在类型形如 fn(T) 时返回 T 的原文。
 func unwrapType(t string, fn string) (string, bool) {
-	s := strings.TrimSpace(t)
-	prefix := fn + "("
-	if !strings.HasPrefix(s, prefix) || !strings.HasSuffix(s, ")") {
+	n, err := ParseType(t)
+	if err != nil || n.Name != fn || len(n.Args) != 1 || n.Args[0].Name == "" {
 		return "", false
 	}
-	return strings.TrimSpace(s[len(prefix) : len(s)-1]), true
+	return n.Args[0].Raw(), true
 }
 
+// baseTypeName 返回去掉 Nullable/LowCardinality 包装与参数后的类型名。
 func baseTypeName(t string) string {
-	s := strings.TrimSpace(t)
-	for {
-		if inner, ok := unwrapType(s, "Nullable"); ok {
-			s = inner
-			continue
-		}
-		if inner, ok := unwrapType(s, "LowCardinality"); ok {
-			s = inner
-			continue
+	n, err := ParseType(t)
+	if err != nil {
+		s := strings.TrimSpace(t)
+		if i := strings.IndexByte(s, '('); i >= 0 {
+			s = s[:i]
 		}
-		break
+		return strings.TrimSpace(s)
 	}
-	if i := strings.IndexByte(s, '('); i >= 0 {
-		s = s[:i]
-	}
-	return strings.TrimSpace(s)
+	return n.Unwrap().Name
 }
 
 func isDateLikeType(t string) bool {
-	base := strings.ToLower(baseTypeName(t))
-	return base == "date" || base == "date32" || base == "datetime" || base == "datetime64"
+	n, err := ParseType(t)
+	return err == nil && n.IsDateLike()
 }
 
+// normalizeCHType 返回用于比较的类型形式：类型名小写、去掉字面量以外的空白；无法解析时退化为整体小写去空格。
 func normalizeCHType(t string) string {
+	if n, err := ParseType(t); err == nil {
+		return n.normalized()
+	}
 	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(t)), " ", "")
 }
 
@@ -858,8 +439,8 @@ func CreateDatabaseIfNotExists(db *sql.DB, database string) error {
 	return err
 }
 
-// CreateTargetTableLikeSource 创建与源表列一致的 MergeTree 目标表。
-func CreateTargetTableLikeSource(db *sql.DB, sourceDatabase string, sourceTable string, targetDatabase string, targetTable string, orderBy string, partitionBy string) error {
+// CreateTargetTableLikeSource 创建与源表列一致的 MergeTree 目标表；列类型经 mapper 映射（nil 时原样复制）。
+func CreateTargetTableLikeSource(db *sql.DB, sourceDatabase string, sourceTable string, targetDatabase string, targetTable string, orderBy string, partitionBy string, mapper *TypeMapper) error {
 	if targetDatabase == "" {
 		targetDatabase = sourceDatabase
 	}
@@ -873,26 +454,10 @@ func CreateTargetTableLikeSource(db *sql.DB, sourceDatabase string, sourceTable
 	if err != nil {
 		return err
 	}
-	var ddlCols string
-	for i, c := range cols {
-		if i > 0 {
-			ddlCols += ","
-		}
-		ddlCols += fmt.Sprintf("%s %s", quoteIdent(c.Name), c.Type)
-	}
-	if strings.TrimSpace(orderBy) == "" {
-		orderBy = "tuple()"
-	}
-	orderBy = normalizeOrderByExpr(orderBy)
-	var parts string
-	if strings.TrimSpace(partitionBy) != "" {
-		parts = fmt.Sprintf(" PARTITION BY %s", partitionBy)
-	}
-	name := qualified(targetDatabase, targetTable)
-	ddl := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s) ENGINE = MergeTree%s ORDER BY %s SETTINGS allow_nullable_key=1", name, ddlCols, parts, orderBy)
+	ddl := targetTableDDL(cols, targetDatabase, targetTable, orderBy, partitionBy, mapper, true)
 	if _, err = db.Exec(ddl); err != nil {
 		if isUnknownAllowNullableKeySettingError(err) {
-			ddl2 := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s) ENGINE = MergeTree%s ORDER BY %s", name, ddlCols, parts, orderBy)
+			ddl2 := targetTableDDL(cols, targetDatabase, targetTable, orderBy, partitionBy, mapper, false)
 			if _, e2 := db.Exec(ddl2); e2 == nil {
 				return nil
 			}
@@ -929,6 +494,37 @@ func DropMaterializedViewIfExists(db *sql.DB, database string, view string) erro
 	return err
 }
 
+// MaterializedViewHasOwnStorage 判断物化视图是否自带存储（建表语句中无 TO 子句），自带存储的视图删除后数据随之删除。
+func MaterializedViewHasOwnStorage(db *sql.DB, database string, view string) (bool, error) {
+	var q string
+	if err := db.QueryRow("SELECT create_table_query FROM system.tables WHERE database = ? AND name = ?", database, view).Scan(&q); err != nil {
+		return false, err
+	}
+	head := q
+	if i := strings.Index(strings.ToUpper(q), " AS SELECT"); i >= 0 {
+		head = q[:i]
+	}
+	return !strings.Contains(strings.ToUpper(head), " TO "), nil
+}
+
+// DetachTable 永久卸载表或视图（保留元数据与数据，服务重启后不会自动挂载），Kafka 引擎表卸载后消费者随之停止。
+func DetachTable(db *sql.DB, database string, table string) error {
+	_, err := db.Exec(fmt.Sprintf("DETACH TABLE IF EXISTS %s PERMANENTLY", qualified(database, table)))
+	return err
+}
+
+// DetachTableTemporarily 以非永久方式卸载表或视图：服务重启后自动重新挂载，用于短时停止消费（如重置消费组位点）。
+func DetachTableTemporarily(db *sql.DB, database string, table string) error {
+	_, err := db.Exec(fmt.Sprintf("DETACH TABLE IF EXISTS %s", qualified(database, table)))
+	return err
+}
+
+// AttachTable 重新挂载此前被 DETACH 的表或视图。
+func AttachTable(db *sql.DB, database string, table string) error {
+	_, err := db.Exec(fmt.Sprintf("ATTACH TABLE IF NOT EXISTS %s", qualified(database, table)))
+	return err
+}
+
 // PartitionsForRows 按 rowsPerPartition 将行数映射为分区数。
 func PartitionsForRows(rows uint64, rowsPerPartition int) int {
 	if rowsPerPartition <= 0 {
@@ -0,0 +1,361 @@
+// clickhouse 包中的类型兼容性分级：把源/目标列类型差异归类为等价、安全扩宽、有损收窄、不兼容或仅可空性变化。
+package clickhouse
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// 兼容性类别，按严重程度递增。
+const (
+	CompatEquivalent     = "equivalent"
+	CompatNullableChange = "nullable_change"
+	CompatSafeWidening   = "safe_widening"
+	CompatLossyNarrowing = "lossy_narrowing"
+	CompatIncompatible   = "incompatible"
+)
+
+var compatRank = map[string]int{
+	CompatEquivalent:     0,
+	CompatNullableChange: 1,
+	CompatSafeWidening:   2,
+	CompatLossyNarrowing: 3,
+	CompatIncompatible:   4,
+}
+
+func worseCompat(a string, b string) string {
+	if compatRank[b] > compatRank[a] {
+		return b
+	}
+	return a
+}
+
+// typeShape 是类型的简化结构：去掉 Nullable/LowCardinality 包装后的类型族与参数。
+type typeShape struct {
+	nullable bool
+	family   string
+	args     []*TypeNode
+	node     *TypeNode
+}
+
+func parseTypeShape(t string) typeShape {
+	n, err := ParseType(t)
+	if err != nil {
+		return typeShape{family: strings.ToLower(strings.TrimSpace(t)), node: &TypeNode{}}
+	}
+	base := n.Unwrap()
+	return typeShape{nullable: n.IsNullable(), family: base.Family(), args: base.Args, node: base}
+}
+
+// ClassifyTypeChange 判断把源类型的值写入目标类型时的兼容性类别。
+func ClassifyTypeChange(sourceType string, targetType string) string {
+	s := parseTypeShape(sourceType)
+	t := parseTypeShape(targetType)
+	base := classifyShape(s, t)
+	if s.nullable == t.nullable {
+		return base
+	}
+	switch {
+	case base == CompatEquivalent:
+		return CompatNullableChange
+	case base == CompatIncompatible:
+		return base
+	case s.nullable && !t.nullable:
+		// 目标去掉 Nullable 后 NULL 会被写成默认值
+		return CompatLossyNarrowing
+	}
+	return base
+}
+
+func classifyShape(s typeShape, t typeShape) string {
+	if s.family == t.family && s.node.normalized() == t.node.normalized() {
+		return CompatEquivalent
+	}
+	switch {
+	case t.family == "string":
+		if s.family == "string" || s.family == "fixedstring" || intBits(s.family) > 0 || isFloatFamily(s.family) ||
+			decimalPrecision(s) > 0 || isDateFamily(s.family) || isEnumFamily(s.family) ||
+			s.family == "uuid" || s.family == "ipv4" || s.family == "ipv6" || s.family == "bool" {
+			return CompatSafeWidening
+		}
+		return CompatIncompatible
+	case s.family == "fixedstring" && t.family == "fixedstring":
+		if argInt(s.args, 0) <= argInt(t.args, 0) {
+			return CompatSafeWidening
+		}
+		return CompatLossyNarrowing
+	case s.family == "string" && t.family == "fixedstring":
+		return CompatLossyNarrowing
+	case intBits(s.family) > 0 || s.family == "bool":
+		return classifyFromInt(s, t)
+	case isFloatFamily(s.family):
+		switch {
+		case isFloatFamily(t.family):
+			if floatBits(s.family) <= floatBits(t.family) {
+				return CompatSafeWidening
+			}
+			return CompatLossyNarrowing
+		case intBits(t.family) > 0 || decimalPrecision(t) > 0:
+			return CompatLossyNarrowing
+		}
+		return CompatIncompatible
+	case decimalPrecision(s) > 0:
+		switch {
+		case decimalPrecision(t) > 0:
+			sp, ss := decimalPrecision(s), decimalScale(s)
+			tp, ts := decimalPrecision(t), decimalScale(t)
+			if ts >= ss && tp-ts >= sp-ss {
+				return CompatSafeWidening
+			}
+			return CompatLossyNarrowing
+		case isFloatFamily(t.family) || intBits(t.family) > 0:
+			return CompatLossyNarrowing
+		}
+		return CompatIncompatible
+	case isDateFamily(s.family) && isDateFamily(t.family):
+		return classifyDates(s, t)
+	case isEnumFamily(s.family) && isEnumFamily(t.family):
+		sv, tv := enumValues(s.node), enumValues(t.node)
+		for k, v := range sv {
+			if tv[k] != v {
+				return CompatLossyNarrowing
+			}
+		}
+		if len(sv) == len(tv) {
+			return CompatEquivalent
+		}
+		return CompatSafeWidening
+	case s.family == t.family && (s.family == "array" || s.family == "map" || s.family == "tuple"):
+		if len(s.args) != len(t.args) {
+			return CompatIncompatible
+		}
+		out := CompatEquivalent
+		for i := range s.args {
+			out = worseCompat(out, ClassifyTypeChange(s.args[i].Raw(), t.args[i].Raw()))
+		}
+		return out
+	}
+	return CompatIncompatible
+}
+
+func classifyFromInt(s typeShape, t typeShape) string {
+	sb, su := intBits(s.family), strings.HasPrefix(s.family, "u")
+	if s.family == "bool" {
+		sb, su = 8, true
+	}
+	switch {
+	case intBits(t.family) > 0:
+		tb, tu := intBits(t.family), strings.HasPrefix(t.family, "u")
+		switch {
+		case su == tu && tb >= sb, su && !tu && tb > sb:
+			return CompatSafeWidening
+		}
+		return CompatLossyNarrowing
+	case t.family == "bool":
+		return CompatLossyNarrowing
+	case isFloatFamily(t.family):
+		// Float32 尾数 24 位、Float64 尾数 53 位
+		if (floatBits(t.family) == 32 && sb <= 16) || (floatBits(t.family) == 64 && sb <= 32) {
+			return CompatSafeWidening
+		}
+		return CompatLossyNarrowing
+	case decimalPrecision(t) > 0:
+		if decimalPrecision(t)-decimalScale(t) >= intDigits(sb, su) {
+			return CompatSafeWidening
+		}
+		return CompatLossyNarrowing
+	}
+	return CompatIncompatible
+}
+
+// classifyDates 按取值范围与精度比较日期时间类型；仅时区不同视为等价（存储值相同）。
+// 取值范围：Date 1970~2149、DateTime 1970~2106、Date32 与 DateTime64 1900~2299，因此 Date 写入 DateTime 为有损。
+func classifyDates(s typeShape, t typeShape) string {
+	if s.family == t.family {
+		if s.family == "datetime64" {
+			sp, tp := argInt(s.args, 0), argInt(t.args, 0)
+			switch {
+			case sp == tp:
+				return CompatEquivalent
+			case sp < tp:
+				return CompatSafeWidening
+			}
+			return CompatLossyNarrowing
+		}
+		return CompatEquivalent
+	}
+	safe := map[string][]string{
+		"date":     {"date32", "datetime64"},
+		"date32":   {"datetime64"},
+		"datetime": {"datetime64"},
+	}
+	for _, f := range safe[s.family] {
+		if f == t.family {
+			return CompatSafeWidening
+		}
+	}
+	return CompatLossyNarrowing
+}
+
+func intBits(family string) int {
+	f := strings.TrimPrefix(family, "u")
+	if !strings.HasPrefix(f, "int") {
+		return 0
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(f, "int"))
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// intDigits 返回整数类型最大值的十进制位数。
+func intDigits(bits int, unsigned bool) int {
+	digits := map[int][2]int{8: {3, 3}, 16: {5, 5}, 32: {10, 10}, 64: {19, 20}, 128: {39, 39}, 256: {77, 78}}
+	d := digits[bits]
+	if unsigned {
+		return d[1]
+	}
+	return d[0]
+}
+
+func isFloatFamily(family string) bool {
+	return family == "float32" || family == "float64"
+}
+
+func floatBits(family string) int {
+	if family == "float32" {
+		return 32
+	}
+	return 64
+}
+
+func isDateFamily(family string) bool {
+	return family == "date" || family == "date32" || family == "datetime" || family == "datetime64"
+}
+
+func isEnumFamily(family string) bool {
+	return family == "enum8" || family == "enum16" || family == "enum"
+}
+
+// decimalPrecision 返回 Decimal 类型的精度；非 Decimal 返回 0。
+func decimalPrecision(s typeShape) int {
+	switch s.family {
+	case "decimal":
+		return argInt(s.args, 0)
+	case "decimal32":
+		return 9
+	case "decimal64":
+		return 18
+	case "decimal128":
+		return 38
+	case "decimal256":
+		return 76
+	}
+	return 0
+}
+
+func decimalScale(s typeShape) int {
+	if s.family == "decimal" {
+		return argInt(s.args, 1)
+	}
+	return argInt(s.args, 0)
+}
+
+func argInt(args []*TypeNode, i int) int {
+	if i >= len(args) {
+		return 0
+	}
+	n, _ := strconv.Atoi(args[i].Literal)
+	return n
+}
+
+func enumValues(n *TypeNode) map[string]int64 {
+	out := map[string]int64{}
+	for _, v := range n.EnumValues() {
+		out[v.Name] = v.Value
+	}
+	return out
+}
+
+// TypeRules 是单表的 schema-diff 忽略与等价规则；列名与类型支持 * 通配。
+type TypeRules struct {
+	IgnoreColumns []string
+	Ignore        []string
+	Equivalent    []TypeEquivalence
+}
+
+// TypeEquivalence 声明一对视为等价的源/目标类型。
+type TypeEquivalence struct {
+	Source string
+	Target string
+}
+
+// globMatch 按字面匹配 * 通配模式（列名等标识符，区分大小写且不做任何规范化）。
+func globMatch(pattern string, s string) bool {
+	re := "^" + strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*") + "$"
+	ok, _ := regexp.MatchString(re, s)
+	return ok
+}
+
+// typeGlobMatch 先按 normalizeCHType 规范化模式与类型，再按 * 通配匹配。
+func typeGlobMatch(pattern string, typ string) bool {
+	return globMatch(normalizeCHType(pattern), normalizeCHType(typ))
+}
+
+// ApplyTypeRules 按规则过滤与改写类型差异：命中 IgnoreColumns 或 Ignore（类别或 issue）的差异移入 ignored，命中 Equivalent 的类型对改判为 equivalent。
+func ApplyTypeRules(diffs []TypeDiff, r TypeRules) ([]TypeDiff, []TypeDiff) {
+	kept := []TypeDiff{}
+	ignored := []TypeDiff{}
+	for _, d := range diffs {
+		if d.Issue == "type_mismatch" {
+			for _, e := range r.Equivalent {
+				if typeGlobMatch(e.Source, d.SourceType) && typeGlobMatch(e.Target, d.TargetType) {
+					d.Class = CompatEquivalent
+					break
+				}
+			}
+		}
+		skip := false
+		for _, p := range r.IgnoreColumns {
+			if globMatch(p, d.Column) {
+				skip = true
+				break
+			}
+		}
+		for _, c := range r.Ignore {
+			if c == d.Issue || c == d.Class {
+				skip = true
+				break
+			}
+		}
+		if skip {
+			ignored = append(ignored, d)
+			continue
+		}
+		kept = append(kept, d)
+	}
+	return kept, ignored
+}
+
+// SyncCompatible 判断在这些差异下同步链路能否无损写入目标表：
+// 目标缺列、有损收窄与不兼容均不可同步；仅可空性变化时要求目标为 Nullable。
+func SyncCompatible(diffs []TypeDiff) bool {
+	for _, d := range diffs {
+		switch d.Issue {
+		case "missing_in_target":
+			return false
+		case "type_mismatch":
+			switch d.Class {
+			case CompatLossyNarrowing, CompatIncompatible:
+				return false
+			case CompatNullableChange:
+				if !isNullableType(d.TargetType) {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,99 @@
+package clickhouse
+
+import "testing"
+
+func TestClassifyTypeChange(t *testing.T) {
+	cases := []struct {
+		source string
+		target string
+		want   string
+	}{
+		// 等价与可空性
+		{"Int32", "Int32", CompatEquivalent},
+		{"LowCardinality(String)", "String", CompatEquivalent},
+		{"DateTime('UTC')", "DateTime('Asia/Shanghai')", CompatEquivalent},
+		{"Int32", "Nullable(Int32)", CompatNullableChange},
+		{"Nullable(Int32)", "Int32", CompatNullableChange},
+		{"Nullable(Int32)", "Int64", CompatLossyNarrowing},
+		{"Int32", "Nullable(Int64)", CompatSafeWidening},
+		// 整数
+		{"Int8", "Int16", CompatSafeWidening},
+		{"UInt32", "Int64", CompatSafeWidening},
+		{"UInt32", "Int32", CompatLossyNarrowing},
+		{"Int64", "Int32", CompatLossyNarrowing},
+		{"Int32", "UInt64", CompatLossyNarrowing},
+		{"Bool", "UInt8", CompatSafeWidening},
+		{"UInt8", "Bool", CompatLossyNarrowing},
+		{"Int16", "Float32", CompatSafeWidening},
+		{"Int32", "Float32", CompatLossyNarrowing},
+		{"Int32", "Float64", CompatSafeWidening},
+		{"Int64", "Float64", CompatLossyNarrowing},
+		{"Int32", "Decimal(10, 0)", CompatSafeWidening},
+		{"Int32", "Decimal(9, 0)", CompatLossyNarrowing},
+		{"UInt64", "Decimal(20, 2)", CompatLossyNarrowing},
+		// 浮点与 Decimal
+		{"Float32", "Float64", CompatSafeWidening},
+		{"Float64", "Float32", CompatLossyNarrowing},
+		{"Float64", "Int64", CompatLossyNarrowing},
+		{"Decimal(10, 2)", "Decimal(12, 4)", CompatSafeWidening},
+		{"Decimal(10, 2)", "Decimal(10, 4)", CompatLossyNarrowing},
+		{"Decimal32(2)", "Decimal(18, 2)", CompatSafeWidening},
+		{"Decimal(18, 4)", "Float64", CompatLossyNarrowing},
+		// 字符串
+		{"Int64", "String", CompatSafeWidening},
+		{"UUID", "String", CompatSafeWidening},
+		{"FixedString(8)", "FixedString(16)", CompatSafeWidening},
+		{"FixedString(16)", "FixedString(8)", CompatLossyNarrowing},
+		{"String", "FixedString(16)", CompatLossyNarrowing},
+		{"String", "Int64", CompatIncompatible},
+		{"Array(Int32)", "String", CompatIncompatible},
+		// 日期：Date 1970~2149、DateTime 1970~2106、Date32/DateTime64 1900~2299
+		{"Date", "Date32", CompatSafeWidening},
+		{"Date", "DateTime", CompatLossyNarrowing},
+		{"Date", "DateTime64(3)", CompatSafeWidening},
+		{"Date32", "Date", CompatLossyNarrowing},
+		{"Date32", "DateTime64(0)", CompatSafeWidening},
+		{"DateTime", "DateTime64(3)", CompatSafeWidening},
+		{"DateTime", "Date", CompatLossyNarrowing},
+		{"DateTime64(3)", "DateTime64(6)", CompatSafeWidening},
+		{"DateTime64(6)", "DateTime64(3)", CompatLossyNarrowing},
+		{"DateTime64(3)", "DateTime", CompatLossyNarrowing},
+		{"Date", "Int32", CompatIncompatible},
+		// 枚举
+		{"Enum8('a' = 1, 'b' = 2)", "Enum8('a' = 1, 'b' = 2)", CompatEquivalent},
+		{"Enum8('a' = 1)", "Enum16('a' = 1, 'b' = 2)", CompatSafeWidening},
+		{"Enum8('a' = 1, 'b' = 2)", "Enum8('a' = 1)", CompatLossyNarrowing},
+		{"Enum8('a' = 1)", "Enum8('a' = 2)", CompatLossyNarrowing},
+		// 复合类型取元素中最严重的类别
+		{"Array(Int32)", "Array(Int64)", CompatSafeWidening},
+		{"Array(Int64)", "Array(Int32)", CompatLossyNarrowing},
+		{"Map(String, Int32)", "Map(String, Nullable(Int32))", CompatNullableChange},
+		{"Tuple(Int32, String)", "Tuple(Int64, String)", CompatSafeWidening},
+		{"Tuple(Int32, String)", "Tuple(Int32)", CompatIncompatible},
+		{"Array(Int32)", "Map(String, Int32)", CompatIncompatible},
+	}
+	for _, c := range cases {
+		if got := ClassifyTypeChange(c.source, c.target); got != c.want {
+			t.Errorf("ClassifyTypeChange(%s, %s) = %s, want %s", c.source, c.target, got, c.want)
+		}
+	}
+}
+
+func TestApplyTypeRules(t *testing.T) {
+	diffs := []TypeDiff{
+		{Column: "Created_At", Issue: "type_mismatch", SourceType: "DateTime", TargetType: "DateTime64(3)", Class: CompatSafeWidening},
+		{Column: "tmp_x", Issue: "missing_in_target"},
+		{Column: "amount", Issue: "type_mismatch", SourceType: "Decimal(18, 2)", TargetType: "Float64", Class: CompatLossyNarrowing},
+	}
+	kept, ignored := ApplyTypeRules(diffs, TypeRules{
+		// 列名按字面匹配，大小写敏感且不做类型规范化
+		IgnoreColumns: []string{"tmp_*", "created_at"},
+		Equivalent:    []TypeEquivalence{{Source: "decimal(18,2)", Target: "Float64"}},
+	})
+	if len(ignored) != 1 || ignored[0].Column != "tmp_x" {
+		t.Fatalf("ignored = %+v", ignored)
+	}
+	if len(kept) != 2 || kept[0].Column != "Created_At" || kept[1].Class != CompatEquivalent {
+		t.Errorf("kept = %+v", kept)
+	}
+}
@@ -0,0 +1,138 @@
+// clickhouse 包中的连接选项：TLS、多地址负载均衡、协议、压缩、超时、连接池与默认查询设置。
+package clickhouse
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	ch "github.com/ClickHouse/clickhouse-go/v2"
+)
+
+// ConnOptions 描述一次 ClickHouse 连接的全部选项；零值字段沿用驱动默认。
+type ConnOptions struct {
+	Addrs              []string
+	User               string
+	Password           string
+	Database           string
+	Protocol           string
+	Secure             bool
+	CAFile             string
+	CertFile           string
+	KeyFile            string
+	ServerName         string
+	InsecureSkipVerify bool
+	LoadBalancing      string
+	Compression        string
+	DialTimeout        time.Duration
+	ReadTimeout        time.Duration
+	MaxOpenConns       int
+	MaxIdleConns       int
+	ConnMaxLifetime    time.Duration
+	Settings           map[string]any
+}
+
+func (o ConnOptions) tlsConfig() (*tls.Config, error) {
+	cfg := &tls.Config{ServerName: strings.TrimSpace(o.ServerName), InsecureSkipVerify: o.InsecureSkipVerify}
+	if ca := strings.TrimSpace(o.CAFile); ca != "" {
+		pem, err := os.ReadFile(ca)
+		if err != nil {
+			return nil, fmt.Errorf("读取 ClickHouse CA 证书失败: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("ClickHouse CA 证书无有效 PEM: %s", ca)
+		}
+		cfg.RootCAs = pool
+	}
+	cert, key := strings.TrimSpace(o.CertFile), strings.TrimSpace(o.KeyFile)
+	if cert != "" || key != "" {
+		if cert == "" || key == "" {
+			return nil, fmt.Errorf("ClickHouse 客户端证书需同时提供 cert 与 key")
+		}
+		pair, err := tls.LoadX509KeyPair(cert, key)
+		if err != nil {
+			return nil, fmt.Errorf("加载 ClickHouse 客户端证书失败: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{pair}
+	}
+	return cfg, nil
+}
+
+// driverOptions 将 ConnOptions 转换为 clickhouse-go 的 Options，并校验枚举取值。
+func (o ConnOptions) driverOptions() (*ch.Options, error) {
+	if len(o.Addrs) == 0 {
+		return nil, fmt.Errorf("缺少 ClickHouse 地址")
+	}
+	opts := &ch.Options{
+		Addr:            o.Addrs,
+		Auth:            ch.Auth{Database: o.Database, Username: o.User, Password: o.Password},
+		DialTimeout:     o.DialTimeout,
+		ReadTimeout:     o.ReadTimeout,
+		MaxOpenConns:    o.MaxOpenConns,
+		MaxIdleConns:    o.MaxIdleConns,
+		ConnMaxLifetime: o.ConnMaxLifetime,
+	}
+	switch strings.ToLower(strings.TrimSpace(o.Protocol)) {
+	case "", "native":
+		opts.Protocol = ch.Native
+	case "http":
+		opts.Protocol = ch.HTTP
+	case "https":
+		opts.Protocol = ch.HTTP
+		o.Secure = true
+	default:
+		return nil, fmt.Errorf("不支持的 ClickHouse 协议: %s（仅支持 native|http|https）", o.Protocol)
+	}
+	switch strings.ToLower(strings.TrimSpace(o.LoadBalancing)) {
+	case "", "in_order":
+		opts.ConnOpenStrategy = ch.ConnOpenInOrder
+	case "round_robin":
+		opts.ConnOpenStrategy = ch.ConnOpenRoundRobin
+	case "random":
+		opts.ConnOpenStrategy = ch.ConnOpenRandom
+	default:
+		return nil, fmt.Errorf("不支持的负载均衡策略: %s（仅支持 in_order|round_robin|random）", o.LoadBalancing)
+	}
+	switch strings.ToLower(strings.TrimSpace(o.Compression)) {
+	case "", "none":
+	case "lz4":
+		opts.Compression = &ch.Compression{Method: ch.CompressionLZ4}
+	case "zstd":
+		opts.Compression = &ch.Compression{Method: ch.CompressionZSTD}
+	default:
+		return nil, fmt.Errorf("不支持的压缩方式: %s（仅支持 none|lz4|zstd）", o.Compression)
+	}
+	if len(o.Settings) > 0 {
+		opts.Settings = ch.Settings{}
+		for k, v := range o.Settings {
+			opts.Settings[k] = v
+		}
+	}
+	if o.Secure {
+		cfg, err := o.tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+		opts.TLS = cfg
+	}
+	return opts, nil
+}
+
+// Open 按连接选项建立 ClickHouse *sql.DB 连接并执行 Ping。
+func Open(o ConnOptions) (*sql.DB, error) {
+	opts, err := o.driverOptions()
+	if err != nil {
+		return nil, err
+	}
+	db := ch.OpenDB(opts)
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
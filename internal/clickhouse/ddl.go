@@ -0,0 +1,373 @@
+// clickhouse 包中的链路对象建表语句生成：sink、物化视图与目标表的 DDL 只依赖传入的列结构，
+// 既供 Create* 方法执行，也供 plan 预先计算。
+package clickhouse
+
+import (
+	"click-house-sync/internal/naming"
+	"fmt"
+	"math/bits"
+	"sort"
+	"strings"
+)
+
+// mvInputSettings 是从 Kafka sink 读取数据的物化视图共用的查询设置（不含 max_partitions_per_insert_block）。
+const mvInputSettings = "stream_like_engine_allow_direct_select=1, input_format_skip_unknown_fields=1, input_format_defaults_for_omitted_fields=1, input_format_null_as_default=1, input_format_json_try_infer_numbers_from_strings=1, input_format_json_read_objects_as_strings=1, date_time_input_format='best_effort'"
+
+// MVOwnSpec 是自带存储的物化视图参数，含义与 CreateMaterializedViewOwn 的同名参数一致。
+type MVOwnSpec struct {
+	MVLayout
+	TTLDays                     int
+	TTLColumn                   string
+	MaxPartitionsPerInsertBlock int
+}
+
+// SinkColumnsFromSource 返回 Kafka sink 的列：源表列类型经 mapper.SinkType 映射，extraColumns 中源表没有的列按名称顺序追加。
+func SinkColumnsFromSource(cols []Column, extraColumns map[string]string, mapper *TypeMapper) []Column {
+	out := make([]Column, 0, len(cols)+len(extraColumns))
+	present := map[string]struct{}{}
+	for _, c := range cols {
+		present[c.Name] = struct{}{}
+		out = append(out, Column{Name: c.Name, Type: mapper.SinkType(c.Type), Position: c.Position})
+	}
+	var names []string
+	for name := range extraColumns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		n := strings.TrimSpace(name)
+		typ := strings.TrimSpace(extraColumns[name])
+		if n == "" || typ == "" {
+			continue
+		}
+		if _, ok := present[n]; ok {
+			continue
+		}
+		out = append(out, Column{Name: n, Type: typ})
+	}
+	return out
+}
+
+func columnsDDL(cols []Column) string {
+	parts := make([]string, len(cols))
+	for i, c := range cols {
+		parts[i] = fmt.Sprintf("%s %s", quoteIdent(c.Name), c.Type)
+	}
+	return strings.Join(parts, ",")
+}
+
+// KafkaSinkDDL 返回源表 sourceDatabase.table 的 Kafka 引擎表（名称见 naming.Sink）的建表语句（不含结尾分号）。
+func KafkaSinkDDL(kafkaDatabase string, sourceDatabase string, table string, cols []Column, brokers []string, topic string, group string, settings KafkaSettings) string {
+	return kafkaEngineTableDDL(qualified(kafkaDatabase, naming.Sink(sourceDatabase, table)), columnsDDL(cols), settings, settings.settings(brokers, topic, group))
+}
+
+// KafkaSinkFallbackDDL 返回 KafkaSinkDDL 去掉可选设置后的降级语句（先去一项、再去多项），
+// 供 apply 在 ClickHouse 不识别可选设置时依次重试，与 CreateKafkaTableFromSource 的回退一致；没有可选设置时为空。
+func KafkaSinkFallbackDDL(kafkaDatabase string, sourceDatabase string, table string, cols []Column, brokers []string, topic string, group string, settings KafkaSettings) []string {
+	name := qualified(kafkaDatabase, naming.Sink(sourceDatabase, table))
+	list := settings.settings(brokers, topic, group)
+	var optional []int
+	for i, st := range list {
+		if st.optional {
+			optional = append(optional, i)
+		}
+	}
+	var masks []int
+	for m := 1; m < 1<<len(optional); m++ {
+		masks = append(masks, m)
+	}
+	sort.SliceStable(masks, func(i, j int) bool {
+		return bits.OnesCount(uint(masks[i])) < bits.OnesCount(uint(masks[j]))
+	})
+	var out []string
+	for _, m := range masks {
+		drop := map[int]bool{}
+		for k, idx := range optional {
+			if m&(1<<k) != 0 {
+				drop[idx] = true
+			}
+		}
+		var kept []kafkaSetting
+		for i, st := range list {
+			if !drop[i] {
+				kept = append(kept, st)
+			}
+		}
+		out = append(out, kafkaEngineTableDDL(name, columnsDDL(cols), settings, kept))
+	}
+	return out
+}
+
+// sinkSelectList 生成从 sink 读取的 SELECT 列表：宽整数转为 64 位，非 Nullable 列以类型默认值替换 NULL；
+// versionColumn 非空时该列按 UInt64 版本号读取。
+func sinkSelectList(sinkCols []Column, versionColumn string) string {
+	var b strings.Builder
+	for i, c := range sinkCols {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		base := strings.ToLower(unwrapNullable(c.Type))
+		isNull := isNullableType(c.Type)
+		name := quoteIdent(c.Name)
+		if versionColumn != "" && c.Name == versionColumn {
+			b.WriteString(fmt.Sprintf("toUInt64(ifNull(%s, 0)) AS %s", name, name))
+			continue
+		}
+		switch base {
+		case "int128", "int256":
+			if isNull {
+				b.WriteString(fmt.Sprintf("toInt64OrNull(%s) AS %s", name, name))
+			} else {
+				b.WriteString(fmt.Sprintf("toInt64(ifNull(%s, 0)) AS %s", name, name))
+			}
+		case "uint128", "uint256":
+			if isNull {
+				b.WriteString(fmt.Sprintf("toUInt64OrNull(%s) AS %s", name, name))
+			} else {
+				b.WriteString(fmt.Sprintf("toUInt64(ifNull(%s, 0)) AS %s", name, name))
+			}
+		default:
+			if isNull {
+				b.WriteString(name)
+			} else {
+				def := defaultExprForType(base)
+				if strings.TrimSpace(def) == "" {
+					b.WriteString(name)
+				} else {
+					b.WriteString(fmt.Sprintf("ifNull(%s, %s) AS %s", name, def, name))
+				}
+			}
+		}
+	}
+	return b.String()
+}
+
+// MaterializedViewDDL 返回把 sink 写入目标表的物化视图（名称见 naming.MVFromKafka）的建表语句；errorStream 为 true 时仅消费解析成功的消息。
+func MaterializedViewDDL(kafkaDatabase string, sourceDatabase string, sourceTable string, targetDatabase string, targetTable string, sinkCols []Column, errorStream bool) string {
+	if targetDatabase == "" {
+		targetDatabase = kafkaDatabase
+	}
+	from := qualified(kafkaDatabase, naming.Sink(sourceDatabase, sourceTable))
+	if errorStream {
+		from += " WHERE " + KafkaErrorFilter
+	}
+	return fmt.Sprintf("CREATE MATERIALIZED VIEW IF NOT EXISTS %s TO %s AS SELECT %s FROM %s SETTINGS %s, max_partitions_per_insert_block=1000",
+		qualified(targetDatabase, naming.MVFromKafka(sourceDatabase, sourceTable)), qualified(targetDatabase, targetTable), sinkSelectList(sinkCols, ""), from, mvInputSettings)
+}
+
+// MaterializedViewOwnDDL 返回自带存储的物化视图（名称见 naming.MVFromKafka）的建表语句。spec.OrderBy 为空时为 tuple()；
+// spec.PartitionBy 为空时按 sink 中的时间列按月分区；TTL 列为空时从 sink 的日期列中挑选。
+func MaterializedViewOwnDDL(kafkaDatabase string, sourceDatabase string, sourceTable string, targetDatabase string, sinkCols []Column, errorStream bool, spec MVOwnSpec) string {
+	return materializedViewOwnDDL(kafkaDatabase, sourceDatabase, sourceTable, targetDatabase, sinkCols, errorStream, spec, true)
+}
+
+// MaterializedViewOwnFallbackDDL 返回不带 allow_nullable_key 的 MaterializedViewOwnDDL，供不识别该设置的 ClickHouse 版本使用。
+func MaterializedViewOwnFallbackDDL(kafkaDatabase string, sourceDatabase string, sourceTable string, targetDatabase string, sinkCols []Column, errorStream bool, spec MVOwnSpec) string {
+	return materializedViewOwnDDL(kafkaDatabase, sourceDatabase, sourceTable, targetDatabase, sinkCols, errorStream, spec, false)
+}
+
+func materializedViewOwnDDL(kafkaDatabase string, sourceDatabase string, sourceTable string, targetDatabase string, sinkCols []Column, errorStream bool, spec MVOwnSpec, allowNullableKey bool) string {
+	if targetDatabase == "" {
+		targetDatabase = kafkaDatabase
+	}
+	eng := strings.ToLower(strings.TrimSpace(spec.Engine))
+	if eng == "" {
+		eng = "merge"
+	}
+	ob := strings.TrimSpace(spec.OrderBy)
+	if ob == "" {
+		ob = "tuple()"
+	}
+	ob = normalizeOrderByExpr(ob)
+	var parts string
+	if strings.TrimSpace(spec.PartitionBy) != "" {
+		parts = fmt.Sprintf(" PARTITION BY %s", spec.PartitionBy)
+	} else {
+		// Prefer time-based monthly partition to avoid high-cardinality partitions
+		var timeCol string
+		if strings.TrimSpace(spec.TTLColumn) != "" {
+			timeCol = strings.TrimSpace(spec.TTLColumn)
+		} else {
+			// first pass: common time column names with date/datetime types
+			var cands = []string{"create_date", "updated_at", "update_ts", "time", "event_time", "insert_ts", "created_at"}
+			for _, cand := range cands {
+				for _, c := range sinkCols {
+					if strings.EqualFold(c.Name, cand) {
+						tn := strings.ToLower(c.Type)
+						if strings.Contains(tn, "date") || strings.Contains(tn, "datetime") {
+							timeCol = c.Name
+							break
+						}
+					}
+				}
+				if timeCol != "" {
+					break
+				}
+			}
+			// second pass: any column with date/datetime type
+			if timeCol == "" {
+				for _, c := range sinkCols {
+					tn := strings.ToLower(c.Type)
+					if strings.Contains(tn, "date") || strings.Contains(tn, "datetime") {
+						timeCol = c.Name
+						break
+					}
+				}
+			}
+		}
+		if strings.TrimSpace(timeCol) != "" {
+			parts = fmt.Sprintf(" PARTITION BY toYYYYMM(%s)", quoteIdent(timeCol))
+		} else {
+			parts = " PARTITION BY tuple()"
+		}
+	}
+	var storage string
+	switch eng {
+	case "replacing":
+		vc := strings.TrimSpace(spec.VersionColumn)
+		if vc != "" {
+			storage = fmt.Sprintf("ENGINE = ReplacingMergeTree(%s)%s ORDER BY %s", quoteIdent(vc), parts, ob)
+		} else {
+			storage = fmt.Sprintf("ENGINE = ReplacingMergeTree()%s ORDER BY %s", parts, ob)
+		}
+	case "collapsing":
+		sc := strings.TrimSpace(spec.SignColumn)
+		if sc == "" {
+			sc = "sign"
+		}
+		storage = fmt.Sprintf("ENGINE = CollapsingMergeTree(%s)%s ORDER BY %s", quoteIdent(sc), parts, ob)
+	case "versioned_collapsing":
+		sc := strings.TrimSpace(spec.SignColumn)
+		if sc == "" {
+			sc = "sign"
+		}
+		vc := strings.TrimSpace(spec.VersionColumn)
+		if vc == "" {
+			vc = "version"
+		}
+		storage = fmt.Sprintf("ENGINE = VersionedCollapsingMergeTree(%s, %s)%s ORDER BY %s", quoteIdent(sc), quoteIdent(vc), parts, ob)
+	default:
+		storage = fmt.Sprintf("ENGINE = MergeTree%s ORDER BY %s", parts, ob)
+	}
+	if spec.TTLDays > 0 {
+		col := strings.TrimSpace(spec.TTLColumn)
+		if col == "" {
+			cand := ""
+			for _, c := range sinkCols {
+				tn := strings.ToLower(c.Type)
+				if strings.Contains(tn, "date") {
+					n := strings.ToLower(c.Name)
+					if n == "update_ts" || n == "updated_at" || n == "ts" || n == "event_time" || n == "created_at" || n == "insert_ts" {
+						cand = c.Name
+						break
+					}
+				}
+			}
+			if cand == "" {
+				for _, c := range sinkCols {
+					tn := strings.ToLower(c.Type)
+					if strings.Contains(tn, "date") {
+						cand = c.Name
+						break
+					}
+				}
+			}
+			col = cand
+		}
+		if strings.TrimSpace(col) != "" {
+			tname := ""
+			for _, c := range sinkCols {
+				if c.Name == col {
+					tname = c.Type
+					break
+				}
+			}
+			tn := strings.ToLower(tname)
+			if strings.Contains(tn, "nullable") {
+				storage = storage + fmt.Sprintf(" TTL toDateTime(%s) + INTERVAL %d DAY WHERE %s IS NOT NULL", quoteIdent(col), spec.TTLDays, quoteIdent(col))
+			} else {
+				storage = storage + fmt.Sprintf(" TTL toDateTime(%s) + INTERVAL %d DAY", quoteIdent(col), spec.TTLDays)
+			}
+		}
+	}
+	if allowNullableKey {
+		storage += " SETTINGS allow_nullable_key=1"
+	}
+	var verCol string
+	if eng == "replacing" {
+		verCol = strings.TrimSpace(spec.VersionColumn)
+	}
+	maxParts := spec.MaxPartitionsPerInsertBlock
+	if maxParts <= 0 {
+		maxParts = 1000
+	}
+	from := qualified(kafkaDatabase, naming.Sink(sourceDatabase, sourceTable))
+	if errorStream {
+		from += " WHERE " + KafkaErrorFilter
+	}
+	return fmt.Sprintf("CREATE MATERIALIZED VIEW IF NOT EXISTS %s %s AS SELECT %s FROM %s SETTINGS %s, max_partitions_per_insert_block=%d",
+		qualified(targetDatabase, naming.MVFromKafka(sourceDatabase, sourceTable)), storage, sinkSelectList(sinkCols, verCol), from, mvInputSettings, maxParts)
+}
+
+// MaterializedViewToKafkaDDL 返回源侧把源表新增行写入 sink 的物化视图（名称见 naming.MVToKafka）的建表语句。
+func MaterializedViewToKafkaDDL(sourceDatabase string, sourceTable string, kafkaDatabase string, sinkCols []Column) string {
+	if kafkaDatabase == "" {
+		kafkaDatabase = sourceDatabase
+	}
+	var selectDDL strings.Builder
+	for i, c := range sinkCols {
+		if i > 0 {
+			selectDDL.WriteString(",")
+		}
+		// SELECT expression typed to sink schema, with explicit cast when needed
+		base := strings.ToLower(unwrapNullable(c.Type))
+		isNull := isNullableType(c.Type)
+		name := quoteIdent(c.Name)
+		switch base {
+		case "int128", "int256":
+			if isNull {
+				selectDDL.WriteString(fmt.Sprintf("toInt64OrNull(%s) AS %s", name, name))
+			} else {
+				selectDDL.WriteString(fmt.Sprintf("toInt64(%s) AS %s", name, name))
+			}
+		case "uint128", "uint256":
+			if isNull {
+				selectDDL.WriteString(fmt.Sprintf("toUInt64OrNull(%s) AS %s", name, name))
+			} else {
+				selectDDL.WriteString(fmt.Sprintf("toUInt64(%s) AS %s", name, name))
+			}
+		case "string":
+			selectDDL.WriteString(fmt.Sprintf("toString(%s) AS %s", name, name))
+		default:
+			selectDDL.WriteString(name)
+		}
+	}
+	return fmt.Sprintf("CREATE MATERIALIZED VIEW IF NOT EXISTS %s TO %s AS SELECT %s FROM %s",
+		qualified(kafkaDatabase, naming.MVToKafka(sourceDatabase, sourceTable)), qualified(kafkaDatabase, naming.Sink(sourceDatabase, sourceTable)), selectDDL.String(), qualified(sourceDatabase, sourceTable))
+}
+
+// TargetTableDDL 返回 custom 布局的 MergeTree 目标表建表语句：列与源表一致（类型经 mapper 映射），orderBy 为空时为 tuple()。
+func TargetTableDDL(cols []Column, targetDatabase string, targetTable string, orderBy string, partitionBy string, mapper *TypeMapper) string {
+	return targetTableDDL(cols, targetDatabase, targetTable, orderBy, partitionBy, mapper, true)
+}
+
+// TargetTableFallbackDDL 返回不带 allow_nullable_key 的 TargetTableDDL，供不识别该设置的 ClickHouse 版本使用。
+func TargetTableFallbackDDL(cols []Column, targetDatabase string, targetTable string, orderBy string, partitionBy string, mapper *TypeMapper) string {
+	return targetTableDDL(cols, targetDatabase, targetTable, orderBy, partitionBy, mapper, false)
+}
+
+func targetTableDDL(cols []Column, targetDatabase string, targetTable string, orderBy string, partitionBy string, mapper *TypeMapper, allowNullableKey bool) string {
+	if strings.TrimSpace(orderBy) == "" {
+		orderBy = "tuple()"
+	}
+	orderBy = normalizeOrderByExpr(orderBy)
+	var parts string
+	if strings.TrimSpace(partitionBy) != "" {
+		parts = fmt.Sprintf(" PARTITION BY %s", partitionBy)
+	}
+	var settings string
+	if allowNullableKey {
+		settings = " SETTINGS allow_nullable_key=1"
+	}
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s) ENGINE = MergeTree%s ORDER BY %s%s", qualified(targetDatabase, targetTable), columnsDDL(mapper.MapColumns(cols)), parts, orderBy, settings)
+}
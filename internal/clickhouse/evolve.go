@@ -0,0 +1,122 @@
+// clickhouse 包中的结构演进辅助方法：对比源表与 sink/目标表并生成列变更。
+package clickhouse
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ColumnChange 描述一次列级变更；Action 取值 add|modify|drop，modify 的 Class 为 FromType -> ToType 的兼容性类别。
+type ColumnChange struct {
+	Column   string `json:"column"`
+	Action   string `json:"action"`
+	FromType string `json:"from_type,omitempty"`
+	ToType   string `json:"to_type,omitempty"`
+	After    string `json:"after,omitempty"`
+	Class    string `json:"class,omitempty"`
+}
+
+// Lossy 判断 MODIFY 是否会损失已有数据：有损收窄、不兼容或去掉 Nullable。
+func (c ColumnChange) Lossy() bool {
+	return c.Action == "modify" && unsafeTypeChange(ClassifyTypeChange(c.FromType, c.ToType), c.ToType)
+}
+
+// SQL 返回对应的 ALTER TABLE 子句（ADD COLUMN / MODIFY COLUMN / DROP COLUMN）。
+func (c ColumnChange) SQL() string {
+	switch c.Action {
+	case "modify":
+		return fmt.Sprintf("MODIFY COLUMN %s %s", quoteIdent(c.Column), c.ToType)
+	case "drop":
+		return fmt.Sprintf("DROP COLUMN IF EXISTS %s", quoteIdent(c.Column))
+	}
+	s := fmt.Sprintf("ADD COLUMN IF NOT EXISTS %s %s", quoteIdent(c.Column), c.ToType)
+	if c.After != "" {
+		s += " AFTER " + quoteIdent(c.After)
+	}
+	return s
+}
+
+// DiffTargetColumns 返回目标表为对齐源表所需的 ADD/MODIFY 列变更（源类型先经 mapper 映射）；目标表多出的列保持不动。
+func DiffTargetColumns(sourceCols []Column, targetCols []Column, mapper *TypeMapper) []ColumnChange {
+	tgt := map[string]string{}
+	for _, c := range targetCols {
+		tgt[c.Name] = c.Type
+	}
+	var out []ColumnChange
+	prev := ""
+	for _, c := range sourceCols {
+		want := mapper.Map(c.Type)
+		t, ok := tgt[c.Name]
+		switch {
+		case !ok:
+			ch := ColumnChange{Column: c.Name, Action: "add", ToType: want}
+			if _, has := tgt[prev]; has {
+				ch.After = prev
+			}
+			out = append(out, ch)
+		case normalizeCHType(t) != normalizeCHType(want):
+			out = append(out, ColumnChange{Column: c.Name, Action: "modify", FromType: t, ToType: want, Class: ClassifyTypeChange(t, want)})
+		}
+		prev = c.Name
+	}
+	return out
+}
+
+// DiffSinkColumns 返回 Kafka sink 相对源表缺失或类型不一致的列（sink 类型按 mapper.SinkType 推导）。
+func DiffSinkColumns(sourceCols []Column, sinkCols []Column, mapper *TypeMapper) []ColumnChange {
+	sink := map[string]string{}
+	for _, c := range sinkCols {
+		sink[c.Name] = c.Type
+	}
+	var out []ColumnChange
+	for _, c := range sourceCols {
+		want := mapper.SinkType(c.Type)
+		t, ok := sink[c.Name]
+		switch {
+		case !ok:
+			out = append(out, ColumnChange{Column: c.Name, Action: "add", ToType: want})
+		case normalizeCHType(t) != normalizeCHType(want):
+			out = append(out, ColumnChange{Column: c.Name, Action: "modify", FromType: t, ToType: want})
+		}
+	}
+	return out
+}
+
+// SinkExtraColumns 返回 sink 中源表不存在的附加列（如 version/sign），重建 sink 时需保留。
+func SinkExtraColumns(sourceCols []Column, sinkCols []Column) map[string]string {
+	src := map[string]struct{}{}
+	for _, c := range sourceCols {
+		src[c.Name] = struct{}{}
+	}
+	out := map[string]string{}
+	for _, c := range sinkCols {
+		if _, ok := src[c.Name]; !ok {
+			out[c.Name] = c.Type
+		}
+	}
+	return out
+}
+
+// AlterColumns 在表上依次执行列变更。
+func AlterColumns(db *sql.DB, database string, table string, changes []ColumnChange) error {
+	for _, c := range changes {
+		ddl := fmt.Sprintf("ALTER TABLE %s %s", qualified(database, table), c.SQL())
+		if _, err := db.Exec(ddl); err != nil {
+			return fmt.Errorf("ddl_failed: %s ; error: %v", ddl, err)
+		}
+	}
+	return nil
+}
+
+var mvToClause = regexp.MustCompile(`(?is)^\s*CREATE\s+MATERIALIZED\s+VIEW\s+\S+\s+TO\s+`)
+
+// MaterializedViewHasTarget 判断物化视图是否为 TO 目标表形式；false 表示视图自带存储（查询型 MV）。
+func MaterializedViewHasTarget(db *sql.DB, database string, view string) (bool, error) {
+	var q string
+	if err := db.QueryRow("SELECT create_table_query FROM system.tables WHERE database = ? AND name = ?", database, view).Scan(&q); err != nil {
+		return false, err
+	}
+	return mvToClause.MatchString(strings.TrimSpace(q)), nil
+}
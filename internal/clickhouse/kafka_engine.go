@@ -0,0 +1,311 @@
+// clickhouse 包中的 Kafka 引擎表设置与错误流辅助方法。
+package clickhouse
+
+import (
+	"click-house-sync/internal/naming"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// KafkaSettings 汇总 Kafka 引擎表的可调参数；零值字段不输出对应 SETTINGS。
+type KafkaSettings struct {
+	Format             string
+	NumConsumers       int
+	MaxBlockSize       int
+	AutoOffsetReset    string
+	SkipBrokenMessages int
+	ThreadPerConsumer  bool
+	PollMaxBatchSize   int
+	FlushIntervalMs    int
+	CommitEveryBatch   bool
+	HandleErrorMode    string
+	// 认证设置：输出为 kafka_security_protocol / kafka_sasl_*；TLS 证书需在 ClickHouse 服务端 <kafka> 配置或 named collection 中提供
+	SecurityProtocol string
+	SASLMechanism    string
+	SASLUsername     string
+	SASLPassword     string
+	// NamedCollection 非空时使用 ENGINE = Kafka(<collection>)，broker 与认证信息取自该集合
+	NamedCollection string
+}
+
+// ErrorStream 判断是否启用 kafka_handle_error_mode = 'stream'。
+func (s KafkaSettings) ErrorStream() bool {
+	return strings.EqualFold(strings.TrimSpace(s.HandleErrorMode), "stream")
+}
+
+// kafkaSetting 是单个 SETTINGS 项；optional 表示旧版本不识别时可降级移除。
+type kafkaSetting struct {
+	name     string
+	value    string
+	optional bool
+}
+
+func (s KafkaSettings) settings(brokers []string, topic string, group string) []kafkaSetting {
+	format := strings.TrimSpace(s.Format)
+	if format == "" {
+		format = "JSONEachRow"
+	}
+	numConsumers := s.NumConsumers
+	if numConsumers <= 0 {
+		numConsumers = 1
+	}
+	var out []kafkaSetting
+	if strings.TrimSpace(s.NamedCollection) == "" {
+		out = append(out, kafkaSetting{name: "kafka_broker_list", value: quoteString(stringsJoin(brokers))})
+	}
+	out = append(out, []kafkaSetting{
+		{name: "kafka_topic_list", value: quoteString(topic)},
+		{name: "kafka_group_name", value: quoteString(group)},
+		{name: "kafka_format", value: quoteString(format)},
+		{name: "kafka_num_consumers", value: fmt.Sprint(numConsumers)},
+	}...)
+	if s.MaxBlockSize > 0 {
+		out = append(out, kafkaSetting{name: "kafka_max_block_size", value: fmt.Sprint(s.MaxBlockSize)})
+	}
+	reset := strings.TrimSpace(s.AutoOffsetReset)
+	if reset == "" {
+		reset = "latest"
+	}
+	// skip 表示不设置 kafka_auto_offset_reset，沿用 ClickHouse 默认行为
+	if !strings.EqualFold(reset, "skip") {
+		out = append(out, kafkaSetting{name: "kafka_auto_offset_reset", value: quoteString(reset), optional: true})
+	}
+	// 错误流模式下坏消息写入错误表，不再跳过
+	if s.SkipBrokenMessages > 0 && !s.ErrorStream() {
+		out = append(out, kafkaSetting{name: "kafka_skip_broken_messages", value: fmt.Sprint(s.SkipBrokenMessages), optional: true})
+	}
+	if s.ThreadPerConsumer {
+		out = append(out, kafkaSetting{name: "kafka_thread_per_consumer", value: "1"})
+	}
+	if s.PollMaxBatchSize > 0 {
+		out = append(out, kafkaSetting{name: "kafka_poll_max_batch_size", value: fmt.Sprint(s.PollMaxBatchSize)})
+	}
+	if s.FlushIntervalMs > 0 {
+		out = append(out, kafkaSetting{name: "kafka_flush_interval_ms", value: fmt.Sprint(s.FlushIntervalMs)})
+	}
+	if s.CommitEveryBatch {
+		out = append(out, kafkaSetting{name: "kafka_commit_every_batch", value: "1"})
+	}
+	if mode := strings.ToLower(strings.TrimSpace(s.HandleErrorMode)); mode != "" && mode != "default" {
+		out = append(out, kafkaSetting{name: "kafka_handle_error_mode", value: quoteString(mode)})
+	}
+	// 使用 named collection 时认证信息由集合提供，不在 DDL 中重复输出
+	if strings.TrimSpace(s.NamedCollection) == "" {
+		if p := strings.ToUpper(strings.TrimSpace(s.SecurityProtocol)); p != "" && p != "PLAINTEXT" {
+			out = append(out, kafkaSetting{name: "kafka_security_protocol", value: quoteString(strings.ToLower(p))})
+		}
+		if strings.TrimSpace(s.SASLUsername) != "" {
+			out = append(out,
+				kafkaSetting{name: "kafka_sasl_mechanism", value: quoteString(strings.ToUpper(strings.TrimSpace(s.SASLMechanism)))},
+				kafkaSetting{name: "kafka_sasl_username", value: quoteString(s.SASLUsername)},
+				kafkaSetting{name: "kafka_sasl_password", value: quoteString(s.SASLPassword)},
+			)
+		}
+	}
+	return out
+}
+
+func joinKafkaSettings(list []kafkaSetting) string {
+	parts := make([]string, 0, len(list))
+	for _, s := range list {
+		parts = append(parts, s.name+" = "+s.value)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// engine 返回引擎名部分：默认 Kafka，配置 named collection 时为 Kafka(<collection>)。
+func (s KafkaSettings) engine() string {
+	if nc := strings.TrimSpace(s.NamedCollection); nc != "" {
+		return "Kafka(" + nc + ")"
+	}
+	return "Kafka"
+}
+
+// KafkaEngineClause 返回 "ENGINE = Kafka SETTINGS ..." 子句，供 DDL 生成使用。
+func KafkaEngineClause(brokers []string, topic string, group string, s KafkaSettings) string {
+	return "ENGINE = " + s.engine() + " SETTINGS " + joinKafkaSettings(s.settings(brokers, topic, group))
+}
+
+func kafkaEngineTableDDL(name string, ddlCols string, s KafkaSettings, list []kafkaSetting) string {
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s) ENGINE = %s SETTINGS %s", name, ddlCols, s.engine(), joinKafkaSettings(list))
+}
+
+// createKafkaEngineTable 执行 Kafka 引擎表 DDL；当 ClickHouse 不识别可选设置时移除该项后重试。
+func createKafkaEngineTable(db *sql.DB, name string, ddlCols string, brokers []string, topic string, group string, s KafkaSettings) error {
+	list := s.settings(brokers, topic, group)
+	ddl := kafkaEngineTableDDL(name, ddlCols, s, list)
+	_, firstErr := db.Exec(ddl)
+	if firstErr == nil {
+		return nil
+	}
+	err := firstErr
+	for {
+		idx := -1
+		for i, st := range list {
+			if st.optional && isUnknownSettingError(err, st.name) {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			return fmt.Errorf("ddl_failed: %s ; error: %v", ddl, firstErr)
+		}
+		list = append(list[:idx:idx], list[idx+1:]...)
+		ddl = kafkaEngineTableDDL(name, ddlCols, s, list)
+		if _, err = db.Exec(ddl); err == nil {
+			return nil
+		}
+	}
+}
+
+func isUnknownSettingError(err error, setting string) bool {
+	if err == nil {
+		return false
+	}
+	s := strings.ToLower(err.Error())
+	if !strings.Contains(s, "unknown setting") {
+		return false
+	}
+	return strings.Contains(s, setting)
+}
+
+// quoteString 返回单引号包裹并转义的 SQL 字符串字面量。
+func quoteString(v string) string {
+	return "'" + strings.ReplaceAll(strings.ReplaceAll(v, "\\", "\\\\"), "'", "\\'") + "'"
+}
+
+// KafkaErrorsTableName 返回源表 sourceDatabase.table 的错误流落库表名，按 naming 模板生成。
+func KafkaErrorsTableName(sourceDatabase string, table string) string {
+	return naming.ErrorsTable(sourceDatabase, table)
+}
+
+// KafkaErrorsViewName 返回源表 sourceDatabase.table 的错误流物化视图名，按 naming 模板生成。
+func KafkaErrorsViewName(sourceDatabase string, table string) string {
+	return naming.ErrorsView(sourceDatabase, table)
+}
+
+// KafkaErrorFilter 是错误流模式下主链路 MV 过滤坏消息的 WHERE 条件。
+const KafkaErrorFilter = "length(_error) = 0"
+
+// KafkaErrorStreamDDL 返回错误表与错误 MV 的建表语句（不含结尾分号）。
+func KafkaErrorStreamDDL(kafkaDatabase string, sourceDatabase string, table string) []string {
+	sink := qualified(kafkaDatabase, naming.Sink(sourceDatabase, table))
+	errTable := qualified(kafkaDatabase, KafkaErrorsTableName(sourceDatabase, table))
+	mv := qualified(kafkaDatabase, KafkaErrorsViewName(sourceDatabase, table))
+	return []string{
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (`topic` String, `partition` UInt64, `offset` UInt64, `raw_message` String, `error` String, `created_at` DateTime DEFAULT now()) ENGINE = MergeTree PARTITION BY toYYYYMM(`created_at`) ORDER BY (`topic`, `partition`, `offset`)", errTable),
+		fmt.Sprintf("CREATE MATERIALIZED VIEW IF NOT EXISTS %s TO %s (`topic` String, `partition` UInt64, `offset` UInt64, `raw_message` String, `error` String) AS SELECT _topic AS `topic`, _partition AS `partition`, _offset AS `offset`, _raw_message AS `raw_message`, _error AS `error` FROM %s WHERE length(_error) > 0", mv, errTable, sink),
+	}
+}
+
+// CreateKafkaErrorStream 为 kafka_handle_error_mode = 'stream' 的 Kafka 表创建错误表与错误 MV，坏消息落库而非丢弃。
+func CreateKafkaErrorStream(db *sql.DB, kafkaDatabase string, sourceDatabase string, table string) error {
+	for _, ddl := range KafkaErrorStreamDDL(kafkaDatabase, sourceDatabase, table) {
+		if _, err := db.Exec(ddl); err != nil {
+			return fmt.Errorf("ddl_failed: %s ; error: %v", ddl, err)
+		}
+	}
+	return nil
+}
+
+// KafkaErrorStreamEnabled 读取 Kafka 引擎表的 engine_full，判断是否启用了错误流模式。
+func KafkaErrorStreamEnabled(db *sql.DB, database string, table string) bool {
+	var engineFull string
+	if err := db.QueryRow("SELECT engine_full FROM system.tables WHERE database = ? AND name = ?", database, table).Scan(&engineFull); err != nil {
+		return false
+	}
+	s := strings.ReplaceAll(strings.ToLower(engineFull), " ", "")
+	return strings.Contains(s, "kafka_handle_error_mode='stream'")
+}
+
+var (
+	engineTopicPattern = regexp.MustCompile(`kafka_topic_list\s*=\s*'([^']*)'`)
+	engineGroupPattern = regexp.MustCompile(`kafka_group_name\s*=\s*'([^']*)'`)
+)
+
+// KafkaTableTopicGroup 从 Kafka 引擎表的 engine_full 读取 topic 与消费者组；未在 SETTINGS 中出现（如使用 named collection）时返回空串。
+func KafkaTableTopicGroup(db *sql.DB, database string, table string) (string, string, error) {
+	var engineFull string
+	if err := db.QueryRow("SELECT engine_full FROM system.tables WHERE database = ? AND name = ?", database, table).Scan(&engineFull); err != nil {
+		return "", "", err
+	}
+	var topic, group string
+	if m := engineTopicPattern.FindStringSubmatch(engineFull); m != nil {
+		topic = m[1]
+	}
+	if m := engineGroupPattern.FindStringSubmatch(engineFull); m != nil {
+		group = m[1]
+	}
+	return topic, group, nil
+}
+
+var (
+	engineSettingPattern         = regexp.MustCompile(`(kafka_\w+)\s*=\s*('(?:[^'\\]|\\.)*'|[^,\s]+)`)
+	engineNamedCollectionPattern = regexp.MustCompile(`^\s*Kafka\(\s*([^)\s]+)\s*\)`)
+)
+
+// KafkaTableSettings 从 Kafka 引擎表的 engine_full 读取 broker 列表与引擎设置，用于按原设置重建该表。
+// ClickHouse 不回显 SASL 密码，返回的 SASLPassword 为空，由调用方按当前配置补充。
+func KafkaTableSettings(db *sql.DB, database string, table string) ([]string, KafkaSettings, error) {
+	var engineFull string
+	if err := db.QueryRow("SELECT engine_full FROM system.tables WHERE database = ? AND name = ?", database, table).Scan(&engineFull); err != nil {
+		return nil, KafkaSettings{}, err
+	}
+	brokers, s := parseKafkaEngineFull(engineFull)
+	return brokers, s, nil
+}
+
+// parseKafkaEngineFull 解析 Kafka 引擎表 engine_full 中的 SETTINGS；未出现的 kafka_auto_offset_reset 记为 skip，保持重建后同样不设置。
+func parseKafkaEngineFull(engineFull string) ([]string, KafkaSettings) {
+	s := KafkaSettings{AutoOffsetReset: "skip"}
+	if m := engineNamedCollectionPattern.FindStringSubmatch(engineFull); m != nil {
+		s.NamedCollection = m[1]
+	}
+	var brokers []string
+	for _, m := range engineSettingPattern.FindAllStringSubmatch(engineFull, -1) {
+		v := m[2]
+		if strings.HasPrefix(v, "'") {
+			v = strings.NewReplacer(`\\`, `\`, `\'`, `'`).Replace(v[1 : len(v)-1])
+		}
+		n, _ := strconv.Atoi(v)
+		on := v == "1" || strings.EqualFold(v, "true")
+		switch m[1] {
+		case "kafka_broker_list":
+			for _, b := range strings.Split(v, ",") {
+				if b = strings.TrimSpace(b); b != "" {
+					brokers = append(brokers, b)
+				}
+			}
+		case "kafka_format":
+			s.Format = v
+		case "kafka_num_consumers":
+			s.NumConsumers = n
+		case "kafka_max_block_size":
+			s.MaxBlockSize = n
+		case "kafka_auto_offset_reset":
+			s.AutoOffsetReset = v
+		case "kafka_skip_broken_messages":
+			s.SkipBrokenMessages = n
+		case "kafka_thread_per_consumer":
+			s.ThreadPerConsumer = on
+		case "kafka_poll_max_batch_size":
+			s.PollMaxBatchSize = n
+		case "kafka_flush_interval_ms":
+			s.FlushIntervalMs = n
+		case "kafka_commit_every_batch":
+			s.CommitEveryBatch = on
+		case "kafka_handle_error_mode":
+			s.HandleErrorMode = v
+		case "kafka_security_protocol":
+			s.SecurityProtocol = v
+		case "kafka_sasl_mechanism":
+			s.SASLMechanism = v
+		case "kafka_sasl_username":
+			s.SASLUsername = v
+		}
+	}
+	return brokers, s
+}
@@ -0,0 +1,32 @@
+package clickhouse
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseKafkaEngineFull(t *testing.T) {
+	engineFull := "Kafka SETTINGS kafka_broker_list = 'k1:9092,k2:9092', kafka_topic_list = 'db.orders', kafka_group_name = 'g', kafka_format = 'JSONEachRow', kafka_num_consumers = 4, kafka_thread_per_consumer = 1, kafka_poll_max_batch_size = 500, kafka_handle_error_mode = 'stream', kafka_security_protocol = 'sasl_ssl', kafka_sasl_mechanism = 'SCRAM-SHA-512', kafka_sasl_username = 'u\\'x', kafka_sasl_password = '[HIDDEN]'"
+	brokers, s := parseKafkaEngineFull(engineFull)
+	if !reflect.DeepEqual(brokers, []string{"k1:9092", "k2:9092"}) {
+		t.Fatalf("brokers = %v", brokers)
+	}
+	want := KafkaSettings{
+		Format:            "JSONEachRow",
+		NumConsumers:      4,
+		AutoOffsetReset:   "skip",
+		ThreadPerConsumer: true,
+		PollMaxBatchSize:  500,
+		HandleErrorMode:   "stream",
+		SecurityProtocol:  "sasl_ssl",
+		SASLMechanism:     "SCRAM-SHA-512",
+		SASLUsername:      "u'x",
+	}
+	if s != want {
+		t.Fatalf("settings = %+v, want %+v", s, want)
+	}
+	_, s = parseKafkaEngineFull("Kafka(kafka_cluster) SETTINGS kafka_topic_list = 'db.orders', kafka_auto_offset_reset = 'earliest'")
+	if s.NamedCollection != "kafka_cluster" || s.AutoOffsetReset != "earliest" {
+		t.Fatalf("named collection settings = %+v", s)
+	}
+}
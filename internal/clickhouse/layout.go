@@ -0,0 +1,243 @@
+// clickhouse 包中的目标表布局：mirror 按源表的引擎、排序/分区/主键、TTL、编解码、默认表达式、跳数索引、投影与设置复刻目标表，
+// 并从源表引擎推导查询 MV 的引擎与版本/符号列。
+package clickhouse
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// 目标表布局。
+const (
+	TargetLayoutCustom = "custom"
+	TargetLayoutMirror = "mirror"
+)
+
+// NormalizeTargetLayout 校验并规范化目标表布局，空值视为 custom。
+func NormalizeTargetLayout(layout string) (string, error) {
+	switch l := strings.ToLower(strings.TrimSpace(layout)); l {
+	case "", TargetLayoutCustom:
+		return TargetLayoutCustom, nil
+	case TargetLayoutMirror:
+		return l, nil
+	}
+	return "", fmt.Errorf("不支持的 target_layout: %s（可选 mirror|custom）", layout)
+}
+
+// MVLayout 是查询 MV 的存储参数，取值含义与 CreateMaterializedViewOwn 的同名参数一致。
+type MVLayout struct {
+	Engine        string `json:"engine"`
+	OrderBy       string `json:"order_by"`
+	PartitionBy   string `json:"partition_by"`
+	VersionColumn string `json:"version_column,omitempty"`
+	SignColumn    string `json:"sign_column,omitempty"`
+}
+
+// engineSpec 是从 engine_full 拆出的引擎：Base 为去掉 Replicated 前缀的引擎名，Args 为引擎参数（复制引擎不含 ZooKeeper 路径与副本名），
+// Rest 为参数之后的 PARTITION BY / ORDER BY / TTL / SETTINGS 等子句。
+type engineSpec struct {
+	Base       string
+	Replicated bool
+	ZKPath     string
+	Replica    string
+	Args       []string
+	Rest       string
+}
+
+func parseEngineFull(engine string, engineFull string) engineSpec {
+	spec := engineSpec{Base: engine}
+	full := strings.TrimSpace(engineFull)
+	rest := strings.TrimSpace(strings.TrimPrefix(full, engine))
+	if strings.HasPrefix(rest, "(") {
+		end := closingParen(rest)
+		spec.Args = splitTopLevel(rest[1:end])
+		rest = rest[end+1:]
+	}
+	spec.Rest = strings.TrimSpace(rest)
+	if strings.HasPrefix(engine, "Replicated") {
+		spec.Base = strings.TrimPrefix(engine, "Replicated")
+		spec.Replicated = true
+		// 显式给出的 ZooKeeper 路径与副本名总是前两个字符串参数；省略时使用服务端 default_replica_path
+		if len(spec.Args) >= 2 && isQuotedLiteral(spec.Args[0]) && isQuotedLiteral(spec.Args[1]) {
+			spec.ZKPath = unquoteLiteral(spec.Args[0])
+			spec.Replica = unquoteLiteral(spec.Args[1])
+			spec.Args = spec.Args[2:]
+		}
+	}
+	return spec
+}
+
+// closingParen 返回以 '(' 开头的字符串中与之匹配的 ')' 下标，未闭合时返回末尾下标。
+func closingParen(s string) int {
+	depth := 0
+	inQuote := false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\' && inQuote:
+			i++
+		case c == '\'':
+			inQuote = !inQuote
+		case inQuote:
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(s) - 1
+}
+
+func isQuotedLiteral(s string) bool {
+	return len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\''
+}
+
+func unquoteLiteral(s string) string {
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "'"), "'")
+	return strings.NewReplacer(`\'`, `'`, `\\`, `\`).Replace(s)
+}
+
+// replicaPathFor 为目标表改写复制路径：路径中与源库名、源表名相同的段替换为目标名。
+// 改写后路径仍与源表实际使用的路径相同（没有 {uuid} 等宏区分）时返回 false，调用方应改用非复制引擎，避免两张表共享副本路径。
+func replicaPathFor(path string, srcDB string, srcTable string, tgtDB string, tgtTable string) (string, bool) {
+	segs := strings.Split(path, "/")
+	for i, s := range segs {
+		switch s {
+		case srcTable:
+			segs[i] = tgtTable
+		case srcDB:
+			segs[i] = tgtDB
+		}
+	}
+	out := strings.Join(segs, "/")
+	switch {
+	case strings.Contains(out, "{uuid}"):
+		return out, true
+	case strings.Contains(out, "{table}") && srcTable != tgtTable:
+		return out, true
+	case strings.Contains(out, "{database}") && srcDB != tgtDB:
+		return out, true
+	}
+	return out, out != path
+}
+
+// MirrorTableDDL 生成与源表结构一致的目标表建表语句（不含结尾分号）：列类型经 mapper 映射，保留默认表达式与编解码
+// （MATERIALIZED/ALIAS/EPHEMERAL 改为 DEFAULT，因为值由同步链路写入；类型被映射改写的列不保留编解码），
+// 跳数索引、投影与 ENGINE 子句（排序/分区/主键/采样键、TTL、设置）原样复刻。复制引擎的路径按目标库表改写，无法区分时降为非复制引擎。
+// 仅支持 MergeTree 家族引擎。
+func MirrorTableDDL(s *TableSchema, sourceDatabase string, sourceTable string, targetDatabase string, targetTable string, mapper *TypeMapper) (string, error) {
+	spec := parseEngineFull(s.Engine, s.EngineFull)
+	if !strings.HasSuffix(spec.Base, "MergeTree") {
+		return "", fmt.Errorf("mirror 布局仅支持 MergeTree 家族引擎，%s.%s 的引擎为 %s", sourceDatabase, sourceTable, s.Engine)
+	}
+	var defs []string
+	for _, c := range s.Columns {
+		typ := mapper.Map(c.Type)
+		def := quoteIdent(c.Name) + " " + typ
+		if e := strings.TrimSpace(c.DefaultExpression); e != "" {
+			def += " DEFAULT " + e
+		}
+		if strings.TrimSpace(c.Codec) != "" && typ == c.Type {
+			def += " " + c.Codec
+		}
+		defs = append(defs, def)
+	}
+	for _, ix := range s.Indexes {
+		defs = append(defs, fmt.Sprintf("INDEX %s %s TYPE %s GRANULARITY %d", quoteIdent(ix.Name), ix.Expr, ix.Type, ix.Granularity))
+	}
+	var projs []string
+	for name := range s.Projections {
+		projs = append(projs, name)
+	}
+	sort.Strings(projs)
+	for _, name := range projs {
+		defs = append(defs, fmt.Sprintf("PROJECTION %s (%s)", quoteIdent(name), s.Projections[name]))
+	}
+	engine := spec.Base
+	args := spec.Args
+	if spec.Replicated {
+		if spec.ZKPath == "" {
+			engine = "Replicated" + spec.Base
+		} else if path, ok := replicaPathFor(spec.ZKPath, sourceDatabase, sourceTable, targetDatabase, targetTable); ok {
+			engine = "Replicated" + spec.Base
+			args = append([]string{quoteString(path), quoteString(spec.Replica)}, args...)
+		}
+	}
+	if len(args) > 0 || strings.Contains(s.EngineFull, s.Engine+"(") {
+		engine += "(" + strings.Join(args, ", ") + ")"
+	}
+	if spec.Rest != "" {
+		engine += " " + spec.Rest
+	}
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s) ENGINE = %s", qualified(targetDatabase, targetTable), strings.Join(defs, ", "), engine), nil
+}
+
+// CreateTargetTableMirror 按源表结构复刻目标表（见 MirrorTableDDL），返回执行的建表语句。
+func CreateTargetTableMirror(db *sql.DB, sourceDatabase string, sourceTable string, targetDatabase string, targetTable string, mapper *TypeMapper) (string, error) {
+	if targetDatabase == "" {
+		targetDatabase = sourceDatabase
+	}
+	if targetTable == "" {
+		targetTable = sourceTable + "_replica"
+	}
+	if err := CreateDatabaseIfNotExists(db, targetDatabase); err != nil {
+		return "", err
+	}
+	s, err := GetTableSchema(db, sourceDatabase, sourceTable)
+	if err != nil {
+		return "", err
+	}
+	ddl, err := MirrorTableDDL(s, sourceDatabase, sourceTable, targetDatabase, targetTable, mapper)
+	if err != nil {
+		return "", err
+	}
+	if _, err := db.Exec(ddl); err != nil {
+		return ddl, fmt.Errorf("ddl_failed: %s ; error: %v", ddl, err)
+	}
+	return ddl, nil
+}
+
+// SourceMVLayout 按源表引擎推导查询 MV 的存储参数：ReplacingMergeTree(ver) → replacing，CollapsingMergeTree(sign) → collapsing，
+// VersionedCollapsingMergeTree(sign, ver) → versioned_collapsing，其余 → merge；排序与分区键沿用源表（无分区键时为 tuple()）。
+func SourceMVLayout(s *TableSchema) MVLayout {
+	spec := parseEngineFull(s.Engine, s.EngineFull)
+	l := MVLayout{Engine: "merge", OrderBy: strings.TrimSpace(s.SortingKey), PartitionBy: strings.TrimSpace(s.PartitionKey)}
+	if l.OrderBy == "" {
+		l.OrderBy = "tuple()"
+	}
+	if l.PartitionBy == "" {
+		l.PartitionBy = "tuple()"
+	}
+	arg := func(i int) string {
+		if i < len(spec.Args) {
+			return strings.Trim(strings.TrimSpace(spec.Args[i]), "`")
+		}
+		return ""
+	}
+	switch spec.Base {
+	case "ReplacingMergeTree":
+		l.Engine = "replacing"
+		l.VersionColumn = arg(0)
+	case "CollapsingMergeTree":
+		l.Engine = "collapsing"
+		l.SignColumn = arg(0)
+	case "VersionedCollapsingMergeTree":
+		l.Engine = "versioned_collapsing"
+		l.SignColumn = arg(0)
+		l.VersionColumn = arg(1)
+	}
+	return l
+}
+
+// GetSourceMVLayout 读取源表结构并推导查询 MV 的存储参数（见 SourceMVLayout）。
+func GetSourceMVLayout(db *sql.DB, database string, table string) (MVLayout, error) {
+	s, err := GetTableSchemaWithColumns(db, database, table, []ColumnDetail{})
+	if err != nil {
+		return MVLayout{}, err
+	}
+	return SourceMVLayout(s), nil
+}
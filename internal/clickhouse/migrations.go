@@ -0,0 +1,109 @@
+// clickhouse 包中 exec-ddl 的迁移台账：记录 SQL 文件中每条语句的执行情况。
+package clickhouse
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/user"
+	"strings"
+	"time"
+)
+
+// MigrationsTable 是 exec-ddl 的迁移台账表名。
+const MigrationsTable = "ch_sync_migrations"
+
+// 台账记录的方向：up 为已执行，down 为已通过 -- +down 段回滚（视为未执行）。
+const (
+	MigrationUp   = "up"
+	MigrationDown = "down"
+)
+
+// 迁移段标记：-- +up/-- +down 成对标记一段变更及其回滚语句，-- +end 结束当前段；段外语句按普通语句执行，不参与回滚。
+const (
+	MigrationMarkerUp   = "-- +up"
+	MigrationMarkerDown = "-- +down"
+	MigrationMarkerEnd  = "-- +end"
+)
+
+// Migration 是一条语句的台账记录：Name 为迁移名（exec-ddl --migration，默认文件绝对路径），Key 标识语句
+// （迁移段内为 段名#序号，段外为语句校验和），Index 仅记录执行时语句在文件中的序号；同一语句以最新一条记录的方向为准。
+type Migration struct {
+	Name      string `json:"migration"`
+	Key       string `json:"statement_key"`
+	Index     int    `json:"statement_index"`
+	Checksum  string `json:"checksum"`
+	Direction string `json:"direction"`
+	AppliedAt string `json:"applied_at"`
+	Executor  string `json:"executor"`
+}
+
+// StatementChecksum 返回语句的校验和（首尾空白不参与计算）。
+func StatementChecksum(stmt string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(stmt)))
+	return hex.EncodeToString(sum[:])
+}
+
+// EnsureMigrationsTable 若不存在则创建迁移台账表（ReplacingMergeTree，同一迁移同一语句保留最新记录）。
+func EnsureMigrationsTable(db *sql.DB, database string) error {
+	if err := CreateDatabaseIfNotExists(db, database); err != nil {
+		return err
+	}
+	ddl := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (`migration` String, `statement_key` String, `statement_index` UInt32, `checksum` String, `direction` String, `applied_at` DateTime64(3), `executor` String) ENGINE = ReplacingMergeTree(`applied_at`) ORDER BY (`migration`, `statement_key`)", qualified(database, MigrationsTable))
+	_, err := db.Exec(ddl)
+	return err
+}
+
+// GetMigrations 读取某迁移当前处于已执行状态（最新记录为 up）的语句台账，按语句标识索引。台账表不存在时返回空结果。
+func GetMigrations(db *sql.DB, database string, name string) (map[string]Migration, error) {
+	out := map[string]Migration{}
+	ok, err := TableExists(db, database, MigrationsTable)
+	if err != nil || !ok {
+		return out, err
+	}
+	q := fmt.Sprintf("SELECT `migration`, `statement_key`, `statement_index`, `checksum`, `direction`, toString(`applied_at`), `executor` FROM %s FINAL WHERE `migration` = ? AND `direction` = 'up' ORDER BY `statement_index`", qualified(database, MigrationsTable))
+	rs, err := db.Query(q, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Close()
+	for rs.Next() {
+		var m Migration
+		var idx uint32
+		if err := rs.Scan(&m.Name, &m.Key, &idx, &m.Checksum, &m.Direction, &m.AppliedAt, &m.Executor); err != nil {
+			return nil, err
+		}
+		m.Index = int(idx)
+		out[m.Key] = m
+	}
+	return out, rs.Err()
+}
+
+// RecordMigration 写入一条语句的台账记录；Direction 为空时为 up，Executor 为空时记录为 用户@主机。
+func RecordMigration(db *sql.DB, database string, m Migration) error {
+	if m.Direction == "" {
+		m.Direction = MigrationUp
+	}
+	if m.Executor == "" {
+		m.Executor = migrationExecutor()
+	}
+	q := fmt.Sprintf("INSERT INTO %s (`migration`, `statement_key`, `statement_index`, `checksum`, `direction`, `applied_at`, `executor`) VALUES (?, ?, ?, ?, ?, ?, ?)", qualified(database, MigrationsTable))
+	_, err := db.Exec(q, m.Name, m.Key, uint32(m.Index), m.Checksum, m.Direction, time.Now(), m.Executor)
+	return err
+}
+
+func migrationExecutor() string {
+	name := os.Getenv("USER")
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		name = u.Username
+	}
+	if h, err := os.Hostname(); err == nil && h != "" {
+		if name == "" {
+			return h
+		}
+		return name + "@" + h
+	}
+	return name
+}
@@ -0,0 +1,209 @@
+// clickhouse 包中的库内对象清单与依赖排序，供 gen-ddl 按依赖顺序输出 DDL。
+package clickhouse
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// 库内对象类别。
+const (
+	ObjectTable            = "table"
+	ObjectView             = "view"
+	ObjectMaterializedView = "materialized_view"
+	ObjectDictionary       = "dictionary"
+	ObjectKafka            = "kafka"
+)
+
+// DatabaseObject 是库内的一个表、视图或字典；DependsOn 为同库中需要先创建的对象名。
+type DatabaseObject struct {
+	Name        string   `json:"name"`
+	Engine      string   `json:"engine"`
+	Kind        string   `json:"kind"`
+	DependsOn   []string `json:"depends_on,omitempty"`
+	createQuery string
+}
+
+// IsView 判断对象是否为视图类（普通视图、物化视图、Kafka 引擎表），gen-ddl 默认不输出这些对象。
+func (o DatabaseObject) IsView() bool {
+	return o.Kind == ObjectView || o.Kind == ObjectMaterializedView || o.Kind == ObjectKafka
+}
+
+// ListDatabaseObjects 读取库内全部表、视图与字典（不含物化视图的内部存储表 .inner*）并计算同库依赖：
+// system.tables 的 dependencies_*（依赖当前表的物化视图）与 loading_dependencies_*（较新版本提供），
+// 以及视图、物化视图、字典 DDL 中引用的表（见 ReferencedTables）。
+func ListDatabaseObjects(db *sql.DB, database string) ([]DatabaseObject, error) {
+	dicts := map[string]bool{}
+	rs, err := db.Query("SELECT name FROM system.dictionaries WHERE database = ?", database)
+	if err != nil {
+		return nil, err
+	}
+	for rs.Next() {
+		var n string
+		if err := rs.Scan(&n); err != nil {
+			rs.Close()
+			return nil, err
+		}
+		dicts[n] = true
+	}
+	rs.Close()
+	if err := rs.Err(); err != nil {
+		return nil, err
+	}
+
+	var hasLoading uint64
+	if err := db.QueryRow("SELECT count() FROM system.columns WHERE database = 'system' AND table = 'tables' AND name = 'loading_dependencies_table'").Scan(&hasLoading); err != nil {
+		return nil, err
+	}
+	loadingCols := "CAST([], 'Array(String)'), CAST([], 'Array(String)')"
+	if hasLoading > 0 {
+		loadingCols = "loading_dependencies_database, loading_dependencies_table"
+	}
+	q := fmt.Sprintf("SELECT name, engine, create_table_query, dependencies_database, dependencies_table, %s FROM system.tables WHERE database = ? AND NOT is_temporary AND NOT startsWith(name, '.inner') ORDER BY name", loadingCols)
+	rs, err = db.Query(q, database)
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Close()
+	var objs []DatabaseObject
+	// dependents[x] 为依赖 x 的对象（x 需先创建）
+	dependents := map[string][]string{}
+	for rs.Next() {
+		var o DatabaseObject
+		var depDB, depTbl, loadDB, loadTbl []string
+		if err := rs.Scan(&o.Name, &o.Engine, &o.createQuery, &depDB, &depTbl, &loadDB, &loadTbl); err != nil {
+			return nil, err
+		}
+		o.Kind = objectKind(o.Engine, dicts[o.Name])
+		for i := range depTbl {
+			if i < len(depDB) && depDB[i] == database {
+				dependents[o.Name] = append(dependents[o.Name], depTbl[i])
+			}
+		}
+		for i := range loadTbl {
+			if i < len(loadDB) && loadDB[i] == database {
+				o.DependsOn = append(o.DependsOn, loadTbl[i])
+			}
+		}
+		if o.Kind != ObjectTable && o.Kind != ObjectKafka {
+			for _, r := range ReferencedTables(o.createQuery, database) {
+				if r.Database == database {
+					o.DependsOn = append(o.DependsOn, r.Table)
+				}
+			}
+		}
+		objs = append(objs, o)
+	}
+	if err := rs.Err(); err != nil {
+		return nil, err
+	}
+	names := map[string]bool{}
+	for _, o := range objs {
+		names[o.Name] = true
+	}
+	for i := range objs {
+		o := &objs[i]
+		for src, ds := range dependents {
+			for _, d := range ds {
+				if d == o.Name {
+					o.DependsOn = append(o.DependsOn, src)
+				}
+			}
+		}
+		o.DependsOn = uniqueDeps(o.DependsOn, o.Name, names)
+	}
+	return objs, nil
+}
+
+func objectKind(engine string, dictionary bool) string {
+	switch {
+	case dictionary:
+		return ObjectDictionary
+	case engine == "MaterializedView":
+		return ObjectMaterializedView
+	case engine == "View" || engine == "LiveView" || engine == "WindowView":
+		return ObjectView
+	case engine == "Kafka":
+		return ObjectKafka
+	}
+	return ObjectTable
+}
+
+// uniqueDeps 去重并排序依赖，去掉自身与不在清单中的对象。
+func uniqueDeps(deps []string, self string, names map[string]bool) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, d := range deps {
+		if d == self || !names[d] || seen[d] {
+			continue
+		}
+		seen[d] = true
+		out = append(out, d)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// SortObjectsByDependency 按依赖做拓扑排序（被依赖的对象在前，同层按名称排序）；只考虑清单内对象之间的依赖。
+// 存在循环依赖时，循环中的对象按名称追加在末尾并通过 cycles 返回。
+func SortObjectsByDependency(objs []DatabaseObject) ([]DatabaseObject, []string) {
+	byName := map[string]DatabaseObject{}
+	indegree := map[string]int{}
+	for _, o := range objs {
+		byName[o.Name] = o
+		indegree[o.Name] = 0
+	}
+	dependents := map[string][]string{}
+	for _, o := range objs {
+		for _, d := range o.DependsOn {
+			if _, ok := byName[d]; ok {
+				indegree[o.Name]++
+				dependents[d] = append(dependents[d], o.Name)
+			}
+		}
+	}
+	var ready []string
+	for n, d := range indegree {
+		if d == 0 {
+			ready = append(ready, n)
+		}
+	}
+	var out []DatabaseObject
+	for len(ready) > 0 {
+		sort.Strings(ready)
+		n := ready[0]
+		ready = ready[1:]
+		out = append(out, byName[n])
+		for _, m := range dependents[n] {
+			indegree[m]--
+			if indegree[m] == 0 {
+				ready = append(ready, m)
+			}
+		}
+		delete(indegree, n)
+	}
+	var cycles []string
+	for n := range indegree {
+		cycles = append(cycles, n)
+	}
+	sort.Strings(cycles)
+	for _, n := range cycles {
+		out = append(out, byName[n])
+	}
+	return out, cycles
+}
+
+// ShowCreateObject 返回对象的建表语句：字典使用 SHOW CREATE DICTIONARY，其余使用 SHOW CREATE TABLE。
+func ShowCreateObject(db *sql.DB, database string, o DatabaseObject) (string, error) {
+	stmt := "SHOW CREATE TABLE"
+	if o.Kind == ObjectDictionary {
+		stmt = "SHOW CREATE DICTIONARY"
+	}
+	var ddl string
+	if err := db.QueryRow(fmt.Sprintf("%s %s", stmt, qualified(database, o.Name))).Scan(&ddl); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(ddl), nil
+}
@@ -0,0 +1,137 @@
+// clickhouse 包中的同步链路状态记录与查询辅助方法。
+package clickhouse
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"time"
+)
+
+// PipelineStateTable 是记录链路暂停/恢复状态的表名，建在 Kafka 引擎表所在库。
+const PipelineStateTable = "ch_sync_pipeline_state"
+
+// PipelineState 描述单个链路对象（sink/MV）最近一次暂停或恢复的记录；记录按源库 + 表名区分，
+// 不同源库的同名表共用 Kafka 库时互不覆盖。SourceDatabase 为空的是引入该列之前写入的旧记录。
+type PipelineState struct {
+	SourceDatabase string `json:"source_database,omitempty"`
+	Table          string `json:"table"`
+	Side           string `json:"side"`
+	Object         string `json:"object"`
+	State          string `json:"state"`
+	Reason         string `json:"reason,omitempty"`
+	Operator       string `json:"operator,omitempty"`
+	UpdatedAt      string `json:"updated_at"`
+}
+
+// EnsurePipelineStateTable 若不存在则创建链路状态表（ReplacingMergeTree，按更新时间保留最新记录）；
+// 旧版状态表缺少 source_database 列时补列并追加到排序键。
+func EnsurePipelineStateTable(db *sql.DB, database string) error {
+	if err := CreateDatabaseIfNotExists(db, database); err != nil {
+		return err
+	}
+	ddl := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (`table` String, `side` String, `object` String, `state` String, `reason` String, `operator` String, `updated_at` DateTime64(3), `source_database` String) ENGINE = ReplacingMergeTree(`updated_at`) ORDER BY (`table`, `side`, `object`, `source_database`)", qualified(database, PipelineStateTable))
+	if _, err := db.Exec(ddl); err != nil {
+		return err
+	}
+	ok, err := pipelineStateHasSourceDatabase(db, database)
+	if err != nil || ok {
+		return err
+	}
+	// 新增列可在同一 ALTER 中追加到排序键末尾
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS `source_database` String, MODIFY ORDER BY (`table`, `side`, `object`, `source_database`)", qualified(database, PipelineStateTable)))
+	return err
+}
+
+func pipelineStateHasSourceDatabase(db *sql.DB, database string) (bool, error) {
+	var n uint64
+	if err := db.QueryRow("SELECT count() FROM system.columns WHERE database = ? AND table = ? AND name = 'source_database'", database, PipelineStateTable).Scan(&n); err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// SetPipelineState 写入一条链路对象状态记录（paused|running）。
+func SetPipelineState(db *sql.DB, database string, st PipelineState) error {
+	if err := EnsurePipelineStateTable(db, database); err != nil {
+		return err
+	}
+	if st.Operator == "" {
+		if h, err := os.Hostname(); err == nil {
+			st.Operator = h
+		}
+	}
+	q := fmt.Sprintf("INSERT INTO %s (`source_database`, `table`, `side`, `object`, `state`, `reason`, `operator`, `updated_at`) VALUES (?, ?, ?, ?, ?, ?, ?, ?)", qualified(database, PipelineStateTable))
+	_, err := db.Exec(q, st.SourceDatabase, st.Table, st.Side, st.Object, st.State, st.Reason, st.Operator, time.Now())
+	return err
+}
+
+// GetPipelineStates 读取库内源表 sourceDatabase.table 的链路状态记录，同时返回未记录源库的旧记录（排在前面，
+// 调用方按顺序覆盖即以新记录为准）；table 为空时返回全部表。状态表不存在时返回空结果；只读，不迁移旧版状态表。
+func GetPipelineStates(db *sql.DB, database string, sourceDatabase string, table string) ([]PipelineState, error) {
+	ok, err := TableExists(db, database, PipelineStateTable)
+	if err != nil || !ok {
+		return nil, err
+	}
+	hasSource, err := pipelineStateHasSourceDatabase(db, database)
+	if err != nil {
+		return nil, err
+	}
+	sourceCol := "''"
+	if hasSource {
+		sourceCol = "`source_database`"
+	}
+	q := fmt.Sprintf("SELECT %s, `table`, `side`, `object`, `state`, `reason`, `operator`, toString(`updated_at`) FROM %s FINAL", sourceCol, qualified(database, PipelineStateTable))
+	var args []any
+	if table != "" {
+		q += " WHERE `table` = ?"
+		args = append(args, table)
+		if hasSource {
+			q += " AND `source_database` IN (?, '')"
+			args = append(args, sourceDatabase)
+		}
+	}
+	q += fmt.Sprintf(" ORDER BY `table`, `side`, `object`, %s", sourceCol)
+	rs, err := db.Query(q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Close()
+	var out []PipelineState
+	for rs.Next() {
+		var s PipelineState
+		if err := rs.Scan(&s.SourceDatabase, &s.Table, &s.Side, &s.Object, &s.State, &s.Reason, &s.Operator, &s.UpdatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rs.Err()
+}
+
+// TableExists 判断表或视图是否存在且处于挂载状态（DETACH 后的对象不在 system.tables 中）。
+func TableExists(db *sql.DB, database string, table string) (bool, error) {
+	var n uint64
+	if err := db.QueryRow("SELECT count() FROM system.tables WHERE database = ? AND name = ?", database, table).Scan(&n); err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// TableCreateQuery 返回表或视图在 system.tables 中的建表语句；对象不存在时返回空串。
+func TableCreateQuery(db *sql.DB, database string, table string) (string, error) {
+	var q string
+	err := db.QueryRow("SELECT create_table_query FROM system.tables WHERE database = ? AND name = ?", database, table).Scan(&q)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return q, err
+}
+
+// DatabaseExists 判断数据库是否存在。
+func DatabaseExists(db *sql.DB, database string) (bool, error) {
+	var n uint64
+	if err := db.QueryRow("SELECT count() FROM system.databases WHERE name = ?", database).Scan(&n); err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
@@ -0,0 +1,172 @@
+// clickhouse 包中的 quarantine 转换模式：主链路只写入可转换的行，无法转换的行由第二个 MV 连同原始值与原因写入隔离表。
+package clickhouse
+
+import (
+	"click-house-sync/internal/naming"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// QuarantineTableName 返回源表 sourceDatabase.table 的隔离表名，按 naming 模板生成。
+func QuarantineTableName(sourceDatabase string, table string) string {
+	return naming.QuarantineTable(sourceDatabase, table)
+}
+
+// QuarantineViewName 返回源表 sourceDatabase.table 的隔离物化视图名，按 naming 模板生成。
+func QuarantineViewName(sourceDatabase string, table string) string {
+	return naming.QuarantineView(sourceDatabase, table)
+}
+
+// CastFailureCondition 返回 String 值无法转换为目标类型的判断条件；目标为 String 等不会失败的类型时返回空串。
+// 标量以 accurateCastOrNull(...) IS NULL 判断，日期以 ...OrNull 解析函数判断，Enum 与转换表达式一样接受名称或数值；
+// 复合类型除校验 JSON 文本外逐元素判断（如 arrayExists 检查数组元素），JSON 类型只校验文本。
+// Nullable 目标的空串视为 NULL，不算失败。
+func CastFailureCondition(sourceExpr string, targetType string) string {
+	n, err := ParseType(strings.TrimSpace(targetType))
+	if err != nil {
+		return ""
+	}
+	base := n.Unwrap()
+	var cond string
+	switch f := base.Family(); {
+	case f == "string":
+		return ""
+	case f == "array" || f == "map" || f == "tuple" || f == "nested" || f == "json" || f == "object":
+		cond = rawFailureCondition(sourceExpr, base, 1)
+	default:
+		cond = castNode(sourceExpr, base, castOrNull) + " IS NULL"
+	}
+	cond += fmt.Sprintf(" AND %s IS NOT NULL", sourceExpr)
+	if n.IsNullable() {
+		cond += fmt.Sprintf(" AND %s != ''", sourceExpr)
+	}
+	return "(" + cond + ")"
+}
+
+// rawFailureCondition 返回 JSON 文本片段 x 无法按 castRawExpr 转换为类型 n 的条件，结构与 castRawExpr 对应；
+// 不会失败时返回空串。depth 用于生成不冲突的 lambda 参数名。
+func rawFailureCondition(x string, n *TypeNode, depth int) string {
+	t := n.Raw()
+	switch f := n.Family(); {
+	case f == "nullable" && len(n.Args) == 1:
+		if inner := rawFailureCondition(x, n.Args[0], depth); inner != "" {
+			return fmt.Sprintf("(%s != 'null' AND %s)", x, inner)
+		}
+		return ""
+	case f == "lowcardinality" && len(n.Args) == 1:
+		return rawFailureCondition(x, n.Args[0], depth)
+	case isDateFamily(f):
+		return castDateExpr("JSONExtractString("+x+")", n, castOrNull) + " IS NULL"
+	case f == "array" && len(n.Args) == 1:
+		v := fmt.Sprintf("x%d", depth)
+		return anyFailure(jsonShapeFailure(x, "'Array'"), existsFailure(v, rawFailureCondition(v, n.Args[0], depth+1), "JSONExtractArrayRaw("+x+")"))
+	case f == "nested" && len(n.Args) > 0:
+		var elems []string
+		for _, a := range n.Args {
+			elems = append(elems, a.argText())
+		}
+		if arr, err := ParseType("Array(Tuple(" + strings.Join(elems, ", ") + "))"); err == nil {
+			return rawFailureCondition(x, arr, depth)
+		}
+		return jsonShapeFailure(x, "'Array'")
+	case f == "map" && len(n.Args) == 2:
+		v := fmt.Sprintf("kv%d", depth)
+		var key string
+		if kf := n.Args[0].Unwrap().Family(); kf != "string" {
+			key = castNode(v+".1", n.Args[0], castOrNull) + " IS NULL"
+		}
+		return anyFailure(jsonShapeFailure(x, "'Object'"), existsFailure(v, anyFailure(key, rawFailureCondition(v+".2", n.Args[1], depth+1)), "JSONExtractKeysAndValuesRaw("+x+")"))
+	case f == "tuple" && len(n.Args) > 0:
+		conds := []string{jsonShapeFailure(x, "'Array', 'Object'")}
+		for i, a := range n.Args {
+			key := strconv.Itoa(i + 1)
+			if a.Field != "" {
+				key = quoteString(a.Field)
+			}
+			conds = append(conds, rawFailureCondition(fmt.Sprintf("JSONExtractRaw(%s, %s)", x, key), a, depth))
+		}
+		return anyFailure(conds...)
+	case f == "string":
+		return ""
+	case f == "json" || f == "object":
+		return fmt.Sprintf("NOT isValidJSON(%s)", x)
+	case f == "fixedstring":
+		return scalarCastExpr("JSONExtractString("+x+")", t, castOrNull) + " IS NULL"
+	case isEnumFamily(f):
+		return castEnumExpr("trim(BOTH '\"' FROM "+x+")", n, castOrNull) + " IS NULL"
+	}
+	return scalarCastExpr("trim(BOTH '\"' FROM "+x+")", t, castOrNull) + " IS NULL"
+}
+
+// jsonShapeFailure 返回 x 不是合法 JSON 或顶层类型不在 kinds（JSONType 的取值，如 'Array'）中的条件。
+func jsonShapeFailure(x string, kinds string) string {
+	return fmt.Sprintf("NOT isValidJSON(%s) OR JSONType(%s) NOT IN (%s)", x, x, kinds)
+}
+
+// existsFailure 返回 arr 中任一元素 v 满足 cond 的条件；cond 为空时返回空串。
+func existsFailure(v string, cond string, arr string) string {
+	if cond == "" {
+		return ""
+	}
+	return fmt.Sprintf("arrayExists(%s -> %s, %s)", v, cond, arr)
+}
+
+// anyFailure 以 OR 连接非空条件并加括号；全部为空时返回空串。
+func anyFailure(conds ...string) string {
+	var out []string
+	for _, c := range conds {
+		if c != "" {
+			out = append(out, c)
+		}
+	}
+	if len(out) == 0 {
+		return ""
+	}
+	return "(" + strings.Join(out, " OR ") + ")"
+}
+
+// QuarantineFilter 返回任一列转换失败的条件（以 OR 连接）；没有可能失败的列时返回空串。
+func QuarantineFilter(targetCols []Column) string {
+	var conds []string
+	for _, c := range targetCols {
+		if cond := CastFailureCondition(quoteIdent(c.Name), c.Type); cond != "" {
+			conds = append(conds, cond)
+		}
+	}
+	return strings.Join(conds, " OR ")
+}
+
+// QuarantineDDL 返回隔离表与隔离 MV 的建表语句（不含结尾分号）。隔离表以 String 保存 sink 中的原始值，
+// 并记录 Kafka 位点与失败原因；baseFilter 为主链路已有的过滤条件（如错误流的 length(_error) = 0），可为空。
+// 没有可能转换失败的列时返回 nil。
+func QuarantineDDL(database string, sourceDatabase string, table string, inputCols []Column, targetCols []Column, baseFilter string) []string {
+	filter := QuarantineFilter(targetCols)
+	if filter == "" {
+		return nil
+	}
+	var reasons []string
+	for _, c := range targetCols {
+		if cond := CastFailureCondition(quoteIdent(c.Name), c.Type); cond != "" {
+			reasons = append(reasons, fmt.Sprintf("if(%s, %s, '')", cond, quoteString(c.Name+": cast_failed -> "+c.Type)))
+		}
+	}
+	colsDDL := []string{"`_topic` String", "`_partition` UInt64", "`_offset` UInt64"}
+	selects := []string{"_topic AS `_topic`", "_partition AS `_partition`", "_offset AS `_offset`"}
+	for _, c := range inputCols {
+		colsDDL = append(colsDDL, quoteIdent(c.Name)+" String")
+		selects = append(selects, fmt.Sprintf("toString(%s) AS %s", quoteIdent(c.Name), quoteIdent(c.Name)))
+	}
+	colsDDL = append(colsDDL, "`_reason` String")
+	selects = append(selects, fmt.Sprintf("arrayStringConcat(arrayFilter(r -> r != '', [%s]), '; ') AS `_reason`", strings.Join(reasons, ", ")))
+	where := "(" + filter + ")"
+	if strings.TrimSpace(baseFilter) != "" {
+		where = baseFilter + " AND " + where
+	}
+	sink := qualified(database, naming.Sink(sourceDatabase, table))
+	qt := qualified(database, QuarantineTableName(sourceDatabase, table))
+	return []string{
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s, `_quarantined_at` DateTime DEFAULT now()) ENGINE = MergeTree PARTITION BY toYYYYMM(`_quarantined_at`) ORDER BY (`_topic`, `_partition`, `_offset`)", qt, strings.Join(colsDDL, ", ")),
+		fmt.Sprintf("CREATE MATERIALIZED VIEW IF NOT EXISTS %s TO %s (%s) AS SELECT %s FROM %s WHERE %s SETTINGS stream_like_engine_allow_direct_select=1, input_format_skip_unknown_fields=1, date_time_input_format='best_effort'", qualified(database, QuarantineViewName(sourceDatabase, table)), qt, strings.Join(colsDDL, ", "), strings.Join(selects, ", "), sink, where),
+	}
+}
@@ -0,0 +1,149 @@
+// clickhouse 包中的对齐脚本生成：根据 schema-diff 的列差异生成让目标表向源表看齐的 ALTER 语句及回滚语句。
+package clickhouse
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ReconcileStatement 是一条列级对齐变更；Guarded 为 true 表示该变更有损或具破坏性，输出时被注释，需人工确认。
+// DownGuarded 为 true 表示回滚语句有损（如撤销扩宽的 MODIFY 会收窄已有数据），脚本中只以注释给出，不参与 exec-ddl 回滚。
+// Unit 是脚本中该变更的迁移段名（-- +up <unit>），用于 exec-ddl --direction down --units。
+type ReconcileStatement struct {
+	Unit        string `json:"unit"`
+	Column      string `json:"column"`
+	Action      string `json:"action"`
+	Class       string `json:"class,omitempty"`
+	Up          string `json:"up"`
+	Down        string `json:"down"`
+	Guarded     bool   `json:"guarded"`
+	Reason      string `json:"reason,omitempty"`
+	DownGuarded bool   `json:"down_guarded,omitempty"`
+	DownReason  string `json:"down_reason,omitempty"`
+}
+
+// ReconcileOptions 控制哪些有风险的变更可以直接执行。
+type ReconcileOptions struct {
+	AllowLossy bool
+	AllowDrop  bool
+}
+
+// ReconcileColumns 按 ADD → MODIFY → DROP 的顺序生成对齐语句；MODIFY 的兼容性按“目标现有类型 → 源类型”判断，
+// 有损收窄、不兼容与去掉 Nullable 的变更默认加保护，目标多出的列（DROP）默认加保护；
+// 被类型规则判为等价（Class 为 CompatEquivalent）的类型差异不生成 MODIFY。
+func ReconcileColumns(database string, table string, sourceCols []Column, targetCols []Column, diffs []TypeDiff, opts ReconcileOptions) []ReconcileStatement {
+	tbl := qualified(database, table)
+	alter := func(c ColumnChange) string {
+		return fmt.Sprintf("ALTER TABLE %s %s;", tbl, c.SQL())
+	}
+	unit := func(action string, column string) string {
+		return fmt.Sprintf("%s.%s/%s_%s", database, table, action, column)
+	}
+	want := map[string]TypeDiff{}
+	for _, d := range diffs {
+		want[d.Column] = d
+	}
+	tgt := map[string]bool{}
+	for _, c := range targetCols {
+		tgt[c.Name] = true
+	}
+	var adds, modifies, drops []ReconcileStatement
+	prev := ""
+	for _, c := range sourceCols {
+		d, ok := want[c.Name]
+		switch {
+		case ok && d.Issue == "missing_in_target":
+			ch := ColumnChange{Column: c.Name, Action: "add", ToType: c.Type}
+			if tgt[prev] {
+				ch.After = prev
+			}
+			adds = append(adds, ReconcileStatement{
+				Unit:   unit("add", c.Name),
+				Column: c.Name,
+				Action: "add",
+				Up:     alter(ch),
+				Down:   alter(ColumnChange{Column: c.Name, Action: "drop"}),
+			})
+			tgt[c.Name] = true
+		case ok && d.Issue == "type_mismatch" && d.Class != CompatEquivalent:
+			class := ClassifyTypeChange(d.TargetType, d.SourceType)
+			st := ReconcileStatement{
+				Unit:   unit("modify", c.Name),
+				Column: c.Name,
+				Action: "modify",
+				Class:  class,
+				Up:     alter(ColumnChange{Column: c.Name, Action: "modify", FromType: d.TargetType, ToType: d.SourceType}),
+				Down:   alter(ColumnChange{Column: c.Name, Action: "modify", FromType: d.SourceType, ToType: d.TargetType}),
+			}
+			if unsafeTypeChange(class, d.SourceType) && !opts.AllowLossy {
+				st.Guarded = true
+				st.Reason = "目标列 " + d.TargetType + " -> " + d.SourceType + " 为 " + class + "，已有数据可能截断或转换失败"
+			}
+			if back := ClassifyTypeChange(d.SourceType, d.TargetType); unsafeTypeChange(back, d.TargetType) {
+				st.DownGuarded = true
+				st.DownReason = "回滚 " + d.SourceType + " -> " + d.TargetType + " 为 " + back + "，会截断变更后写入的数据"
+			}
+			modifies = append(modifies, st)
+		}
+		prev = c.Name
+	}
+	for _, c := range targetCols {
+		d, ok := want[c.Name]
+		if !ok || d.Issue != "missing_in_source" {
+			continue
+		}
+		st := ReconcileStatement{
+			Unit:   unit("drop", c.Name),
+			Column: c.Name,
+			Action: "drop",
+			Up:     alter(ColumnChange{Column: c.Name, Action: "drop"}),
+			Down:   alter(ColumnChange{Column: c.Name, Action: "add", ToType: c.Type}),
+		}
+		if !opts.AllowDrop {
+			st.Guarded = true
+			st.Reason = "删除目标列会丢失数据，回滚只能恢复列定义"
+		}
+		drops = append(drops, st)
+	}
+	out := append(adds, modifies...)
+	return append(out, drops...)
+}
+
+// unsafeTypeChange 判断把列改为 toType 的类别是否有损：有损收窄、不兼容与去掉 Nullable。
+func unsafeTypeChange(class string, toType string) bool {
+	return class == CompatLossyNarrowing || class == CompatIncompatible ||
+		(class == CompatNullableChange && !isNullableType(toType))
+}
+
+// RenderReconcileSQL 把对齐语句渲染为 exec-ddl 可执行的脚本：每条变更为一个 -- +up/-- +down 迁移段，
+// exec-ddl 正常执行时只执行 up，up 失败或 --direction down 时才执行 down。
+// 被保护的变更整段以注释形式输出；回滚有损时 down 以注释形式输出，该段不可自动回滚。
+func RenderReconcileSQL(header string, stmts []ReconcileStatement) string {
+	var b strings.Builder
+	b.WriteString("-- " + header + "\n")
+	if len(stmts) == 0 {
+		b.WriteString("-- 无需变更\n")
+		return b.String()
+	}
+	for _, st := range stmts {
+		if st.Guarded {
+			b.WriteString("-- [guarded] " + st.Reason + "\n")
+			b.WriteString("-- " + st.Up + "\n")
+			continue
+		}
+		if st.Class != "" {
+			b.WriteString("-- [" + st.Class + "]\n")
+		}
+		b.WriteString(MigrationMarkerUp + " " + st.Unit + "\n")
+		b.WriteString(st.Up + "\n")
+		if st.DownGuarded {
+			b.WriteString("-- [down guarded] " + st.DownReason + "\n")
+			b.WriteString("-- " + st.Down + "\n")
+		} else {
+			b.WriteString(MigrationMarkerDown + " " + st.Unit + "\n")
+			b.WriteString(st.Down + "\n")
+		}
+		b.WriteString(MigrationMarkerEnd + "\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,54 @@
+package clickhouse
+
+import "testing"
+
+func TestRenderReconcileSQL(t *testing.T) {
+	src := []Column{{Name: "id", Type: "UInt64"}, {Name: "amount", Type: "Int64"}, {Name: "code", Type: "String"}, {Name: "note", Type: "String"}}
+	tgt := []Column{{Name: "id", Type: "UInt64"}, {Name: "amount", Type: "Int32"}, {Name: "code", Type: "UInt8"}, {Name: "legacy", Type: "String"}}
+	diffs := []TypeDiff{
+		{Column: "amount", Issue: "type_mismatch", SourceType: "Int64", TargetType: "Int32"},
+		{Column: "code", Issue: "type_mismatch", SourceType: "String", TargetType: "UInt8"},
+		{Column: "note", Issue: "missing_in_target", SourceType: "String"},
+		{Column: "legacy", Issue: "missing_in_source", TargetType: "String"},
+	}
+	stmts := ReconcileColumns("demo", "orders", src, tgt, diffs, ReconcileOptions{})
+	got := RenderReconcileSQL("demo.orders -> demo.orders", stmts)
+	want := `-- demo.orders -> demo.orders
+-- +up demo.orders/add_note
+ALTER TABLE ` + "`demo`.`orders`" + ` ADD COLUMN IF NOT EXISTS ` + "`note`" + ` String AFTER ` + "`code`" + `;
+-- +down demo.orders/add_note
+ALTER TABLE ` + "`demo`.`orders`" + ` DROP COLUMN IF EXISTS ` + "`note`" + `;
+-- +end
+-- [safe_widening]
+-- +up demo.orders/modify_amount
+ALTER TABLE ` + "`demo`.`orders`" + ` MODIFY COLUMN ` + "`amount`" + ` Int64;
+-- [down guarded] 回滚 Int64 -> Int32 为 lossy_narrowing，会截断变更后写入的数据
+-- ALTER TABLE ` + "`demo`.`orders`" + ` MODIFY COLUMN ` + "`amount`" + ` Int32;
+-- +end
+-- [safe_widening]
+-- +up demo.orders/modify_code
+ALTER TABLE ` + "`demo`.`orders`" + ` MODIFY COLUMN ` + "`code`" + ` String;
+-- [down guarded] 回滚 String -> UInt8 为 incompatible，会截断变更后写入的数据
+-- ALTER TABLE ` + "`demo`.`orders`" + ` MODIFY COLUMN ` + "`code`" + ` UInt8;
+-- +end
+-- [guarded] 删除目标列会丢失数据，回滚只能恢复列定义
+-- ALTER TABLE ` + "`demo`.`orders`" + ` DROP COLUMN IF EXISTS ` + "`legacy`" + `;
+`
+	if got != want {
+		t.Fatalf("\n got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestReconcileColumnsSkipsRuleEquivalent(t *testing.T) {
+	src := []Column{{Name: "id", Type: "UInt64"}, {Name: "created_at", Type: "DateTime64(3)"}, {Name: "amount", Type: "Int64"}}
+	tgt := []Column{{Name: "id", Type: "UInt64"}, {Name: "created_at", Type: "DateTime"}, {Name: "amount", Type: "Int32"}}
+	diffs := []TypeDiff{
+		{Column: "created_at", Issue: "type_mismatch", SourceType: "DateTime64(3)", TargetType: "DateTime"},
+		{Column: "amount", Issue: "type_mismatch", SourceType: "Int64", TargetType: "Int32"},
+	}
+	kept, _ := ApplyTypeRules(diffs, TypeRules{Equivalent: []TypeEquivalence{{Source: "DateTime64(*)", Target: "DateTime"}}})
+	stmts := ReconcileColumns("demo", "orders", src, tgt, kept, ReconcileOptions{})
+	if len(stmts) != 1 || stmts[0].Column != "amount" {
+		t.Fatalf("want only amount to be modified, got %+v", stmts)
+	}
+}
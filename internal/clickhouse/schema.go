@@ -0,0 +1,334 @@
+// clickhouse 包中的完整表结构读取与对比：引擎、排序/分区/主键、TTL、编解码、默认表达式、跳数索引、投影与表设置。
+package clickhouse
+
+import (
+	"database/sql"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ColumnDetail 是带默认表达式与编解码信息的列描述。
+type ColumnDetail struct {
+	Name              string `json:"name"`
+	Type              string `json:"type"`
+	Position          uint64 `json:"position"`
+	DefaultKind       string `json:"default_kind,omitempty"`
+	DefaultExpression string `json:"default_expression,omitempty"`
+	Codec             string `json:"codec,omitempty"`
+}
+
+// SkipIndex 描述一个跳数索引。
+type SkipIndex struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Expr        string `json:"expr"`
+	Granularity uint64 `json:"granularity"`
+}
+
+// TableSchema 汇总 system.tables / system.columns / system.data_skipping_indices 中的表结构信息。
+type TableSchema struct {
+	Engine       string            `json:"engine"`
+	SortingKey   string            `json:"sorting_key"`
+	PartitionKey string            `json:"partition_key"`
+	PrimaryKey   string            `json:"primary_key"`
+	SamplingKey  string            `json:"sampling_key"`
+	TTL          string            `json:"ttl"`
+	Settings     map[string]string `json:"settings"`
+	Columns      []ColumnDetail    `json:"columns"`
+	Indexes      []SkipIndex       `json:"indexes"`
+	Projections  map[string]string `json:"projections"`
+	// EngineFull 为 system.tables.engine_full 原文（引擎参数及其后的键、TTL 与设置），供 mirror 布局复刻目标表
+	EngineFull string `json:"-"`
+}
+
+// SchemaDiffItem 是单个结构差异；Object 为列名、索引名、设置名等，表级属性为空。
+type SchemaDiffItem struct {
+	Object string `json:"object,omitempty"`
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Issue  string `json:"issue"`
+}
+
+// SchemaDiffCategories 是 schema-diff 支持的差异类别；columns/order 为列名类型与位置，其余为表结构维度。
+var SchemaDiffCategories = []string{"columns", "order", "engine", "order_by", "partition_by", "primary_key", "sampling", "ttl", "codecs", "defaults", "indexes", "projections", "settings"}
+
+var (
+	engineTTLPattern      = regexp.MustCompile(`(?s)\sTTL\s+(.*?)(?:\s+SETTINGS\s+|$)`)
+	engineSettingsPattern = regexp.MustCompile(`(?s)\sSETTINGS\s+(.*)$`)
+	projectionPattern     = regexp.MustCompile(`(?i)\bPROJECTION\s+(` + "`[^`]+`" + `|\w+)\s*\(`)
+)
+
+// ColumnsOf 把列详情转换为 GetColumns 同构的列描述。
+func ColumnsOf(details []ColumnDetail) []Column {
+	out := make([]Column, 0, len(details))
+	for _, c := range details {
+		out = append(out, Column{Name: c.Name, Type: c.Type, Position: c.Position})
+	}
+	return out
+}
+
+// GetDatabaseColumns 一次查询读取整个库的列详情，按表名分组。
+func GetDatabaseColumns(db *sql.DB, database string) (map[string][]ColumnDetail, error) {
+	rs, err := db.Query("SELECT table, name, type, position, default_kind, default_expression, compression_codec FROM system.columns WHERE database = ? ORDER BY table, position", database)
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Close()
+	out := map[string][]ColumnDetail{}
+	for rs.Next() {
+		var table string
+		var c ColumnDetail
+		if err := rs.Scan(&table, &c.Name, &c.Type, &c.Position, &c.DefaultKind, &c.DefaultExpression, &c.Codec); err != nil {
+			return nil, err
+		}
+		out[table] = append(out[table], c)
+	}
+	return out, rs.Err()
+}
+
+// ListDataTables 返回库中存放数据的表（排除视图、物化视图、Kafka 引擎表与字典），按名称排序。
+func ListDataTables(db *sql.DB, database string) ([]string, error) {
+	rs, err := db.Query("SELECT name FROM system.tables WHERE database = ? AND engine NOT IN ('View', 'MaterializedView', 'LiveView', 'WindowView', 'Kafka', 'Dictionary') AND NOT is_temporary ORDER BY name", database)
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Close()
+	var out []string
+	for rs.Next() {
+		var n string
+		if err := rs.Scan(&n); err != nil {
+			return nil, err
+		}
+		out = append(out, n)
+	}
+	return out, rs.Err()
+}
+
+// GetTableSchema 读取表的完整结构信息。
+func GetTableSchema(db *sql.DB, database string, table string) (*TableSchema, error) {
+	return GetTableSchemaWithColumns(db, database, table, nil)
+}
+
+// GetTableSchemaWithColumns 与 GetTableSchema 相同，但复用已读取的列详情（为 nil 时查询 system.columns）。
+func GetTableSchemaWithColumns(db *sql.DB, database string, table string, columns []ColumnDetail) (*TableSchema, error) {
+	s := &TableSchema{Settings: map[string]string{}, Projections: map[string]string{}}
+	var createQuery string
+	err := db.QueryRow("SELECT engine, sorting_key, partition_key, primary_key, sampling_key, engine_full, create_table_query FROM system.tables WHERE database = ? AND name = ?", database, table).
+		Scan(&s.Engine, &s.SortingKey, &s.PartitionKey, &s.PrimaryKey, &s.SamplingKey, &s.EngineFull, &createQuery)
+	if err != nil {
+		return nil, err
+	}
+	engineFull := s.EngineFull
+	if m := engineTTLPattern.FindStringSubmatch(engineFull); m != nil {
+		s.TTL = strings.TrimSpace(m[1])
+	}
+	if m := engineSettingsPattern.FindStringSubmatch(engineFull); m != nil {
+		for _, kv := range splitTopLevel(m[1]) {
+			parts := strings.SplitN(kv, "=", 2)
+			if len(parts) == 2 {
+				s.Settings[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+			}
+		}
+	}
+	for _, loc := range projectionPattern.FindAllStringSubmatchIndex(createQuery, -1) {
+		name := strings.Trim(createQuery[loc[2]:loc[3]], "`")
+		body := balancedParens(createQuery[loc[1]-1:])
+		s.Projections[name] = body
+	}
+	if columns != nil {
+		s.Columns = columns
+	} else {
+		rs, err := db.Query("SELECT name, type, position, default_kind, default_expression, compression_codec FROM system.columns WHERE database = ? AND table = ? ORDER BY position", database, table)
+		if err != nil {
+			return nil, err
+		}
+		defer rs.Close()
+		for rs.Next() {
+			var c ColumnDetail
+			if err := rs.Scan(&c.Name, &c.Type, &c.Position, &c.DefaultKind, &c.DefaultExpression, &c.Codec); err != nil {
+				return nil, err
+			}
+			s.Columns = append(s.Columns, c)
+		}
+		if err := rs.Err(); err != nil {
+			return nil, err
+		}
+	}
+	irs, err := db.Query("SELECT name, type, expr, granularity FROM system.data_skipping_indices WHERE database = ? AND table = ? ORDER BY name", database, table)
+	if err != nil {
+		return nil, err
+	}
+	defer irs.Close()
+	for irs.Next() {
+		var ix SkipIndex
+		if err := irs.Scan(&ix.Name, &ix.Type, &ix.Expr, &ix.Granularity); err != nil {
+			return nil, err
+		}
+		s.Indexes = append(s.Indexes, ix)
+	}
+	return s, irs.Err()
+}
+
+// splitTopLevel 按顶层逗号切分（忽略括号与引号内的逗号）。
+func splitTopLevel(s string) []string {
+	var out []string
+	depth := 0
+	inQuote := false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\' && inQuote:
+			i++
+		case c == '\'':
+			inQuote = !inQuote
+		case inQuote:
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			out = append(out, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+	if rest := strings.TrimSpace(s[start:]); rest != "" {
+		out = append(out, rest)
+	}
+	return out
+}
+
+// balancedParens 返回以 '(' 开头的平衡括号片段（不含外层括号）。
+func balancedParens(s string) string {
+	depth := 0
+	inQuote := false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\' && inQuote:
+			i++
+		case c == '\'':
+			inQuote = !inQuote
+		case inQuote:
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+			if depth == 0 {
+				return strings.TrimSpace(s[1:i])
+			}
+		}
+	}
+	return strings.TrimSpace(strings.TrimPrefix(s, "("))
+}
+
+// engineSignature 返回引擎名及其参数（如 ReplacingMergeTree(version)），取自 engine_full 开头；无参数时只返回引擎名。
+func engineSignature(s *TableSchema) string {
+	rest := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(s.EngineFull), s.Engine))
+	if s.Engine == "" || !strings.HasPrefix(strings.TrimSpace(s.EngineFull), s.Engine) || !strings.HasPrefix(rest, "(") {
+		return s.Engine
+	}
+	return s.Engine + "(" + balancedParens(rest) + ")"
+}
+
+func normalizeExpr(s string) string {
+	return strings.Join(strings.Fields(strings.ReplaceAll(s, "`", "")), " ")
+}
+
+// CompareTableSchemas 按启用的类别对比两张表的结构维度（不含 columns/order，由 AnalyzeTypeDiff 与位置对比负责）。
+func CompareTableSchemas(src *TableSchema, tgt *TableSchema, enabled map[string]bool) map[string][]SchemaDiffItem {
+	out := map[string][]SchemaDiffItem{}
+	scalar := func(cat, s, t string) {
+		if enabled[cat] && normalizeExpr(s) != normalizeExpr(t) {
+			out[cat] = append(out[cat], SchemaDiffItem{Source: s, Target: t, Issue: cat + "_mismatch"})
+		}
+	}
+	scalar("engine", engineSignature(src), engineSignature(tgt))
+	scalar("order_by", src.SortingKey, tgt.SortingKey)
+	scalar("partition_by", src.PartitionKey, tgt.PartitionKey)
+	scalar("primary_key", src.PrimaryKey, tgt.PrimaryKey)
+	scalar("sampling", src.SamplingKey, tgt.SamplingKey)
+	scalar("ttl", src.TTL, tgt.TTL)
+
+	tcols := map[string]ColumnDetail{}
+	for _, c := range tgt.Columns {
+		tcols[c.Name] = c
+	}
+	for _, sc := range src.Columns {
+		tc, ok := tcols[sc.Name]
+		if !ok {
+			continue
+		}
+		if enabled["codecs"] && normalizeExpr(sc.Codec) != normalizeExpr(tc.Codec) {
+			out["codecs"] = append(out["codecs"], SchemaDiffItem{Object: sc.Name, Source: sc.Codec, Target: tc.Codec, Issue: "codec_mismatch"})
+		}
+		if enabled["defaults"] {
+			sd := strings.TrimSpace(sc.DefaultKind + " " + sc.DefaultExpression)
+			td := strings.TrimSpace(tc.DefaultKind + " " + tc.DefaultExpression)
+			if normalizeExpr(sd) != normalizeExpr(td) {
+				out["defaults"] = append(out["defaults"], SchemaDiffItem{Object: sc.Name, Source: sd, Target: td, Issue: "default_mismatch"})
+			}
+		}
+	}
+	if enabled["indexes"] {
+		sidx := map[string]SkipIndex{}
+		for _, ix := range src.Indexes {
+			sidx[ix.Name] = ix
+		}
+		tidx := map[string]SkipIndex{}
+		for _, ix := range tgt.Indexes {
+			tidx[ix.Name] = ix
+		}
+		out["indexes"] = append(out["indexes"], compareNamed(indexDefs(sidx), indexDefs(tidx), "index")...)
+	}
+	if enabled["projections"] {
+		out["projections"] = append(out["projections"], compareNamed(src.Projections, tgt.Projections, "projection")...)
+	}
+	if enabled["settings"] {
+		out["settings"] = append(out["settings"], compareNamed(src.Settings, tgt.Settings, "setting")...)
+	}
+	for k, v := range out {
+		if len(v) == 0 {
+			delete(out, k)
+		}
+	}
+	return out
+}
+
+func indexDefs(m map[string]SkipIndex) map[string]string {
+	out := map[string]string{}
+	for name, ix := range m {
+		out[name] = ix.Expr + " TYPE " + ix.Type + " GRANULARITY " + strconv.FormatUint(ix.Granularity, 10)
+	}
+	return out
+}
+
+// compareNamed 对比两组按名称索引的定义，输出缺失与不一致项（按名称排序）。
+func compareNamed(src map[string]string, tgt map[string]string, kind string) []SchemaDiffItem {
+	names := map[string]struct{}{}
+	for n := range src {
+		names[n] = struct{}{}
+	}
+	for n := range tgt {
+		names[n] = struct{}{}
+	}
+	var sorted []string
+	for n := range names {
+		sorted = append(sorted, n)
+	}
+	sort.Strings(sorted)
+	var out []SchemaDiffItem
+	for _, n := range sorted {
+		s, sok := src[n]
+		t, tok := tgt[n]
+		switch {
+		case !tok:
+			out = append(out, SchemaDiffItem{Object: n, Source: s, Issue: kind + "_missing_in_target"})
+		case !sok:
+			out = append(out, SchemaDiffItem{Object: n, Target: t, Issue: kind + "_missing_in_source"})
+		case normalizeExpr(s) != normalizeExpr(t):
+			out = append(out, SchemaDiffItem{Object: n, Source: s, Target: t, Issue: kind + "_mismatch"})
+		}
+	}
+	return out
+}
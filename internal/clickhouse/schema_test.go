@@ -0,0 +1,20 @@
+package clickhouse
+
+import "testing"
+
+func TestCompareTableSchemasEngineArgs(t *testing.T) {
+	src := &TableSchema{Engine: "ReplacingMergeTree", EngineFull: "ReplacingMergeTree(version) ORDER BY id SETTINGS index_granularity = 8192"}
+	tgt := &TableSchema{Engine: "ReplacingMergeTree", EngineFull: "ReplacingMergeTree(updated_at) ORDER BY id SETTINGS index_granularity = 8192"}
+	out := CompareTableSchemas(src, tgt, map[string]bool{"engine": true})
+	if len(out["engine"]) != 1 || out["engine"][0].Source != "ReplacingMergeTree(version)" || out["engine"][0].Target != "ReplacingMergeTree(updated_at)" {
+		t.Errorf("引擎参数不同应报告差异: %+v", out["engine"])
+	}
+	tgt.EngineFull = "ReplacingMergeTree(`version`) ORDER BY id"
+	if out := CompareTableSchemas(src, tgt, map[string]bool{"engine": true}); len(out["engine"]) != 0 {
+		t.Errorf("仅反引号不同不应报告差异: %+v", out["engine"])
+	}
+	plain := &TableSchema{Engine: "MergeTree", EngineFull: "MergeTree ORDER BY id"}
+	if got := engineSignature(plain); got != "MergeTree" {
+		t.Errorf("engineSignature = %s", got)
+	}
+}
@@ -0,0 +1,257 @@
+// clickhouse 包中基于词法切分的库名改写：按映射把 DDL 中出现库名限定的位置替换为目标库，其余文本原样保留。
+package clickhouse
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseDatabaseMap 解析 a=b,c=d 形式的库名映射。
+func ParseDatabaseMap(s string) (map[string]string, error) {
+	out := map[string]string{}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(part, "=")
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		if !ok || k == "" || v == "" {
+			return nil, fmt.Errorf("库名映射 %q 格式应为 源库=目标库", part)
+		}
+		if prev, dup := out[k]; dup && prev != v {
+			return nil, fmt.Errorf("库名映射中 %s 对应多个目标库: %s, %s", k, prev, v)
+		}
+		out[k] = v
+	}
+	return out, nil
+}
+
+// 带库名参数（'db'、db 或 db.table/'db.table'）的函数与引擎，按小写名称索引到该参数的位置。
+var databaseArgFuncs = map[string]int{
+	"remote":             1,
+	"remotesecure":       1,
+	"cluster":            1,
+	"clusterallreplicas": 1,
+	"distributed":        1,
+	"buffer":             0,
+	"merge":              0,
+	"dictionary":         0,
+	"joinget":            0,
+	"joingetornull":      0,
+}
+
+// 库名参数只能是 'db.table' 形式的函数（单独的 'name' 是表名或字典名）。
+var tableArgFuncs = map[string]bool{"dictionary": true, "joinget": true, "joingetornull": true}
+
+// 名称前出现这些关键字时，紧随其后的 [db.]name 是表、视图或字典引用。
+var tableRefKeywords = map[string]bool{
+	"FROM": true, "JOIN": true, "TO": true, "INTO": true, "TABLE": true, "TABLES": true,
+	"VIEW": true, "DICTIONARY": true, "EXISTS": true,
+}
+
+// RenameDatabases 按 mapping（源库 → 目标库）改写 DDL 中的库名：
+//   - CREATE/ALTER/DROP 的对象名、FROM/JOIN/INTO、物化视图 TO 目标、CREATE TABLE ... AS db.t 与三段式 db.table.column 中的库名
+//   - CREATE DATABASE 的库名，GRANT/REVOKE 与行策略 ON db.* / ON db.table 中的库名
+//   - remote/cluster/Distributed/Buffer/Merge/Dictionary/joinGet 的库名参数，dictGet 系列函数的 'db.dict' 参数
+//   - 字典 SOURCE(CLICKHOUSE(DB '..')) 中的库名
+//
+// 反引号、双引号与裸标识符均可识别，替换后保持原引号风格；其他字符串字面量与注释不做改动。
+func RenameDatabases(ddl string, mapping map[string]string) string {
+	if len(mapping) == 0 {
+		return ddl
+	}
+	toks := TokenizeSQL(ddl)
+	// sig 为有效词元在 toks 中的下标
+	var sig []int
+	for i, t := range toks {
+		if t.Kind != SQLSpace && t.Kind != SQLComment {
+			sig = append(sig, i)
+		}
+	}
+	tok := func(k int) SQLToken {
+		if k < 0 || k >= len(sig) {
+			return SQLToken{}
+		}
+		return toks[sig[k]]
+	}
+	renamed := map[int]bool{}
+	renameIdent := func(k int) {
+		if renamed[k] {
+			return
+		}
+		name, ok := tok(k).Ident()
+		if !ok {
+			return
+		}
+		if to, ok := mapping[name]; ok {
+			toks[sig[k]].Text = requoteIdent(tok(k), to)
+			renamed[k] = true
+		}
+	}
+	// renameQualified 改写第 k 个词元开始的 db.name 中的库名；name 可为 *（授权）
+	renameQualified := func(k int) {
+		if tok(k+1).Text == "." {
+			if _, ok := tok(k + 2).Ident(); ok || tok(k+2).Text == "*" {
+				renameIdent(k)
+			}
+		}
+	}
+	// renameStringArg 改写字符串参数 'db' 或 'db.name'（withTable 为 true 时只接受带表名的形式）
+	renameStringArg := func(k int, withTable bool) {
+		v, ok := tok(k).StringValue()
+		if !ok || renamed[k] {
+			return
+		}
+		db, rest, qualified := strings.Cut(v, ".")
+		if withTable && !qualified {
+			return
+		}
+		to, ok := mapping[db]
+		if !ok {
+			return
+		}
+		if qualified {
+			to += "." + rest
+		}
+		toks[sig[k]].Text = quoteSQLString(to)
+		renamed[k] = true
+	}
+
+	stmtKind := ""
+	inSource := 0
+	for k := 0; k < len(sig); k++ {
+		t := tok(k)
+		upper := strings.ToUpper(t.Text)
+		if k == 0 || tok(k-1).Text == ";" {
+			stmtKind = ""
+			if t.Kind == SQLIdent {
+				stmtKind = upper
+			}
+		}
+		switch {
+		case t.Kind == SQLIdent && tableRefKeywords[upper]:
+			renameQualified(k + 1)
+		case t.Keyword("AS") && stmtKind == "CREATE":
+			// CREATE TABLE t AS db.src（复制结构）
+			renameQualified(k + 1)
+		case t.Keyword("DATABASE") && tok(k-1).Keyword("CREATE"):
+			j := k + 1
+			if tok(j).Keyword("IF") {
+				j += 3
+			}
+			renameIdent(j)
+		case t.Keyword("ON") && (stmtKind == "GRANT" || stmtKind == "REVOKE" || (stmtKind == "CREATE" && containsKeyword(toks, sig, k, "POLICY"))):
+			renameQualified(k + 1)
+		case t.Keyword("SOURCE") && tok(k+1).Text == "(":
+			inSource = 1
+			k++
+		case inSource > 0 && t.Text == "(":
+			inSource++
+		case inSource > 0 && t.Text == ")":
+			inSource--
+		case inSource > 0 && t.Keyword("DB"):
+			if tok(k+1).Kind == SQLString {
+				renameStringArg(k+1, false)
+			} else {
+				renameIdent(k + 1)
+			}
+		case t.Kind == SQLIdent && tok(k+1).Text == "(":
+			lower := strings.ToLower(t.Text)
+			if strings.HasPrefix(lower, "dictget") || lower == "dicthas" || lower == "dictgethierarchy" || lower == "dictisin" || strings.HasPrefix(lower, "dictgetchildren") || strings.HasPrefix(lower, "dictgetdescendants") {
+				renameStringArg(k+2, true)
+				continue
+			}
+			pos, ok := databaseArgFuncs[lower]
+			if !ok {
+				continue
+			}
+			if a := argStart(toks, sig, k+1, pos); a >= 0 {
+				switch {
+				case tok(a).Kind == SQLString:
+					renameStringArg(a, tableArgFuncs[lower])
+				case tok(a+1).Text == "." || tok(a+1).Text == "," || tok(a+1).Text == ")":
+					renameIdent(a)
+				}
+			}
+		default:
+			// 三段式 db.table.column
+			if _, ok := t.Ident(); ok && tok(k+1).Text == "." && tok(k+3).Text == "." && tok(k-1).Text != "." {
+				if _, ok := tok(k + 2).Ident(); ok {
+					if _, ok := tok(k + 4).Ident(); ok {
+						renameIdent(k)
+					}
+				}
+			}
+		}
+	}
+	var b strings.Builder
+	for _, t := range toks {
+		b.WriteString(t.Text)
+	}
+	return b.String()
+}
+
+// argStart 返回从左括号 sig[open] 起第 pos 个顶层参数首个有效词元的位置；参数不存在时返回 -1。
+func argStart(toks []SQLToken, sig []int, open int, pos int) int {
+	depth := 0
+	n := 0
+	for k := open; k < len(sig); k++ {
+		switch toks[sig[k]].Text {
+		case "(", "[":
+			depth++
+			if depth == 1 && k == open && pos == 0 {
+				if k+1 < len(sig) {
+					return k + 1
+				}
+				return -1
+			}
+		case ")", "]":
+			depth--
+			if depth == 0 {
+				return -1
+			}
+		case ",":
+			if depth == 1 {
+				n++
+				if n == pos && k+1 < len(sig) {
+					return k + 1
+				}
+			}
+		}
+	}
+	return -1
+}
+
+// containsKeyword 判断当前语句中第 k 个有效词元之前是否出现过关键字 kw。
+func containsKeyword(toks []SQLToken, sig []int, k int, kw string) bool {
+	for j := k - 1; j >= 0; j-- {
+		t := toks[sig[j]]
+		if t.Text == ";" {
+			return false
+		}
+		if t.Keyword(kw) {
+			return true
+		}
+	}
+	return false
+}
+
+// requoteIdent 以原词元的引号风格写出新名称；裸标识符在新名称不是合法裸标识符时改用反引号。
+func requoteIdent(orig SQLToken, name string) string {
+	if orig.Kind == SQLQuoted {
+		q := string(orig.Text[0])
+		return q + strings.ReplaceAll(strings.ReplaceAll(name, `\`, `\\`), q, `\`+q) + q
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if !isIdentByte(c) || (i == 0 && c >= '0' && c <= '9') {
+			return quoteIdent(name)
+		}
+	}
+	return name
+}
+
+func quoteSQLString(v string) string {
+	return "'" + strings.ReplaceAll(strings.ReplaceAll(v, `\`, `\\`), "'", `\'`) + "'"
+}
@@ -0,0 +1,97 @@
+package clickhouse
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTokenizeSQLLossless(t *testing.T) {
+	src := "CREATE TABLE `a``b`.\"c\" (s String DEFAULT 'x\\'y', /* c */ n UInt8) -- tail\nENGINE = MergeTree ORDER BY 1.5e-3"
+	var b strings.Builder
+	for _, tk := range TokenizeSQL(src) {
+		b.WriteString(tk.Text)
+	}
+	if b.String() != src {
+		t.Fatalf("切分后无法还原原文:\n got: %s\nwant: %s", b.String(), src)
+	}
+}
+
+func TestRenameDatabases(t *testing.T) {
+	mapping := map[string]string{"demo": "mv", "dim": "dim_prod", "ops": "ops-2"}
+	cases := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			"create_table_backticks",
+			"CREATE TABLE `demo`.`orders` (`s` String DEFAULT 'demo.orders') ENGINE = MergeTree ORDER BY tuple()",
+			"CREATE TABLE `mv`.`orders` (`s` String DEFAULT 'demo.orders') ENGINE = MergeTree ORDER BY tuple()",
+		},
+		{
+			"materialized_view_to_and_join",
+			"CREATE MATERIALIZED VIEW demo.mv_from_kafka_orders TO demo.orders (`id` UInt64) AS SELECT o.id FROM demo.kafka_orders_sink AS o LEFT JOIN dim.users AS u ON o.uid = u.id WHERE demo.kafka_orders_sink.id > 0",
+			"CREATE MATERIALIZED VIEW mv.mv_from_kafka_orders TO mv.orders (`id` UInt64) AS SELECT o.id FROM mv.kafka_orders_sink AS o LEFT JOIN dim_prod.users AS u ON o.uid = u.id WHERE mv.kafka_orders_sink.id > 0",
+		},
+		{
+			"dictionary_source_and_dictget",
+			"CREATE DICTIONARY demo.users_dict (id UInt64, name String) PRIMARY KEY id SOURCE(CLICKHOUSE(HOST 'localhost' DB 'dim' TABLE 'users')) LAYOUT(FLAT()) LIFETIME(60)",
+			"CREATE DICTIONARY mv.users_dict (id UInt64, name String) PRIMARY KEY id SOURCE(CLICKHOUSE(HOST 'localhost' DB 'dim_prod' TABLE 'users')) LAYOUT(FLAT()) LIFETIME(60)",
+		},
+		{
+			"view_dictget_and_literals",
+			"CREATE VIEW demo.v AS SELECT dictGet('demo.users_dict', 'name', uid) AS n, 'FROM demo.x' AS s FROM demo.orders",
+			"CREATE VIEW mv.v AS SELECT dictGet('mv.users_dict', 'name', uid) AS n, 'FROM demo.x' AS s FROM mv.orders",
+		},
+		{
+			"distributed_and_remote",
+			"CREATE TABLE demo.orders_all AS demo.orders ENGINE = Distributed('c1', 'demo', 'orders', rand())",
+			"CREATE TABLE mv.orders_all AS mv.orders ENGINE = Distributed('c1', 'mv', 'orders', rand())",
+		},
+		{
+			"remote_table_function",
+			"CREATE VIEW demo.r AS SELECT * FROM remote('h:9000', dim.users) UNION ALL SELECT * FROM remote('h:9000', 'dim', 'users') UNION ALL SELECT * FROM merge('dim', '^u')",
+			"CREATE VIEW mv.r AS SELECT * FROM remote('h:9000', dim_prod.users) UNION ALL SELECT * FROM remote('h:9000', 'dim_prod', 'users') UNION ALL SELECT * FROM merge('dim_prod', '^u')",
+		},
+		{
+			"quote_required",
+			"CREATE TABLE ops.t (x UInt8) ENGINE = Memory",
+			"CREATE TABLE `ops-2`.t (x UInt8) ENGINE = Memory",
+		},
+		{
+			"grants_and_policies",
+			"GRANT SELECT ON demo.* TO analyst; CREATE ROW POLICY p ON demo.orders FOR SELECT USING 1 TO analyst",
+			"GRANT SELECT ON mv.* TO analyst; CREATE ROW POLICY p ON mv.orders FOR SELECT USING 1 TO analyst",
+		},
+		{
+			"create_database_and_comments",
+			"CREATE DATABASE IF NOT EXISTS demo -- demo.orders\n",
+			"CREATE DATABASE IF NOT EXISTS mv -- demo.orders\n",
+		},
+		{
+			"unmapped_and_columns",
+			"CREATE VIEW other.v AS SELECT demo.id FROM other.t AS demo",
+			"CREATE VIEW other.v AS SELECT demo.id FROM other.t AS demo",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := RenameDatabases(c.in, mapping); got != c.want {
+				t.Errorf("\n got: %s\nwant: %s", got, c.want)
+			}
+		})
+	}
+}
+
+func TestParseDatabaseMap(t *testing.T) {
+	m, err := ParseDatabaseMap("a=b, c = d,")
+	if err != nil || len(m) != 2 || m["a"] != "b" || m["c"] != "d" {
+		t.Fatalf("ParseDatabaseMap = %v, %v", m, err)
+	}
+	if _, err := ParseDatabaseMap("a"); err == nil {
+		t.Fatal("缺少 = 时应报错")
+	}
+	if _, err := ParseDatabaseMap("a=b,a=c"); err == nil {
+		t.Fatal("同一源库映射到多个目标库时应报错")
+	}
+}
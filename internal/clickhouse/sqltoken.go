@@ -0,0 +1,245 @@
+// clickhouse 包中的 SQL 词法切分：无损切分 DDL（各词元文本拼接后与原文一致），供依赖分析与库名改写使用。
+package clickhouse
+
+import "strings"
+
+// SQL 词元类型。
+const (
+	SQLSpace   = 's' // 空白
+	SQLComment = 'c' // -- 行注释或 /* */ 块注释
+	SQLIdent   = 'i' // 裸标识符或关键字
+	SQLQuoted  = 'q' // 反引号或双引号标识符
+	SQLString  = 'l' // 单引号字符串
+	SQLNumber  = 'n' // 数字
+	SQLPunct   = 'p' // 标点与运算符（单字符）
+)
+
+// SQLToken 是 SQL 文本中的一个词元，Text 为原文（含引号与转义）。
+type SQLToken struct {
+	Kind byte
+	Text string
+}
+
+// Ident 返回标识符词元的名称（去掉引号与转义）；非标识符返回 false。
+func (t SQLToken) Ident() (string, bool) {
+	switch t.Kind {
+	case SQLIdent:
+		return t.Text, true
+	case SQLQuoted:
+		return unquoteSQL(t.Text), true
+	}
+	return "", false
+}
+
+// Keyword 判断词元是否为指定关键字（裸标识符，忽略大小写）。
+func (t SQLToken) Keyword(kw string) bool {
+	return t.Kind == SQLIdent && strings.EqualFold(t.Text, kw)
+}
+
+// StringValue 返回字符串词元的值（去掉引号与转义）。
+func (t SQLToken) StringValue() (string, bool) {
+	if t.Kind != SQLString {
+		return "", false
+	}
+	return unquoteSQL(t.Text), true
+}
+
+// TokenizeSQL 把 SQL 切分为词元；未闭合的引号或注释延续到文本末尾。
+func TokenizeSQL(s string) []SQLToken {
+	var out []SQLToken
+	for i := 0; i < len(s); {
+		c := s[i]
+		j := i + 1
+		kind := byte(SQLPunct)
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			for j < len(s) && (s[j] == ' ' || s[j] == '\t' || s[j] == '\n' || s[j] == '\r') {
+				j++
+			}
+			kind = SQLSpace
+		case c == '-' && j < len(s) && s[j] == '-':
+			for j < len(s) && s[j] != '\n' {
+				j++
+			}
+			kind = SQLComment
+		case c == '/' && j < len(s) && s[j] == '*':
+			j++
+			for j < len(s) && !(s[j] == '*' && j+1 < len(s) && s[j+1] == '/') {
+				j++
+			}
+			j = minInt(j+2, len(s))
+			kind = SQLComment
+		case c == '\'' || c == '`' || c == '"':
+			for j < len(s) {
+				if s[j] == '\\' && j+1 < len(s) {
+					j += 2
+					continue
+				}
+				if s[j] == c {
+					if j+1 < len(s) && s[j+1] == c {
+						j += 2
+						continue
+					}
+					j++
+					break
+				}
+				j++
+			}
+			kind = SQLQuoted
+			if c == '\'' {
+				kind = SQLString
+			}
+		case c >= '0' && c <= '9':
+			for j < len(s) && (s[j] == '.' || s[j] == '_' || isIdentByte(s[j]) ||
+				((s[j] == '-' || s[j] == '+') && (s[j-1] == 'e' || s[j-1] == 'E'))) {
+				j++
+			}
+			kind = SQLNumber
+		case c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80:
+			for j < len(s) && (isIdentByte(s[j]) || s[j] == '$' || s[j] >= 0x80) {
+				j++
+			}
+			kind = SQLIdent
+		}
+		out = append(out, SQLToken{Kind: kind, Text: s[i:j]})
+		i = j
+	}
+	return out
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// unquoteSQL 去掉引号并还原 \x 与双写引号转义。
+func unquoteSQL(s string) string {
+	if len(s) < 2 {
+		return s
+	}
+	q := s[0]
+	body := s[1:]
+	if body[len(body)-1] == q {
+		body = body[:len(body)-1]
+	}
+	var b strings.Builder
+	for i := 0; i < len(body); i++ {
+		if body[i] == '\\' && i+1 < len(body) {
+			i++
+			b.WriteByte(body[i])
+			continue
+		}
+		if body[i] == q && i+1 < len(body) && body[i+1] == q {
+			i++
+		}
+		b.WriteByte(body[i])
+	}
+	return b.String()
+}
+
+// significantTokens 返回去掉空白与注释后的词元。
+func significantTokens(toks []SQLToken) []SQLToken {
+	var out []SQLToken
+	for _, t := range toks {
+		if t.Kind != SQLSpace && t.Kind != SQLComment {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// TableRef 是 DDL 中引用的库表；Database 为空表示未限定库名。
+type TableRef struct {
+	Database string
+	Table    string
+}
+
+// ReferencedTables 返回视图、物化视图与字典 DDL 中引用的库表：FROM/JOIN 的表、物化视图的 TO 目标、
+// 字典 SOURCE(CLICKHOUSE(DB '..' TABLE '..')) 与 dictGet 系列函数的字典名。未限定库名的引用使用 defaultDB。
+func ReferencedTables(query string, defaultDB string) []TableRef {
+	toks := significantTokens(TokenizeSQL(query))
+	var out []TableRef
+	seen := map[TableRef]bool{}
+	add := func(r TableRef) {
+		if r.Database == "" {
+			r.Database = defaultDB
+		}
+		if r.Table == "" || seen[r] {
+			return
+		}
+		seen[r] = true
+		out = append(out, r)
+	}
+	for i := 0; i < len(toks); i++ {
+		t := toks[i]
+		switch {
+		case t.Keyword("FROM") || t.Keyword("JOIN") || t.Keyword("TO"):
+			// TTL ... TO DISK/VOLUME '...' 不是表引用
+			if t.Keyword("TO") && i+2 < len(toks) && (toks[i+1].Keyword("DISK") || toks[i+1].Keyword("VOLUME")) && toks[i+2].Kind == SQLString {
+				continue
+			}
+			if r, n, ok := qualifiedNameAt(toks, i+1); ok {
+				// FROM/JOIN 后名称紧跟括号的是表函数；TO 目标后的括号是物化视图的列定义
+				if !t.Keyword("TO") && i+1+n < len(toks) && toks[i+1+n].Text == "(" {
+					continue
+				}
+				add(r)
+			}
+		case t.Keyword("SOURCE") && i+3 < len(toks) && toks[i+1].Text == "(" && toks[i+2].Keyword("CLICKHOUSE") && toks[i+3].Text == "(":
+			var r TableRef
+			depth := 0
+			for j := i + 3; j < len(toks); j++ {
+				if toks[j].Text == "(" {
+					depth++
+				} else if toks[j].Text == ")" {
+					depth--
+					if depth == 0 {
+						break
+					}
+				}
+				if j+1 < len(toks) {
+					if v, ok := toks[j+1].StringValue(); ok {
+						if toks[j].Keyword("DB") {
+							r.Database = v
+						} else if toks[j].Keyword("TABLE") {
+							r.Table = v
+						}
+					}
+				}
+			}
+			add(r)
+		case t.Kind == SQLIdent && strings.HasPrefix(strings.ToLower(t.Text), "dict") && i+2 < len(toks) && toks[i+1].Text == "(":
+			if v, ok := toks[i+2].StringValue(); ok {
+				if k := strings.Index(v, "."); k >= 0 {
+					add(TableRef{Database: v[:k], Table: v[k+1:]})
+				} else {
+					add(TableRef{Table: v})
+				}
+			}
+		}
+	}
+	return out
+}
+
+// qualifiedNameAt 读取 toks[i] 开始的 [db.]name，返回引用与占用的词元数。
+func qualifiedNameAt(toks []SQLToken, i int) (TableRef, int, bool) {
+	if i >= len(toks) {
+		return TableRef{}, 0, false
+	}
+	first, ok := toks[i].Ident()
+	if !ok {
+		return TableRef{}, 0, false
+	}
+	if i+2 < len(toks) && toks[i+1].Text == "." {
+		if second, ok := toks[i+2].Ident(); ok {
+			return TableRef{Database: first, Table: second}, 3, true
+		}
+	}
+	return TableRef{Table: first}, 1, true
+}
@@ -0,0 +1,223 @@
+// clickhouse 包中的 CAST 表达式生成：把 String 中间层的值按目标列类型逐层转换，复合类型递归到元素级别；
+// 支持严格（失败即报错）与宽松（...OrNull/...OrDefault）两种转换方式。
+package clickhouse
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// sync-cast 的转换模式。
+const (
+	CastModeStrict     = "strict"
+	CastModeLenient    = "lenient"
+	CastModeQuarantine = "quarantine"
+)
+
+// NormalizeCastMode 校验并规范化转换模式，空值视为 strict。
+func NormalizeCastMode(mode string) (string, error) {
+	switch m := strings.ToLower(strings.TrimSpace(mode)); m {
+	case "", CastModeStrict:
+		return CastModeStrict, nil
+	case CastModeLenient, CastModeQuarantine:
+		return m, nil
+	}
+	return "", fmt.Errorf("不支持的 cast_mode: %s（可选 strict|lenient|quarantine）", mode)
+}
+
+// castFlavor 决定单个值转换失败时的行为：报错、取默认值或取 NULL。
+type castFlavor int
+
+const (
+	castStrict castFlavor = iota
+	castOrDefault
+	castOrNull
+)
+
+// BuildStrictCastExpr 生成把 String 中间层列转换为目标类型的表达式。
+// 日期类使用 best-effort 解析；Array/Map/Tuple/Nested 在中间层中保存为 JSON 文本，按元素递归解析后再整体 CAST；
+// Enum 同时接受名称与数值（严格与宽松模式一致）。
+func BuildStrictCastExpr(sourceExpr string, targetType string) string {
+	return buildCastExpr(sourceExpr, targetType, castStrict)
+}
+
+// BuildLenientCastExpr 与 BuildStrictCastExpr 结构相同，但无法转换的值不报错：Nullable 列取 NULL，其余取类型默认值。
+func BuildLenientCastExpr(sourceExpr string, targetType string) string {
+	return buildCastExpr(sourceExpr, targetType, castOrDefault)
+}
+
+func buildCastExpr(sourceExpr string, targetType string, flavor castFlavor) string {
+	t := strings.TrimSpace(targetType)
+	if t == "" {
+		return sourceExpr
+	}
+	n, err := ParseType(t)
+	if err != nil {
+		return scalarCastExpr(sourceExpr, t, flavor)
+	}
+	return castNode(sourceExpr, n, flavor)
+}
+
+// scalarCastExpr 按 flavor 选择 CAST、accurateCastOrDefault 或 accurateCastOrNull。
+func scalarCastExpr(x string, t string, flavor castFlavor) string {
+	switch flavor {
+	case castOrDefault:
+		return fmt.Sprintf("accurateCastOrDefault(%s, %s)", x, quoteString(t))
+	case castOrNull:
+		return fmt.Sprintf("accurateCastOrNull(%s, %s)", x, quoteString(t))
+	}
+	return fmt.Sprintf("CAST(%s, %s)", x, quoteString(t))
+}
+
+func castNode(sourceExpr string, n *TypeNode, flavor castFlavor) string {
+	t := n.Raw()
+	switch f := n.Family(); {
+	case f == "nullable" && len(n.Args) == 1:
+		if flavor != castStrict {
+			flavor = castOrNull
+		}
+		return fmt.Sprintf("CAST(%s, %s)", castNode(sourceExpr, n.Args[0], flavor), quoteString(t))
+	case f == "lowcardinality" && len(n.Args) == 1:
+		return fmt.Sprintf("CAST(%s, %s)", castNode(sourceExpr, n.Args[0], flavor), quoteString(t))
+	case isDateFamily(f):
+		return castDateExpr(sourceExpr, n, flavor)
+	case f == "string":
+		return sourceExpr
+	case f == "array" || f == "map" || f == "tuple" || f == "nested" || f == "json" || f == "object":
+		return castRawExpr(sourceExpr, n, 1, flavor)
+	case isEnumFamily(f):
+		return castEnumExpr(sourceExpr, n, flavor)
+	}
+	return scalarCastExpr(sourceExpr, t, flavor)
+}
+
+// castDateExpr 解析日期文本；DateTime64 保留精度，带时区的类型按该时区解析。
+// 宽松模式使用 ...OrZero（取默认值）或 ...OrNull 解析函数。
+func castDateExpr(sourceExpr string, n *TypeNode, flavor castFlavor) string {
+	t := n.Raw()
+	args := []string{sourceExpr}
+	fn := "parseDateTimeBestEffort"
+	switch n.Family() {
+	case "datetime64":
+		fn = "parseDateTime64BestEffort"
+		for _, a := range n.Args {
+			args = append(args, a.Raw())
+		}
+	case "date32":
+		// Date32 超出 DateTime 的取值范围，使用 DateTime64 解析
+		fn = "parseDateTime64BestEffort"
+	case "datetime":
+		if len(n.Args) > 0 {
+			args = append(args, n.Args[0].Raw())
+		}
+	}
+	switch flavor {
+	case castOrDefault:
+		fn += "OrZero"
+	case castOrNull:
+		fn += "OrNull"
+		t = "Nullable(" + t + ")"
+	}
+	return fmt.Sprintf("CAST(%s(%s), %s)", fn, strings.Join(args, ", "), quoteString(t))
+}
+
+// castEnumExpr 先把数值文本映射为对应的枚举名称（已是名称的值保持不变），再按 flavor 转换为枚举类型，
+// 严格模式下未知名称或数值报错，宽松模式取 NULL 或默认值。
+func castEnumExpr(sourceExpr string, n *TypeNode, flavor castFlavor) string {
+	var names, values []string
+	for _, v := range n.EnumValues() {
+		names = append(names, quoteString(v.Name))
+		values = append(values, quoteString(strconv.FormatInt(v.Value, 10)))
+	}
+	if len(names) == 0 {
+		return scalarCastExpr(sourceExpr, n.Raw(), flavor)
+	}
+	named := fmt.Sprintf("if(has([%s], %s), %s, transform(%s, [%s], [%s], %s))",
+		strings.Join(names, ", "), sourceExpr, sourceExpr, sourceExpr, strings.Join(values, ", "), strings.Join(names, ", "), sourceExpr)
+	return scalarCastExpr(named, n.Raw(), flavor)
+}
+
+// castRawExpr 把 JSON 文本片段 x 转换为类型 n；depth 用于生成不冲突的 lambda 参数名。
+func castRawExpr(x string, n *TypeNode, depth int, flavor castFlavor) string {
+	t := n.Raw()
+	inner := flavor
+	if inner == castOrNull {
+		// 复合类型的元素与键不会为 NULL，除非元素自身为 Nullable
+		inner = castOrDefault
+	}
+	switch f := n.Family(); {
+	case f == "nullable" && len(n.Args) == 1:
+		if flavor != castStrict {
+			flavor = castOrNull
+		}
+		return fmt.Sprintf("CAST(%s, %s)", castRawExpr("nullIf("+x+", 'null')", n.Args[0], depth, flavor), quoteString(t))
+	case f == "lowcardinality" && len(n.Args) == 1:
+		return fmt.Sprintf("CAST(%s, %s)", castRawExpr(x, n.Args[0], depth, flavor), quoteString(t))
+	case isDateFamily(f):
+		return castDateExpr("JSONExtractString("+x+")", n, flavor)
+	case f == "array" && len(n.Args) == 1:
+		v := fmt.Sprintf("x%d", depth)
+		return fmt.Sprintf("arrayMap(%s -> %s, JSONExtractArrayRaw(%s))", v, castRawExpr(v, n.Args[0], depth+1, inner), x)
+	case f == "nested" && len(n.Args) > 0:
+		// Nested 在 JSON 中表现为对象数组，等价于 Array(Tuple(...))
+		var elems []string
+		for _, a := range n.Args {
+			elems = append(elems, a.argText())
+		}
+		if arr, err := ParseType("Array(Tuple(" + strings.Join(elems, ", ") + "))"); err == nil {
+			return castRawExpr(x, arr, depth, inner)
+		}
+	case f == "map" && len(n.Args) == 2:
+		v := fmt.Sprintf("kv%d", depth)
+		return fmt.Sprintf("CAST(arrayMap(%s -> (%s, %s), JSONExtractKeysAndValuesRaw(%s)), %s)",
+			v, castNode(v+".1", n.Args[0], inner), castRawExpr(v+".2", n.Args[1], depth+1, inner), x, quoteString(t))
+	case f == "tuple" && len(n.Args) > 0:
+		elems := make([]string, len(n.Args))
+		for i, a := range n.Args {
+			key := strconv.Itoa(i + 1)
+			if a.Field != "" {
+				key = quoteString(a.Field)
+			}
+			elems[i] = castRawExpr(fmt.Sprintf("JSONExtractRaw(%s, %s)", x, key), a, depth, inner)
+		}
+		return fmt.Sprintf("CAST(tuple(%s), %s)", strings.Join(elems, ", "), quoteString(t))
+	case f == "string":
+		return "JSONExtractString(" + x + ")"
+	case f == "fixedstring":
+		return scalarCastExpr("JSONExtractString("+x+")", t, flavor)
+	case f == "json" || f == "object":
+		if flavor != castStrict {
+			x = "if(isValidJSON(" + x + "), " + x + ", '{}')"
+		}
+		return fmt.Sprintf("CAST(%s, %s)", x, quoteString(t))
+	case isEnumFamily(f):
+		return castEnumExpr("trim(BOTH '\"' FROM "+x+")", n, flavor)
+	}
+	// 数值、UUID、Bool 等标量：去掉 JSON 字符串引号（如被引号包裹的 64 位整数）后 CAST
+	return scalarCastExpr("trim(BOTH '\"' FROM "+x+")", t, flavor)
+}
+
+// FlattenNestedColumns 把 Nested(a T, b U) 列 n 展开为 n.a Array(T)、n.b Array(U)，与 ClickHouse 默认的 flatten_nested 行为一致。
+func FlattenNestedColumns(cols []Column) []Column {
+	var out []Column
+	for _, c := range cols {
+		n, err := ParseType(c.Type)
+		var flat []Column
+		if err == nil && n.Family() == "nested" {
+			for _, a := range n.Args {
+				if a.Field == "" {
+					flat = nil
+					break
+				}
+				flat = append(flat, Column{Name: c.Name + "." + a.Field, Type: "Array(" + a.Raw() + ")", Position: c.Position})
+			}
+		}
+		if len(flat) == 0 {
+			out = append(out, c)
+			continue
+		}
+		out = append(out, flat...)
+	}
+	return out
+}
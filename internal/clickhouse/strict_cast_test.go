@@ -0,0 +1,114 @@
+package clickhouse
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "重新生成 testdata 下的 golden 文件")
+
+// TestBuildStrictCastExprGolden 按类型逐一比对 BuildStrictCastExpr 的输出；修改生成逻辑后用 go test -update 刷新。
+func TestBuildStrictCastExprGolden(t *testing.T) {
+	cases := []struct {
+		name string
+		typ  string
+	}{
+		{"string", "String"},
+		{"int64", "Int64"},
+		{"nullable_datetime", "Nullable(DateTime)"},
+		{"datetime_tz", "DateTime('Asia/Shanghai')"},
+		{"datetime64", "DateTime64(3, 'UTC')"},
+		{"date32", "Date32"},
+		{"array_int", "Array(Int32)"},
+		{"array_datetime", "Array(DateTime)"},
+		{"array_nullable_string", "Array(Nullable(String))"},
+		{"array_array_date", "Array(Array(Date))"},
+		{"map_string_uint64", "Map(String, UInt64)"},
+		{"map_date_array", "Map(Date, Array(DateTime64(3)))"},
+		{"tuple_unnamed", "Tuple(String, Int8)"},
+		{"tuple_named", "Tuple(id UInt64, created_at DateTime)"},
+		{"nested", "Nested(k String, v Decimal(18, 4))"},
+		{"enum8", "Enum8('a' = 1, 'b\\'c' = -2)"},
+		{"lowcardinality_enum", "LowCardinality(Enum16('x' = 100))"},
+		{"array_enum", "Array(Enum8('on' = 1, 'off' = 0))"},
+		{"json", "JSON"},
+		{"array_json", "Array(JSON)"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			checkGolden(t, c.name, c.typ+"\n"+BuildStrictCastExpr("`v`", c.typ)+"\n")
+		})
+	}
+}
+
+// TestBuildLenientCastExprGolden 覆盖宽松模式下标量、日期、可空与复合类型的 ...OrNull/...OrDefault 表达式及隔离条件（复合类型逐元素判断）。
+func TestBuildLenientCastExprGolden(t *testing.T) {
+	cases := []struct {
+		name string
+		typ  string
+	}{
+		{"lenient_int32", "Int32"},
+		{"lenient_nullable_int64", "Nullable(Int64)"},
+		{"lenient_datetime64", "DateTime64(3, 'UTC')"},
+		{"lenient_nullable_date", "Nullable(Date)"},
+		{"lenient_array_nullable_int", "Array(Nullable(Int8))"},
+		{"lenient_map_datetime", "Map(String, DateTime)"},
+		{"lenient_enum8", "Enum8('a' = 1, 'b' = 2)"},
+		{"lenient_json", "JSON"},
+		{"lenient_string", "LowCardinality(Nullable(String))"},
+		{"lenient_array_int", "Array(Int32)"},
+		{"lenient_map_uint64_enum", "Map(UInt64, Enum8('a' = 1))"},
+		{"lenient_tuple_named", "Tuple(id UInt64, tags Array(String))"},
+		{"lenient_nested", "Nested(k String, v Nullable(Decimal(18, 4)))"},
+		{"lenient_array_json", "Array(JSON)"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := c.typ + "\n" + BuildLenientCastExpr("`v`", c.typ) + "\n" + CastFailureCondition("`v`", c.typ) + "\n"
+			checkGolden(t, c.name, got)
+		})
+	}
+}
+
+func checkGolden(t *testing.T, name string, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", "strict_cast", name+".golden")
+	if *updateGolden {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("读取 golden 文件失败（可用 -update 生成）: %v", err)
+	}
+	if got != string(want) {
+		t.Errorf("%s 输出不一致\n got: %s\nwant: %s", name, strings.TrimSpace(got), strings.TrimSpace(string(want)))
+	}
+}
+
+func TestFlattenNestedColumns(t *testing.T) {
+	got := FlattenNestedColumns([]Column{
+		{Name: "id", Type: "UInt64"},
+		{Name: "attrs", Type: "Nested(k String, `v` Nullable(Int32))"},
+	})
+	want := []Column{
+		{Name: "id", Type: "UInt64"},
+		{Name: "attrs.k", Type: "Array(String)"},
+		{Name: "attrs.v", Type: "Array(Nullable(Int32))"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d columns, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i].Name != want[i].Name || got[i].Type != want[i].Type {
+			t.Errorf("column %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,375 @@
+// clickhouse 包中的类型解析：把 system.columns 报告的类型字符串解析为语法树，覆盖参数、嵌套、命名 Tuple、Enum 取值与 DateTime64(p, 'tz')。
+package clickhouse
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// TypeNode 是类型语法树中的一个节点。Name 非空时为类型（如 Array、DateTime64），否则为字面量参数：
+// Literal 为数字、标识符或字符串内容（已去引号与转义），Value 为 "'a' = 1"、"k = v" 形式中等号右侧的原文。
+type TypeNode struct {
+	Name    string
+	Field   string
+	Literal string
+	Quoted  bool
+	Value   string
+	Args    []*TypeNode
+	raw     string
+	// fieldRaw 为字段名在原始字符串中的文本；pathField 表示字段是 JSON 路径提示（如 JSON(a.b UInt32)），按原文输出
+	fieldRaw  string
+	pathField bool
+}
+
+// EnumValue 是 Enum 类型中的一项。
+type EnumValue struct {
+	Name  string
+	Value int64
+}
+
+// ParseType 解析 ClickHouse 类型字符串。
+func ParseType(t string) (*TypeNode, error) {
+	toks, err := lexType(t)
+	if err != nil {
+		return nil, err
+	}
+	p := &typeParser{src: t, toks: toks}
+	n, err := p.parseType()
+	if err != nil {
+		return nil, err
+	}
+	if p.i < len(p.toks) {
+		return nil, fmt.Errorf("类型 %q 在位置 %d 处有多余内容", t, p.toks[p.i].pos)
+	}
+	return n, nil
+}
+
+// Family 返回小写的类型名。
+func (n *TypeNode) Family() string {
+	return strings.ToLower(n.Name)
+}
+
+// Raw 返回节点在原始字符串中的文本（不含命名元素的字段名）。
+func (n *TypeNode) Raw() string {
+	return n.raw
+}
+
+// Unwrap 去掉外层的 Nullable 与 LowCardinality 包装。
+func (n *TypeNode) Unwrap() *TypeNode {
+	for (n.Family() == "nullable" || n.Family() == "lowcardinality") && len(n.Args) == 1 && n.Args[0].Name != "" {
+		n = n.Args[0]
+	}
+	return n
+}
+
+// IsNullable 判断类型是否可为 NULL（含 LowCardinality(Nullable(T))）。
+func (n *TypeNode) IsNullable() bool {
+	for len(n.Args) == 1 {
+		switch n.Family() {
+		case "nullable":
+			return true
+		case "lowcardinality":
+			n = n.Args[0]
+			continue
+		}
+		break
+	}
+	return false
+}
+
+// IsDateLike 判断去掉包装后是否为 Date/Date32/DateTime/DateTime64。
+func (n *TypeNode) IsDateLike() bool {
+	return isDateFamily(n.Unwrap().Family())
+}
+
+// EnumValues 返回 Enum 类型的取值；未显式写出取值时按 ClickHouse 规则从 1 起自动编号。
+func (n *TypeNode) EnumValues() []EnumValue {
+	if !isEnumFamily(n.Family()) {
+		return nil
+	}
+	var out []EnumValue
+	next := int64(1)
+	for _, a := range n.Args {
+		if a.Name != "" || !a.Quoted {
+			continue
+		}
+		v := next
+		if a.Value != "" {
+			if parsed, err := strconv.ParseInt(a.Value, 10, 64); err == nil {
+				v = parsed
+			}
+		}
+		out = append(out, EnumValue{Name: a.Literal, Value: v})
+		next = v + 1
+	}
+	return out
+}
+
+// String 返回规范格式的类型字符串。
+func (n *TypeNode) String() string {
+	var b strings.Builder
+	n.format(&b, false)
+	return b.String()
+}
+
+// normalized 返回用于比较的形式：类型名小写、去掉非字面量处的空白。
+func (n *TypeNode) normalized() string {
+	var b strings.Builder
+	n.format(&b, true)
+	return b.String()
+}
+
+func (n *TypeNode) format(b *strings.Builder, compact bool) {
+	sep, eq := ", ", " = "
+	if compact {
+		sep, eq = ",", "="
+	}
+	if n.Field != "" {
+		b.WriteString(n.fieldText() + " ")
+	}
+	if n.Name == "" {
+		if n.Quoted {
+			b.WriteString(quoteString(n.Literal))
+		} else {
+			b.WriteString(n.Literal)
+		}
+		if n.Value != "" {
+			b.WriteString(eq + n.Value)
+		}
+		return
+	}
+	if compact {
+		b.WriteString(n.Family())
+	} else {
+		b.WriteString(n.Name)
+	}
+	if n.Args == nil {
+		return
+	}
+	b.WriteString("(")
+	for i, a := range n.Args {
+		if i > 0 {
+			b.WriteString(sep)
+		}
+		a.format(b, compact)
+	}
+	b.WriteString(")")
+}
+
+// argText 返回参数在原始字符串中的文本，命名元素包含字段名。
+func (n *TypeNode) argText() string {
+	if n.Field != "" {
+		return n.fieldText() + " " + n.raw
+	}
+	return n.raw
+}
+
+// fieldText 返回字段名的输出形式：JSON 路径提示保留原文（a.b 与 `a.b` 含义不同），其余按需加反引号。
+func (n *TypeNode) fieldText() string {
+	if n.pathField && n.fieldRaw != "" {
+		return n.fieldRaw
+	}
+	return quoteFieldName(n.Field)
+}
+
+func quoteFieldName(s string) string {
+	for i, c := range s {
+		if !(c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (i > 0 && c >= '0' && c <= '9')) {
+			return quoteIdent(s)
+		}
+	}
+	return s
+}
+
+type typeToken struct {
+	kind byte // i 标识符、q 反引号标识符、n 数字、s 字符串，其余为标点本身
+	text string
+	pos  int
+	end  int
+}
+
+func lexType(s string) ([]typeToken, error) {
+	var out []typeToken
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(' || c == ')' || c == ',' || c == '=':
+			out = append(out, typeToken{kind: c, text: string(c), pos: i, end: i + 1})
+			i++
+		case c == '\'' || c == '`':
+			var b strings.Builder
+			j := i + 1
+			closed := false
+			for j < len(s) {
+				switch {
+				case s[j] == '\\' && j+1 < len(s):
+					b.WriteByte(s[j+1])
+					j += 2
+					continue
+				case s[j] == c && j+1 < len(s) && s[j+1] == c:
+					b.WriteByte(c)
+					j += 2
+					continue
+				case s[j] == c:
+					closed = true
+				}
+				if closed {
+					break
+				}
+				b.WriteByte(s[j])
+				j++
+			}
+			if !closed {
+				return nil, fmt.Errorf("类型 %q 中的引号未闭合", s)
+			}
+			kind := byte('s')
+			if c == '`' {
+				kind = 'q'
+			}
+			out = append(out, typeToken{kind: kind, text: b.String(), pos: i, end: j + 1})
+			i = j + 1
+		case c == '-' || c == '+' || (c >= '0' && c <= '9'):
+			j := i + 1
+			for j < len(s) && (s[j] == '.' || s[j] == 'e' || s[j] == 'E' || (s[j] >= '0' && s[j] <= '9') ||
+				((s[j] == '-' || s[j] == '+') && (s[j-1] == 'e' || s[j-1] == 'E'))) {
+				j++
+			}
+			out = append(out, typeToken{kind: 'n', text: s[i:j], pos: i, end: j})
+			i = j
+		case c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z'):
+			j := i + 1
+			for j < len(s) && (s[j] == '_' || s[j] == '.' || (s[j] >= 'a' && s[j] <= 'z') || (s[j] >= 'A' && s[j] <= 'Z') || (s[j] >= '0' && s[j] <= '9')) {
+				j++
+			}
+			out = append(out, typeToken{kind: 'i', text: s[i:j], pos: i, end: j})
+			i = j
+		default:
+			return nil, fmt.Errorf("类型 %q 在位置 %d 处有无法识别的字符 %q", s, i, c)
+		}
+	}
+	return out, nil
+}
+
+type typeParser struct {
+	src  string
+	toks []typeToken
+	i    int
+}
+
+func (p *typeParser) peek(k int) byte {
+	if p.i+k < len(p.toks) {
+		return p.toks[p.i+k].kind
+	}
+	return 0
+}
+
+func (p *typeParser) expect(kind byte) (typeToken, error) {
+	if p.peek(0) != kind {
+		if p.i < len(p.toks) {
+			return typeToken{}, fmt.Errorf("类型 %q 在位置 %d 处应为 %q", p.src, p.toks[p.i].pos, kind)
+		}
+		return typeToken{}, fmt.Errorf("类型 %q 意外结束", p.src)
+	}
+	p.i++
+	return p.toks[p.i-1], nil
+}
+
+func (p *typeParser) parseType() (*TypeNode, error) {
+	name, err := p.expect('i')
+	if err != nil {
+		return nil, err
+	}
+	n := &TypeNode{Name: name.text}
+	end := name.end
+	if p.peek(0) == '(' {
+		p.i++
+		n.Args = []*TypeNode{}
+		for p.peek(0) != ')' {
+			a, err := p.parseArg()
+			if err != nil {
+				return nil, err
+			}
+			if f := n.Family(); f == "json" || f == "object" {
+				a.pathField = true
+			}
+			n.Args = append(n.Args, a)
+			if p.peek(0) != ',' {
+				break
+			}
+			p.i++
+			if p.peek(0) == ')' {
+				return nil, fmt.Errorf("类型 %q 在位置 %d 处参数列表以逗号结尾", p.src, p.toks[p.i].pos)
+			}
+		}
+		closing, err := p.expect(')')
+		if err != nil {
+			return nil, err
+		}
+		end = closing.end
+	}
+	n.raw = p.src[name.pos:end]
+	return n, nil
+}
+
+func (p *typeParser) parseArg() (*TypeNode, error) {
+	switch p.peek(0) {
+	case 's', 'n':
+		tok := p.toks[p.i]
+		p.i++
+		n := &TypeNode{Literal: tok.text, Quoted: tok.kind == 's'}
+		end := tok.end
+		if p.peek(0) == '=' {
+			p.i++
+			if p.peek(0) != 'n' && p.peek(0) != 's' && p.peek(0) != 'i' {
+				return nil, fmt.Errorf("类型 %q 中 '=' 后缺少取值", p.src)
+			}
+			n.Value = p.src[p.toks[p.i].pos:p.toks[p.i].end]
+			end = p.toks[p.i].end
+			p.i++
+		}
+		n.raw = p.src[tok.pos:end]
+		return n, nil
+	case 'q':
+		field := p.toks[p.i]
+		p.i++
+		n, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		n.Field = field.text
+		n.fieldRaw = p.src[field.pos:field.end]
+		return n, nil
+	case 'i':
+		switch p.peek(1) {
+		case '=':
+			// 类型设置参数，如 JSON(max_dynamic_paths = 10)
+			key := p.toks[p.i]
+			p.i += 2
+			if p.peek(0) != 'n' && p.peek(0) != 's' && p.peek(0) != 'i' {
+				return nil, fmt.Errorf("类型 %q 中 '=' 后缺少取值", p.src)
+			}
+			val := p.toks[p.i]
+			p.i++
+			return &TypeNode{Literal: key.text, Value: p.src[val.pos:val.end], raw: p.src[key.pos:val.end]}, nil
+		case 'i':
+			// 命名元素：Tuple(a String)、Nested(k String)
+			field := p.toks[p.i]
+			p.i++
+			n, err := p.parseType()
+			if err != nil {
+				return nil, err
+			}
+			n.Field = field.text
+			n.fieldRaw = p.src[field.pos:field.end]
+			return n, nil
+		}
+		return p.parseType()
+	}
+	if p.i < len(p.toks) {
+		return nil, fmt.Errorf("类型 %q 在位置 %d 处应为类型参数", p.src, p.toks[p.i].pos)
+	}
+	return nil, fmt.Errorf("类型 %q 意外结束", p.src)
+}
@@ -0,0 +1,152 @@
+package clickhouse
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestParseTypeRoundTrip 覆盖 ParseType 解析后 String（规范格式）、normalized（比较形式）与 Raw（原文）的输出，
+// 并确认 String 的结果可再次解析且保持不变。
+func TestParseTypeRoundTrip(t *testing.T) {
+	cases := []struct {
+		in         string
+		str        string
+		normalized string
+	}{
+		{"UInt64", "UInt64", "uint64"},
+		{"LowCardinality(Nullable(String))", "LowCardinality(Nullable(String))", "lowcardinality(nullable(string))"},
+		{"Map(String,Array(Decimal(18,4)))", "Map(String, Array(Decimal(18, 4)))", "map(string,array(decimal(18,4)))"},
+		{"AggregateFunction(uniq, String)", "AggregateFunction(uniq, String)", "aggregatefunction(uniq,string)"},
+		{"AggregateFunction(quantiles(0.5, 0.9), UInt64)", "AggregateFunction(quantiles(0.5, 0.9), UInt64)", "aggregatefunction(quantiles(0.5,0.9),uint64)"},
+		{"SimpleAggregateFunction(sum, UInt64)", "SimpleAggregateFunction(sum, UInt64)", "simpleaggregatefunction(sum,uint64)"},
+		{"Tuple(String,Int8)", "Tuple(String, Int8)", "tuple(string,int8)"},
+		{"Tuple(a String, `b c` Nullable(Int32), `id` UInt8)", "Tuple(a String, `b c` Nullable(Int32), id UInt8)", "tuple(a string,`b c` nullable(int32),id uint8)"},
+		{"Enum8('a' = -1, 'b\\'c' = 2, 'x'=-128)", "Enum8('a' = -1, 'b\\'c' = 2, 'x' = -128)", "enum8('a'=-1,'b\\'c'=2,'x'=-128)"},
+		{"DateTime64(3,'Asia/Shanghai')", "DateTime64(3, 'Asia/Shanghai')", "datetime64(3,'Asia/Shanghai')"},
+		{"JSON(max_dynamic_paths=10, a.b UInt32, `a.b` String, SKIP x, SKIP a.c)", "JSON(max_dynamic_paths = 10, a.b UInt32, `a.b` String, SKIP x, SKIP a.c)", "json(max_dynamic_paths=10,a.b uint32,`a.b` string,SKIP x,SKIP a.c)"},
+		{"JSON(a.b UInt32)", "JSON(a.b UInt32)", "json(a.b uint32)"},
+		{"Variant(String, UInt64, Array(String))", "Variant(String, UInt64, Array(String))", "variant(string,uint64,array(string))"},
+		{"Nested(k String, v Array(Nullable(Int32)))", "Nested(k String, v Array(Nullable(Int32)))", "nested(k string,v array(nullable(int32)))"},
+		{"Object('json')", "Object('json')", "object('json')"},
+	}
+	for _, c := range cases {
+		n, err := ParseType(c.in)
+		if err != nil {
+			t.Errorf("ParseType(%q): %v", c.in, err)
+			continue
+		}
+		if n.Raw() != c.in {
+			t.Errorf("Raw(%q) = %q", c.in, n.Raw())
+		}
+		if got := n.String(); got != c.str {
+			t.Errorf("String(%q) = %q, want %q", c.in, got, c.str)
+		}
+		if got := n.normalized(); got != c.normalized {
+			t.Errorf("normalized(%q) = %q, want %q", c.in, got, c.normalized)
+		}
+		again, err := ParseType(n.String())
+		if err != nil {
+			t.Errorf("重新解析 %q: %v", n.String(), err)
+			continue
+		}
+		if again.String() != n.String() || again.normalized() != n.normalized() {
+			t.Errorf("往返后不一致: %q -> %q", n.String(), again.String())
+		}
+	}
+}
+
+func TestParseTypeDetails(t *testing.T) {
+	n, err := ParseType("Enum16('a' = -5, 'b', 'c' = 10)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []EnumValue{{"a", -5}, {"b", -4}, {"c", 10}}
+	got := n.EnumValues()
+	if len(got) != len(want) {
+		t.Fatalf("EnumValues = %+v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("EnumValues[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+	tup, err := ParseType("Tuple(`user id` UInt64, name LowCardinality(Nullable(String)))")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tup.Args[0].Field != "user id" || tup.Args[1].Field != "name" || !tup.Args[1].IsNullable() || tup.Args[1].Unwrap().Family() != "string" {
+		t.Errorf("命名 Tuple 字段解析错误: %+v %+v", tup.Args[0], tup.Args[1])
+	}
+	if got := tup.Args[0].argText(); got != "`user id` UInt64" {
+		t.Errorf("argText = %q", got)
+	}
+}
+
+func TestParseTypeErrors(t *testing.T) {
+	cases := map[string]string{
+		"":                     "意外结束",
+		"Array(":               "意外结束",
+		"Array(String":         "意外结束",
+		"Tuple(a String,)":     "逗号结尾",
+		"Enum8('a)":            "引号未闭合",
+		"Int32)":               "多余内容",
+		"Map(String, Int32) x": "多余内容",
+		"Enum8('a' = )":        "缺少取值",
+		"JSON(a =)":            "缺少取值",
+		"Decimal(18, #)":       "无法识别的字符",
+		"(String)":             "位置 0",
+	}
+	for in, want := range cases {
+		_, err := ParseType(in)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("ParseType(%q) error = %v, want containing %q", in, err, want)
+		}
+	}
+}
+
+func TestTypeHelpers(t *testing.T) {
+	defaults := map[string]string{
+		"String":                 "''",
+		"LowCardinality(String)": "''",
+		"FixedString(4)":         "''",
+		"Int32":                  "0",
+		"Nullable(Float64)":      "0",
+		"Decimal(18, 4)":         "0",
+		"Date32":                 "toDate(0)",
+		"DateTime64(3, 'UTC')":   "toDateTime(0)",
+		"UUID":                   "toUUID('00000000-0000-0000-0000-000000000000')",
+		"Array(String)":          "",
+		"Map(String, String)":    "",
+		"bad(":                   "",
+	}
+	for in, want := range defaults {
+		if got := defaultExprForType(in); got != want {
+			t.Errorf("defaultExprForType(%q) = %q, want %q", in, got, want)
+		}
+	}
+	normalized := map[string]string{
+		"LowCardinality( String )":       "lowcardinality(string)",
+		"DateTime64(3, 'Asia/Shanghai')": "datetime64(3,'Asia/Shanghai')",
+		"Decimal(18, *)":                 "decimal(18,*)",
+	}
+	for in, want := range normalized {
+		if got := normalizeCHType(in); got != want {
+			t.Errorf("normalizeCHType(%q) = %q, want %q", in, got, want)
+		}
+	}
+	wide := map[string]string{
+		"Int128":            "Int64",
+		"Nullable(UInt256)": "Nullable(UInt64)",
+		"Int32":             "Int32",
+		"Array(Int128)":     "Array(Int128)",
+		"Decimal(38, 2)":    "Decimal(38, 2)",
+	}
+	for in, want := range wide {
+		if got := mapTypeTo64(in); got != want {
+			t.Errorf("mapTypeTo64(%q) = %q, want %q", in, got, want)
+		}
+	}
+	if got := mapWideInt("Nullable(Int256)", func(string) string { return "String" }); got != "Nullable(String)" {
+		t.Errorf("mapWideInt = %q", got)
+	}
+}
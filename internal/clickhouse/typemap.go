@@ -0,0 +1,110 @@
+// clickhouse 包中的类型映射规则：按正则把源列类型改写为目标集群约定的类型，用于目标表 DDL、sink 结构与严格 CAST。
+package clickhouse
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// TypeMapping 是一条类型映射规则：Match 为整串匹配的正则，To 为替换结果，可用 $1 等引用分组。
+type TypeMapping struct {
+	Match string
+	To    string
+}
+
+type typeMappingRule struct {
+	re *regexp.Regexp
+	to string
+}
+
+// TypeMapper 按顺序应用类型映射规则，首条命中的规则生效；nil 表示不做映射。
+type TypeMapper struct {
+	rules []typeMappingRule
+}
+
+// NewTypeMapper 编译映射规则；没有规则时返回 nil。
+func NewTypeMapper(rules []TypeMapping) (*TypeMapper, error) {
+	var out []typeMappingRule
+	for _, r := range rules {
+		if strings.TrimSpace(r.Match) == "" || strings.TrimSpace(r.To) == "" {
+			return nil, fmt.Errorf("type_mappings 规则缺少 match 或 to: %+v", r)
+		}
+		re, err := regexp.Compile(`^(?:` + strings.TrimSpace(r.Match) + `)$`)
+		if err != nil {
+			return nil, fmt.Errorf("type_mappings 规则 %q 无效: %v", r.Match, err)
+		}
+		out = append(out, typeMappingRule{re: re, to: strings.TrimSpace(r.To)})
+	}
+	if len(out) == 0 {
+		return nil, nil
+	}
+	return &TypeMapper{rules: out}, nil
+}
+
+// compactType 去掉逗号后的空格，使 "Decimal(38, 10)" 与 "Decimal(38,10)" 都能被同一规则匹配。
+func compactType(t string) string {
+	return strings.ReplaceAll(strings.TrimSpace(t), ", ", ",")
+}
+
+func (m *TypeMapper) lookup(t string) (string, bool) {
+	if m == nil {
+		return t, false
+	}
+	t = strings.TrimSpace(t)
+	for _, r := range m.rules {
+		for _, cand := range []string{t, compactType(t)} {
+			if loc := r.re.FindStringSubmatchIndex(cand); loc != nil {
+				return string(r.re.ExpandString(nil, r.to, cand, loc)), true
+			}
+		}
+	}
+	// 整体未命中时逐个参数匹配，覆盖 Nullable/LowCardinality/Array、Map 的键值与命名 Tuple 元素等任意嵌套
+	n, err := ParseType(t)
+	if err != nil || len(n.Args) == 0 {
+		return t, false
+	}
+	parts := make([]string, len(n.Args))
+	hit := false
+	for i, a := range n.Args {
+		parts[i] = a.argText()
+		if a.Name == "" {
+			continue
+		}
+		if mapped, ok := m.lookup(a.Raw()); ok {
+			hit = true
+			parts[i] = mapped
+			if a.Field != "" {
+				parts[i] = a.fieldText() + " " + mapped
+			}
+		}
+	}
+	if !hit {
+		return t, false
+	}
+	return n.Name + "(" + strings.Join(parts, ", ") + ")", true
+}
+
+// Map 返回源类型在目标表中的类型；未命中规则时原样返回。
+func (m *TypeMapper) Map(t string) string {
+	out, _ := m.lookup(t)
+	return out
+}
+
+// SinkType 返回 Kafka sink 中的列类型：命中规则时使用映射结果，否则沿用默认的 Int128/Int256 -> String 映射。
+func (m *TypeMapper) SinkType(t string) string {
+	if out, ok := m.lookup(t); ok {
+		return out
+	}
+	return mapTypeToString(t)
+}
+
+// MapColumns 返回按规则改写类型后的列副本。
+func (m *TypeMapper) MapColumns(cols []Column) []Column {
+	out := make([]Column, len(cols))
+	for i, c := range cols {
+		c.Type = m.Map(c.Type)
+		out[i] = c
+	}
+	return out
+}
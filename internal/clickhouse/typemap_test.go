@@ -0,0 +1,35 @@
+package clickhouse
+
+import "testing"
+
+// TestTypeMapperNested 覆盖映射规则在包装类型、Map 键值与命名 Tuple 元素内部的应用。
+func TestTypeMapperNested(t *testing.T) {
+	m, err := NewTypeMapper([]TypeMapping{{Match: `Decimal\(38,10\)`, To: "Float64"}, {Match: `Int128`, To: "String"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		in   string
+		want string
+	}{
+		{"Decimal(38, 10)", "Float64"},
+		{"Nullable(Decimal(38,10))", "Nullable(Float64)"},
+		{"Array(LowCardinality(Nullable(Int128)))", "Array(LowCardinality(Nullable(String)))"},
+		{"Map(String, Decimal(38,10))", "Map(String, Float64)"},
+		{"Map(Int128, Array(Decimal(38, 10)))", "Map(String, Array(Float64))"},
+		{"Tuple(a Decimal(38,10))", "Tuple(a Float64)"},
+		{"Tuple(id UInt64, `amount x` Nullable(Decimal(38,10)), n Int128)", "Tuple(id UInt64, `amount x` Nullable(Float64), n String)"},
+		{"Map(String, Tuple(a Decimal(38,10), b String))", "Map(String, Tuple(a Float64, b String))"},
+		{"Map(String, Decimal(18,4))", "Map(String, Decimal(18,4))"},
+		{"UInt64", "UInt64"},
+	}
+	for _, c := range cases {
+		if got := m.Map(c.in); got != c.want {
+			t.Errorf("Map(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+	var none *TypeMapper
+	if got := none.Map("Map(String, Decimal(38,10))"); got != "Map(String, Decimal(38,10))" {
+		t.Errorf("nil mapper changed type: %q", got)
+	}
+}
@@ -17,11 +17,63 @@ type ClickHouse struct {
 	Password string `mapstructure:"password"`
 	Database string `mapstructure:"database"`
 	Secure   bool   `mapstructure:"secure"`
+	// 以下为高级连接选项：addrs 非空时替代 host:port
+	Addrs           []string       `mapstructure:"addrs"`
+	Protocol        string         `mapstructure:"protocol"`
+	TLS             ClickHouseTLS  `mapstructure:"tls"`
+	LoadBalancing   string         `mapstructure:"load_balancing"`
+	Compression     string         `mapstructure:"compression"`
+	DialTimeout     int            `mapstructure:"dial_timeout"`
+	ReadTimeout     int            `mapstructure:"read_timeout"`
+	MaxOpenConns    int            `mapstructure:"max_open_conns"`
+	MaxIdleConns    int            `mapstructure:"max_idle_conns"`
+	ConnMaxLifetime int            `mapstructure:"conn_max_lifetime"`
+	Settings        map[string]any `mapstructure:"settings"`
 }
 
-// Kafka 保存 Kafka broker 地址。
+// ClickHouseTLS 保存 ClickHouse TLS 证书与校验选项。
+type ClickHouseTLS struct {
+	CAFile             string `mapstructure:"ca_file" yaml:"ca_file,omitempty" json:"ca_file,omitempty"`
+	CertFile           string `mapstructure:"cert_file" yaml:"cert_file,omitempty" json:"cert_file,omitempty"`
+	KeyFile            string `mapstructure:"key_file" yaml:"key_file,omitempty" json:"key_file,omitempty"`
+	ServerName         string `mapstructure:"server_name" yaml:"server_name,omitempty" json:"server_name,omitempty"`
+	InsecureSkipVerify bool   `mapstructure:"insecure_skip_verify" yaml:"insecure_skip_verify,omitempty" json:"insecure_skip_verify,omitempty"`
+}
+
+// ClickHouseOverride 是 tables.yaml 中表级的 ClickHouse 连接覆盖项；未设置的字段沿用全局配置。
+type ClickHouseOverride struct {
+	Addrs           []string       `mapstructure:"addrs" yaml:"addrs,omitempty" json:"addrs,omitempty"`
+	Secure          *bool          `mapstructure:"secure" yaml:"secure,omitempty" json:"secure,omitempty"`
+	Protocol        string         `mapstructure:"protocol" yaml:"protocol,omitempty" json:"protocol,omitempty"`
+	TLS             *ClickHouseTLS `mapstructure:"tls" yaml:"tls,omitempty" json:"tls,omitempty"`
+	LoadBalancing   string         `mapstructure:"load_balancing" yaml:"load_balancing,omitempty" json:"load_balancing,omitempty"`
+	Compression     string         `mapstructure:"compression" yaml:"compression,omitempty" json:"compression,omitempty"`
+	DialTimeout     int            `mapstructure:"dial_timeout" yaml:"dial_timeout,omitempty" json:"dial_timeout,omitempty"`
+	ReadTimeout     int            `mapstructure:"read_timeout" yaml:"read_timeout,omitempty" json:"read_timeout,omitempty"`
+	MaxOpenConns    int            `mapstructure:"max_open_conns" yaml:"max_open_conns,omitempty" json:"max_open_conns,omitempty"`
+	MaxIdleConns    int            `mapstructure:"max_idle_conns" yaml:"max_idle_conns,omitempty" json:"max_idle_conns,omitempty"`
+	ConnMaxLifetime int            `mapstructure:"conn_max_lifetime" yaml:"conn_max_lifetime,omitempty" json:"conn_max_lifetime,omitempty"`
+	Settings        map[string]any `mapstructure:"settings" yaml:"settings,omitempty" json:"settings,omitempty"`
+}
+
+// Kafka 保存 Kafka broker 地址与认证配置。
 type Kafka struct {
-	Brokers []string `mapstructure:"brokers"`
+	Brokers          []string `mapstructure:"brokers"`
+	SecurityProtocol string   `mapstructure:"security_protocol"`
+	SASLMechanism    string   `mapstructure:"sasl_mechanism"`
+	SASLUsername     string   `mapstructure:"sasl_username"`
+	SASLPassword     string   `mapstructure:"sasl_password"`
+	TLS              KafkaTLS `mapstructure:"tls"`
+	// NamedCollection 为 ClickHouse 中预先定义的 Kafka named collection；设置后引擎表从中读取 broker 与认证信息
+	NamedCollection string `mapstructure:"named_collection"`
+}
+
+// KafkaTLS 保存 Kafka TLS 证书路径（工具侧连接使用）。
+type KafkaTLS struct {
+	CAFile             string `mapstructure:"ca_file"`
+	CertFile           string `mapstructure:"cert_file"`
+	KeyFile            string `mapstructure:"key_file"`
+	InsecureSkipVerify bool   `mapstructure:"insecure_skip_verify"`
 }
 
 // Sync 保存全局同步默认值与相关路径。
@@ -42,6 +94,29 @@ type Sync struct {
     MVTTLColumn      string `mapstructure:"mv_ttl_column"`
     VersionTimeColumn string `mapstructure:"version_time_column"`
     MaxPartitionsPerInsertBlock int `mapstructure:"max_partitions_per_insert_block"`
+	KafkaEngine      KafkaEngine `mapstructure:"kafka_engine"`
+	// CastMode 为 gen-ddl --with-sync-cast 的转换模式 strict|lenient|quarantine
+	CastMode string `mapstructure:"cast_mode"`
+	// TargetLayout 为目标表与查询 MV 的布局 mirror|custom
+	TargetLayout string `mapstructure:"target_layout"`
+}
+
+// TypeMapping 是按模式改写列类型的规则：match 为整串匹配的正则，to 为替换结果（可用 $1 引用分组）。
+// 全局规则位于配置文件顶层 type_mappings，表级规则位于 tables.yaml 的 type_mappings，表级优先匹配。
+type TypeMapping struct {
+	Match string `mapstructure:"match" yaml:"match" json:"match"`
+	To    string `mapstructure:"to" yaml:"to" json:"to"`
+}
+
+// KafkaEngine 保存 Kafka 引擎表的调优设置；全局位于 sync.kafka_engine，表级位于 tables.yaml 的 kafka_engine。
+type KafkaEngine struct {
+	NumConsumers       int    `mapstructure:"num_consumers" yaml:"num_consumers,omitempty" json:"num_consumers,omitempty"`
+	ThreadPerConsumer  *bool  `mapstructure:"thread_per_consumer" yaml:"thread_per_consumer,omitempty" json:"thread_per_consumer,omitempty"`
+	PollMaxBatchSize   int    `mapstructure:"poll_max_batch_size" yaml:"poll_max_batch_size,omitempty" json:"poll_max_batch_size,omitempty"`
+	FlushIntervalMs    int    `mapstructure:"flush_interval_ms" yaml:"flush_interval_ms,omitempty" json:"flush_interval_ms,omitempty"`
+	CommitEveryBatch   *bool  `mapstructure:"commit_every_batch" yaml:"commit_every_batch,omitempty" json:"commit_every_batch,omitempty"`
+	SkipBrokenMessages *int   `mapstructure:"skip_broken_messages" yaml:"skip_broken_messages,omitempty" json:"skip_broken_messages,omitempty"`
+	HandleErrorMode    string `mapstructure:"handle_error_mode" yaml:"handle_error_mode,omitempty" json:"handle_error_mode,omitempty"`
 }
 
 // Table 描述单表级的覆盖配置（brokers、批量、游标等）。
@@ -67,6 +142,41 @@ type Table struct {
 	MVTTLDays        int      `mapstructure:"mv_ttl_days" yaml:"mv_ttl_days" json:"mv_ttl_days"`
     MVTTLColumn      string   `mapstructure:"mv_ttl_column" yaml:"mv_ttl_column" json:"mv_ttl_column"`
     VersionTimeColumn string   `mapstructure:"version_time_column" yaml:"version_time_column" json:"version_time_column"`
+	KafkaEngine      *KafkaEngine `mapstructure:"kafka_engine" yaml:"kafka_engine,omitempty" json:"kafka_engine,omitempty"`
+	ClickHouse       *ClickHouseOverride `mapstructure:"clickhouse" yaml:"clickhouse,omitempty" json:"clickhouse,omitempty"`
+	SchemaDiff       *SchemaDiffRules `mapstructure:"schema_diff" yaml:"schema_diff,omitempty" json:"schema_diff,omitempty"`
+	TypeMappings     []TypeMapping    `mapstructure:"type_mappings" yaml:"type_mappings,omitempty" json:"type_mappings,omitempty"`
+	CastMode         string           `mapstructure:"cast_mode" yaml:"cast_mode,omitempty" json:"cast_mode,omitempty"`
+	TargetLayout     string           `mapstructure:"target_layout" yaml:"target_layout,omitempty" json:"target_layout,omitempty"`
+	// TopicConfig 为创建 Topic 时设置的主题级配置（如 retention.ms、cleanup.policy、min.insync.replicas），kafka topic-config 按此比对与调整已有 Topic
+	TopicConfig      map[string]string `mapstructure:"topic_config" yaml:"topic_config,omitempty" json:"topic_config,omitempty"`
+}
+
+// SchemaDiffRules 是 tables.yaml 中单表的 schema-diff 规则：忽略的列、忽略的类别/issue 与视为等价的类型对（支持 * 通配）。
+type SchemaDiffRules struct {
+	IgnoreColumns   []string          `mapstructure:"ignore_columns" yaml:"ignore_columns,omitempty" json:"ignore_columns,omitempty"`
+	Ignore          []string          `mapstructure:"ignore" yaml:"ignore,omitempty" json:"ignore,omitempty"`
+	EquivalentTypes []TypeEquivalence `mapstructure:"equivalent_types" yaml:"equivalent_types,omitempty" json:"equivalent_types,omitempty"`
+}
+
+// TypeEquivalence 声明一对视为等价的源/目标类型。
+type TypeEquivalence struct {
+	Source string `mapstructure:"source" yaml:"source" json:"source"`
+	Target string `mapstructure:"target" yaml:"target" json:"target"`
+}
+
+// Naming 保存链路对象的命名模板（占位符 {env}、{db}、{table}、{group}），未设置的模板沿用默认命名。
+type Naming struct {
+	Env             string `mapstructure:"env"`
+	Topic           string `mapstructure:"topic"`
+	Sink            string `mapstructure:"sink"`
+	MVFromKafka     string `mapstructure:"mv_from_kafka"`
+	MVToKafka       string `mapstructure:"mv_to_kafka"`
+	Group           string `mapstructure:"group"`
+	ErrorsTable     string `mapstructure:"errors_table"`
+	ErrorsView      string `mapstructure:"errors_view"`
+	QuarantineTable string `mapstructure:"quarantine_table"`
+	QuarantineView  string `mapstructure:"quarantine_view"`
 }
 
 // Logging 控制日志级别/格式以及可选的文件输出。
@@ -82,6 +192,8 @@ type Config struct {
 	Kafka      Kafka      `mapstructure:"kafka"`
 	Sync       Sync       `mapstructure:"sync"`
 	Logging    Logging    `mapstructure:"logging"`
+	Naming     Naming     `mapstructure:"naming"`
+	TypeMappings []TypeMapping `mapstructure:"type_mappings"`
 }
 
 // LoadTablesFile 加载 tables.yaml；既支持数组形式也支持 {tables: []} 包裹形式。
@@ -151,7 +263,7 @@ func UpdateTableCursorStart(path string, tableName string, newCursor string) err
 	return nil
 }
 
-// Load 读取配置文件并反序列化到 Config。
+// Load 读取配置文件并反序列化到 Config，随后解析凭据字段中的密钥引用。
 func Load(path string) (*Config, error) {
 	vp := viper.New()
 	vp.SetConfigFile(path)
@@ -162,6 +274,9 @@ func Load(path string) (*Config, error) {
 	if err := vp.Unmarshal(&c); err != nil {
 		return nil, err
 	}
+	if err := c.resolveSecrets(); err != nil {
+		return nil, err
+	}
 	return &c, nil
 }
 
@@ -0,0 +1,98 @@
+// config 包中的密钥引用解析：支持 ${env:NAME}、file:/path 与 exec:command。
+package config
+
+import (
+	"click-house-sync/internal/redact"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+var envRefPattern = regexp.MustCompile(`\$\{env:([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// IsSecretRef 判断取值是否为密钥引用。
+func IsSecretRef(v string) bool {
+	v = strings.TrimSpace(v)
+	return strings.HasPrefix(v, "file:") || strings.HasPrefix(v, "exec:") || envRefPattern.MatchString(v)
+}
+
+// ResolveSecret 解析密钥引用并把解析结果登记到 redact，日志与命令输出中随之屏蔽；普通字符串原样返回。
+//
+//	${env:CH_PASS}          读取环境变量（可嵌入在字符串中）
+//	file:/run/secrets/ch    读取文件内容并去除结尾换行
+//	exec:vault-read ch/pass 经 sh -c 执行命令并取标准输出（去除结尾换行），支持引号与管道
+func ResolveSecret(v string) (string, error) {
+	out, err := resolveSecret(v)
+	if err != nil {
+		return "", err
+	}
+	if IsSecretRef(v) {
+		redact.Register(out)
+	}
+	return out, nil
+}
+
+func resolveSecret(v string) (string, error) {
+	t := strings.TrimSpace(v)
+	switch {
+	case strings.HasPrefix(t, "file:"):
+		path := strings.TrimSpace(strings.TrimPrefix(t, "file:"))
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("读取密钥文件失败: %s: %w", path, err)
+		}
+		return strings.TrimRight(string(b), "\r\n"), nil
+	case strings.HasPrefix(t, "exec:"):
+		command := strings.TrimSpace(strings.TrimPrefix(t, "exec:"))
+		if command == "" {
+			return "", fmt.Errorf("exec 密钥引用缺少命令")
+		}
+		// 交给 shell 解析，带引号或空格的参数（如 exec:vault read -field="pass word" ch）按 shell 规则拆分
+		c := exec.Command("sh", "-c", command)
+		c.Stderr = os.Stderr
+		out, err := c.Output()
+		if err != nil {
+			return "", fmt.Errorf("执行密钥命令失败: %s: %w", strings.Fields(command)[0], err)
+		}
+		return strings.TrimRight(string(out), "\r\n"), nil
+	}
+	var missing []string
+	out := envRefPattern.ReplaceAllStringFunc(v, func(m string) string {
+		name := envRefPattern.FindStringSubmatch(m)[1]
+		val, ok := os.LookupEnv(name)
+		if !ok {
+			missing = append(missing, name)
+		}
+		return val
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("环境变量未设置: %s", strings.Join(missing, ", "))
+	}
+	return out, nil
+}
+
+// resolveSecrets 在加载配置后解析凭据字段中的密钥引用。
+func (c *Config) resolveSecrets() error {
+	fields := []struct {
+		name string
+		ptr  *string
+	}{
+		{"clickhouse.user", &c.ClickHouse.User},
+		{"clickhouse.password", &c.ClickHouse.Password},
+		{"kafka.sasl_username", &c.Kafka.SASLUsername},
+		{"kafka.sasl_password", &c.Kafka.SASLPassword},
+	}
+	for _, f := range fields {
+		if !IsSecretRef(*f.ptr) {
+			continue
+		}
+		v, err := ResolveSecret(*f.ptr)
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.name, err)
+		}
+		*f.ptr = v
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package config
+
+import (
+	"click-house-sync/internal/redact"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolveSecret(t *testing.T) {
+	t.Setenv("CHSYNC_TEST_PASS", "env-s3cret")
+	file := filepath.Join(t.TempDir(), "pass")
+	if err := os.WriteFile(file, []byte("file-s3cret\r\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain value", "plain-value", "plain-value"},
+		{"env reference", "${env:CHSYNC_TEST_PASS}", "env-s3cret"},
+		{"embedded env reference", "u:${env:CHSYNC_TEST_PASS}", "u:env-s3cret"},
+		{"file trims trailing newline", "file:" + file, "file-s3cret"},
+		{"exec trims trailing newline", `exec:printf '%s\n\n' 'exec s3cret'`, "exec s3cret"},
+		{"exec runs through the shell", "exec:echo exec-piped | tr a-z A-Z", "EXEC-PIPED"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := ResolveSecret(c.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != c.want {
+				t.Fatalf("ResolveSecret(%q) = %q, want %q", c.in, got, c.want)
+			}
+			registered := redact.String(got) == redact.Mask
+			if registered != IsSecretRef(c.in) {
+				t.Fatalf("registered = %v for %q", registered, c.in)
+			}
+		})
+	}
+}
+
+func TestResolveSecretErrors(t *testing.T) {
+	cases := []struct {
+		name   string
+		in     string
+		hidden string
+	}{
+		{"missing env", "${env:CHSYNC_TEST_UNSET}", ""},
+		{"missing file", "file:" + filepath.Join(t.TempDir(), "missing"), ""},
+		{"empty exec", "exec:", ""},
+		{"failing exec", "exec:printf fail-s3cret; exit 3", "fail-s3cret"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := ResolveSecret(c.in)
+			if err == nil {
+				t.Fatalf("ResolveSecret(%q) = %q, want error", c.in, got)
+			}
+			if c.hidden != "" && strings.Contains(err.Error(), c.hidden) {
+				t.Fatalf("error leaks command output: %v", err)
+			}
+		})
+	}
+}
@@ -13,13 +13,19 @@ import (
 
 // CreateTopic 按指定分区数与副本因子创建 Kafka 主题。
 func CreateTopic(brokers []string, topic string, partitions int, replication int) error {
+	return CreateTopicWithConfigs(brokers, topic, partitions, replication, nil)
+}
+
+// CreateTopicWithConfigs 创建 Kafka 主题并通过 CreateTopics 的 ConfigEntries 设置主题级配置（如 retention.ms、cleanup.policy）；
+// 主题已存在时不做任何修改，已有主题的配置由 AlterTopicConfigs 调整。
+func CreateTopicWithConfigs(brokers []string, topic string, partitions int, replication int, configs map[string]string) error {
 	if len(brokers) == 0 {
 		return fmt.Errorf("no brokers")
 	}
 	var conn *k.Conn
 	var firstErr error
 	for _, b := range brokers {
-		c, err := k.Dial("tcp", b)
+		c, err := dialer.Dial("tcp", b)
 		if err == nil {
 			conn = c
 			break
@@ -43,12 +49,16 @@ func CreateTopic(brokers []string, topic string, partitions int, replication int
 		return err
 	}
 	host := net.JoinHostPort(controller.Host, strconv.Itoa(controller.Port))
-	admin, err := k.Dial("tcp", host)
+	admin, err := dialer.Dial("tcp", host)
 	if err != nil {
 		return err
 	}
 	defer admin.Close()
-	if err := admin.CreateTopics(k.TopicConfig{Topic: topic, NumPartitions: partitions, ReplicationFactor: replication}); err != nil {
+	tc := k.TopicConfig{Topic: topic, NumPartitions: partitions, ReplicationFactor: replication}
+	for _, name := range sortedKeys(configs) {
+		tc.ConfigEntries = append(tc.ConfigEntries, k.ConfigEntry{ConfigName: name, ConfigValue: configs[name]})
+	}
+	if err := admin.CreateTopics(tc); err != nil {
 		return err
 	}
 	return WaitTopicReady(brokers, topic, 10*time.Second)
@@ -66,7 +76,7 @@ func WaitTopicReady(brokers []string, topic string, timeout time.Duration) error
 			if ready[b] {
 				continue
 			}
-			conn, err := k.Dial("tcp", b)
+			conn, err := dialer.Dial("tcp", b)
 			if err == nil {
 				parts, err := conn.ReadPartitions(topic)
 				conn.Close()
@@ -93,7 +103,7 @@ func DeleteTopic(brokers []string, topic string) error {
 	var conn *k.Conn
 	var firstErr error
 	for _, b := range brokers {
-		c, err := k.Dial("tcp", b)
+		c, err := dialer.Dial("tcp", b)
 		if err == nil {
 			conn = c
 			break
@@ -117,7 +127,7 @@ func DeleteTopic(brokers []string, topic string) error {
 		return err
 	}
 	host := net.JoinHostPort(controller.Host, strconv.Itoa(controller.Port))
-	admin, err := k.Dial("tcp", host)
+	admin, err := dialer.Dial("tcp", host)
 	if err != nil {
 		return err
 	}
@@ -149,7 +159,7 @@ func ListTopicNames(brokers []string) ([]string, error) {
 	}
 	var firstErr error
 	for _, b := range brokers {
-		conn, err := k.Dial("tcp", b)
+		conn, err := dialer.Dial("tcp", b)
 		if err != nil {
 			if firstErr == nil {
 				firstErr = err
@@ -184,7 +194,7 @@ func ReadTopicPartitions(brokers []string, topic string) ([]k.Partition, error)
 	}
 	var firstErr error
 	for _, b := range brokers {
-		conn, err := k.Dial("tcp", b)
+		conn, err := dialer.Dial("tcp", b)
 		if err != nil {
 			if firstErr == nil {
 				firstErr = err
@@ -213,7 +223,7 @@ func CountTopicMessages(brokers []string, topic string) (int64, error) {
 	var total int64
 	for _, p := range parts {
 		host := net.JoinHostPort(p.Leader.Host, strconv.Itoa(p.Leader.Port))
-		conn, err := k.DialLeader(context.Background(), "tcp", host, topic, p.ID)
+		conn, err := dialer.DialLeader(context.Background(), "tcp", host, topic, p.ID)
 		if err != nil {
 			return 0, err
 		}
@@ -0,0 +1,271 @@
+// kafka 包提供消费者组位点查询与重置辅助方法。
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	k "github.com/segmentio/kafka-go"
+)
+
+// PartitionLag 描述消费者组在单个分区上的位点与积压。
+type PartitionLag struct {
+	Partition       int     `json:"partition"`
+	CommittedOffset int64   `json:"committed_offset"`
+	FirstOffset     int64   `json:"first_offset"`
+	HighWatermark   int64   `json:"high_watermark"`
+	Lag             int64   `json:"lag"`
+	LagSeconds      float64 `json:"lag_seconds"`
+	OldestUnread    string  `json:"oldest_unread,omitempty"`
+	Error           string  `json:"error,omitempty"`
+}
+
+// PartitionOffset 描述重置后写入的分区位点。
+type PartitionOffset struct {
+	Partition int   `json:"partition"`
+	Previous  int64 `json:"previous"`
+	Offset    int64 `json:"offset"`
+}
+
+// newClient 返回指向 brokers 的 kafka-go Client，组相关请求由 transport 自动路由到协调者。
+func newClient(brokers []string) *k.Client {
+	return &k.Client{Addr: k.TCP(brokers...), Timeout: 10 * time.Second, Transport: transport}
+}
+
+// partitionBounds 读取分区的 first-offset 与 high watermark（last-offset）。
+func partitionBounds(p k.Partition, topic string) (int64, int64, error) {
+	host := net.JoinHostPort(p.Leader.Host, strconv.Itoa(p.Leader.Port))
+	conn, err := dialer.DialLeader(context.Background(), "tcp", host, topic, p.ID)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer conn.Close()
+	first, err := conn.ReadFirstOffset()
+	if err != nil {
+		return 0, 0, err
+	}
+	last, err := conn.ReadLastOffset()
+	if err != nil {
+		return 0, 0, err
+	}
+	return first, last, nil
+}
+
+// messageTimeAt 读取分区内指定位点消息的时间戳。
+func messageTimeAt(p k.Partition, topic string, offset int64) (time.Time, error) {
+	host := net.JoinHostPort(p.Leader.Host, strconv.Itoa(p.Leader.Port))
+	conn, err := dialer.DialLeader(context.Background(), "tcp", host, topic, p.ID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer conn.Close()
+	if _, err := conn.Seek(offset, k.SeekAbsolute); err != nil {
+		return time.Time{}, err
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	msg, err := conn.ReadMessage(10 << 20)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return msg.Time, nil
+}
+
+// FetchGroupOffsets 返回消费者组在主题各分区上已提交的位点；未提交的分区为 -1。
+func FetchGroupOffsets(brokers []string, group string, topic string) (map[int]int64, error) {
+	parts, err := ReadTopicPartitions(brokers, topic)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int, 0, len(parts))
+	for _, p := range parts {
+		ids = append(ids, p.ID)
+	}
+	resp, err := newClient(brokers).OffsetFetch(context.Background(), &k.OffsetFetchRequest{
+		GroupID: group,
+		Topics:  map[string][]int{topic: ids},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+	out := map[int]int64{}
+	for _, id := range ids {
+		out[id] = -1
+	}
+	for _, p := range resp.Topics[topic] {
+		if p.Error != nil {
+			return nil, p.Error
+		}
+		out[p.Partition] = p.CommittedOffset
+	}
+	return out, nil
+}
+
+// GroupLag 计算消费者组在主题各分区上的积压条数，以及最早未消费消息距今的秒数。
+func GroupLag(brokers []string, group string, topic string) ([]PartitionLag, error) {
+	parts, err := ReadTopicPartitions(brokers, topic)
+	if err != nil {
+		return nil, err
+	}
+	committed, err := FetchGroupOffsets(brokers, group, topic)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].ID < parts[j].ID })
+	now := time.Now()
+	var out []PartitionLag
+	for _, p := range parts {
+		item := PartitionLag{Partition: p.ID, CommittedOffset: committed[p.ID]}
+		first, last, err := partitionBounds(p, topic)
+		if err != nil {
+			item.Error = err.Error()
+			out = append(out, item)
+			continue
+		}
+		item.FirstOffset = first
+		item.HighWatermark = last
+		// 未提交或位点已被 retention 清理时，按 first-offset 计算积压
+		next := item.CommittedOffset
+		if next < first {
+			next = first
+		}
+		if last > next {
+			item.Lag = last - next
+		}
+		if item.Lag > 0 {
+			if ts, err := messageTimeAt(p, topic, next); err == nil {
+				item.OldestUnread = ts.Format("2006-01-02 15:04:05")
+				if d := now.Sub(ts); d > 0 {
+					item.LagSeconds = d.Seconds()
+				}
+			} else {
+				item.Error = err.Error()
+			}
+		}
+		out = append(out, item)
+	}
+	return out, nil
+}
+
+// GroupState 返回消费者组状态（Empty/Stable/PreparingRebalance 等）与成员数。
+func GroupState(brokers []string, group string) (string, int, error) {
+	resp, err := newClient(brokers).DescribeGroups(context.Background(), &k.DescribeGroupsRequest{GroupIDs: []string{group}})
+	if err != nil {
+		return "", 0, err
+	}
+	for _, g := range resp.Groups {
+		if g.GroupID != group {
+			continue
+		}
+		if g.Error != nil {
+			return "", 0, g.Error
+		}
+		return g.GroupState, len(g.Members), nil
+	}
+	return "", 0, fmt.Errorf("group not found: %s", group)
+}
+
+// WaitGroupEmpty 等待消费者组不再有活跃成员（Empty/Dead），用于安全重置位点。
+func WaitGroupEmpty(brokers []string, group string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		state, members, err := GroupState(brokers, group)
+		if err == nil && (members == 0 || strings.EqualFold(state, "Empty") || strings.EqualFold(state, "Dead")) {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			if err != nil {
+				return err
+			}
+			return fmt.Errorf("group still active: %s (state=%s, members=%d)", group, state, members)
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// ResolveResetOffsets 按 mode（earliest|latest|timestamp|offset）计算各分区的目标位点。
+func ResolveResetOffsets(brokers []string, topic string, mode string, at time.Time, offset int64) (map[int]int64, error) {
+	parts, err := ReadTopicPartitions(brokers, topic)
+	if err != nil {
+		return nil, err
+	}
+	out := map[int]int64{}
+	for _, p := range parts {
+		first, last, err := partitionBounds(p, topic)
+		if err != nil {
+			return nil, err
+		}
+		switch strings.ToLower(strings.TrimSpace(mode)) {
+		case "earliest":
+			out[p.ID] = first
+		case "latest":
+			out[p.ID] = last
+		case "offset":
+			v := offset
+			if v < first {
+				v = first
+			}
+			if v > last {
+				v = last
+			}
+			out[p.ID] = v
+		case "timestamp":
+			host := net.JoinHostPort(p.Leader.Host, strconv.Itoa(p.Leader.Port))
+			conn, err := dialer.DialLeader(context.Background(), "tcp", host, topic, p.ID)
+			if err != nil {
+				return nil, err
+			}
+			v, err := conn.ReadOffset(at)
+			conn.Close()
+			if err != nil {
+				return nil, err
+			}
+			// 时间点之后没有消息时 broker 返回 -1，此时定位到末尾
+			if v < 0 {
+				v = last
+			}
+			out[p.ID] = v
+		default:
+			return nil, fmt.Errorf("unknown reset mode: %s", mode)
+		}
+	}
+	return out, nil
+}
+
+// ResetGroupOffsets 为（已无活跃成员的）消费者组提交新的分区位点，返回重置前后的位点。
+func ResetGroupOffsets(brokers []string, group string, topic string, offsets map[int]int64) ([]PartitionOffset, error) {
+	prev, err := FetchGroupOffsets(brokers, group, topic)
+	if err != nil {
+		return nil, err
+	}
+	var commits []k.OffsetCommit
+	for id, v := range offsets {
+		commits = append(commits, k.OffsetCommit{Partition: id, Offset: v})
+	}
+	sort.Slice(commits, func(i, j int) bool { return commits[i].Partition < commits[j].Partition })
+	resp, err := newClient(brokers).OffsetCommit(context.Background(), &k.OffsetCommitRequest{
+		GroupID:      group,
+		GenerationID: -1,
+		Topics:       map[string][]k.OffsetCommit{topic: commits},
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range resp.Topics[topic] {
+		if p.Error != nil {
+			return nil, fmt.Errorf("commit partition %d: %w", p.Partition, p.Error)
+		}
+	}
+	var out []PartitionOffset
+	for _, c := range commits {
+		out = append(out, PartitionOffset{Partition: c.Partition, Previous: prev[c.Partition], Offset: c.Offset})
+	}
+	return out, nil
+}
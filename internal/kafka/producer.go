@@ -23,6 +23,7 @@ func NewWriter(brokers []string, topic string, batchSize int) *k.Writer {
 		BatchTimeout: time.Second,
 		RequiredAcks: k.RequireAll,
 		Balancer:     &k.Hash{},
+		Transport:    transport,
 	}
 }
 
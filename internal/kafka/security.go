@@ -0,0 +1,145 @@
+// kafka 包中的 SASL/TLS 认证配置，供管理辅助方法、消费者组操作与 Writer 共用。
+package kafka
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	k "github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+)
+
+// Security 描述连接 Kafka 的认证方式；Protocol 取值 PLAINTEXT|SSL|SASL_PLAINTEXT|SASL_SSL。
+type Security struct {
+	Protocol           string
+	Mechanism          string
+	Username           string
+	Password           string
+	CAFile             string
+	CertFile           string
+	KeyFile            string
+	InsecureSkipVerify bool
+}
+
+var (
+	dialer    = &k.Dialer{Timeout: 10 * time.Second, DualStack: true}
+	transport = &k.Transport{}
+)
+
+// NormalizedProtocol 返回大写的安全协议；未显式设置时按是否配置了用户名/证书推导。
+func (s Security) NormalizedProtocol() string {
+	p := strings.ToUpper(strings.TrimSpace(s.Protocol))
+	if p != "" {
+		return p
+	}
+	hasSASL := strings.TrimSpace(s.Username) != ""
+	hasTLS := strings.TrimSpace(s.CAFile) != "" || strings.TrimSpace(s.CertFile) != ""
+	switch {
+	case hasSASL && hasTLS:
+		return "SASL_SSL"
+	case hasSASL:
+		return "SASL_PLAINTEXT"
+	case hasTLS:
+		return "SSL"
+	}
+	return "PLAINTEXT"
+}
+
+// NormalizedMechanism 返回大写的 SASL 机制，默认 PLAIN。
+func (s Security) NormalizedMechanism() string {
+	m := strings.ToUpper(strings.TrimSpace(s.Mechanism))
+	if m == "" {
+		return "PLAIN"
+	}
+	return m
+}
+
+// UsesSASL 判断是否启用 SASL 认证。
+func (s Security) UsesSASL() bool {
+	p := s.NormalizedProtocol()
+	return p == "SASL_PLAINTEXT" || p == "SASL_SSL"
+}
+
+// UsesTLS 判断是否启用 TLS。
+func (s Security) UsesTLS() bool {
+	p := s.NormalizedProtocol()
+	return p == "SSL" || p == "SASL_SSL"
+}
+
+func (s Security) mechanism() (sasl.Mechanism, error) {
+	if strings.TrimSpace(s.Username) == "" {
+		return nil, fmt.Errorf("kafka sasl 需要用户名")
+	}
+	switch s.NormalizedMechanism() {
+	case "PLAIN":
+		return plain.Mechanism{Username: s.Username, Password: s.Password}, nil
+	case "SCRAM-SHA-256":
+		return scram.Mechanism(scram.SHA256, s.Username, s.Password)
+	case "SCRAM-SHA-512":
+		return scram.Mechanism(scram.SHA512, s.Username, s.Password)
+	}
+	return nil, fmt.Errorf("不支持的 kafka sasl 机制: %s（仅支持 PLAIN|SCRAM-SHA-256|SCRAM-SHA-512）", s.Mechanism)
+}
+
+func (s Security) tlsConfig() (*tls.Config, error) {
+	cfg := &tls.Config{InsecureSkipVerify: s.InsecureSkipVerify}
+	if ca := strings.TrimSpace(s.CAFile); ca != "" {
+		pem, err := os.ReadFile(ca)
+		if err != nil {
+			return nil, fmt.Errorf("读取 kafka CA 证书失败: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("kafka CA 证书无有效 PEM: %s", ca)
+		}
+		cfg.RootCAs = pool
+	}
+	cert, key := strings.TrimSpace(s.CertFile), strings.TrimSpace(s.KeyFile)
+	if cert != "" || key != "" {
+		if cert == "" || key == "" {
+			return nil, fmt.Errorf("kafka 客户端证书需同时提供 cert 与 key")
+		}
+		pair, err := tls.LoadX509KeyPair(cert, key)
+		if err != nil {
+			return nil, fmt.Errorf("加载 kafka 客户端证书失败: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{pair}
+	}
+	return cfg, nil
+}
+
+// Configure 按认证配置重建包内共享的 Dialer 与 Transport；应在任何 Kafka 调用之前执行一次。
+func Configure(s Security) error {
+	switch s.NormalizedProtocol() {
+	case "PLAINTEXT", "SSL", "SASL_PLAINTEXT", "SASL_SSL":
+	default:
+		return fmt.Errorf("不支持的 kafka 安全协议: %s（仅支持 PLAINTEXT|SSL|SASL_PLAINTEXT|SASL_SSL）", s.Protocol)
+	}
+	d := &k.Dialer{Timeout: 10 * time.Second, DualStack: true}
+	t := &k.Transport{}
+	if s.UsesTLS() {
+		cfg, err := s.tlsConfig()
+		if err != nil {
+			return err
+		}
+		d.TLS = cfg
+		t.TLS = cfg
+	}
+	if s.UsesSASL() {
+		m, err := s.mechanism()
+		if err != nil {
+			return err
+		}
+		d.SASLMechanism = m
+		t.SASL = m
+	}
+	dialer = d
+	transport = t
+	return nil
+}
@@ -2,11 +2,16 @@
 package logging
 
 import (
+	"click-house-sync/internal/redact"
+	"fmt"
+	"time"
+
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
 // New 创建一个 zap SugaredLogger，可配置级别、格式以及可选的文件输出。
+// 消息与字段在写出前经 redact.String 屏蔽密码等敏感信息，与命令的 JSON 输出一致。
 func New(level string, format string, file string) (*zap.SugaredLogger, func(), error) {
 	var cfg zap.Config
 	if format == "json" {
@@ -31,7 +36,16 @@ func New(level string, format string, file string) (*zap.SugaredLogger, func(),
 		cfg.OutputPaths = []string{file}
 		cfg.ErrorOutputPaths = []string{file}
 	}
-	lg, err := cfg.Build()
+	// 采样需包在屏蔽之外，否则屏蔽层会绕过采样器的 Check
+	sampling := cfg.Sampling
+	cfg.Sampling = nil
+	opts := []zap.Option{zap.WrapCore(func(c zapcore.Core) zapcore.Core { return redactCore{c} })}
+	if sampling != nil {
+		opts = append(opts, zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+			return zapcore.NewSamplerWithOptions(c, time.Second, sampling.Initial, sampling.Thereafter)
+		}))
+	}
+	lg, err := cfg.Build(opts...)
 	if err != nil {
 		return nil, func() {}, err
 	}
@@ -39,3 +53,50 @@ func New(level string, format string, file string) (*zap.SugaredLogger, func(),
 	cleanup := func() { _ = lg.Sync() }
 	return sugar, cleanup, nil
 }
+
+// redactCore 在写出前屏蔽日志消息与字段中的敏感信息。
+type redactCore struct {
+	zapcore.Core
+}
+
+func (c redactCore) With(fields []zapcore.Field) zapcore.Core {
+	return redactCore{c.Core.With(redactFields(fields))}
+}
+
+func (c redactCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c redactCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	ent.Message = redact.String(ent.Message)
+	return c.Core.Write(ent, redactFields(fields))
+}
+
+// redactFields 屏蔽字符串字段；错误、Stringer 与任意对象字段仅在文本含敏感信息时改写为屏蔽后的字符串，其余保持原结构。
+func redactFields(fields []zapcore.Field) []zapcore.Field {
+	out := make([]zapcore.Field, len(fields))
+	for i, f := range fields {
+		switch f.Type {
+		case zapcore.StringType:
+			f.String = redact.String(f.String)
+		case zapcore.ErrorType, zapcore.StringerType, zapcore.ReflectType, zapcore.ByteStringType:
+			var s string
+			switch v := f.Interface.(type) {
+			case error:
+				s = v.Error()
+			case []byte:
+				s = string(v)
+			default:
+				s = fmt.Sprint(v)
+			}
+			if r := redact.String(s); r != s {
+				f = zap.String(f.Key, r)
+			}
+		}
+		out[i] = f
+	}
+	return out
+}
@@ -0,0 +1,48 @@
+package logging
+
+import (
+	"click-house-sync/internal/redact"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestNewRedactsSecrets(t *testing.T) {
+	const secret = "log-s3cret"
+	redact.Register(secret)
+	for _, format := range []string{"json", "console"} {
+		t.Run(format, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "app.log")
+			lg, cleanup, err := New("debug", format, file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lg.With("dsn", "tcp://u:"+secret+"@h").Infow("connecting with "+secret,
+				"pw", secret,
+				"err", errors.New("bad "+secret),
+				"obj", map[string]string{"k": secret},
+			)
+			lg.Desugar().Warn("plain", zap.Stringer("s", stringer(secret)), zap.ByteString("b", []byte(secret)))
+			cleanup()
+			b, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out := string(b)
+			if strings.Contains(out, secret) {
+				t.Fatalf("secret leaked into log output:\n%s", out)
+			}
+			if n := strings.Count(out, redact.Mask); n < 7 {
+				t.Fatalf("want at least 7 masked values, got %d:\n%s", n, out)
+			}
+		})
+	}
+}
+
+type stringer string
+
+func (s stringer) String() string { return string(s) }
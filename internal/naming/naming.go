@@ -0,0 +1,187 @@
+// naming 包按模板生成链路对象名称：Topic、Kafka 引擎表（sink）、两侧物化视图、消费组，以及错误流与隔离对象。
+// 模板由配置文件 naming 段设置，命令启动时通过 Configure 生效；未设置的模板沿用历史命名。
+package naming
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// 默认模板，与引入模板前的硬编码命名一致。
+const (
+	DefaultTopic       = "{db}_{table}"
+	DefaultSink        = "kafka_{table}_sink"
+	DefaultMVFromKafka = "mv_from_kafka_{table}"
+	DefaultMVToKafka   = "mv_to_kafka_{table}"
+	DefaultGroup       = "{group}-{table}"
+
+	DefaultErrorsTable     = "{table}_kafka_errors"
+	DefaultErrorsView      = "mv_kafka_errors_{table}"
+	DefaultQuarantineTable = "{table}_quarantine"
+	DefaultQuarantineView  = "mv_quarantine_{table}"
+)
+
+// Templates 是各类对象的命名模板，可用占位符 {env}、{db}（源库）、{table}（源表）与 {group}（全局 group_name）。
+type Templates struct {
+	Env             string
+	Topic           string
+	Sink            string
+	MVFromKafka     string
+	MVToKafka       string
+	Group           string
+	ErrorsTable     string
+	ErrorsView      string
+	QuarantineTable string
+	QuarantineView  string
+}
+
+var (
+	current = Templates{
+		Topic:           DefaultTopic,
+		Sink:            DefaultSink,
+		MVFromKafka:     DefaultMVFromKafka,
+		MVToKafka:       DefaultMVToKafka,
+		Group:           DefaultGroup,
+		ErrorsTable:     DefaultErrorsTable,
+		ErrorsView:      DefaultErrorsView,
+		QuarantineTable: DefaultQuarantineTable,
+		QuarantineView:  DefaultQuarantineView,
+	}
+	placeholderRe = regexp.MustCompile(`\{[^{}]*\}`)
+	topicRe       = regexp.MustCompile(`^[a-zA-Z0-9._-]+$`)
+)
+
+// Configure 校验并启用命名模板；空模板使用默认值。模板含未知占位符、括号不成对，或用到 {env} 而 Env 为空时返回错误。
+func Configure(t Templates) error {
+	t.Env = strings.TrimSpace(t.Env)
+	fields := []struct {
+		key string
+		val *string
+		def string
+	}{
+		{"topic", &t.Topic, DefaultTopic},
+		{"sink", &t.Sink, DefaultSink},
+		{"mv_from_kafka", &t.MVFromKafka, DefaultMVFromKafka},
+		{"mv_to_kafka", &t.MVToKafka, DefaultMVToKafka},
+		{"group", &t.Group, DefaultGroup},
+		{"errors_table", &t.ErrorsTable, DefaultErrorsTable},
+		{"errors_view", &t.ErrorsView, DefaultErrorsView},
+		{"quarantine_table", &t.QuarantineTable, DefaultQuarantineTable},
+		{"quarantine_view", &t.QuarantineView, DefaultQuarantineView},
+	}
+	for _, f := range fields {
+		*f.val = strings.TrimSpace(*f.val)
+		if *f.val == "" {
+			*f.val = f.def
+		}
+		if err := validate(*f.val, t.Env); err != nil {
+			return fmt.Errorf("naming.%s 模板 %q 无效: %v", f.key, *f.val, err)
+		}
+	}
+	current = t
+	return nil
+}
+
+func validate(tmpl string, env string) error {
+	for _, p := range placeholderRe.FindAllString(tmpl, -1) {
+		switch p {
+		case "{db}", "{table}", "{group}":
+		case "{env}":
+			if env == "" {
+				return fmt.Errorf("使用了 {env} 但未设置 naming.env")
+			}
+		default:
+			return fmt.Errorf("未知占位符 %s（可用 {env}、{db}、{table}、{group}）", p)
+		}
+	}
+	if strings.ContainsAny(placeholderRe.ReplaceAllString(tmpl, ""), "{}") {
+		return fmt.Errorf("花括号不成对")
+	}
+	return nil
+}
+
+// Current 返回当前生效的模板。
+func Current() Templates {
+	return current
+}
+
+func render(tmpl string, db string, table string, group string) string {
+	return strings.NewReplacer("{env}", current.Env, "{db}", db, "{table}", table, "{group}", group).Replace(tmpl)
+}
+
+// Topic 返回源表 db.table 的 Topic 名。
+func Topic(db string, table string) string {
+	return render(current.Topic, db, table, "")
+}
+
+// Sink 返回源表 db.table 的 Kafka 引擎表名。
+func Sink(db string, table string) string {
+	return render(current.Sink, db, table, "")
+}
+
+// MVFromKafka 返回把 sink 写入目标表的物化视图名。
+func MVFromKafka(db string, table string) string {
+	return render(current.MVFromKafka, db, table, "")
+}
+
+// MVToKafka 返回源侧把源表写入 sink 的物化视图名。
+func MVToKafka(db string, table string) string {
+	return render(current.MVToKafka, db, table, "")
+}
+
+// Group 返回源表 db.table 的消费组名；group 为全局 group_name。
+func Group(db string, table string, group string) string {
+	return render(current.Group, db, table, group)
+}
+
+// ErrorsTable 返回错误流落库表名（kafka_handle_error_mode = 'stream' 时的坏消息表）。
+func ErrorsTable(db string, table string) string {
+	return render(current.ErrorsTable, db, table, "")
+}
+
+// ErrorsView 返回把坏消息写入错误流落库表的物化视图名。
+func ErrorsView(db string, table string) string {
+	return render(current.ErrorsView, db, table, "")
+}
+
+// QuarantineTable 返回 quarantine 转换模式的隔离表名。
+func QuarantineTable(db string, table string) string {
+	return render(current.QuarantineTable, db, table, "")
+}
+
+// QuarantineView 返回把转换失败的行写入隔离表的物化视图名。
+func QuarantineView(db string, table string) string {
+	return render(current.QuarantineView, db, table, "")
+}
+
+// ValidateTopic 校验 Topic 名是否满足 Kafka 的限制：仅含字母、数字、'.'、'_'、'-'，长度不超过 249，且不为 . 或 ..。
+func ValidateTopic(topic string) error {
+	if topic == "" || topic == "." || topic == ".." || len(topic) > 249 || !topicRe.MatchString(topic) {
+		return fmt.Errorf("Topic 名 %q 不合法（仅允许字母、数字、.、_、-，长度 1~249）", topic)
+	}
+	return nil
+}
+
+// IsPipelineObject 判断表名是否符合 sink、两侧物化视图、错误流或隔离对象的命名模板（各占位符匹配任意非空文本）。
+func IsPipelineObject(name string) bool {
+	for _, tmpl := range []string{current.Sink, current.MVFromKafka, current.MVToKafka, current.ErrorsTable, current.ErrorsView, current.QuarantineTable, current.QuarantineView} {
+		var b strings.Builder
+		b.WriteString("^")
+		last := 0
+		for _, loc := range placeholderRe.FindAllStringIndex(tmpl, -1) {
+			b.WriteString(regexp.QuoteMeta(tmpl[last:loc[0]]))
+			if tmpl[loc[0]:loc[1]] == "{env}" {
+				b.WriteString(regexp.QuoteMeta(current.Env))
+			} else {
+				b.WriteString(".+")
+			}
+			last = loc[1]
+		}
+		b.WriteString(regexp.QuoteMeta(tmpl[last:]) + "$")
+		if regexp.MustCompile(b.String()).MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,55 @@
+package naming
+
+import "testing"
+
+func TestConfigure(t *testing.T) {
+	defer Configure(Templates{})
+	if err := Configure(Templates{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := Topic("demo", "orders"); got != "demo_orders" {
+		t.Errorf("默认 Topic = %s", got)
+	}
+	if got := Group("demo", "orders", "ch-sync"); got != "ch-sync-orders" {
+		t.Errorf("默认 Group = %s", got)
+	}
+	if err := Configure(Templates{Env: "prod", Topic: "{env}.{db}.{table}.v1", Sink: "kafka_{db}_{table}_sink"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := Topic("demo", "orders"); got != "prod.demo.orders.v1" {
+		t.Errorf("Topic = %s", got)
+	}
+	if got := Sink("demo", "orders"); got != "kafka_demo_orders_sink" {
+		t.Errorf("Sink = %s", got)
+	}
+	if got := MVFromKafka("demo", "orders"); got != "mv_from_kafka_orders" {
+		t.Errorf("未设置的模板应沿用默认值，MVFromKafka = %s", got)
+	}
+	if got := ErrorsTable("demo", "orders"); got != "orders_kafka_errors" {
+		t.Errorf("默认 ErrorsTable = %s", got)
+	}
+	if err := Configure(Templates{Env: "prod", Topic: "{env}.{db}.{table}.v1", Sink: "kafka_{db}_{table}_sink", QuarantineTable: "{db}_{table}_quarantine"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := QuarantineTable("demo", "orders"); got != "demo_orders_quarantine" {
+		t.Errorf("QuarantineTable = %s", got)
+	}
+	if !IsPipelineObject("demo_orders_quarantine") || !IsPipelineObject("mv_kafka_errors_orders") {
+		t.Error("IsPipelineObject 应匹配错误流与隔离对象")
+	}
+	if !IsPipelineObject("kafka_demo_orders_sink") || IsPipelineObject("orders") {
+		t.Error("IsPipelineObject 未按模板匹配")
+	}
+	for _, bad := range []Templates{
+		{Topic: "{env}.{table}"},
+		{Topic: "{schema}_{table}"},
+		{Sink: "kafka_{table"},
+	} {
+		if err := Configure(bad); err == nil {
+			t.Errorf("模板 %+v 应报错", bad)
+		}
+	}
+	if err := ValidateTopic("prod.demo/orders"); err == nil {
+		t.Error("含 / 的 Topic 名应报错")
+	}
+}
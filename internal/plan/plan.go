@@ -0,0 +1,111 @@
+// plan 包定义 plan/apply 使用的计划文件：按表列出链路所需的 Topic 与 ClickHouse 对象、与现状的差异以及将要执行的精确操作。
+package plan
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Version 是计划文件格式版本。
+const Version = 1
+
+// 步骤动作：create 创建缺失对象，alter 对已有目标表执行列变更或调整已有 Topic 的配置，noop 已与期望一致，drift 存在差异但需人工处理（apply 不执行）。
+const (
+	ActionCreate = "create"
+	ActionAlter  = "alter"
+	ActionNoop   = "noop"
+	ActionDrift  = "drift"
+)
+
+// 步骤对象类型。
+const (
+	KindDatabase    = "database"
+	KindTopic       = "topic"
+	KindSink        = "sink"
+	KindErrorStream = "error_stream"
+	KindTarget      = "target"
+	KindMV          = "mv"
+	KindMVToKafka   = "mv_to_kafka"
+)
+
+// Plan 是一次 plan 的完整结果。
+type Plan struct {
+	Version   int         `json:"version"`
+	CreatedAt string      `json:"created_at"`
+	Tables    []TablePlan `json:"tables"`
+}
+
+// TablePlan 是单表链路的步骤，按依赖顺序排列（库、Topic、sink、错误流、目标表、MV）。
+type TablePlan struct {
+	Table  string `json:"table"`
+	Source string `json:"source"`
+	Steps  []Step `json:"steps"`
+}
+
+// Step 是单个对象的计划动作；SQL 为 ClickHouse 对象按顺序执行的语句，Topic 为 Kafka 主题操作。
+// FallbackSQL 是 SQL 首条语句去掉可选设置（如 Kafka 引擎的可选 SETTINGS、allow_nullable_key）后的降级版本，
+// apply 仅在首条语句因 ClickHouse 不识别设置而失败时依次尝试，与 sync 建表时的回退一致。
+type Step struct {
+	Kind        string   `json:"kind"`
+	Object      string   `json:"object"`
+	Action      string   `json:"action"`
+	Reason      string   `json:"reason,omitempty"`
+	SQL         []string `json:"sql,omitempty"`
+	FallbackSQL []string `json:"fallback_sql,omitempty"`
+	Topic       *Topic   `json:"topic,omitempty"`
+}
+
+// Topic 描述 Kafka 主题；Configs 为主题级配置，创建时一并设置，已有主题配置不一致时由 alter 调整。
+type Topic struct {
+	Name              string            `json:"name"`
+	Brokers           []string          `json:"brokers"`
+	Partitions        int               `json:"partitions"`
+	ReplicationFactor int               `json:"replication_factor"`
+	Configs           map[string]string `json:"configs,omitempty"`
+}
+
+// Executable 判断步骤是否由 apply 执行。
+func (s Step) Executable() bool {
+	return s.Action == ActionCreate || s.Action == ActionAlter
+}
+
+// Counts 按动作统计步骤数。
+func (p *Plan) Counts() map[string]int {
+	out := map[string]int{ActionCreate: 0, ActionAlter: 0, ActionNoop: 0, ActionDrift: 0}
+	for _, t := range p.Tables {
+		for _, s := range t.Steps {
+			out[s.Action]++
+		}
+	}
+	return out
+}
+
+// Save 把计划写入文件（先写临时文件再改名）。计划中的 Kafka 引擎表 DDL 可能包含 SASL 凭据，文件权限为 0600。
+func Save(path string, p *Plan) error {
+	b, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(b, '\n'), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Load 读取并校验计划文件。
+func Load(path string) (*Plan, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var p Plan
+	if err := json.Unmarshal(b, &p); err != nil {
+		return nil, fmt.Errorf("解析计划文件 %s 失败: %v", path, err)
+	}
+	if p.Version != Version {
+		return nil, fmt.Errorf("计划文件 %s 的版本 %d 不受支持（当前为 %d）", path, p.Version, Version)
+	}
+	return &p, nil
+}
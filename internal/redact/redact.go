@@ -0,0 +1,70 @@
+// redact 包在输出前屏蔽密码、SASL 凭据等敏感信息。
+package redact
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Mask 是替换敏感值的占位符。
+const Mask = "******"
+
+var (
+	mu      sync.RWMutex
+	secrets []string
+)
+
+// patterns 屏蔽无需事先登记即可识别的凭据形态：DDL 中的 SASL 密码、DSN/URL 中的密码；每个模式含 前缀/值/后缀 三个分组。
+var patterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)(kafka_sasl_password\s*=\s*')((?:[^'\\]|\\.)*)(')`),
+	regexp.MustCompile(`(?i)(\bpassword\s*=\s*)([^\s&;,'"]+)()`),
+	regexp.MustCompile(`(://[^:/@\s]+:)([^@/\s]+)(@)`),
+}
+
+// Register 登记需要屏蔽的敏感值（空串与过短的值忽略，避免误伤普通文本）。
+func Register(values ...string) {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, v := range values {
+		if len(v) < 3 {
+			continue
+		}
+		dup := false
+		for _, s := range secrets {
+			if s == v {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			secrets = append(secrets, v)
+		}
+	}
+	// 长值优先替换，避免短值是长值子串时残留片段
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+}
+
+// String 返回屏蔽敏感信息后的文本。
+func String(s string) string {
+	mu.RLock()
+	list := secrets
+	mu.RUnlock()
+	for _, v := range list {
+		s = strings.ReplaceAll(s, v, Mask)
+		// JSON 序列化后的文本中引号与反斜杠会被转义
+		if esc := jsonEscape(v); esc != v {
+			s = strings.ReplaceAll(s, esc, Mask)
+		}
+	}
+	for _, re := range patterns {
+		s = re.ReplaceAllString(s, "${1}"+Mask+"${3}")
+	}
+	return s
+}
+
+func jsonEscape(v string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "<", `\u003c`, ">", `\u003e`, "&", `\u0026`)
+	return r.Replace(v)
+}